@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+)
+
+const batchSize = 5000 // rows read per query
+
+// row is a raw report, one per line in the archive files, in the cmd/export JSONL format read by cmd/import
+type row struct {
+	ID      string          `json:"id"`
+	Time    time.Time       `json:"time"`
+	Country string          `json:"country,omitempty"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// FileName returns the archive file name of the reports of a month, like "insights-2025-01.jsonl.gz".
+// Names sort chronologically
+func FileName(t time.Time) string {
+	return "insights-" + t.UTC().Format("2006-01") + ".jsonl.gz"
+}
+
+// Write appends the reports older than cutoff with a rowid up to maxRowID to the archive file of their
+// month in dir, returning the number of reports archived. Each run appends a new gzip member to the files,
+// which gzip readers (and cmd/import) read as a single stream. Files are synced before returning, so the
+// reports can then be purged. If it fails, the reports already appended are archived again by the next
+// run: cmd/import skips the duplicates
+func Write(ctx context.Context, dbConn *sql.DB, dir string, cutoff time.Time, maxRowID int64) (int64, error) {
+	if err := os.MkdirAll(dir, consts.DirPermissions); err != nil {
+		return 0, fmt.Errorf("creating archive folder: %w", err)
+	}
+	files := map[string]*file{}
+	var total, lastRowID int64
+	err := func() error {
+		for {
+			reports, last, err := db.SelectOldReports(ctx, dbConn, cutoff, lastRowID, maxRowID, batchSize)
+			if err != nil {
+				return err
+			}
+			for _, r := range reports {
+				name := FileName(r.Time)
+				f, ok := files[name]
+				if !ok {
+					if f, err = openFile(filepath.Join(dir, name)); err != nil {
+						return err
+					}
+					files[name] = f
+				}
+				if err := f.enc.Encode(row{ID: r.ID, Time: r.Time.UTC(), Country: r.Country, Data: json.RawMessage(r.Data)}); err != nil {
+					return fmt.Errorf("writing %s: %w", name, err)
+				}
+				total++
+			}
+			if last == lastRowID {
+				return nil
+			}
+			lastRowID = last
+		}
+	}()
+	var errs []error
+	for name, f := range files {
+		if err := f.close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing %s: %w", name, err))
+		}
+	}
+	return total, errors.Join(append([]error{err}, errs...)...)
+}
+
+// file is an archive file opened for appending a gzip member
+type file struct {
+	f   *os.File
+	bw  *bufio.Writer
+	zw  *gzip.Writer
+	enc *json.Encoder
+}
+
+func openFile(path string) (*file, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, consts.FilePermissions) //#nosec G304 -- path is controlled
+	if err != nil {
+		return nil, fmt.Errorf("opening archive file: %w", err)
+	}
+	bw := bufio.NewWriter(f)
+	zw := gzip.NewWriter(bw)
+	return &file{f: f, bw: bw, zw: zw, enc: json.NewEncoder(zw)}, nil
+}
+
+func (a *file) close() error {
+	err := errors.Join(a.zw.Close(), a.bw.Flush())
+	if err == nil {
+		err = a.f.Sync()
+	}
+	return errors.Join(err, a.f.Close())
+}
@@ -0,0 +1,112 @@
+package backup
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// backupFileRegex matches files like "insights-2025-11-29.zip"
+var backupFileRegex = regexp.MustCompile(`^insights-\d{4}-\d{2}-\d{2}\.zip$`)
+
+// FileName returns the backup file name for the given date. Names sort chronologically,
+// which is the order cmd/consolidate processes them.
+func FileName(t time.Time) string {
+	return "insights-" + t.Format(consts.DateFormat) + ".zip"
+}
+
+// Create writes a zip containing a consistent snapshot of the database as insights.db, the
+// layout expected by cmd/consolidate. The snapshot is taken with VACUUM INTO, so it is
+// self-contained and no WAL/SHM files are needed.
+func Create(ctx context.Context, dbConn *sql.DB, dir string, t time.Time) (string, error) {
+	if err := os.MkdirAll(dir, consts.DirPermissions); err != nil {
+		return "", fmt.Errorf("creating backup folder: %w", err)
+	}
+	tempDir, err := os.MkdirTemp(dir, ".backup-*")
+	if err != nil {
+		return "", fmt.Errorf("creating temp folder: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	snapshotPath := filepath.Join(tempDir, "insights.db")
+	if _, err := dbConn.ExecContext(ctx, "VACUUM INTO ?", snapshotPath); err != nil {
+		return "", fmt.Errorf("creating snapshot: %w", err)
+	}
+
+	tempZip := filepath.Join(tempDir, "backup.zip")
+	if err := zipFile(snapshotPath, tempZip); err != nil {
+		return "", fmt.Errorf("creating zip: %w", err)
+	}
+
+	backupPath := filepath.Join(dir, FileName(t))
+	if err := os.Rename(tempZip, backupPath); err != nil {
+		return "", fmt.Errorf("moving backup: %w", err)
+	}
+	return backupPath, nil
+}
+
+func zipFile(srcPath, zipPath string) error {
+	out, err := os.OpenFile(zipPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, consts.FilePermissions) //#nosec G304 -- path is controlled
+	if err != nil {
+		return err
+	}
+	defer func() { _ = out.Close() }()
+
+	src, err := os.Open(srcPath) //#nosec G304 -- path is controlled
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	zw := zip.NewWriter(out)
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     filepath.Base(srcPath),
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// Prune removes the oldest backups in dir, keeping the most recent `keep` files
+func Prune(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, entry := range entries {
+		if !entry.IsDir() && backupFileRegex.MatchString(entry.Name()) {
+			backups = append(backups, entry.Name())
+		}
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+	slices.Sort(backups)
+	for _, name := range backups[:len(backups)-keep] {
+		log.Printf("Removing old backup %s", name) //#nosec G706 -- name is from controlled directory listing
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package backup
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/dedup"
+)
+
+// mergeBatchSize is the number of reports stored per transaction by Merge
+const mergeBatchSize = 1000
+
+// Open extracts the database from a backup zip (see Create) into a temp directory and opens it.
+// The returned cleanup function closes the database and removes the temp directory
+func Open(zipPath string) (*sql.DB, func(), error) {
+	// Create temp directory for extraction
+	tempDir, err := os.MkdirTemp("", "insights-backup-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating temp directory: %w", err)
+	}
+	log.Printf("Extracting backup to temp dir: %s", tempDir)
+	removeTemp := func() { _ = os.RemoveAll(tempDir) }
+
+	// Extract insights.db from zip
+	dbPath, err := extractDB(zipPath, tempDir)
+	if err != nil {
+		removeTemp()
+		return nil, nil, fmt.Errorf("extracting database: %w", err)
+	}
+
+	// Open source database
+	srcDB, err := db.OpenDB(dbPath)
+	if err != nil {
+		removeTemp()
+		return nil, nil, fmt.Errorf("opening source database: %w", err)
+	}
+	return srcDB, func() {
+		_ = srcDB.Close()
+		removeTemp()
+	}, nil
+}
+
+func extractDB(zipPath, destDir string) (string, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = r.Close() }()
+
+	var dbFile *zip.File
+	for _, f := range r.File {
+		// Skip macOS metadata files and look for insights.db
+		if strings.HasPrefix(f.Name, "__MACOSX") {
+			continue
+		}
+		if filepath.Base(f.Name) == "insights.db" {
+			dbFile = f
+			break
+		}
+	}
+
+	if dbFile == nil {
+		return "", fmt.Errorf("insights.db not found in zip")
+	}
+
+	// Extract the database file
+	destPath := filepath.Join(destDir, "insights.db")
+	if err := extractFile(dbFile, destPath); err != nil {
+		return "", err
+	}
+
+	// Also extract WAL and SHM files if present (for consistency)
+	for _, f := range r.File {
+		if strings.HasPrefix(f.Name, "__MACOSX") {
+			continue
+		}
+		base := filepath.Base(f.Name)
+		if base == "insights.db-wal" || base == "insights.db-shm" {
+			_ = extractFile(f, filepath.Join(destDir, base))
+		}
+	}
+
+	return destPath, nil
+}
+
+// extractFile extracts f to destPath, refusing files larger than consts.MaxBackupExtractSize: backup zips
+// can be uploaded to /admin/restore, so both the declared and the actual sizes are checked
+func extractFile(f *zip.File, destPath string) error {
+	if f.UncompressedSize64 > consts.MaxBackupExtractSize {
+		return fmt.Errorf("%s is too large (%d bytes, max %d)", f.Name, f.UncompressedSize64, int64(consts.MaxBackupExtractSize))
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rc.Close() }()
+
+	outFile, err := os.Create(destPath) //#nosec G304 -- destPath is controlled
+	if err != nil {
+		return err
+	}
+	defer func() { _ = outFile.Close() }()
+
+	n, err := io.Copy(outFile, io.LimitReader(rc, consts.MaxBackupExtractSize+1))
+	if err != nil {
+		return err
+	}
+	if n > consts.MaxBackupExtractSize {
+		return fmt.Errorf("%s is larger than %d bytes", f.Name, int64(consts.MaxBackupExtractSize))
+	}
+	return nil
+}
+
+// Merge stores the reports of src (a backup opened with Open) missing from dest, skipping the ones whose
+// (id, time) pair is already in keys, like cmd/consolidate. Reports of instances blocked in dest are skipped.
+// The stored reports are marked as late for their dates (see db.ImportReports). Returns the number of stored
+// reports (without the ones db.ImportReports rejects or skips) and the dates that received some, in
+// ascending order
+func Merge(ctx context.Context, src, dest *sql.DB, keys dedup.KeySet) (int64, []time.Time, error) {
+	blockedList, err := db.ListBlockedInstances(dest)
+	if err != nil {
+		return 0, nil, err
+	}
+	blocked := make(map[string]struct{}, len(blockedList))
+	for _, b := range blockedList {
+		blocked[b.ID] = struct{}{}
+	}
+	dates, err := db.SelectDates(src)
+	if err != nil {
+		return 0, nil, err
+	}
+	var merged int64
+	var mergedDates []time.Time
+	for _, date := range dates {
+		reports, err := db.SelectRawReports(src, date)
+		if err != nil {
+			return merged, mergedDates, fmt.Errorf("merging %s: %w", date.Format(consts.DateFormat), err)
+		}
+		var batch []db.RawReport
+		var n int64
+		for r := range reports {
+			if _, ok := blocked[r.ID]; ok {
+				continue
+			}
+			// Keys are built from the time as read back from the database (see dedup.LoadKeys)
+			r.Time = r.Time.UTC()
+			isNew, err := keys.Add(dedup.NewKey(r.ID, r.Time.Format(time.RFC3339Nano)))
+			if err != nil {
+				return merged, mergedDates, fmt.Errorf("deduplicating rows: %w", err)
+			}
+			if isNew {
+				batch = append(batch, r)
+			}
+			if len(batch) == mergeBatchSize {
+				stored, err := db.ImportReports(ctx, dest, batch)
+				if err != nil {
+					return merged, mergedDates, fmt.Errorf("merging %s: %w", date.Format(consts.DateFormat), err)
+				}
+				n += stored
+				batch = batch[:0]
+			}
+		}
+		if len(batch) > 0 {
+			stored, err := db.ImportReports(ctx, dest, batch)
+			if err != nil {
+				return merged, mergedDates, fmt.Errorf("merging %s: %w", date.Format(consts.DateFormat), err)
+			}
+			n += stored
+		}
+		if n > 0 {
+			log.Printf("Merged %d reports for %s", n, date.Format(consts.DateFormat))
+			merged += n
+			mergedDates = append(mergedDates, date)
+		}
+	}
+	return merged, mergedDates, nil
+}
@@ -0,0 +1,94 @@
+package charts
+
+import (
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/summary"
+)
+
+// buildActiveInstancesChart plots the daily, weekly and monthly active instances. The daily count misses
+// the instances that don't report every day, which the wider windows include
+func buildActiveInstancesChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Line {
+	ts := buildTimeSeriesData(summaries)
+	start := summaries[0].Time
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Active Installations",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show:      opts.Bool(true),
+			Right:     "10",
+			Orient:    "vertical",
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Date",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Installations",
+			NameLocation: "center",
+			NameGap:      50,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "80",
+			Right:  "280",
+			Bottom: "60",
+		}),
+	)
+
+	line.SetXAxis(ts.Dates)
+
+	// Summaries computed before the windowed counts were introduced have none, left as gaps
+	series := []struct {
+		name  string
+		value func(summary.Summary) int64
+	}{
+		{"Daily", func(s summary.Summary) int64 { return s.NumInstances }},
+		{"Weekly", func(s summary.Summary) int64 { return s.WeeklyInstances }},
+		{"Monthly", func(s summary.Summary) int64 { return s.MonthlyInstances }},
+	}
+	provisional := ts.provisionalIndex(summaries)
+	markAreas := append(buildMarkAreaData(ts.findGaps(), theme), buildProvisionalMarkArea(ts, provisional, theme)...)
+	for i, ser := range series {
+		data := make([]opts.LineData, len(ts.Dates))
+		for d := range ts.Dates {
+			s := ts.Lookup[start.AddDate(0, 0, d)]
+			if s == nil || ser.value(s.Data) == 0 {
+				data[d] = opts.LineData{Value: nil}
+				continue
+			}
+			data[d] = opts.LineData{Value: ser.value(s.Data)}
+		}
+		// First series gets the mark areas
+		if i == 0 {
+			line.AddSeries(ser.name, data, charts.WithMarkAreaData(markAreas...))
+			continue
+		}
+		line.AddSeries(ser.name, data)
+	}
+
+	return line
+}
@@ -0,0 +1,120 @@
+package charts
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/summary"
+)
+
+// Anomaly is a day whose instance count deviates suspiciously from the previous days, usually caused by
+// collection problems (drops) or misbehaving clients (spikes)
+type Anomaly struct {
+	Date      time.Time
+	Instances int64
+	Mean      float64 // Rolling mean of the previous consts.AnomalyWindowDays
+	Deviation float64 // Percentage from the mean, negative for drops
+}
+
+// Kind returns "drop" or "spike"
+func (a Anomaly) Kind() string {
+	if a.Deviation < 0 {
+		return "drop"
+	}
+	return "spike"
+}
+
+// anomalyJSON is the format of the anomalies exported in charts.json
+type anomalyJSON struct {
+	Date      string  `json:"date"`
+	Kind      string  `json:"kind"`
+	Instances int64   `json:"instances"`
+	Mean      float64 `json:"mean"`
+	Deviation float64 `json:"deviation"` // Percentage
+}
+
+// DetectAnomalies flags the days whose instance count deviates from the mean of the previous
+// consts.AnomalyWindowDays days by more than consts.AnomalyStdDevs standard deviations (and more than
+// consts.AnomalyMinDeviationPct). Days already flagged are left out of the following days' windows, so
+// a single bad day doesn't hide the next ones. Days with fewer than consts.AnomalyMinDays previous days
+// of data are not evaluated, nor are provisional days, as their counts are still growing. Sampled days
+// (see summary.Summary.SamplingRate) are skipped, and left out of the baseline: their counts are estimates
+func DetectAnomalies(summaries []summary.SummaryRecord) []Anomaly {
+	summaries = summaries[:provisionalFrom(summaries)]
+	var anomalies []Anomaly
+	var normal []summary.SummaryRecord // Days not flagged, used as the baseline
+	for _, s := range summaries {
+		if s.Data.SamplingRate > 0 {
+			continue
+		}
+		windowStart := s.Time.AddDate(0, 0, -consts.AnomalyWindowDays)
+		var values []float64
+		for _, prev := range normal {
+			if !prev.Time.Before(windowStart) {
+				values = append(values, float64(prev.Data.NumInstances))
+			}
+		}
+		if len(values) < consts.AnomalyMinDays {
+			normal = append(normal, s)
+			continue
+		}
+
+		mean, stdDev := meanStdDev(values)
+		diff := float64(s.Data.NumInstances) - mean
+		deviation := diff / mean * 100
+		if math.Abs(diff) > consts.AnomalyStdDevs*stdDev && math.Abs(deviation) > consts.AnomalyMinDeviationPct {
+			anomalies = append(anomalies, Anomaly{
+				Date:      s.Time,
+				Instances: s.Data.NumInstances,
+				Mean:      math.Round(mean*100) / 100,
+				Deviation: math.Round(deviation*100) / 100,
+			})
+			continue
+		}
+		normal = append(normal, s)
+	}
+	return anomalies
+}
+
+func meanStdDev(values []float64) (mean, stdDev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		stdDev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stdDev / float64(len(values)))
+}
+
+func anomaliesToJSON(anomalies []Anomaly) []anomalyJSON {
+	result := make([]anomalyJSON, 0, len(anomalies))
+	for _, a := range anomalies {
+		result = append(result, anomalyJSON{
+			Date:      a.Date.Format(consts.DateFormat),
+			Kind:      a.Kind(),
+			Instances: a.Instances,
+			Mean:      a.Mean,
+			Deviation: a.Deviation,
+		})
+	}
+	return result
+}
+
+// buildAnomalyMarkPoints creates MarkPoints pinning each anomaly on a series of instance counts
+func buildAnomalyMarkPoints(anomalies []Anomaly, theme consts.ChartTheme) []opts.MarkPointNameCoordItem {
+	items := make([]opts.MarkPointNameCoordItem, 0, len(anomalies))
+	for _, a := range anomalies {
+		items = append(items, opts.MarkPointNameCoordItem{
+			Name:       a.Kind(),
+			Coordinate: []interface{}{a.Date.Format(consts.ChartDateFormat), a.Instances},
+			Value:      fmt.Sprintf("%+.0f%%", a.Deviation),
+			Symbol:     "pin",
+			ItemStyle:  &opts.ItemStyle{Color: theme.AnomalyColor},
+		})
+	}
+	return items
+}
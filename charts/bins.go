@@ -0,0 +1,60 @@
+package charts
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var numberPrinter = message.NewPrinter(language.English)
+
+// binLabels returns the labels of the ranges of the bins (see summary.Bins), in order: "0", "1-99",
+// "100-499"... up to the last, open range, like "1,000,000+"
+func binLabels(bins []int64) []string {
+	labels := make([]string, len(bins))
+	for i, bin := range bins {
+		switch {
+		case i == len(bins)-1:
+			labels[i] = numberPrinter.Sprintf("%d+", bin)
+		case bins[i+1]-1 == bin:
+			labels[i] = numberPrinter.Sprintf("%d", bin)
+		default:
+			labels[i] = numberPrinter.Sprintf("%d-%d", bin, bins[i+1]-1)
+		}
+	}
+	return labels
+}
+
+// binnedData adds up the counts of a summary map keyed by number, either a bin lower bound (see
+// summary.mapToBins) or an exact value (e.g. Summary.Players), in the ranges of the bins, in order. Keys
+// of summaries computed with other bins are counted in the range containing them, so they are not lost.
+// Keys below the first bin are ignored
+func binnedData(bins []int64, counts map[string]uint64) []opts.BarData {
+	values := make([]uint64, len(bins))
+	for key, n := range counts {
+		v, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+		if i := binIndex(bins, v); i >= 0 {
+			values[i] += n
+		}
+	}
+	data := make([]opts.BarData, len(bins))
+	for i, v := range values {
+		data[i] = opts.BarData{Value: v}
+	}
+	return data
+}
+
+// binIndex returns the index of the range of the bins containing v, -1 if it's below the first bin
+func binIndex(bins []int64, v int64) int {
+	i, found := slices.BinarySearch(bins, v)
+	if found {
+		return i
+	}
+	return i - 1
+}
@@ -0,0 +1,58 @@
+package charts
+
+import (
+	"sync"
+	"time"
+
+	appconfig "github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/summary"
+)
+
+// summariesCache keeps the parsed summaries in memory, so chart rendering and exports don't re-read
+// all summary files on every request. Entries expire after consts.SummariesCacheTTL, and are
+// invalidated whenever a summary is saved in this process
+type summariesCache struct {
+	mu         sync.Mutex
+	records    []summary.SummaryRecord
+	dataFolder string
+	loadedAt   time.Time
+	valid      bool
+}
+
+var cache summariesCache
+
+func init() {
+	summary.OnSave(InvalidateSummariesCache)
+}
+
+// CachedSummaries returns all summaries (see summary.GetSummaries), from the cache when fresh, with the
+// counts of sampled days scaled to estimates of all the instances (see summary.Summary.Scaled), so all the
+// charts and metrics use the estimates. The returned slice is shared and must not be modified
+func CachedSummaries() ([]summary.SummaryRecord, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	dataFolder := appconfig.DataFolder()
+	if cache.valid && cache.dataFolder == dataFolder && time.Since(cache.loadedAt) < consts.SummariesCacheTTL {
+		return cache.records, nil
+	}
+
+	records, err := summary.GetSummaries()
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		records[i].Data = records[i].Data.Scaled()
+	}
+	cache.records, cache.dataFolder, cache.loadedAt, cache.valid = records, dataFolder, time.Now(), true
+	return records, nil
+}
+
+// InvalidateSummariesCache forces the next CachedSummaries call to reload the summaries
+func InvalidateSummariesCache() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.valid = false
+	cache.records = nil
+}
@@ -3,13 +3,19 @@ package charts
 import (
 	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
@@ -17,22 +23,29 @@ import (
 	"github.com/go-echarts/go-echarts/v2/opts"
 	"github.com/navidrome/insights/consts"
 	"github.com/navidrome/insights/summary"
+	"golang.org/x/text/language"
 )
 
-// ExcludeIncompleteDays removes any trailing days when the instance count drops significantly
-// (more than 20% drop) compared to the previous day, as this indicates incomplete data.
+// ExcludeIncompleteDays removes any trailing days when the instance count drops significantly (below
+// Config.IncompleteThreshold, 20% by default) compared to the previous day, as this indicates incomplete data.
+// This only applies to summaries written before days were finalized (see summary.Summary.Finalized): once
+// any day is finalized, the provisional ones are kept and rendered as such (see provisionalFrom).
 func ExcludeIncompleteDays(summaries []summary.SummaryRecord) []summary.SummaryRecord {
 	if len(summaries) == 0 {
 		return nil
 	}
+	if slices.ContainsFunc(summaries, func(s summary.SummaryRecord) bool { return s.Data.Finalized }) {
+		return summaries
+	}
 
 	// Remove trailing incomplete data (significant drops from previous day)
+	threshold := CurrentConfig().IncompleteThreshold
 	for len(summaries) > 1 {
 		last := summaries[len(summaries)-1]
 		prev := summaries[len(summaries)-2]
 		if prev.Data.NumInstances > 0 {
 			dropRatio := float64(last.Data.NumInstances) / float64(prev.Data.NumInstances)
-			if dropRatio < consts.IncompleteThreshold { // Detect significant drop
+			if dropRatio < threshold { // Detect significant drop
 				summaries = summaries[:len(summaries)-1]
 				continue
 			}
@@ -42,6 +55,20 @@ func ExcludeIncompleteDays(summaries []summary.SummaryRecord) []summary.SummaryR
 	return summaries
 }
 
+// provisionalFrom returns the index of the first of the trailing provisional (not finalized) days, which
+// can still change as reports arrive. Returns len(summaries) if there are none, or if no day is finalized
+// (summaries written before days were finalized)
+func provisionalFrom(summaries []summary.SummaryRecord) int {
+	i := len(summaries)
+	for i > 0 && !summaries[i-1].Data.Finalized {
+		i--
+	}
+	if i == 0 {
+		return len(summaries)
+	}
+	return i
+}
+
 // timeSeriesData holds a continuous date range with data for each date.
 // Dates without data will have nil in the lookup map.
 type timeSeriesData struct {
@@ -123,7 +150,7 @@ func (ts timeSeriesData) findGaps() []gapRange {
 }
 
 // buildMarkAreaData creates MarkArea data pairs for highlighting gaps
-func buildMarkAreaData(gaps []gapRange) [][]opts.MarkAreaData {
+func buildMarkAreaData(gaps []gapRange, theme consts.ChartTheme) [][]opts.MarkAreaData {
 	if len(gaps) == 0 {
 		return nil
 	}
@@ -136,12 +163,12 @@ func buildMarkAreaData(gaps []gapRange) [][]opts.MarkAreaData {
 				XAxis: gap.StartDate,
 				MarkAreaStyle: opts.MarkAreaStyle{
 					ItemStyle: &opts.ItemStyle{
-						Color: consts.GapHighlightColor,
+						Color: theme.GapHighlightColor,
 					},
 					Label: &opts.Label{
 						Show:     opts.Bool(true),
 						Position: "inside",
-						Color:    consts.GapLabelColor,
+						Color:    theme.GapLabelColor,
 					},
 				},
 			},
@@ -153,9 +180,70 @@ func buildMarkAreaData(gaps []gapRange) [][]opts.MarkAreaData {
 	return areas
 }
 
+// provisionalIndex returns the index in ts.Dates of the first provisional day of the summaries (see
+// provisionalFrom), or len(ts.Dates) if there are none
+func (ts timeSeriesData) provisionalIndex(summaries []summary.SummaryRecord) int {
+	from := provisionalFrom(summaries)
+	if from == len(summaries) {
+		return len(ts.Dates)
+	}
+	return int(summaries[from].Time.Sub(ts.Start).Hours() / 24)
+}
+
+// buildProvisionalMarkArea highlights the provisional days, from the index in ts.Dates to the end.
+// Returns nil if there are none
+func buildProvisionalMarkArea(ts timeSeriesData, from int, theme consts.ChartTheme) [][]opts.MarkAreaData {
+	if from >= len(ts.Dates) {
+		return nil
+	}
+	return [][]opts.MarkAreaData{{
+		{
+			Name:  "Provisional",
+			XAxis: ts.Dates[from],
+			MarkAreaStyle: opts.MarkAreaStyle{
+				ItemStyle: &opts.ItemStyle{
+					Color: theme.ProvisionalColor,
+				},
+				Label: &opts.Label{
+					Show:     opts.Bool(true),
+					Position: "insideTop",
+					Color:    theme.GapLabelColor,
+				},
+			},
+		},
+		{
+			XAxis: ts.Dates[len(ts.Dates)-1],
+		},
+	}}
+}
+
+// splitProvisional splits a series at the index of the first provisional day: final keeps the values of
+// the final days, and provisional the values from the last final day on, so both lines connect. The other
+// values are nil. provisional is nil if there are no provisional days
+func splitProvisional(data []opts.LineData, from int) (final, provisional []opts.LineData) {
+	if from >= len(data) {
+		return data, nil
+	}
+	final = make([]opts.LineData, len(data))
+	provisional = make([]opts.LineData, len(data))
+	copy(final[:from], data[:from])
+	start := max(0, from-1)
+	copy(provisional[start:], data[start:])
+	return final, provisional
+}
+
+func provisionalName(series string) string {
+	return series + " (provisional)"
+}
+
+// provisionalOpts styles provisional series as dotted lines, as their values can still change
+func provisionalOpts() charts.SeriesOpts {
+	return charts.WithLineStyleOpts(opts.LineStyle{Type: "dotted"})
+}
+
 func ChartsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		summaries, err := summary.GetSummaries()
+		summaries, err := CachedSummaries()
 		if err != nil {
 			log.Printf("Error loading summaries: %v", err)
 			http.Error(w, "Failed to load data", http.StatusInternalServerError)
@@ -168,52 +256,90 @@ func ChartsHandler() http.HandlerFunc {
 			return
 		}
 
+		theme := ThemeByName(r.URL.Query().Get("theme"))
+
 		page := components.NewPage()
 		page.PageTitle = "Navidrome Insights"
 		page.AddCharts(
-			buildVersionsChart(summaries),
-			buildOSChart(summaries),
-			buildPlayerTypesChart(summaries),
-			buildPlayersChart(summaries),
-			buildPlayersPerInstallationChart(summaries),
-			buildTracksChart(summaries),
-			buildAlbumsArtistsChart(summaries),
+			buildVersionsChart(summaries, theme),
+			buildVersionAdoptionChart(summaries, theme),
+		)
+		if len(stableReleases(summaries)) > 0 {
+			page.AddCharts(buildLatestReleaseChart(summaries, theme))
+		}
+		page.AddCharts(
+			buildOSChart(summaries, theme),
+			buildArchitecturesChart(summaries, theme),
+			buildPlayerTypesChart(summaries, theme),
+			buildPlayersChart(summaries, theme),
+			buildGrowthChart(summaries, theme),
+			buildNewReturningChart(summaries, theme),
+			buildPlayersPerInstallationChart(summaries, theme),
+			buildUsersPerInstallationChart(summaries, theme),
+			buildTracksChart(summaries, theme),
+			buildAlbumsArtistsChart(summaries, theme),
+			buildFilesystemsChart(summaries, theme),
 		)
+		if summaries[len(summaries)-1].Data.MonthlyInstances > 0 {
+			page.AddCharts(buildActiveInstancesChart(summaries, theme))
+		}
+		if len(summaries[len(summaries)-1].Data.OSVersions) > 0 {
+			page.AddCharts(buildOSVersionsChart(summaries, theme))
+		}
+		if len(summaries[len(summaries)-1].Data.CPUs) > 0 {
+			page.AddCharts(buildHostSizesChart(summaries, theme))
+		}
+		if len(summaries[len(summaries)-1].Data.Features) > 0 {
+			page.AddCharts(buildFeaturesChart(summaries, theme))
+		}
+		if summaries[len(summaries)-1].Data.TotalTracks > 0 {
+			page.AddCharts(buildLibraryTotalsChart(summaries, theme))
+		}
+		// The countries map is left out: its world map is registered by the dashboard (web/index.html), and
+		// echarts can't render a chart of this page before it is
 
 		w.Header().Set("Content-Type", "text/html")
 		_ = page.Render(w)
 	}
 }
 
-func buildVersionsChart(summaries []summary.SummaryRecord) *charts.Line {
+func buildVersionsChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Line {
 	// Build continuous date range with gaps
 	ts := buildTimeSeriesData(summaries)
 	start := summaries[0].Time
 
 	// Calculate the cutoff date for rolling window (last N calendar days)
 	lastDate := summaries[len(summaries)-1].Time
-	cutoffDate := lastDate.AddDate(0, 0, -consts.VersionSelectionDays)
+	cfg := CurrentConfig()
+	cutoffDate := lastDate.AddDate(0, 0, -cfg.VersionSelectionDays)
+
+	// Versions of each day, optionally with the patch releases grouped
+	versionsOf := func(s *summary.SummaryRecord) map[string]uint64 {
+		return s.Data.Versions
+	}
+	if cfg.GroupPatchVersions {
+		grouped := make(map[time.Time]map[string]uint64, len(summaries))
+		for _, s := range summaries {
+			grouped[s.Time] = groupPatchVersions(s.Data.Versions)
+		}
+		versionsOf = func(s *summary.SummaryRecord) map[string]uint64 {
+			return grouped[s.Time]
+		}
+	}
 
 	// Collect version totals only from the rolling window for top-N selection
 	versionTotals := make(map[string]uint64)
-	for _, s := range summaries {
-		if !s.Time.Before(cutoffDate) {
-			for version, count := range s.Data.Versions {
+	for i := range summaries {
+		if !summaries[i].Time.Before(cutoffDate) {
+			for version, count := range versionsOf(&summaries[i]) {
 				versionTotals[version] += count
 			}
 		}
 	}
 
-	// Get top N versions by total count in the rolling window
-	topVersionsList := getTopKeys(versionTotals, consts.TopVersionsCount)
-
-	// Sort versions by last day's count (highest to lowest)
-	lastSummary := summaries[len(summaries)-1]
-	slices.SortFunc(topVersionsList, func(a, b string) int {
-		countA := lastSummary.Data.Versions[a]
-		countB := lastSummary.Data.Versions[b]
-		return cmp.Compare(countB, countA)
-	})
+	// Get top N versions by total count in the rolling window, newest first
+	topVersionsList := getTopKeys(versionTotals, cfg.TopVersionsCount)
+	slices.SortFunc(topVersionsList, compareVersions)
 
 	// Create a set of top versions for quick lookup
 	topVersionsSet := make(map[string]bool)
@@ -227,11 +353,11 @@ func buildVersionsChart(summaries []summary.SummaryRecord) *charts.Line {
 		charts.WithInitializationOpts(opts.Initialization{
 			Width:           consts.ChartWidth,
 			Height:          consts.ChartHeight,
-			BackgroundColor: consts.ChartBackgroundColor,
+			BackgroundColor: theme.BackgroundColor,
 		}),
 		charts.WithTitleOpts(opts.Title{
 			Title:      "Number of Navidrome Installations",
-			TitleStyle: &opts.TextStyle{Color: consts.ChartTextColor},
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{
 			Show:    opts.Bool(true),
@@ -241,23 +367,25 @@ func buildVersionsChart(summaries []summary.SummaryRecord) *charts.Line {
 			Show:      opts.Bool(true),
 			Right:     "10",
 			Orient:    "vertical",
-			TextStyle: &opts.TextStyle{Color: consts.ChartTextColor},
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
 		}),
 		charts.WithXAxisOpts(opts.XAxis{
 			Name:         "Date",
 			NameLocation: "center",
 			NameGap:      30,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithYAxisOpts(opts.YAxis{
 			Name:         "Installations",
 			NameLocation: "center",
 			NameGap:      50,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithGridOpts(opts.Grid{
 			Left:   "80",
@@ -291,7 +419,8 @@ func buildVersionsChart(summaries []summary.SummaryRecord) *charts.Line {
 			// Calculate totals for this day
 			var allTotal uint64
 			var othersCount uint64
-			for version, count := range s.Data.Versions {
+			versions := versionsOf(s)
+			for version, count := range versions {
 				allTotal += count
 				if !topVersionsSet[version] {
 					othersCount += count
@@ -299,22 +428,31 @@ func buildVersionsChart(summaries []summary.SummaryRecord) *charts.Line {
 			}
 			allData[i] = opts.LineData{Value: allTotal}
 			for _, version := range topVersionsList {
-				versionData[version][i] = opts.LineData{Value: s.Data.Versions[version]}
+				versionData[version][i] = opts.LineData{Value: versions[version]}
 			}
 			othersData[i] = opts.LineData{Value: othersCount}
 		}
 	}
 
-	// Find gaps and create mark areas
+	// Find gaps and provisional days, and create mark areas
 	gaps := ts.findGaps()
-	markAreas := buildMarkAreaData(gaps)
-
-	// Add series - first series gets the mark areas
-	line.AddSeries("All", allData, charts.WithMarkAreaData(markAreas...))
+	provisional := ts.provisionalIndex(summaries)
+	markAreas := append(buildMarkAreaData(gaps, theme), buildProvisionalMarkArea(ts, provisional, theme)...)
+
+	// Add series - first series gets the mark areas, release annotations and pins on anomalous days. The
+	// total of the provisional days is drawn as a separate dotted series
+	markPoints := buildAnomalyMarkPoints(DetectAnomalies(summaries), theme)
+	allFinal, allProvisional := splitProvisional(allData, provisional)
+	line.AddSeries("All", allFinal, append(releaseMarkLineOpts(summaries),
+		charts.WithMarkAreaData(markAreas...), charts.WithMarkPointNameCoordItemOpts(markPoints...))...)
+	line.AddSeries(movingAverageName("All"), movingAverage(allData, consts.MovingAverageDays), movingAverageOpts())
 	for _, version := range topVersionsList {
 		line.AddSeries(version, versionData[version])
 	}
 	line.AddSeries("Others", othersData)
+	if allProvisional != nil {
+		line.AddSeries(provisionalName("All"), allProvisional, provisionalOpts())
+	}
 
 	line.SetSeriesOptions(
 		charts.WithLineChartOpts(opts.LineChart{Smooth: opts.Bool(true)}),
@@ -323,171 +461,162 @@ func buildVersionsChart(summaries []summary.SummaryRecord) *charts.Line {
 	return line
 }
 
-func buildOSChart(summaries []summary.SummaryRecord) *charts.Pie {
-	if len(summaries) == 0 {
-		return nil
-	}
-	latest := summaries[len(summaries)-1]
-
-	// Prepare data
-	var data []opts.PieData
-	for os, count := range latest.Data.OS {
-		data = append(data, opts.PieData{Name: os, Value: count})
-	}
-
-	// Sort data by value descending
-	sort.Slice(data, func(i, j int) bool {
-		return data[i].Value.(uint64) > data[j].Value.(uint64)
-	})
-
-	pie := charts.NewPie()
-	pie.SetGlobalOptions(
-		charts.WithInitializationOpts(opts.Initialization{
-			Width:           consts.ChartWidth,
-			Height:          consts.ChartHeight,
-			BackgroundColor: consts.ChartBackgroundColor,
-		}),
-		charts.WithTitleOpts(opts.Title{
-			Title:      "Operating systems and architectures",
-			TitleStyle: &opts.TextStyle{Color: consts.ChartTextColor},
-		}),
-		charts.WithTooltipOpts(opts.Tooltip{
-			Show:      opts.Bool(true),
-			Trigger:   "item",
-			Formatter: "{b}: {c} ({d}%)",
-		}),
-		charts.WithLegendOpts(opts.Legend{
-			Show:      opts.Bool(true),
-			Right:     "10",
-			Orient:    "vertical",
-			TextStyle: &opts.TextStyle{Color: consts.ChartTextColor},
-			Type:      "scroll",
-		}),
-	)
+// releaseMark is a Navidrome release shown as an annotation on time series charts
+type releaseMark struct {
+	Date    time.Time
+	Version string
+}
 
-	pie.AddSeries("OS", data).
-		SetSeriesOptions(
-			charts.WithLabelOpts(opts.Label{
-				Show: opts.Bool(false),
-			}),
-			charts.WithPieChartOpts(opts.PieChart{
-				Radius: []string{"0%", "75%"},
-				Center: []string{"40%", "50%"},
-			}),
-		)
+// minorReleaseRegex matches minor release versions, like "0.54.0 (0b184893)"
+var minorReleaseRegex = regexp.MustCompile(`^(\d+\.\d+\.0) \(`)
 
-	return pie
-}
+// stableReleaseRegex matches any stable release version, like "0.54.2 (0b184893)"
+var stableReleaseRegex = regexp.MustCompile(`^(\d+\.\d+\.\d+) \(`)
 
-func buildPlayerTypesChart(summaries []summary.SummaryRecord) *charts.Pie {
+// detectReleases returns the first date each release matching releaseRegex was reported, ignoring
+// releases already present in the first summary (released before the time range)
+func detectReleases(summaries []summary.SummaryRecord, releaseRegex *regexp.Regexp) []releaseMark {
 	if len(summaries) == 0 {
 		return nil
 	}
-	latest := summaries[len(summaries)-1]
-
-	// Calculate total count
-	var total uint64
-	for _, count := range latest.Data.PlayerTypes {
-		total += count
+	seen := make(map[string]bool)
+	for version := range summaries[0].Data.Versions {
+		if m := releaseRegex.FindStringSubmatch(version); m != nil {
+			seen[m[1]] = true
+		}
 	}
 
-	// Group players with less than threshold into "Others"
-	threshold := float64(total) * consts.PlayerGroupThreshold
-	var data []opts.PieData
-	var othersCount uint64
-	for playerType, count := range latest.Data.PlayerTypes {
-		if float64(count) < threshold {
-			othersCount += count
-		} else {
-			data = append(data, opts.PieData{Name: playerType, Value: count})
+	var releases []releaseMark
+	for _, s := range summaries[1:] {
+		var found []string
+		for version := range s.Data.Versions {
+			if m := releaseRegex.FindStringSubmatch(version); m != nil && !seen[m[1]] {
+				seen[m[1]] = true
+				found = append(found, m[1])
+			}
+		}
+		slices.Sort(found)
+		for _, v := range found {
+			releases = append(releases, releaseMark{Date: s.Time, Version: v})
 		}
 	}
-	if othersCount > 0 {
-		data = append(data, opts.PieData{Name: "Others", Value: othersCount})
+	return releases
+}
+
+// buildReleaseMarkLines creates vertical MarkLines for each release
+func buildReleaseMarkLines(releases []releaseMark) []opts.MarkLineNameXAxisItem {
+	items := make([]opts.MarkLineNameXAxisItem, 0, len(releases))
+	for _, r := range releases {
+		items = append(items, opts.MarkLineNameXAxisItem{
+			Name:  r.Version,
+			XAxis: r.Date.Format(consts.ChartDateFormat),
+		})
 	}
+	return items
+}
 
-	// Sort data by value descending
-	sort.Slice(data, func(i, j int) bool {
-		return data[i].Value.(uint64) > data[j].Value.(uint64)
-	})
+// movingAverage calculates, for each date with data, the mean of the values of the last `days` days
+// (including it), skipping the missing ones. Dates without data keep nil values, so gaps are preserved
+func movingAverage(data []opts.LineData, days int) []opts.LineData {
+	avg := make([]opts.LineData, len(data))
+	for i := range data {
+		if data[i].Value == nil {
+			continue
+		}
+		var sum float64
+		var n int
+		for _, d := range data[max(0, i-days+1) : i+1] {
+			if v, ok := lineValue(d.Value); ok {
+				sum += v
+				n++
+			}
+		}
+		avg[i] = opts.LineData{Value: math.Round(sum/float64(n)*100) / 100}
+	}
+	return avg
+}
 
-	pie := charts.NewPie()
-	pie.SetGlobalOptions(
-		charts.WithInitializationOpts(opts.Initialization{
-			Width:           consts.ChartWidth,
-			Height:          consts.ChartHeight,
-			BackgroundColor: consts.ChartBackgroundColor,
-		}),
-		charts.WithTitleOpts(opts.Title{
-			Title:      "Client types",
-			TitleStyle: &opts.TextStyle{Color: consts.ChartTextColor},
-		}),
-		charts.WithTooltipOpts(opts.Tooltip{
-			Show:      opts.Bool(true),
-			Trigger:   "item",
-			Formatter: "{b}: {c} ({d}%)",
-		}),
-		charts.WithLegendOpts(opts.Legend{
-			Show:      opts.Bool(true),
-			Right:     "10",
-			Orient:    "vertical",
-			TextStyle: &opts.TextStyle{Color: consts.ChartTextColor},
-			Type:      "scroll",
-		}),
-	)
+// lineValue returns the numeric value of a series item, false if missing
+func lineValue(v any) (float64, bool) {
+	switch v := v.(type) {
+	case uint64:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
 
-	pie.AddSeries("Client type", data).
-		SetSeriesOptions(
-			charts.WithLabelOpts(opts.Label{
-				Show: opts.Bool(false),
-			}),
-			charts.WithPieChartOpts(opts.PieChart{
-				Radius: []string{"0%", "75%"},
-				Center: []string{"40%", "50%"},
-			}),
-		)
+func movingAverageName(series string) string {
+	return fmt.Sprintf("%s (%d-day average)", series, consts.MovingAverageDays)
+}
 
-	return pie
+// movingAverageOpts styles moving average series as dashed lines, to set them apart from the daily values
+func movingAverageOpts() charts.SeriesOpts {
+	return charts.WithLineStyleOpts(opts.LineStyle{Type: "dashed"})
 }
 
-func buildPlayersChart(summaries []summary.SummaryRecord) *charts.Line {
-	// Build continuous date range with gaps
+// weekOverWeekGrowth calculates, for each date, the growth percentage compared to 7 days before.
+// Dates without data for either day have nil values.
+func weekOverWeekGrowth(ts timeSeriesData, value func(summary.Summary) uint64) []opts.LineData {
+	data := make([]opts.LineData, len(ts.Dates))
+	for i := range ts.Dates {
+		date := ts.Start.AddDate(0, 0, i)
+		cur := ts.Lookup[date]
+		prev := ts.Lookup[date.AddDate(0, 0, -7)]
+		if cur == nil || prev == nil || value(prev.Data) == 0 {
+			data[i] = opts.LineData{Value: nil}
+			continue
+		}
+		growth := (float64(value(cur.Data)) - float64(value(prev.Data))) / float64(value(prev.Data)) * 100
+		data[i] = opts.LineData{Value: math.Round(growth*100) / 100}
+	}
+	return data
+}
+
+func buildGrowthChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Line {
 	ts := buildTimeSeriesData(summaries)
-	start := summaries[0].Time
 
 	line := charts.NewLine()
 	line.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
 			Width:           consts.ChartWidth,
 			Height:          consts.ChartHeight,
-			BackgroundColor: consts.ChartBackgroundColor,
+			BackgroundColor: theme.BackgroundColor,
 		}),
 		charts.WithTitleOpts(opts.Title{
-			Title:      "Number of Active Clients",
-			TitleStyle: &opts.TextStyle{Color: consts.ChartTextColor},
+			Title:      "Week-over-Week Growth",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{
 			Show:    opts.Bool(true),
 			Trigger: "axis",
 		}),
 		charts.WithLegendOpts(opts.Legend{
-			Show: opts.Bool(false),
+			Show:      opts.Bool(true),
+			Right:     "10",
+			Orient:    "vertical",
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
 		}),
 		charts.WithXAxisOpts(opts.XAxis{
 			Name:         "Date",
 			NameLocation: "center",
 			NameGap:      30,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithYAxisOpts(opts.YAxis{
-			Name:         "Clients",
+			Name:         "Growth (%)",
 			NameLocation: "center",
 			NameGap:      50,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithGridOpts(opts.Grid{
 			Left:   "80",
@@ -498,27 +627,20 @@ func buildPlayersChart(summaries []summary.SummaryRecord) *charts.Line {
 
 	line.SetXAxis(ts.Dates)
 
-	// Calculate total players for each date, with nil for missing dates
-	totalData := make([]opts.LineData, len(ts.Dates))
-	for i := range ts.Dates {
-		date := start.AddDate(0, 0, i)
-		s := ts.Lookup[date]
-		if s == nil {
-			totalData[i] = opts.LineData{Value: nil}
-		} else {
-			var total uint64
-			for _, count := range s.Data.PlayerTypes {
-				total += count
-			}
-			totalData[i] = opts.LineData{Value: total}
+	installationsData := weekOverWeekGrowth(ts, func(s summary.Summary) uint64 {
+		return uint64(s.NumInstances)
+	})
+	clientsData := weekOverWeekGrowth(ts, func(s summary.Summary) uint64 {
+		var total uint64
+		for _, count := range s.PlayerTypes {
+			total += count
 		}
-	}
-
-	// Find gaps and create mark areas
-	gaps := ts.findGaps()
-	markAreas := buildMarkAreaData(gaps)
+		return total
+	})
 
-	line.AddSeries("Total Clients", totalData, charts.WithMarkAreaData(markAreas...))
+	// First series gets the release annotations
+	line.AddSeries("Installations", installationsData, releaseMarkLineOpts(summaries)...)
+	line.AddSeries("Active Clients", clientsData)
 
 	line.SetSeriesOptions(
 		charts.WithLineChartOpts(opts.LineChart{Smooth: opts.Bool(true)}),
@@ -527,246 +649,1001 @@ func buildPlayersChart(summaries []summary.SummaryRecord) *charts.Line {
 	return line
 }
 
-func buildPlayersPerInstallationChart(summaries []summary.SummaryRecord) *charts.Bar {
-	if len(summaries) == 0 {
-		return nil
-	}
-	latest := summaries[len(summaries)-1]
-
-	// Define bins for grouping player counts to handle the long tail
-	bins := []struct {
-		label string
-		min   int
-		max   int // inclusive, -1 means infinity
-	}{
-		{"0", 0, 0},
-		{"1", 1, 1},
-		{"2", 2, 2},
-		{"3", 3, 3},
-		{"4", 4, 4},
-		{"5", 5, 5},
-		{"6-10", 6, 10},
-		{"11-20", 11, 20},
-		{"21-50", 21, 50},
-		{"50+", 51, -1},
-	}
-
-	// Aggregate data into bins
-	binValues := make([]uint64, len(bins))
-	for countStr, value := range latest.Data.Players {
-		var count int
-		_, _ = fmt.Sscanf(countStr, "%d", &count)
-
-		for i, bin := range bins {
-			if count >= bin.min && (bin.max == -1 || count <= bin.max) {
-				binValues[i] += value
-				break
-			}
-		}
-	}
-
-	// Build chart data
-	xLabels := make([]string, len(bins))
-	data := make([]opts.BarData, len(bins))
-	for i, bin := range bins {
-		xLabels[i] = bin.label
-		data[i] = opts.BarData{Value: binValues[i]}
-	}
+func buildNewReturningChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Bar {
+	ts := buildTimeSeriesData(summaries)
 
 	bar := charts.NewBar()
 	bar.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
 			Width:           consts.ChartWidth,
 			Height:          consts.ChartHeight,
-			BackgroundColor: consts.ChartBackgroundColor,
+			BackgroundColor: theme.BackgroundColor,
 		}),
 		charts.WithTitleOpts(opts.Title{
-			Title:      "Active Clients per Installation",
-			TitleStyle: &opts.TextStyle{Color: consts.ChartTextColor},
+			Title:      "New vs. Returning Installations",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{
 			Show:    opts.Bool(true),
 			Trigger: "axis",
 		}),
 		charts.WithLegendOpts(opts.Legend{
-			Show: opts.Bool(false),
+			Show:      opts.Bool(true),
+			Right:     "10",
+			Orient:    "vertical",
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
 		}),
 		charts.WithXAxisOpts(opts.XAxis{
-			Name:         "Active Clients per Installation",
+			Name:         "Date",
 			NameLocation: "center",
 			NameGap:      30,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithYAxisOpts(opts.YAxis{
-			Name:         "Count of Installations",
+			Name:         "Installations",
 			NameLocation: "center",
 			NameGap:      50,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithGridOpts(opts.Grid{
 			Left:   "80",
+			Right:  "280",
 			Bottom: "60",
 		}),
 	)
 
-	bar.SetXAxis(xLabels).AddSeries("Installations", data)
+	bar.SetXAxis(ts.Dates)
+
+	// Churned instances are plotted as negative values, below the axis
+	returningData := make([]opts.BarData, len(ts.Dates))
+	newData := make([]opts.BarData, len(ts.Dates))
+	churnedData := make([]opts.BarData, len(ts.Dates))
+	for i := range ts.Dates {
+		s := ts.Lookup[ts.Start.AddDate(0, 0, i)]
+		if s == nil {
+			returningData[i] = opts.BarData{Value: nil}
+			newData[i] = opts.BarData{Value: nil}
+			churnedData[i] = opts.BarData{Value: nil}
+			continue
+		}
+		returningData[i] = opts.BarData{Value: max(s.Data.NumInstances-s.Data.NewInstances, 0)}
+		newData[i] = opts.BarData{Value: s.Data.NewInstances}
+		churnedData[i] = opts.BarData{Value: -s.Data.ChurnedInstances}
+	}
+
+	stacked := charts.WithBarChartOpts(opts.BarChart{Stack: "instances"})
+	bar.AddSeries("Returning", returningData, stacked).
+		AddSeries("New", newData, stacked).
+		AddSeries("Churned", churnedData, stacked)
 
 	return bar
 }
 
-var trackBinLabels = []string{
-	"0", "1-500", "501-1,000", "1,001-5,000", "5,001-10,000",
-	"10,001-20,000", "20,001-50,000", "50,001-100,000",
-	"100,001-500,000", "500,001-1,000,000", ">1,000,001",
+// versionShare returns the percentage of installations running the given release, across all its builds
+func versionShare(versions map[string]uint64, release string) float64 {
+	var total, count uint64
+	for version, c := range versions {
+		total += c
+		if m := stableReleaseRegex.FindStringSubmatch(version); m != nil && m[1] == release {
+			count += c
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(count)/float64(total)*10000) / 100
 }
 
-var albumArtistBinLabels = []string{
-	"0", "1-10", "11-50", "51-100", "101-500",
-	"501-1,000", "1,001-2,000", "2,001-5,000",
-	"5,001-10,000", "10,001-50,000", "50,001-100,000", ">100,000",
-}
+func buildVersionAdoptionChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Line {
+	ts := buildTimeSeriesData(summaries)
 
-func buildTracksChart(summaries []summary.SummaryRecord) *charts.Bar {
-	if len(summaries) == 0 {
-		return nil
+	// Only the most recent releases, newest first
+	releases := detectReleases(summaries, stableReleaseRegex)
+	if len(releases) > consts.AdoptionReleases {
+		releases = releases[len(releases)-consts.AdoptionReleases:]
 	}
-	latest := summaries[len(summaries)-1]
+	slices.Reverse(releases)
 
-	// Map bin values to labels, maintaining order from trackBins in summary.go
-	binToLabel := map[string]string{
-		"0":       "0",
-		"1":       "1-500",
-		"500":     "501-1,000",
-		"1000":    "1,001-5,000",
-		"5000":    "5,001-10,000",
-		"10000":   "10,001-20,000",
-		"20000":   "20,001-50,000",
-		"50000":   "50,001-100,000",
-		"100000":  "100,001-500,000",
-		"500000":  "500,001-1,000,000",
-		"1000000": ">1,000,001",
-	}
-
-	// Build data in the order of trackBinLabels
-	data := make([]opts.BarData, len(trackBinLabels))
-	for i, label := range trackBinLabels {
-		var value uint64
-		for binKey, binLabel := range binToLabel {
-			if binLabel == label {
-				value = latest.Data.Tracks[binKey]
-				break
-			}
-		}
-		data[i] = opts.BarData{Value: value}
+	var lastDate time.Time
+	if len(summaries) > 0 {
+		lastDate = summaries[len(summaries)-1].Time
+	}
+	maxDays := 0
+	for _, r := range releases {
+		maxDays = max(maxDays, int(lastDate.Sub(r.Date).Hours()/24))
+	}
+	days := make([]string, maxDays+1)
+	for i := range days {
+		days[i] = fmt.Sprintf("%d", i)
 	}
 
-	bar := charts.NewBar()
-	bar.SetGlobalOptions(
+	line := charts.NewLine()
+	line.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
 			Width:           consts.ChartWidth,
 			Height:          consts.ChartHeight,
-			BackgroundColor: consts.ChartBackgroundColor,
+			BackgroundColor: theme.BackgroundColor,
 		}),
 		charts.WithTitleOpts(opts.Title{
-			Title:      "Number of Tracks in Library",
-			TitleStyle: &opts.TextStyle{Color: consts.ChartTextColor},
+			Title:      "Version Adoption",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{
 			Show:    opts.Bool(true),
 			Trigger: "axis",
 		}),
 		charts.WithLegendOpts(opts.Legend{
-			Show: opts.Bool(false),
+			Show:      opts.Bool(true),
+			Right:     "10",
+			Orient:    "vertical",
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
 		}),
 		charts.WithXAxisOpts(opts.XAxis{
-			Name:         "Count of Installations",
+			Name:         "Days since release",
 			NameLocation: "center",
 			NameGap:      30,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithYAxisOpts(opts.YAxis{
-			Name:         "Tracks in Library",
+			Name:         "Installations (%)",
 			NameLocation: "center",
-			NameGap:      130,
+			NameGap:      50,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithGridOpts(opts.Grid{
-			Left:   "180",
+			Left:   "80",
+			Right:  "280",
 			Bottom: "60",
 		}),
 	)
 
-	bar.SetXAxis(trackBinLabels).
-		AddSeries("Installations", data).
-		XYReversal()
+	line.SetXAxis(days)
 
-	return bar
-}
+	for _, r := range releases {
+		data := make([]opts.LineData, len(days))
+		for d := range days {
+			s := ts.Lookup[r.Date.AddDate(0, 0, d)]
+			if s == nil {
+				data[d] = opts.LineData{Value: nil}
+				continue
+			}
+			data[d] = opts.LineData{Value: versionShare(s.Data.Versions, r.Version)}
+		}
+		line.AddSeries(r.Version, data)
+	}
 
-func buildAlbumsArtistsChart(summaries []summary.SummaryRecord) *charts.Bar {
-	if len(summaries) == 0 {
+	line.SetSeriesOptions(
+		charts.WithLineChartOpts(opts.LineChart{Smooth: opts.Bool(true)}),
+	)
+
+	return line
+}
+
+func buildOSChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Pie {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+
+	// Prepare data
+	var data []opts.PieData
+	for os, count := range latest.Data.OS {
+		data = append(data, opts.PieData{Name: os, Value: count})
+	}
+
+	// Sort data by value descending
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Value.(uint64) > data[j].Value.(uint64)
+	})
+
+	pie := charts.NewPie()
+	pie.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Operating systems and architectures",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:      opts.Bool(true),
+			Trigger:   "item",
+			Formatter: "{b}: {c} ({d}%)",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show:      opts.Bool(true),
+			Right:     "10",
+			Orient:    "vertical",
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
+			Type:      "scroll",
+		}),
+	)
+
+	pie.AddSeries("OS", data).
+		SetSeriesOptions(
+			charts.WithLabelOpts(opts.Label{
+				Show: opts.Bool(false),
+			}),
+			charts.WithPieChartOpts(opts.PieChart{
+				Radius: []string{"0%", "75%"},
+				Center: []string{"40%", "50%"},
+			}),
+		)
+
+	return pie
+}
+
+// osArchitectures sums the OS counts ("<OS> - <arch>", see summary.mapOS) by architecture
+func osArchitectures(osCounts map[string]uint64) map[string]uint64 {
+	archs := make(map[string]uint64)
+	for name, count := range osCounts {
+		arch := "unknown"
+		if i := strings.LastIndex(name, " - "); i >= 0 && name[i+3:] != "" {
+			arch = name[i+3:]
+		}
+		archs[arch] += count
+	}
+	return archs
+}
+
+// buildArchitecturesChart shows the number of installations per architecture over time, stacked, so
+// hardware trends (e.g. ARM growth) stand out. Architectures are sorted by their last day's count
+func buildArchitecturesChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Line {
+	ts := buildTimeSeriesData(summaries)
+
+	archsByDate := make(map[time.Time]map[string]uint64, len(summaries))
+	totals := make(map[string]uint64)
+	for _, s := range summaries {
+		archs := osArchitectures(s.Data.OS)
+		archsByDate[s.Time] = archs
+		for arch, count := range archs {
+			totals[arch] += count
+		}
+	}
+	archList := slices.Collect(maps.Keys(totals))
+	last := archsByDate[summaries[len(summaries)-1].Time]
+	slices.SortFunc(archList, func(a, b string) int {
+		return cmp.Or(cmp.Compare(last[b], last[a]), cmp.Compare(totals[b], totals[a]), cmp.Compare(a, b))
+	})
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Installations by Architecture",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show:      opts.Bool(true),
+			Right:     "10",
+			Orient:    "vertical",
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Date",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Installations",
+			NameLocation: "center",
+			NameGap:      50,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "80",
+			Right:  "280",
+			Bottom: "60",
+		}),
+	)
+
+	line.SetXAxis(ts.Dates)
+
+	markAreas := buildMarkAreaData(ts.findGaps(), theme)
+	for i, arch := range archList {
+		data := make([]opts.LineData, len(ts.Dates))
+		for j := range ts.Dates {
+			if archs, ok := archsByDate[ts.Start.AddDate(0, 0, j)]; ok {
+				data[j] = opts.LineData{Value: archs[arch]}
+			}
+		}
+		// First series gets the mark areas
+		if i == 0 {
+			line.AddSeries(arch, data, charts.WithMarkAreaData(markAreas...))
+			continue
+		}
+		line.AddSeries(arch, data)
+	}
+
+	line.SetSeriesOptions(
+		charts.WithLineChartOpts(opts.LineChart{Stack: "total"}),
+		charts.WithAreaStyleOpts(opts.AreaStyle{Opacity: opts.Float(0.6)}),
+	)
+
+	return line
+}
+
+// OtherPlayerTypes returns the player types grouped as "Others" in the player types chart, the ones with
+// less than CurrentConfig().PlayerGroupThreshold of the total
+func OtherPlayerTypes(playerTypes map[string]uint64) map[string]uint64 {
+	var total uint64
+	for _, count := range playerTypes {
+		total += count
+	}
+	threshold := float64(total) * CurrentConfig().PlayerGroupThreshold
+	others := map[string]uint64{}
+	for playerType, count := range playerTypes {
+		if float64(count) < threshold {
+			others[playerType] = count
+		}
+	}
+	return others
+}
+
+func buildPlayerTypesChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Pie {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+
+	// Group players with less than threshold into "Others"
+	others := OtherPlayerTypes(latest.Data.PlayerTypes)
+	var data []opts.PieData
+	var othersCount uint64
+	for playerType, count := range latest.Data.PlayerTypes {
+		if _, ok := others[playerType]; ok {
+			othersCount += count
+		} else {
+			data = append(data, opts.PieData{Name: playerType, Value: count})
+		}
+	}
+	if othersCount > 0 {
+		data = append(data, opts.PieData{Name: "Others", Value: othersCount})
+	}
+
+	// Sort data by value descending
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Value.(uint64) > data[j].Value.(uint64)
+	})
+
+	pie := charts.NewPie()
+	pie.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Client types",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:      opts.Bool(true),
+			Trigger:   "item",
+			Formatter: "{b}: {c} ({d}%)",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show:      opts.Bool(true),
+			Right:     "10",
+			Orient:    "vertical",
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
+			Type:      "scroll",
+		}),
+	)
+
+	pie.AddSeries("Client type", data).
+		SetSeriesOptions(
+			charts.WithLabelOpts(opts.Label{
+				Show: opts.Bool(false),
+			}),
+			charts.WithPieChartOpts(opts.PieChart{
+				Radius: []string{"0%", "75%"},
+				Center: []string{"40%", "50%"},
+			}),
+		)
+
+	return pie
+}
+
+// buildCountriesChart shows the installations of the latest day by country on a world map, shaded by count.
+// The map is not bundled with the chart: the dashboard registers it as consts.WorldMapName, with its regions
+// named by ISO 3166-1 numeric code, and the series' nameMap matches them to the stored alpha-2 codes
+func buildCountriesChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *mapChart {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+
+	data := make([]opts.MapData, 0, len(latest.Data.Countries))
+	var maxCount uint64
+	for _, country := range slices.Sorted(maps.Keys(latest.Data.Countries)) {
+		count := latest.Data.Countries[country]
+		data = append(data, opts.MapData{Name: country, Value: count})
+		maxCount = max(maxCount, count)
+	}
+
+	chart := &mapChart{Map: charts.NewMap(), nameMap: countryNameMap()}
+	chart.RegisterMapType(consts.WorldMapName)
+	chart.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Installations by country",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:      opts.Bool(true),
+			Trigger:   "item",
+			Formatter: "{b}: {c}",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show: opts.Bool(false),
+		}),
+		charts.WithVisualMapOpts(opts.VisualMap{
+			Type:       "continuous",
+			Calculable: opts.Bool(true),
+			Max:        float32(maxCount),
+			Left:       "10",
+			Bottom:     "10",
+			TextStyle:  &opts.TextStyle{Color: theme.TextColor},
+			InRange:    &opts.VisualMapInRange{Color: []string{"#c6d2f0", echartsPalette[0]}},
+		}),
+	)
+
+	chart.AddSeries("Installations", data).
+		SetSeriesOptions(
+			charts.WithItemStyleOpts(opts.ItemStyle{
+				AreaColor:   theme.GridColor,
+				BorderColor: theme.BackgroundColor,
+			}),
+		)
+
+	return chart
+}
+
+// mapChart is a go-echarts map chart with the nameMap of its series, which go-echarts doesn't support
+type mapChart struct {
+	*charts.Map
+	nameMap map[string]string
+}
+
+func (c *mapChart) JSON() map[string]interface{} {
+	type seriesWithNameMap struct {
+		charts.SingleSeries
+		NameMap map[string]string `json:"nameMap,omitempty"`
+	}
+	options := c.Map.JSON()
+	series := make([]seriesWithNameMap, len(c.MultiSeries))
+	for i, s := range c.MultiSeries {
+		series[i] = seriesWithNameMap{SingleSeries: s, NameMap: c.nameMap}
+	}
+	options["series"] = series
+	return options
+}
+
+// countryNameMap maps the ISO 3166-1 numeric codes of all countries, zero padded like the ids of the
+// world map, to their alpha-2 codes
+var countryNameMap = sync.OnceValue(func() map[string]string {
+	nameMap := make(map[string]string)
+	for a := 'A'; a <= 'Z'; a++ {
+		for b := 'A'; b <= 'Z'; b++ {
+			code := string([]rune{a, b})
+			// Skip the deprecated codes of countries that were renamed, like UK for GB
+			region, err := language.ParseRegion(code)
+			if err != nil || !region.IsCountry() || region.Canonicalize().String() != code || region.M49() == 0 {
+				continue
+			}
+			nameMap[fmt.Sprintf("%03d", region.M49())] = code
+		}
+	}
+	return nameMap
+})
+
+func buildPlayersChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Line {
+	// Build continuous date range with gaps
+	ts := buildTimeSeriesData(summaries)
+	start := summaries[0].Time
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Number of Active Clients",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show:      opts.Bool(true),
+			Right:     "10",
+			Orient:    "vertical",
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Date",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Clients",
+			NameLocation: "center",
+			NameGap:      50,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "80",
+			Right:  "280",
+			Bottom: "60",
+		}),
+	)
+
+	line.SetXAxis(ts.Dates)
+
+	// Calculate total players for each date, with nil for missing dates
+	totalData := make([]opts.LineData, len(ts.Dates))
+	for i := range ts.Dates {
+		date := start.AddDate(0, 0, i)
+		s := ts.Lookup[date]
+		if s == nil {
+			totalData[i] = opts.LineData{Value: nil}
+		} else {
+			var total uint64
+			for _, count := range s.Data.PlayerTypes {
+				total += count
+			}
+			totalData[i] = opts.LineData{Value: total}
+		}
+	}
+
+	// Find gaps and provisional days, and create mark areas
+	gaps := ts.findGaps()
+	provisional := ts.provisionalIndex(summaries)
+	markAreas := append(buildMarkAreaData(gaps, theme), buildProvisionalMarkArea(ts, provisional, theme)...)
+
+	totalFinal, totalProvisional := splitProvisional(totalData, provisional)
+	line.AddSeries("Total Clients", totalFinal, append(releaseMarkLineOpts(summaries), charts.WithMarkAreaData(markAreas...))...)
+	line.AddSeries(movingAverageName("Total Clients"), movingAverage(totalData, consts.MovingAverageDays), movingAverageOpts())
+	if totalProvisional != nil {
+		line.AddSeries(provisionalName("Total Clients"), totalProvisional, provisionalOpts())
+	}
+
+	line.SetSeriesOptions(
+		charts.WithLineChartOpts(opts.LineChart{Smooth: opts.Bool(true)}),
+	)
+
+	return line
+}
+
+// perInstallationBins groups small per-installation counts (clients, users) to handle the long tail
+var perInstallationBins = []int64{0, 1, 2, 3, 4, 5, 6, 11, 21, 51}
+
+// perInstallationData aggregates the installations counted by number (e.g. Summary.Players) into the
+// perInstallationBins, returning the bin labels and bar data
+func perInstallationData(counts map[string]uint64) ([]string, []opts.BarData) {
+	return binLabels(perInstallationBins), binnedData(perInstallationBins, counts)
+}
+
+func buildPlayersPerInstallationChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Bar {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+	xLabels, data := perInstallationData(latest.Data.Players)
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Active Clients per Installation",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show: opts.Bool(false),
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Active Clients per Installation",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Count of Installations",
+			NameLocation: "center",
+			NameGap:      50,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "80",
+			Bottom: "60",
+		}),
+	)
+
+	bar.SetXAxis(xLabels).AddSeries("Installations", data)
+
+	return bar
+}
+
+// buildUsersPerInstallationChart shows how many installations have each number of active users, from
+// the latest summary, to follow multi-user adoption
+func buildUsersPerInstallationChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Bar {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+	xLabels, data := perInstallationData(latest.Data.Users)
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Active Users per Installation",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show: opts.Bool(false),
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Active Users per Installation",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Count of Installations",
+			NameLocation: "center",
+			NameGap:      50,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "80",
+			Bottom: "60",
+		}),
+	)
+
+	bar.SetXAxis(xLabels).AddSeries("Installations", data)
+
+	return bar
+}
+
+func buildTracksChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Bar {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+
+	bins := summary.CurrentBins().Tracks
+	data := binnedData(bins, latest.Data.Tracks)
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Number of Tracks in Library",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show: opts.Bool(false),
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Count of Installations",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Tracks in Library",
+			NameLocation: "center",
+			NameGap:      130,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "180",
+			Bottom: "60",
+		}),
+	)
+
+	bar.SetXAxis(binLabels(bins)).
+		AddSeries("Installations", data).
+		XYReversal()
+
+	return bar
+}
+
+func buildAlbumsArtistsChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Bar {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+
+	// Albums and artists have the same bins (see summary.LoadBins)
+	bins := summary.CurrentBins().Albums
+	albumsData := binnedData(bins, latest.Data.Albums)
+	artistsData := binnedData(bins, latest.Data.Artists)
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Albums and Artists in Library",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show:   opts.Bool(true),
+			Top:    "30",
+			Orient: "horizontal",
+			TextStyle: &opts.TextStyle{
+				Color: theme.TextColor,
+			},
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Count of Installations",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Items in Library",
+			NameLocation: "center",
+			NameGap:      100,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "140",
+			Top:    "80",
+			Bottom: "60",
+		}),
+	)
+
+	bar.SetXAxis(binLabels(bins)).
+		AddSeries("Albums", albumsData).
+		AddSeries("Artists", artistsData).
+		XYReversal()
+
+	return bar
+}
+
+// buildLibraryTotalsChart shows the aggregate catalog size (tracks, albums and artists summed over all
+// installations) over time. Days summarized before the totals were added have no data
+func buildLibraryTotalsChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Line {
+	ts := buildTimeSeriesData(summaries)
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Library Totals",
+			Subtitle:   "Sum over all installations",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show:      opts.Bool(true),
+			Right:     "10",
+			Orient:    "vertical",
+			TextStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Date",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Items",
+			NameLocation: "center",
+			NameGap:      70,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "100",
+			Right:  "280",
+			Bottom: "60",
+		}),
+	)
+
+	line.SetXAxis(ts.Dates)
+
+	series := []struct {
+		name  string
+		value func(summary.Summary) int64
+	}{
+		{"Tracks", func(s summary.Summary) int64 { return s.TotalTracks }},
+		{"Albums", func(s summary.Summary) int64 { return s.TotalAlbums }},
+		{"Artists", func(s summary.Summary) int64 { return s.TotalArtists }},
+	}
+	markAreas := buildMarkAreaData(ts.findGaps(), theme)
+	for i, sr := range series {
+		data := make([]opts.LineData, len(ts.Dates))
+		for j := range ts.Dates {
+			if s := ts.Lookup[ts.Start.AddDate(0, 0, j)]; s != nil && s.Data.TotalTracks > 0 {
+				data[j] = opts.LineData{Value: sr.value(s.Data)}
+			}
+		}
+		// First series gets the mark areas
+		if i == 0 {
+			line.AddSeries(sr.name, data, charts.WithMarkAreaData(markAreas...))
+			continue
+		}
+		line.AddSeries(sr.name, data)
+	}
+
+	line.SetSeriesOptions(
+		charts.WithLineChartOpts(opts.LineChart{Smooth: opts.Bool(true)}),
+	)
+
+	return line
+}
+
+func buildFilesystemsChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Bar {
+	if len(summaries) == 0 {
 		return nil
 	}
 	latest := summaries[len(summaries)-1]
 
-	// Map bin values to labels, maintaining order from AlbumBins/ArtistBins in summary.go
-	binToLabel := map[string]string{
-		"0":      "0",
-		"1":      "1-10",
-		"10":     "11-50",
-		"50":     "51-100",
-		"100":    "101-500",
-		"500":    "501-1,000",
-		"1000":   "1,001-2,000",
-		"2000":   "2,001-5,000",
-		"5000":   "5,001-10,000",
-		"10000":  "10,001-50,000",
-		"50000":  "50,001-100,000",
-		"100000": ">100,000",
-	}
-
-	// Build albums data
-	albumsData := make([]opts.BarData, len(albumArtistBinLabels))
-	for i, label := range albumArtistBinLabels {
-		var value uint64
-		for binKey, binLabel := range binToLabel {
-			if binLabel == label {
-				value += latest.Data.Albums[binKey]
-			}
-		}
-		albumsData[i] = opts.BarData{Value: value}
+	// Select top filesystems by combined music and data usage
+	totals := make(map[string]uint64)
+	for fs, count := range latest.Data.MusicFS {
+		totals[fs] += count
 	}
+	for fs, count := range latest.Data.DataFS {
+		totals[fs] += count
+	}
+	topFS := getTopKeys(totals, consts.TopFilesystemsCount)
 
-	// Build artists data
-	artistsData := make([]opts.BarData, len(albumArtistBinLabels))
-	for i, label := range albumArtistBinLabels {
-		var value uint64
-		for binKey, binLabel := range binToLabel {
-			if binLabel == label {
-				value += latest.Data.Artists[binKey]
-			}
+	var musicOthers, dataOthers uint64
+	for fs, count := range latest.Data.MusicFS {
+		if !slices.Contains(topFS, fs) {
+			musicOthers += count
+		}
+	}
+	for fs, count := range latest.Data.DataFS {
+		if !slices.Contains(topFS, fs) {
+			dataOthers += count
 		}
-		artistsData[i] = opts.BarData{Value: value}
 	}
 
+	labels := slices.Clone(topFS)
+	musicData := make([]opts.BarData, 0, len(topFS)+1)
+	dataData := make([]opts.BarData, 0, len(topFS)+1)
+	for _, fs := range topFS {
+		musicData = append(musicData, opts.BarData{Value: latest.Data.MusicFS[fs]})
+		dataData = append(dataData, opts.BarData{Value: latest.Data.DataFS[fs]})
+	}
+	if musicOthers > 0 || dataOthers > 0 {
+		labels = append(labels, "Others")
+		musicData = append(musicData, opts.BarData{Value: musicOthers})
+		dataData = append(dataData, opts.BarData{Value: dataOthers})
+	}
+
+	// Reverse so the most used filesystem is shown at the top of the horizontal chart
+	slices.Reverse(labels)
+	slices.Reverse(musicData)
+	slices.Reverse(dataData)
+
 	bar := charts.NewBar()
 	bar.SetGlobalOptions(
 		charts.WithInitializationOpts(opts.Initialization{
 			Width:           consts.ChartWidth,
 			Height:          consts.ChartHeight,
-			BackgroundColor: consts.ChartBackgroundColor,
+			BackgroundColor: theme.BackgroundColor,
 		}),
 		charts.WithTitleOpts(opts.Title{
-			Title:      "Albums and Artists in Library",
-			TitleStyle: &opts.TextStyle{Color: consts.ChartTextColor},
+			Title:      "Filesystem Types",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
 		}),
 		charts.WithTooltipOpts(opts.Tooltip{
 			Show:    opts.Bool(true),
@@ -777,7 +1654,7 @@ func buildAlbumsArtistsChart(summaries []summary.SummaryRecord) *charts.Bar {
 			Top:    "30",
 			Orient: "horizontal",
 			TextStyle: &opts.TextStyle{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
 		}),
 		charts.WithXAxisOpts(opts.XAxis{
@@ -785,16 +1662,18 @@ func buildAlbumsArtistsChart(summaries []summary.SummaryRecord) *charts.Bar {
 			NameLocation: "center",
 			NameGap:      30,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithYAxisOpts(opts.YAxis{
-			Name:         "Items in Library",
+			Name:         "Filesystem",
 			NameLocation: "center",
 			NameGap:      100,
 			AxisLabel: &opts.AxisLabel{
-				Color: consts.ChartTextColor,
+				Color: theme.TextColor,
 			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
 		}),
 		charts.WithGridOpts(opts.Grid{
 			Left:   "140",
@@ -803,9 +1682,215 @@ func buildAlbumsArtistsChart(summaries []summary.SummaryRecord) *charts.Bar {
 		}),
 	)
 
-	bar.SetXAxis(albumArtistBinLabels).
-		AddSeries("Albums", albumsData).
-		AddSeries("Artists", artistsData).
+	bar.SetXAxis(labels).
+		AddSeries("Music folder", musicData).
+		AddSeries("Data folder", dataData).
+		XYReversal()
+
+	return bar
+}
+
+// buildHostSizesChart shows the distribution of CPU cores of the hosts running Navidrome, from the latest
+// summary. The typical memory used by Navidrome is shown in the subtitle
+func buildHostSizesChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Bar {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+
+	labels := binLabels(summary.CPUBins)
+	data := binnedData(summary.CPUBins, latest.Data.CPUs)
+
+	var subtitle string
+	if cpu := latest.Data.CPUStats; cpu != nil {
+		subtitle = fmt.Sprintf("Median: %.0f cores", cpu.Median)
+	}
+	if mem := latest.Data.MemStats; mem != nil {
+		if subtitle != "" {
+			subtitle += " | "
+		}
+		subtitle += fmt.Sprintf("Navidrome memory: median %.0f MB, mean %.0f MB", mem.Median, mem.Mean)
+	}
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:         "Host Sizes (CPU Cores)",
+			Subtitle:      subtitle,
+			TitleStyle:    &opts.TextStyle{Color: theme.TextColor},
+			SubtitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show: opts.Bool(false),
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "CPU Cores",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name: "Count of Installations",
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Top:    "80",
+			Bottom: "60",
+		}),
+	)
+
+	bar.SetXAxis(labels).AddSeries("Installations", data)
+
+	return bar
+}
+
+// buildOSVersionsChart shows the most used Windows and macOS versions, from the latest summary
+func buildOSVersionsChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Bar {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+
+	// Versions are already prefixed with the OS name, so they can be ranked together
+	counts := make(map[string]uint64)
+	for _, versions := range latest.Data.OSVersions {
+		for version, count := range versions {
+			counts[version] += count
+		}
+	}
+	labels := getTopKeys(counts, consts.TopOSVersionsCount)
+	data := make([]opts.BarData, 0, len(labels))
+	for _, version := range labels {
+		data = append(data, opts.BarData{Value: counts[version]})
+	}
+
+	// Reverse so the most used version is shown at the top of the horizontal chart
+	slices.Reverse(labels)
+	slices.Reverse(data)
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Windows and macOS Versions",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithLegendOpts(opts.Legend{
+			Show: opts.Bool(false),
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Count of Installations",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "140",
+			Top:    "60",
+			Bottom: "60",
+		}),
+	)
+
+	bar.SetXAxis(labels).
+		AddSeries("Installations", data).
+		XYReversal()
+
+	return bar
+}
+
+// buildFeaturesChart shows the percentage of installations with each feature enabled, from the latest summary
+func buildFeaturesChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Bar {
+	if len(summaries) == 0 {
+		return nil
+	}
+	latest := summaries[len(summaries)-1]
+
+	// Sort ascending, so the most adopted feature is shown at the top of the horizontal chart
+	names := slices.Collect(maps.Keys(latest.Data.Features))
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(latest.Data.Features[a], latest.Data.Features[b]), cmp.Compare(b, a))
+	})
+
+	data := make([]opts.BarData, 0, len(names))
+	for _, name := range names {
+		var pct float64
+		if latest.Data.NumInstances > 0 {
+			pct = float64(latest.Data.Features[name]) / float64(latest.Data.NumInstances) * 100
+		}
+		data = append(data, opts.BarData{Value: math.Round(pct*10) / 10})
+	}
+
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Feature Adoption",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "% of Installations",
+			NameLocation: "center",
+			NameGap:      30,
+			Max:          100,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "140",
+			Top:    "60",
+			Bottom: "60",
+		}),
+	)
+
+	bar.SetXAxis(names).
+		AddSeries("Enabled", data).
 		XYReversal()
 
 	return bar
@@ -835,50 +1920,54 @@ func getTopKeys(m map[string]uint64, n int) []string {
 	return result
 }
 
+// ErrNoData is returned when there are no summaries to build charts from
+var ErrNoData = errors.New("no data available")
+
 // ExportChartsJSON generates a JSON file with all chart configurations
 func ExportChartsJSON(outputDir string) error {
-	summaries, err := summary.GetSummaries()
-	if err != nil {
-		return err
-	}
-	// Exclude incomplete days (significant drops indicate incomplete data)
-	summaries = ExcludeIncompleteDays(summaries)
-	if len(summaries) == 0 {
+	jsonData, err := GenerateChartsJSON(time.Time{}, time.Time{})
+	if errors.Is(err, ErrNoData) {
 		log.Print("No data to export")
 		return nil
 	}
+	if err != nil {
+		return err
+	}
 
-	// Build all charts
-	versionsChart := buildVersionsChart(summaries)
-	versionsChart.Validate()
-
-	osChart := buildOSChart(summaries)
-	osChart.Validate()
-
-	playerTypesChart := buildPlayerTypesChart(summaries)
-	playerTypesChart.Validate()
-
-	playersChart := buildPlayersChart(summaries)
-	playersChart.Validate()
+	// Ensure output directory exists
+	if err := os.MkdirAll(outputDir, consts.DirPermissions); err != nil {
+		return err
+	}
 
-	playersPerInstallationChart := buildPlayersPerInstallationChart(summaries)
-	playersPerInstallationChart.Validate()
+	// Write to file
+	outputPath := filepath.Join(outputDir, consts.ChartsJSONFile)
+	if err := os.WriteFile(outputPath, jsonData, consts.FilePermissions); err != nil {
+		return err
+	}
 
-	tracksChart := buildTracksChart(summaries)
-	tracksChart.Validate()
+	log.Printf("Exported charts to %s", outputPath)
+	return nil
+}
 
-	albumsArtistsChart := buildAlbumsArtistsChart(summaries)
-	albumsArtistsChart.Validate()
+// GenerateChartsJSON builds the JSON document with all chart configurations, for summaries
+// between from and to (inclusive, zero values are unbounded). Returns ErrNoData if there are no summaries.
+func GenerateChartsJSON(from, to time.Time) ([]byte, error) {
+	summaries, err := loadChartSummaries(from, to)
+	if err != nil {
+		return nil, err
+	}
 
-	// Combine all charts into a single JSON array to preserve order
-	chartsData := []map[string]interface{}{
-		{"id": "versions", "options": versionsChart.JSON()},
-		{"id": "os", "options": osChart.JSON()},
-		{"id": "players", "options": playersChart.JSON()},
-		{"id": "playerTypes", "options": playerTypesChart.JSON()},
-		// {"id": "playersPerInstallation", "options": playersPerInstallationChart.JSON()},
-		{"id": "tracks", "options": tracksChart.JSON()},
-		{"id": "albumsArtists", "options": albumsArtistsChart.JSON()},
+	// Build all charts, in both themes. Options for the light theme are kept in "options" for
+	// compatibility with existing clients
+	light := buildChartEntries(summaries, consts.LightTheme)
+	dark := buildChartEntries(summaries, consts.DarkTheme)
+	chartsData := make([]map[string]interface{}, len(light))
+	for i := range light {
+		chartsData[i] = map[string]interface{}{
+			"id":          light[i].id,
+			"options":     light[i].options,
+			"darkOptions": dark[i].options,
+		}
 	}
 
 	// Get the most recent total instances count
@@ -891,26 +1980,208 @@ func ExportChartsJSON(outputDir string) error {
 	output := map[string]interface{}{
 		"totalInstances": totalInstances,
 		"lastUpdated":    time.Now().UTC().Format(time.RFC3339),
+		"anomalies":      anomaliesToJSON(DetectAnomalies(summaries)),
 		"charts":         chartsData,
 	}
 
 	// Marshal to JSON
-	jsonData, err := json.MarshalIndent(output, "", "  ")
+	return json.MarshalIndent(output, "", "  ")
+}
+
+// chartEntry is a chart's id and its echarts options, as exported in charts.json
+type chartEntry struct {
+	id      string
+	options map[string]interface{}
+}
+
+// exportableChart is implemented by all go-echarts chart types
+type exportableChart interface {
+	Validate()
+	JSON() map[string]interface{}
+}
+
+// chartDef defines an exported chart. Charts with a nil available func are always exported
+type chartDef struct {
+	id        string
+	build     func([]summary.SummaryRecord, consts.ChartTheme) exportableChart
+	available func([]summary.SummaryRecord) bool
+}
+
+func newChartDef[C exportableChart](id string, build func([]summary.SummaryRecord, consts.ChartTheme) C) chartDef {
+	return chartDef{id: id, build: func(s []summary.SummaryRecord, t consts.ChartTheme) exportableChart { return build(s, t) }}
+}
+
+// chartDefs lists all exported charts, in display order
+var chartDefs = []chartDef{
+	newChartDef("versions", buildVersionsChart),
+	newChartDef("versionAdoption", buildVersionAdoptionChart),
+	// Only when a stable release is known, from the releases file or the summaries
+	{
+		id: "latestRelease",
+		build: func(s []summary.SummaryRecord, t consts.ChartTheme) exportableChart {
+			return buildLatestReleaseChart(s, t)
+		},
+		available: func(summaries []summary.SummaryRecord) bool {
+			return len(stableReleases(summaries)) > 0
+		},
+	},
+	newChartDef("os", buildOSChart),
+	newChartDef("architectures", buildArchitecturesChart),
+	newChartDef("players", buildPlayersChart),
+	newChartDef("playerTypes", buildPlayerTypesChart),
+	newChartDef("growth", buildGrowthChart),
+	// Weekly and monthly active instances are only available in summaries generated after they were added
+	{
+		id: "activeInstances",
+		build: func(s []summary.SummaryRecord, t consts.ChartTheme) exportableChart {
+			return buildActiveInstancesChart(s, t)
+		},
+		available: func(summaries []summary.SummaryRecord) bool {
+			return summaries[len(summaries)-1].Data.MonthlyInstances > 0
+		},
+	},
+	newChartDef("newReturning", buildNewReturningChart),
+	// newChartDef("playersPerInstallation", buildPlayersPerInstallationChart),
+	newChartDef("usersPerInstallation", buildUsersPerInstallationChart),
+	newChartDef("tracks", buildTracksChart),
+	newChartDef("albumsArtists", buildAlbumsArtistsChart),
+	newChartDef("filesystems", buildFilesystemsChart),
+	// OS versions are only available in summaries generated after they were added
+	{
+		id: "osVersions",
+		build: func(s []summary.SummaryRecord, t consts.ChartTheme) exportableChart {
+			return buildOSVersionsChart(s, t)
+		},
+		available: func(summaries []summary.SummaryRecord) bool {
+			return len(summaries[len(summaries)-1].Data.OSVersions) > 0
+		},
+	},
+	// Host sizes are only available in summaries generated after they were added
+	{
+		id:    "hostSizes",
+		build: func(s []summary.SummaryRecord, t consts.ChartTheme) exportableChart { return buildHostSizesChart(s, t) },
+		available: func(summaries []summary.SummaryRecord) bool {
+			return len(summaries[len(summaries)-1].Data.CPUs) > 0
+		},
+	},
+	// Features are only available in summaries generated after they were added
+	{
+		id:    "features",
+		build: func(s []summary.SummaryRecord, t consts.ChartTheme) exportableChart { return buildFeaturesChart(s, t) },
+		available: func(summaries []summary.SummaryRecord) bool {
+			return len(summaries[len(summaries)-1].Data.Features) > 0
+		},
+	},
+	// Library totals are only available in summaries generated after they were added
+	{
+		id: "libraryTotals",
+		build: func(s []summary.SummaryRecord, t consts.ChartTheme) exportableChart {
+			return buildLibraryTotalsChart(s, t)
+		},
+		available: func(summaries []summary.SummaryRecord) bool {
+			return summaries[len(summaries)-1].Data.TotalTracks > 0
+		},
+	},
+	// Countries are only available when the server is configured with a GeoIP database
+	{
+		id:    "countries",
+		build: func(s []summary.SummaryRecord, t consts.ChartTheme) exportableChart { return buildCountriesChart(s, t) },
+		available: func(summaries []summary.SummaryRecord) bool {
+			return len(summaries[len(summaries)-1].Data.Countries) > 0
+		},
+	},
+}
+
+func (d chartDef) render(summaries []summary.SummaryRecord, theme consts.ChartTheme) map[string]interface{} {
+	chart := d.build(summaries, theme)
+	chart.Validate()
+	return chart.JSON()
+}
+
+// buildChartEntries builds all exported charts with the given theme, in display order
+func buildChartEntries(summaries []summary.SummaryRecord, theme consts.ChartTheme) []chartEntry {
+	var entries []chartEntry
+	for _, d := range chartDefs {
+		if d.available != nil && !d.available(summaries) {
+			continue
+		}
+		entries = append(entries, chartEntry{d.id, d.render(summaries, theme)})
+	}
+	return entries
+}
+
+// ErrUnknownChart is returned when requesting a chart that does not exist or is not available
+var ErrUnknownChart = errors.New("unknown chart")
+
+// ThemeByName returns the chart theme with the given name, defaulting to the light theme
+func ThemeByName(name string) consts.ChartTheme {
+	if name == consts.DarkTheme.Name {
+		return consts.DarkTheme
+	}
+	return consts.LightTheme
+}
+
+// GenerateChartJSON builds the echarts options of a single chart, for summaries between from and to
+// (inclusive, zero values are unbounded). Returns ErrUnknownChart if there is no chart with that id,
+// or ErrNoData if there are no summaries.
+func GenerateChartJSON(id string, theme consts.ChartTheme, from, to time.Time) ([]byte, error) {
+	options, err := chartOptions(id, theme, from, to)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return json.Marshal(options)
+}
 
-	// Ensure output directory exists
-	if err := os.MkdirAll(outputDir, consts.DirPermissions); err != nil {
-		return err
+// chartOptions builds the echarts options of a single chart. See GenerateChartJSON
+func chartOptions(id string, theme consts.ChartTheme, from, to time.Time) (map[string]interface{}, error) {
+	idx := slices.IndexFunc(chartDefs, func(d chartDef) bool { return d.id == id })
+	if idx < 0 {
+		return nil, ErrUnknownChart
+	}
+	summaries, err := loadChartSummaries(from, to)
+	if err != nil {
+		return nil, err
+	}
+	d := chartDefs[idx]
+	if d.available != nil && !d.available(summaries) {
+		return nil, ErrUnknownChart
 	}
+	return d.render(summaries, theme), nil
+}
 
-	// Write to file
-	outputPath := filepath.Join(outputDir, consts.ChartsJSONFile)
-	if err := os.WriteFile(outputPath, jsonData, consts.FilePermissions); err != nil {
-		return err
+// loadChartSummaries returns the summaries used for charts: complete or provisional days between from and to.
+// Returns ErrNoData if there are none
+func loadChartSummaries(from, to time.Time) ([]summary.SummaryRecord, error) {
+	summaries, err := CachedSummaries()
+	if err != nil {
+		return nil, err
+	}
+	// Exclude incomplete days (significant drops indicate incomplete data)
+	summaries = ExcludeIncompleteDays(summaries)
+	summaries = summary.FilterSummaries(summaries, from, to)
+	if len(summaries) == 0 {
+		return nil, ErrNoData
 	}
+	return summaries, nil
+}
 
-	log.Printf("Exported charts to %s", outputPath)
-	return nil
+// LatestSummary returns the summary of the most recent complete day: today's summary is still being
+// collected, and trailing provisional or incomplete days are excluded (see ExcludeIncompleteDays).
+// Returns ErrNoData if there is none
+func LatestSummary() (summary.SummaryRecord, error) {
+	summaries, err := CachedSummaries()
+	if err != nil {
+		return summary.SummaryRecord{}, err
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	end := len(summaries)
+	for end > 0 && !summaries[end-1].Time.Before(today) {
+		end--
+	}
+	summaries = ExcludeIncompleteDays(summaries[:end])
+	summaries = summaries[:provisionalFrom(summaries)]
+	if len(summaries) == 0 {
+		return summary.SummaryRecord{}, ErrNoData
+	}
+	return summaries[len(summaries)-1], nil
 }
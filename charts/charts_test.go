@@ -2,13 +2,17 @@ package charts
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/navidrome/insights/consts"
 	"github.com/navidrome/insights/summary"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -38,6 +42,45 @@ var _ = Describe("Charts", func() {
 		Expect(os.Setenv("DATA_FOLDER", originalDataFolder)).To(Succeed())
 	})
 
+	Describe("ConfigFromEnv", func() {
+		AfterEach(func() {
+			Configure(DefaultConfig())
+		})
+
+		It("uses the defaults when not configured", func() {
+			cfg, err := ConfigFromEnv()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg).To(Equal(DefaultConfig()))
+		})
+
+		It("reads the thresholds from the env vars", func() {
+			GinkgoT().Setenv("CHARTS_INCOMPLETE_THRESHOLD", "0.5")
+			GinkgoT().Setenv("CHARTS_TOP_VERSIONS", "5")
+			GinkgoT().Setenv("CHARTS_VERSION_SELECTION_DAYS", "30")
+			GinkgoT().Setenv("CHARTS_PLAYER_GROUP_THRESHOLD", "0.01")
+			GinkgoT().Setenv("CHARTS_GROUP_PATCHES", "true")
+			cfg, err := ConfigFromEnv()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg).To(Equal(Config{IncompleteThreshold: 0.5, TopVersionsCount: 5, VersionSelectionDays: 30, PlayerGroupThreshold: 0.01, GroupPatchVersions: true}))
+		})
+
+		It("rejects invalid values", func() {
+			GinkgoT().Setenv("CHARTS_TOP_VERSIONS", "0")
+			_, err := ConfigFromEnv()
+			Expect(err).To(MatchError(ContainSubstring("CHARTS_TOP_VERSIONS")))
+		})
+
+		It("applies the configured incomplete threshold", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{NumInstances: 100}},
+				{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Data: summary.Summary{NumInstances: 70}},
+			}
+			Expect(ExcludeIncompleteDays(summaries)).To(HaveLen(1))
+			Configure(Config{IncompleteThreshold: 0.5})
+			Expect(ExcludeIncompleteDays(summaries)).To(HaveLen(2))
+		})
+	})
+
 	Describe("ExcludeIncompleteDays", func() {
 		It("returns nil when summaries are empty", func() {
 			Expect(ExcludeIncompleteDays(nil)).To(BeNil())
@@ -72,6 +115,158 @@ var _ = Describe("Charts", func() {
 			Expect(result).To(HaveLen(3))
 			Expect(result[2].Data.NumInstances).To(Equal(int64(1100)))
 		})
+
+		It("keeps the provisional days once days are finalized", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{NumInstances: 1000, Finalized: true}},
+				{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Data: summary.Summary{NumInstances: 100}},
+			}
+			Expect(ExcludeIncompleteDays(summaries)).To(HaveLen(2))
+		})
+	})
+
+	Describe("provisional days", func() {
+		var summaries []summary.SummaryRecord
+
+		BeforeEach(func() {
+			summaries = []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Finalized: true, Versions: map[string]uint64{"0.54.0": 10}, PlayerTypes: map[string]uint64{"NavidromeUI": 4}}},
+				{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Finalized: true, Versions: map[string]uint64{"0.54.0": 20}, PlayerTypes: map[string]uint64{"NavidromeUI": 8}}},
+				{Time: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.0": 5}, PlayerTypes: map[string]uint64{"NavidromeUI": 2}}},
+			}
+		})
+
+		It("finds the trailing days not finalized", func() {
+			Expect(provisionalFrom(summaries)).To(Equal(2))
+			summaries[2].Data.Finalized = true
+			Expect(provisionalFrom(summaries)).To(Equal(3))
+		})
+
+		It("considers all days final when none is finalized", func() {
+			summaries[0].Data.Finalized = false
+			summaries[1].Data.Finalized = false
+			Expect(provisionalFrom(summaries)).To(Equal(3))
+		})
+
+		It("splits the provisional values of a series, connected to the last final day", func() {
+			data := []opts.LineData{{Value: uint64(10)}, {Value: uint64(20)}, {Value: uint64(5)}}
+			final, provisional := splitProvisional(data, 2)
+			Expect(final).To(Equal([]opts.LineData{{Value: uint64(10)}, {Value: uint64(20)}, {}}))
+			Expect(provisional).To(Equal([]opts.LineData{{}, {Value: uint64(20)}, {Value: uint64(5)}}))
+
+			final, provisional = splitProvisional(data, 3)
+			Expect(final).To(Equal(data))
+			Expect(provisional).To(BeNil())
+		})
+
+		It("marks the provisional days on the installations and clients charts", func() {
+			versions := buildVersionsChart(summaries, consts.LightTheme)
+			Expect(versions.MultiSeries[0].Data).To(Equal([]opts.LineData{{Value: uint64(10)}, {Value: uint64(20)}, {}}))
+			last := versions.MultiSeries[len(versions.MultiSeries)-1]
+			Expect(last.Name).To(Equal("All (provisional)"))
+			Expect(last.Data).To(Equal([]opts.LineData{{}, {Value: uint64(20)}, {Value: uint64(5)}}))
+			Expect(versions.MultiSeries[0].MarkAreas.Data).To(HaveLen(1))
+
+			players := buildPlayersChart(summaries, consts.LightTheme)
+			Expect(players.MultiSeries[2].Name).To(Equal("Total Clients (provisional)"))
+			Expect(players.MultiSeries[0].MarkAreas.Data).To(HaveLen(1))
+		})
+
+		It("has no provisional series when all days are final", func() {
+			summaries[2].Data.Finalized = true
+			versions := buildVersionsChart(summaries, consts.LightTheme)
+			Expect(versions.MultiSeries[len(versions.MultiSeries)-1].Name).To(Equal("Others"))
+		})
+	})
+
+	Describe("compareVersions", func() {
+		It("sorts versions newest first, by semantic version", func() {
+			versions := []string{"dev", "0.9.0 (abcdef12)", "0.54.10 (11111111)", "0.55.0-SNAPSHOT (22222222)", "0.54.2 (33333333)", "0.55.0 (44444444)", "1.0.0 (55555555)"}
+			slices.SortFunc(versions, compareVersions)
+			Expect(versions).To(Equal([]string{"1.0.0 (55555555)", "0.55.0 (44444444)", "0.55.0-SNAPSHOT (22222222)", "0.54.10 (11111111)", "0.54.2 (33333333)", "0.9.0 (abcdef12)", "dev"}))
+		})
+
+		It("sorts patch groups", func() {
+			versions := []string{"0.9.x", "0.54.x", "dev", "0.55.x"}
+			slices.SortFunc(versions, compareVersions)
+			Expect(versions).To(Equal([]string{"0.55.x", "0.54.x", "0.9.x", "dev"}))
+		})
+	})
+
+	Describe("groupPatchVersions", func() {
+		It("sums the builds and patch releases of each minor version", func() {
+			grouped := groupPatchVersions(map[string]uint64{
+				"0.54.1 (11111111)": 1, "0.54.2 (22222222)": 2, "0.54.2 (33333333)": 3, "0.55.0-SNAPSHOT (44444444)": 4, "dev": 5,
+			})
+			Expect(grouped).To(Equal(map[string]uint64{"0.54.x": 6, "0.55.x": 4, "dev": 5}))
+		})
+
+		It("groups the series of the versions chart when configured", func() {
+			Configure(Config{TopVersionsCount: 15, VersionSelectionDays: 60, GroupPatchVersions: true})
+			DeferCleanup(Configure, DefaultConfig())
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.1 (11111111)": 1, "0.54.2 (22222222)": 2, "0.55.0 (33333333)": 3}}},
+			}
+			chart := buildVersionsChart(summaries, consts.LightTheme)
+			var names []string
+			for _, series := range chart.MultiSeries {
+				names = append(names, series.Name)
+			}
+			Expect(names).To(ContainElements("0.55.x", "0.54.x"))
+			Expect(names).NotTo(ContainElement("0.54.1 (11111111)"))
+			Expect(slices.Index(names, "0.55.x")).To(BeNumerically("<", slices.Index(names, "0.54.x")))
+		})
+	})
+
+	Describe("releaseAdoption", func() {
+		day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC) }
+		summaries := []summary.SummaryRecord{
+			{Time: day(1), Data: summary.Summary{Versions: map[string]uint64{"0.54.1 (11111111)": 10}}},
+			{Time: day(2), Data: summary.Summary{Versions: map[string]uint64{"0.54.1 (11111111)": 9, "0.54.2 (22222222)": 1}}},
+			{Time: day(3), Data: summary.Summary{Versions: map[string]uint64{"0.54.1 (11111111)": 5, "0.54.2 (22222222)": 4, "0.54.2 (33333333)": 1}}},
+		}
+		AfterEach(func() {
+			setReleases(nil)
+		})
+
+		It("uses the releases detected in the summaries without a releases file", func() {
+			releases := stableReleases(summaries)
+			Expect(releaseAdoption(releases, summaries[0]).Release).To(BeEmpty())
+			Expect(releaseAdoption(releases, summaries[1])).To(Equal(ReleaseAdoption{Date: day(2), Release: "0.54.2", Percent: 10}))
+			Expect(releaseAdoption(releases, summaries[2])).To(Equal(ReleaseAdoption{Date: day(3), Release: "0.54.2", Percent: 50}))
+		})
+
+		It("uses the latest stable release of the releases file published by each day", func() {
+			setReleases([]releaseMark{
+				{Date: day(1), Version: "v0.54.1"},
+				{Date: day(2), Version: "0.55.0-rc1"},
+				{Date: day(3), Version: "0.54.2"},
+			})
+			releases := stableReleases(summaries)
+			Expect(releaseAdoption(releases, summaries[0])).To(Equal(ReleaseAdoption{Date: day(1), Release: "0.54.1", Percent: 100}))
+			Expect(releaseAdoption(releases, summaries[1])).To(Equal(ReleaseAdoption{Date: day(2), Release: "0.54.1", Percent: 90}))
+			Expect(releaseAdoption(releases, summaries[2])).To(Equal(ReleaseAdoption{Date: day(3), Release: "0.54.2", Percent: 50}))
+		})
+
+		It("charts the adoption, with gaps before the first known release", func() {
+			chart := buildLatestReleaseChart(summaries, consts.LightTheme)
+			Expect(chart.MultiSeries[0].Data).To(Equal([]opts.LineData{{}, {Name: "0.54.2", Value: 10.0}, {Name: "0.54.2", Value: 50.0}}))
+		})
+	})
+
+	Describe("buildActiveInstancesChart", func() {
+		It("plots the daily, weekly and monthly active instances, with gaps before they were counted", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{NumInstances: 10, Finalized: true}},
+				{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Data: summary.Summary{NumInstances: 12, WeeklyInstances: 15, MonthlyInstances: 20, Finalized: true}},
+			}
+			chart := buildActiveInstancesChart(summaries, consts.LightTheme)
+			Expect(chart.MultiSeries).To(HaveLen(3))
+			Expect(chart.MultiSeries[0].Data).To(Equal([]opts.LineData{{Value: int64(10)}, {Value: int64(12)}}))
+			Expect(chart.MultiSeries[1].Data).To(Equal([]opts.LineData{{}, {Value: int64(15)}}))
+			Expect(chart.MultiSeries[2].Name).To(Equal("Monthly"))
+			Expect(chart.MultiSeries[2].Data).To(Equal([]opts.LineData{{}, {Value: int64(20)}}))
+		})
 	})
 
 	Describe("buildTimeSeriesData", func() {
@@ -214,6 +409,118 @@ var _ = Describe("Charts", func() {
 			Expect(summaries[0].Data.NumInstances).To(Equal(int64(100)))
 			Expect(summaries[1].Data.NumInstances).To(Equal(int64(200)))
 		})
+
+		Describe("index", func() {
+			var indexPath string
+
+			BeforeEach(func() {
+				indexPath = filepath.Join(tempDir, "summaries", "index.json")
+			})
+
+			It("builds the index on first read and keeps it updated on save", func() {
+				Expect(summary.SaveSummary(summary.Summary{NumInstances: 100}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+				Expect(indexPath).NotTo(BeAnExistingFile())
+
+				summaries, err := summary.GetSummaries()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(summaries).To(HaveLen(1))
+				Expect(indexPath).To(BeAnExistingFile())
+
+				Expect(summary.SaveSummary(summary.Summary{NumInstances: 150}, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))).To(Succeed())
+				data, err := os.ReadFile(indexPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(data)).To(ContainSubstring("2025-01-02"))
+
+				summaries, err = summary.GetSummaries()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(summaries).To(HaveLen(2))
+				Expect(summaries[1].Data.NumInstances).To(Equal(int64(150)))
+			})
+
+			It("falls back to a full walk when an indexed file is missing", func() {
+				Expect(summary.SaveSummary(summary.Summary{NumInstances: 100}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+				Expect(summary.SaveSummary(summary.Summary{NumInstances: 150}, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))).To(Succeed())
+				_, err := summary.GetSummaries()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(os.Remove(summary.SummaryFilePath(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))).To(Succeed())
+
+				summaries, err := summary.GetSummaries()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(summaries).To(HaveLen(1))
+				Expect(summaries[0].Data.NumInstances).To(Equal(int64(150)))
+			})
+
+			It("falls back to a full walk when the index is malformed", func() {
+				Expect(summary.SaveSummary(summary.Summary{NumInstances: 100}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+				Expect(os.WriteFile(indexPath, []byte("not json"), 0600)).To(Succeed())
+
+				summaries, err := summary.GetSummaries()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(summaries).To(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("CachedSummaries", func() {
+		It("serves cached summaries until a new summary is saved", func() {
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 100}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+			summaries, err := CachedSummaries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summaries).To(HaveLen(1))
+
+			// Files written behind the store's back are not seen until the cache is invalidated
+			Expect(os.Remove(summary.SummaryFilePath(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))).To(Succeed())
+			summaries, err = CachedSummaries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summaries).To(HaveLen(1))
+
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 150}, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))).To(Succeed())
+			summaries, err = CachedSummaries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summaries).To(HaveLen(1))
+			Expect(summaries[0].Data.NumInstances).To(Equal(int64(150)))
+		})
+
+		It("scales the counts of sampled days", func() {
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 100, SamplingRate: 0.1}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+			summaries, err := CachedSummaries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summaries[0].Data.NumInstances).To(Equal(int64(1000)))
+			Expect(summaries[0].Data.Estimated).To(BeTrue())
+		})
+
+		It("reloads after explicit invalidation", func() {
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 100}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+			_, err := CachedSummaries()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(os.RemoveAll(filepath.Join(tempDir, "summaries"))).To(Succeed())
+			InvalidateSummariesCache()
+			summaries, err := CachedSummaries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summaries).To(BeEmpty())
+		})
+	})
+
+	Describe("LatestSummary", func() {
+		It("returns ErrNoData when there are no summaries", func() {
+			_, err := LatestSummary()
+			Expect(err).To(MatchError(ErrNoData))
+		})
+
+		It("skips today and trailing incomplete days", func() {
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 1000}, today.AddDate(0, 0, -3))).To(Succeed())
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 1100}, today.AddDate(0, 0, -2))).To(Succeed())
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 500}, today.AddDate(0, 0, -1))).To(Succeed())
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 1200}, today)).To(Succeed())
+
+			latest, err := LatestSummary()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(latest.Time).To(Equal(today.AddDate(0, 0, -2)))
+			Expect(latest.Data.NumInstances).To(Equal(int64(1100)))
+		})
 	})
 
 	Describe("ChartsHandler", func() {
@@ -265,7 +572,7 @@ var _ = Describe("Charts", func() {
 
 	Describe("buildOSChart", func() {
 		It("returns nil when no summaries exist", func() {
-			chart := buildOSChart([]summary.SummaryRecord{})
+			chart := buildOSChart([]summary.SummaryRecord{}, consts.LightTheme)
 			Expect(chart).To(BeNil())
 		})
 
@@ -281,14 +588,14 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildOSChart(summaries)
+			chart := buildOSChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 		})
 	})
 
 	Describe("buildPlayerTypesChart", func() {
 		It("returns nil when no summaries exist", func() {
-			chart := buildPlayerTypesChart([]summary.SummaryRecord{})
+			chart := buildPlayerTypesChart([]summary.SummaryRecord{}, consts.LightTheme)
 			Expect(chart).To(BeNil())
 		})
 
@@ -304,7 +611,7 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildPlayerTypesChart(summaries)
+			chart := buildPlayerTypesChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 		})
 
@@ -336,7 +643,7 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildPlayerTypesChart(summaries)
+			chart := buildPlayerTypesChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 
 			// Marshal chart to JSON and verify content
@@ -360,6 +667,84 @@ var _ = Describe("Charts", func() {
 		})
 	})
 
+	Describe("OtherPlayerTypes", func() {
+		It("returns the player types grouped into Others, with their counts", func() {
+			// Total: 1000, threshold: 2 (0.2%)
+			others := OtherPlayerTypes(map[string]uint64{"PlayerA": 990, "PlayerB": 2, "PlayerC": 1, "PlayerD": 7})
+			Expect(others).To(Equal(map[string]uint64{"PlayerC": 1}))
+		})
+
+		It("returns an empty map when there are no player types", func() {
+			Expect(OtherPlayerTypes(nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("buildCountriesChart", func() {
+		It("returns nil when no summaries exist", func() {
+			chart := buildCountriesChart([]summary.SummaryRecord{}, consts.LightTheme)
+			Expect(chart).To(BeNil())
+		})
+
+		It("maps the installations by ISO code on the world map", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Now(), Data: summary.Summary{Countries: map[string]uint64{"FR": 10, "GB": 5, "BR": 1}}},
+			}
+
+			chart := buildCountriesChart(summaries, consts.LightTheme)
+			Expect(chart).NotTo(BeNil())
+
+			jsonBytes, err := json.Marshal(chart.JSON())
+			Expect(err).NotTo(HaveOccurred())
+			var options struct {
+				VisualMap []struct {
+					Max float64 `json:"max"`
+				} `json:"visualMap"`
+				Series []struct {
+					Type    string            `json:"type"`
+					Map     string            `json:"map"`
+					NameMap map[string]string `json:"nameMap"`
+					Data    []struct {
+						Name  string  `json:"name"`
+						Value float64 `json:"value"`
+					} `json:"data"`
+				} `json:"series"`
+			}
+			Expect(json.Unmarshal(jsonBytes, &options)).To(Succeed())
+
+			Expect(options.VisualMap).To(HaveLen(1))
+			Expect(options.VisualMap[0].Max).To(Equal(10.0))
+			Expect(options.Series).To(HaveLen(1))
+			series := options.Series[0]
+			Expect(series.Type).To(Equal("map"))
+			Expect(series.Map).To(Equal(consts.WorldMapName))
+			Expect(series.Data).To(HaveLen(3))
+			Expect(series.Data[0].Name).To(Equal("BR"))
+			Expect(series.NameMap).To(HaveKeyWithValue("250", "FR"))
+			Expect(series.NameMap).To(HaveKeyWithValue("826", "GB"))
+			Expect(series.NameMap).To(HaveKeyWithValue("076", "BR"))
+		})
+
+		It("draws the top countries as bars in images", func() {
+			countries := map[string]uint64{}
+			for i := 0; i < 25; i++ {
+				countries[fmt.Sprintf("C%02d", i)] = uint64(100 - i)
+			}
+			summaries := []summary.SummaryRecord{
+				{Time: time.Now(), Data: summary.Summary{Countries: countries}},
+			}
+
+			opt, err := imageOptions(buildCountriesChart(summaries, consts.LightTheme).JSON())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opt.SeriesList).To(HaveLen(1))
+			Expect(opt.SeriesList[0].Data).To(HaveLen(consts.TopCountriesCount))
+			Expect(opt.YAxisOptions).To(HaveLen(1))
+			names := opt.YAxisOptions[0].Data
+			Expect(names).To(HaveLen(consts.TopCountriesCount))
+			Expect(names[0]).To(Equal("C19"))
+			Expect(names[len(names)-1]).To(Equal("C00"))
+		})
+	})
+
 	Describe("buildPlayersChart", func() {
 		It("returns line chart with player totals over time", func() {
 			summaries := []summary.SummaryRecord{
@@ -373,7 +758,7 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildPlayersChart(summaries)
+			chart := buildPlayersChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 		})
 
@@ -385,14 +770,275 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildPlayersChart(summaries)
+			chart := buildPlayersChart(summaries, consts.LightTheme)
+			Expect(chart).NotTo(BeNil())
+		})
+	})
+
+	Describe("weekOverWeekGrowth", func() {
+		It("computes growth against the value 7 days before", func() {
+			var summaries []summary.SummaryRecord
+			baseDate := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i := 0; i < 8; i++ {
+				summaries = append(summaries, summary.SummaryRecord{
+					Time: baseDate.AddDate(0, 0, i),
+					Data: summary.Summary{NumInstances: int64(100 + i*10)},
+				})
+			}
+			ts := buildTimeSeriesData(summaries)
+			data := weekOverWeekGrowth(ts, func(s summary.Summary) uint64 { return uint64(s.NumInstances) })
+			Expect(data).To(HaveLen(8))
+			for i := 0; i < 7; i++ {
+				Expect(data[i].Value).To(BeNil())
+			}
+			// Day 8 has 170 vs Day 1's 100
+			Expect(data[7].Value).To(Equal(70.0))
+		})
+
+		It("returns nil when the previous week has no data", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{NumInstances: 100}},
+				{Time: time.Date(2025, 1, 9, 0, 0, 0, 0, time.UTC), Data: summary.Summary{NumInstances: 120}},
+			}
+			ts := buildTimeSeriesData(summaries)
+			data := weekOverWeekGrowth(ts, func(s summary.Summary) uint64 { return uint64(s.NumInstances) })
+			Expect(data[8].Value).To(BeNil())
+		})
+	})
+
+	Describe("movingAverage", func() {
+		It("averages the available values of the window, keeping gaps", func() {
+			data := []opts.LineData{{Value: uint64(10)}, {Value: uint64(20)}, {Value: nil}, {Value: uint64(30)}, {Value: uint64(40)}}
+			avg := movingAverage(data, 3)
+			Expect(avg).To(Equal([]opts.LineData{{Value: 10.0}, {Value: 15.0}, {Value: nil}, {Value: 25.0}, {Value: 35.0}}))
+		})
+
+		It("adds the average series to the installations and clients charts", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.0": 10}, PlayerTypes: map[string]uint64{"NavidromeUI": 4}}},
+				{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.0": 20}, PlayerTypes: map[string]uint64{"NavidromeUI": 8}}},
+			}
+			versions := buildVersionsChart(summaries, consts.LightTheme)
+			Expect(versions.MultiSeries[1].Name).To(Equal("All (7-day average)"))
+			Expect(versions.MultiSeries[1].Data).To(Equal([]opts.LineData{{Value: 10.0}, {Value: 15.0}}))
+			players := buildPlayersChart(summaries, consts.LightTheme)
+			Expect(players.MultiSeries[1].Name).To(Equal("Total Clients (7-day average)"))
+			Expect(players.MultiSeries[1].Data).To(Equal([]opts.LineData{{Value: 4.0}, {Value: 6.0}}))
+		})
+	})
+
+	Describe("buildArchitecturesChart", func() {
+		It("sums the OS counts by architecture", func() {
+			Expect(osArchitectures(map[string]uint64{
+				"Linux - amd64": 10, "Linux (containerized) - amd64": 5, "Linux - arm64": 3, "macOS - arm64": 2, "Windows": 1,
+			})).To(Equal(map[string]uint64{"amd64": 15, "arm64": 5, "unknown": 1}))
+		})
+
+		It("stacks a series per architecture, sorted by the last day's count", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{OS: map[string]uint64{"Linux - amd64": 10, "Linux - arm64": 2}}},
+				{Time: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), Data: summary.Summary{OS: map[string]uint64{"Linux - amd64": 8, "Linux - arm64": 9, "Linux - riscv64": 1}}},
+			}
+			chart := buildArchitecturesChart(summaries, consts.LightTheme)
+			Expect(chart.MultiSeries).To(HaveLen(3))
+			Expect(chart.MultiSeries[0].Name).To(Equal("arm64"))
+			Expect(chart.MultiSeries[0].Data).To(Equal([]opts.LineData{{Value: uint64(2)}, {}, {Value: uint64(9)}}))
+			Expect(chart.MultiSeries[1].Name).To(Equal("amd64"))
+			Expect(chart.MultiSeries[2].Name).To(Equal("riscv64"))
+			Expect(chart.MultiSeries[2].Data).To(Equal([]opts.LineData{{Value: uint64(0)}, {}, {Value: uint64(1)}}))
+			Expect(chart.MultiSeries[0].MarkAreas.Data).To(HaveLen(1))
+		})
+	})
+
+	Describe("buildLibraryTotalsChart", func() {
+		It("plots the library totals, without data for days summarized before they were added", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{NumInstances: 5}},
+				{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Data: summary.Summary{TotalTracks: 1000, TotalAlbums: 100, TotalArtists: 50}},
+				{Time: time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC), Data: summary.Summary{TotalTracks: 1200, TotalAlbums: 110, TotalArtists: 55}},
+			}
+			chart := buildLibraryTotalsChart(summaries, consts.LightTheme)
+			Expect(chart.MultiSeries).To(HaveLen(3))
+			Expect(chart.MultiSeries[0].Name).To(Equal("Tracks"))
+			Expect(chart.MultiSeries[0].Data).To(Equal([]opts.LineData{{}, {Value: int64(1000)}, {}, {Value: int64(1200)}}))
+			Expect(chart.MultiSeries[2].Name).To(Equal("Artists"))
+			Expect(chart.MultiSeries[2].Data).To(Equal([]opts.LineData{{}, {Value: int64(50)}, {}, {Value: int64(55)}}))
+			Expect(chart.MultiSeries[0].MarkAreas.Data).To(HaveLen(1))
+		})
+	})
+
+	Describe("buildVersionAdoptionChart", func() {
+		It("plots the share of each recent release by days since release", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.0 (aaaaaaaa)": 100}}},
+				{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.0 (aaaaaaaa)": 90, "0.54.1 (bbbbbbbb)": 10}}},
+				{Time: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.0 (aaaaaaaa)": 50, "0.54.1 (bbbbbbbb)": 40, "0.54.1 (cccccccc)": 10}}},
+			}
+
+			chart := buildVersionAdoptionChart(summaries, consts.LightTheme)
+			Expect(chart).NotTo(BeNil())
+			Expect(chart.MultiSeries).To(HaveLen(1))
+			Expect(chart.MultiSeries[0].Name).To(Equal("0.54.1"))
+
+			data := chart.MultiSeries[0].Data.([]opts.LineData)
+			Expect(data).To(HaveLen(2))
+			Expect(data[0].Value).To(Equal(10.0))
+			Expect(data[1].Value).To(Equal(50.0))
+		})
+
+		It("handles summaries without new releases", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"dev": 1}}},
+			}
+			chart := buildVersionAdoptionChart(summaries, consts.LightTheme)
+			Expect(chart).NotTo(BeNil())
+			Expect(chart.MultiSeries).To(BeEmpty())
+		})
+	})
+
+	Describe("buildNewReturningChart", func() {
+		It("stacks returning and new instances, with churned below the axis", func() {
+			summaries := []summary.SummaryRecord{
+				{
+					Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+					Data: summary.Summary{NumInstances: 100, NewInstances: 10, ChurnedInstances: 5},
+				},
+				{
+					Time: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC),
+					Data: summary.Summary{NumInstances: 110, NewInstances: 15, ChurnedInstances: 3},
+				},
+			}
+
+			chart := buildNewReturningChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
+			Expect(chart.MultiSeries).To(HaveLen(3))
+
+			returning := chart.MultiSeries[0].Data.([]opts.BarData)
+			Expect(returning[0].Value).To(Equal(int64(90)))
+			Expect(returning[1].Value).To(BeNil())
+			Expect(returning[2].Value).To(Equal(int64(95)))
+			churned := chart.MultiSeries[2].Data.([]opts.BarData)
+			Expect(churned[0].Value).To(Equal(int64(-5)))
+		})
+	})
+
+	Describe("detectReleases", func() {
+		It("returns the first date each new minor release was reported", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.0 (aaaaaaaa)": 10}}},
+				{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.1 (bbbbbbbb)": 10}}},
+				{Time: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.55.0 (cccccccc)": 1}}},
+				{Time: time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.55.0 (cccccccc)": 5}}},
+			}
+			releases := detectReleases(summaries, minorReleaseRegex)
+			Expect(releases).To(HaveLen(1))
+			Expect(releases[0].Version).To(Equal("0.55.0"))
+			Expect(releases[0].Date).To(Equal(time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)))
+		})
+
+		It("returns nil for empty summaries", func() {
+			Expect(detectReleases(nil, minorReleaseRegex)).To(BeNil())
+		})
+	})
+
+	Describe("LoadReleases", func() {
+		summaries := []summary.SummaryRecord{
+			{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.54.0 (aaaaaaaa)": 10}}},
+			{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.55.0 (cccccccc)": 5}}},
+			{Time: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), Data: summary.Summary{Versions: map[string]uint64{"0.55.0 (cccccccc)": 5}}},
+		}
+
+		AfterEach(func() {
+			setReleases(nil)
+		})
+
+		It("annotates the detected minor releases when there is no releases file", func() {
+			n, err := LoadReleases()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(BeZero())
+			Expect(chartReleases(summaries)).To(Equal([]releaseMark{
+				{Date: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Version: "0.55.0"},
+			}))
+		})
+
+		It("annotates the releases of the file within the summaries date range", func() {
+			file := `[{"date": "2025-01-03", "version": "0.55.1"}, {"date": "2024-12-01", "version": "0.54.0"}, {"date": "2025-01-02", "version": "0.55.0"}]`
+			Expect(os.WriteFile(filepath.Join(tempDir, consts.ReleasesFile), []byte(file), 0600)).To(Succeed())
+			n, err := LoadReleases()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(3))
+			Expect(chartReleases(summaries)).To(Equal([]releaseMark{
+				{Date: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), Version: "0.55.0"},
+				{Date: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), Version: "0.55.1"},
+			}))
+		})
+
+		It("keeps the current releases when the file is invalid", func() {
+			Expect(os.WriteFile(filepath.Join(tempDir, consts.ReleasesFile), []byte(`[{"date": "2025-01-02", "version": "0.55.0"}]`), 0600)).To(Succeed())
+			_, err := LoadReleases()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(tempDir, consts.ReleasesFile), []byte(`[{"date": "Jan 2", "version": "0.55.0"}]`), 0600)).To(Succeed())
+			_, err = LoadReleases()
+			Expect(err).To(MatchError(ContainSubstring("invalid date")))
+			Expect(currentReleases()).To(HaveLen(1))
+		})
+
+		It("adds the release annotations to the installations and clients charts", func() {
+			versions := buildVersionsChart(summaries, consts.LightTheme)
+			Expect(versions.MultiSeries[0].MarkLines).NotTo(BeNil())
+			Expect(versions.MultiSeries[0].MarkLines.Data).To(HaveLen(1))
+			players := buildPlayersChart(summaries, consts.LightTheme)
+			Expect(players.MultiSeries[0].MarkLines).NotTo(BeNil())
+			Expect(players.MultiSeries[0].MarkLines.Data).To(HaveLen(1))
+		})
+	})
+
+	Describe("DetectAnomalies", func() {
+		// dailyCounts creates one summary per day starting on Jan 1st, 2025
+		dailyCounts := func(counts ...int64) []summary.SummaryRecord {
+			var summaries []summary.SummaryRecord
+			for i, n := range counts {
+				summaries = append(summaries, summary.SummaryRecord{
+					Time: time.Date(2025, 1, 1+i, 0, 0, 0, 0, time.UTC),
+					Data: summary.Summary{NumInstances: n},
+				})
+			}
+			return summaries
+		}
+
+		It("returns nil for a steady series", func() {
+			Expect(DetectAnomalies(dailyCounts(1000, 1010, 995, 1005, 1000, 1012, 998, 1003, 1007, 1001))).To(BeNil())
+		})
+
+		It("flags drops and spikes from the rolling mean", func() {
+			anomalies := DetectAnomalies(dailyCounts(1000, 1010, 995, 1005, 1000, 1012, 998, 600, 1003, 1500, 1001))
+			Expect(anomalies).To(HaveLen(2))
+			Expect(anomalies[0].Date).To(Equal(time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)))
+			Expect(anomalies[0].Kind()).To(Equal("drop"))
+			Expect(anomalies[0].Instances).To(Equal(int64(600)))
+			Expect(anomalies[0].Deviation).To(BeNumerically("~", -40, 0.5))
+			Expect(anomalies[1].Date).To(Equal(time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)))
+			Expect(anomalies[1].Kind()).To(Equal("spike"))
+		})
+
+		It("does not evaluate days without enough history", func() {
+			Expect(DetectAnomalies(dailyCounts(1000, 1010, 995, 1005, 1000, 500))).To(BeNil())
+		})
+
+		It("ignores small deviations in very stable series", func() {
+			Expect(DetectAnomalies(dailyCounts(1000, 1000, 1000, 1000, 1000, 1000, 1000, 1020))).To(BeNil())
+		})
+
+		It("skips sampled days", func() {
+			summaries := dailyCounts(1000, 1010, 995, 1005, 1000, 1012, 998, 100, 1003)
+			summaries[7].Data.SamplingRate = 0.1
+			Expect(DetectAnomalies(summaries)).To(BeNil())
 		})
 	})
 
 	Describe("buildPlayersPerInstallationChart", func() {
 		It("returns nil when no summaries exist", func() {
-			chart := buildPlayersPerInstallationChart([]summary.SummaryRecord{})
+			chart := buildPlayersPerInstallationChart([]summary.SummaryRecord{}, consts.LightTheme)
 			Expect(chart).To(BeNil())
 		})
 
@@ -404,7 +1050,7 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildPlayersPerInstallationChart(summaries)
+			chart := buildPlayersPerInstallationChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 		})
 
@@ -416,14 +1062,63 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildPlayersPerInstallationChart(summaries)
+			chart := buildPlayersPerInstallationChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 		})
 	})
 
+	Describe("buildUsersPerInstallationChart", func() {
+		It("returns nil when no summaries exist", func() {
+			chart := buildUsersPerInstallationChart([]summary.SummaryRecord{}, consts.LightTheme)
+			Expect(chart).To(BeNil())
+		})
+
+		It("bins the active users of the latest summary", func() {
+			summaries := []summary.SummaryRecord{
+				{
+					Time: time.Now(),
+					Data: summary.Summary{Users: map[string]uint64{"1": 500, "2": 200, "7": 30, "9": 20, "120": 3}},
+				},
+			}
+
+			chart := buildUsersPerInstallationChart(summaries, consts.LightTheme)
+			Expect(chart.MultiSeries).To(HaveLen(1))
+			data := chart.MultiSeries[0].Data.([]opts.BarData)
+			Expect(data).To(HaveLen(len(perInstallationBins)))
+			Expect(data[1].Value).To(Equal(uint64(500)))
+			Expect(data[6].Value).To(Equal(uint64(50)))
+			Expect(data[9].Value).To(Equal(uint64(3)))
+		})
+	})
+
+	Describe("binLabels", func() {
+		It("derives the range labels from the bins", func() {
+			Expect(binLabels([]int64{0, 1, 100, 1000, 1000000})).To(Equal([]string{"0", "1-99", "100-999", "1,000-999,999", "1,000,000+"}))
+			Expect(binLabels(summary.CPUBins)).To(Equal([]string{"1", "2-3", "4-7", "8-15", "16-31", "32-63", "64+"}))
+		})
+
+		It("has a label for every configured bin", func() {
+			bins := summary.DefaultBins()
+			Expect(binLabels(bins.Tracks)).To(HaveLen(len(bins.Tracks)))
+			Expect(binLabels(bins.Albums)).To(HaveLen(len(bins.Albums)))
+		})
+	})
+
+	Describe("binnedData", func() {
+		It("counts each key in the range containing it", func() {
+			data := binnedData([]int64{0, 1, 100, 1000}, map[string]uint64{"0": 5, "1": 10, "100": 20, "500": 2, "5000": 3})
+			Expect(data).To(Equal([]opts.BarData{{Value: uint64(5)}, {Value: uint64(10)}, {Value: uint64(22)}, {Value: uint64(3)}}))
+		})
+
+		It("ignores keys below the first bin and non-numeric keys", func() {
+			data := binnedData([]int64{1, 2}, map[string]uint64{"0": 5, "x": 1, "2": 7})
+			Expect(data).To(Equal([]opts.BarData{{Value: uint64(0)}, {Value: uint64(7)}}))
+		})
+	})
+
 	Describe("buildTracksChart", func() {
 		It("returns nil when no summaries exist", func() {
-			chart := buildTracksChart([]summary.SummaryRecord{})
+			chart := buildTracksChart([]summary.SummaryRecord{}, consts.LightTheme)
 			Expect(chart).To(BeNil())
 		})
 
@@ -435,7 +1130,7 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildTracksChart(summaries)
+			chart := buildTracksChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 		})
 
@@ -447,14 +1142,14 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildTracksChart(summaries)
+			chart := buildTracksChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 		})
 	})
 
 	Describe("buildAlbumsArtistsChart", func() {
 		It("returns nil when no summaries exist", func() {
-			chart := buildAlbumsArtistsChart([]summary.SummaryRecord{})
+			chart := buildAlbumsArtistsChart([]summary.SummaryRecord{}, consts.LightTheme)
 			Expect(chart).To(BeNil())
 		})
 
@@ -469,7 +1164,7 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildAlbumsArtistsChart(summaries)
+			chart := buildAlbumsArtistsChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 		})
 
@@ -481,11 +1176,153 @@ var _ = Describe("Charts", func() {
 				},
 			}
 
-			chart := buildAlbumsArtistsChart(summaries)
+			chart := buildAlbumsArtistsChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 		})
 	})
 
+	Describe("buildFilesystemsChart", func() {
+		It("returns nil when no summaries exist", func() {
+			chart := buildFilesystemsChart([]summary.SummaryRecord{}, consts.LightTheme)
+			Expect(chart).To(BeNil())
+		})
+
+		It("returns horizontal bar chart with music and data filesystems from latest summary", func() {
+			summaries := []summary.SummaryRecord{
+				{
+					Time: time.Now(),
+					Data: summary.Summary{
+						MusicFS: map[string]uint64{"ext4": 50, "nfs": 20, "cifs": 10},
+						DataFS:  map[string]uint64{"ext4": 70, "btrfs": 10},
+					},
+				},
+			}
+
+			chart := buildFilesystemsChart(summaries, consts.LightTheme)
+			Expect(chart).NotTo(BeNil())
+			chart.Validate()
+
+			jsonBytes, err := json.Marshal(chart.JSON())
+			Expect(err).NotTo(HaveOccurred())
+			jsonStr := string(jsonBytes)
+			Expect(jsonStr).To(ContainSubstring("Music folder"))
+			Expect(jsonStr).To(ContainSubstring("Data folder"))
+			Expect(jsonStr).To(ContainSubstring("btrfs"))
+			Expect(jsonStr).NotTo(ContainSubstring("Others"))
+		})
+
+		It("handles empty filesystem data", func() {
+			summaries := []summary.SummaryRecord{
+				{Time: time.Now(), Data: summary.Summary{}},
+			}
+
+			chart := buildFilesystemsChart(summaries, consts.LightTheme)
+			Expect(chart).NotTo(BeNil())
+		})
+	})
+
+	Describe("buildOSVersionsChart", func() {
+		It("returns nil when no summaries exist", func() {
+			chart := buildOSVersionsChart([]summary.SummaryRecord{}, consts.LightTheme)
+			Expect(chart).To(BeNil())
+		})
+
+		It("ranks Windows and macOS versions together, most used at the top", func() {
+			summaries := []summary.SummaryRecord{
+				{
+					Time: time.Now(),
+					Data: summary.Summary{
+						OSVersions: map[string]map[string]uint64{
+							"Windows": {"Windows 11": 20, "Windows 10": 40},
+							"macOS":   {"macOS 14": 30},
+						},
+					},
+				},
+			}
+
+			chart := buildOSVersionsChart(summaries, consts.LightTheme)
+			Expect(chart).NotTo(BeNil())
+			chart.Validate()
+
+			jsonBytes, err := json.Marshal(chart.JSON())
+			Expect(err).NotTo(HaveOccurred())
+			var options struct {
+				YAxis []struct{ Data []string } `json:"yAxis"`
+			}
+			Expect(json.Unmarshal(jsonBytes, &options)).To(Succeed())
+			Expect(options.YAxis[0].Data).To(Equal([]string{"Windows 11", "macOS 14", "Windows 10"}))
+		})
+	})
+
+	Describe("buildHostSizesChart", func() {
+		It("returns nil when no summaries exist", func() {
+			chart := buildHostSizesChart([]summary.SummaryRecord{}, consts.LightTheme)
+			Expect(chart).To(BeNil())
+		})
+
+		It("shows the CPU cores distribution and memory usage from the latest summary", func() {
+			summaries := []summary.SummaryRecord{
+				{
+					Time: time.Now(),
+					Data: summary.Summary{
+						CPUs:     map[string]uint64{"2": 10, "4": 30, "64": 1},
+						CPUStats: &summary.Stats{Median: 4},
+						MemStats: &summary.Stats{Median: 120, Mean: 150.4},
+					},
+				},
+			}
+
+			chart := buildHostSizesChart(summaries, consts.LightTheme)
+			Expect(chart).NotTo(BeNil())
+			chart.Validate()
+
+			jsonBytes, err := json.Marshal(chart.JSON())
+			Expect(err).NotTo(HaveOccurred())
+			jsonStr := string(jsonBytes)
+			Expect(jsonStr).To(ContainSubstring("4-7"))
+			Expect(jsonStr).To(ContainSubstring("64+"))
+			Expect(jsonStr).To(ContainSubstring("Median: 4 cores"))
+			Expect(jsonStr).To(ContainSubstring("median 120 MB, mean 150 MB"))
+		})
+	})
+
+	Describe("buildFeaturesChart", func() {
+		It("returns nil when no summaries exist", func() {
+			chart := buildFeaturesChart([]summary.SummaryRecord{}, consts.LightTheme)
+			Expect(chart).To(BeNil())
+		})
+
+		It("shows the percentage of installations with each feature, most adopted at the top", func() {
+			summaries := []summary.SummaryRecord{
+				{
+					Time: time.Now(),
+					Data: summary.Summary{
+						NumInstances: 200,
+						Features:     map[string]uint64{"Last.fm": 100, "Jukebox": 5, "Sharing": 30},
+					},
+				},
+			}
+
+			chart := buildFeaturesChart(summaries, consts.LightTheme)
+			Expect(chart).NotTo(BeNil())
+			chart.Validate()
+
+			jsonBytes, err := json.Marshal(chart.JSON())
+			Expect(err).NotTo(HaveOccurred())
+			var options struct {
+				YAxis  []struct{ Data []string } `json:"yAxis"`
+				Series []struct {
+					Data []struct{ Value float64 } `json:"data"`
+				} `json:"series"`
+			}
+			Expect(json.Unmarshal(jsonBytes, &options)).To(Succeed())
+			Expect(options.YAxis[0].Data).To(Equal([]string{"Jukebox", "Sharing", "Last.fm"}))
+			Expect(options.Series[0].Data).To(HaveLen(3))
+			Expect(options.Series[0].Data[0].Value).To(Equal(2.5))
+			Expect(options.Series[0].Data[2].Value).To(Equal(50.0))
+		})
+	})
+
 	Describe("getTopKeys", func() {
 		It("returns top N keys sorted by value descending", func() {
 			m := map[string]uint64{
@@ -546,7 +1383,7 @@ var _ = Describe("Charts", func() {
 				})
 			}
 
-			chart := buildVersionsChart(summaries)
+			chart := buildVersionsChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 
 			// Marshal chart to JSON and verify v0.2.0 appears (it should be in top N)
@@ -594,7 +1431,7 @@ var _ = Describe("Charts", func() {
 				})
 			}
 
-			chart := buildVersionsChart(summaries)
+			chart := buildVersionsChart(summaries, consts.LightTheme)
 			Expect(chart).NotTo(BeNil())
 
 			jsonBytes, err := json.Marshal(chart.JSON())
@@ -610,6 +1447,71 @@ var _ = Describe("Charts", func() {
 		})
 	})
 
+	Describe("GenerateChartJSON", func() {
+		BeforeEach(func() {
+			s := summary.Summary{NumInstances: 100, Versions: map[string]uint64{"0.54.0": 100}}
+			Expect(summary.SaveSummary(s, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+		})
+
+		It("returns the options of a single chart in the requested theme", func() {
+			data, err := GenerateChartJSON("versions", consts.DarkTheme, time.Time{}, time.Time{})
+			Expect(err).NotTo(HaveOccurred())
+			var options map[string]any
+			Expect(json.Unmarshal(data, &options)).To(Succeed())
+			Expect(options).To(HaveKeyWithValue("backgroundColor", consts.DarkTheme.BackgroundColor))
+			Expect(options).To(HaveKey("series"))
+		})
+
+		It("returns ErrUnknownChart for unknown or unavailable charts", func() {
+			_, err := GenerateChartJSON("nope", consts.LightTheme, time.Time{}, time.Time{})
+			Expect(err).To(MatchError(ErrUnknownChart))
+			_, err = GenerateChartJSON("countries", consts.LightTheme, time.Time{}, time.Time{})
+			Expect(err).To(MatchError(ErrUnknownChart))
+		})
+
+		It("returns ErrNoData when the range has no summaries", func() {
+			_, err := GenerateChartJSON("versions", consts.LightTheme, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+			Expect(err).To(MatchError(ErrNoData))
+		})
+	})
+
+	Describe("GenerateChartsJSON", func() {
+		It("returns ErrNoData when no summaries are in range", func() {
+			s := summary.Summary{NumInstances: 100, Versions: map[string]uint64{"0.54.0": 100}}
+			Expect(summary.SaveSummary(s, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+
+			_, err := GenerateChartsJSON(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+			Expect(err).To(MatchError(ErrNoData))
+		})
+
+		It("only includes summaries in range", func() {
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 100}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+			Expect(summary.SaveSummary(summary.Summary{NumInstances: 110}, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))).To(Succeed())
+
+			data, err := GenerateChartsJSON(time.Time{}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+			Expect(err).NotTo(HaveOccurred())
+			var output map[string]interface{}
+			Expect(json.Unmarshal(data, &output)).To(Succeed())
+			Expect(output["totalInstances"]).To(BeEquivalentTo(100))
+		})
+
+		It("includes the anomalies detected", func() {
+			for i, n := range []int64{1000, 1010, 995, 1005, 1000, 1012, 998, 600, 1003} {
+				Expect(summary.SaveSummary(summary.Summary{NumInstances: n}, time.Date(2025, 1, 1+i, 0, 0, 0, 0, time.UTC))).To(Succeed())
+			}
+
+			data, err := GenerateChartsJSON(time.Time{}, time.Time{})
+			Expect(err).NotTo(HaveOccurred())
+			var output struct {
+				Anomalies []map[string]interface{} `json:"anomalies"`
+			}
+			Expect(json.Unmarshal(data, &output)).To(Succeed())
+			Expect(output.Anomalies).To(HaveLen(1))
+			Expect(output.Anomalies[0]["date"]).To(Equal("2025-01-08"))
+			Expect(output.Anomalies[0]["kind"]).To(Equal("drop"))
+		})
+	})
+
 	Describe("ExportChartsJSON", func() {
 		var outputDir string
 
@@ -668,14 +1570,42 @@ var _ = Describe("Charts", func() {
 			
 			// Verify charts array
 			chartsData := output["charts"].([]interface{})
-			Expect(chartsData).To(HaveLen(6))
+			Expect(chartsData).To(HaveLen(12))
 			Expect(chartsData[0].(map[string]interface{})["id"]).To(Equal("versions"))
-			Expect(chartsData[1].(map[string]interface{})["id"]).To(Equal("os"))
-			Expect(chartsData[2].(map[string]interface{})["id"]).To(Equal("players"))
-			Expect(chartsData[3].(map[string]interface{})["id"]).To(Equal("playerTypes"))
-			// Expect(chartsData[4].(map[string]interface{})["id"]).To(Equal("playersPerInstallation"))
-			Expect(chartsData[4].(map[string]interface{})["id"]).To(Equal("tracks"))
-			Expect(chartsData[5].(map[string]interface{})["id"]).To(Equal("albumsArtists"))
+			Expect(chartsData[1].(map[string]interface{})["id"]).To(Equal("versionAdoption"))
+			Expect(chartsData[2].(map[string]interface{})["id"]).To(Equal("os"))
+			Expect(chartsData[3].(map[string]interface{})["id"]).To(Equal("architectures"))
+			Expect(chartsData[4].(map[string]interface{})["id"]).To(Equal("players"))
+			Expect(chartsData[5].(map[string]interface{})["id"]).To(Equal("playerTypes"))
+			Expect(chartsData[6].(map[string]interface{})["id"]).To(Equal("growth"))
+			Expect(chartsData[7].(map[string]interface{})["id"]).To(Equal("newReturning"))
+			// Expect(chartsData[8].(map[string]interface{})["id"]).To(Equal("playersPerInstallation"))
+			Expect(chartsData[8].(map[string]interface{})["id"]).To(Equal("usersPerInstallation"))
+			Expect(chartsData[9].(map[string]interface{})["id"]).To(Equal("tracks"))
+			Expect(chartsData[10].(map[string]interface{})["id"]).To(Equal("albumsArtists"))
+			Expect(chartsData[11].(map[string]interface{})["id"]).To(Equal("filesystems"))
+		})
+
+		It("exports light and dark options for each chart", func() {
+			s := summary.Summary{NumInstances: 100, Versions: map[string]uint64{"0.54.0": 100}}
+			Expect(summary.SaveSummary(s, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(Succeed())
+			Expect(ExportChartsJSON(outputDir)).To(Succeed())
+
+			data, err := os.ReadFile(filepath.Join(outputDir, "charts.json")) //#nosec G304 -- test file path
+			Expect(err).NotTo(HaveOccurred())
+			var output struct {
+				Charts []struct {
+					ID          string         `json:"id"`
+					Options     map[string]any `json:"options"`
+					DarkOptions map[string]any `json:"darkOptions"`
+				} `json:"charts"`
+			}
+			Expect(json.Unmarshal(data, &output)).To(Succeed())
+			Expect(output.Charts).NotTo(BeEmpty())
+			for _, c := range output.Charts {
+				Expect(c.Options).To(HaveKeyWithValue("backgroundColor", consts.LightTheme.BackgroundColor), c.ID)
+				Expect(c.DarkOptions).To(HaveKeyWithValue("backgroundColor", consts.DarkTheme.BackgroundColor), c.ID)
+			}
 		})
 	})
 })
@@ -0,0 +1,101 @@
+package charts
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// Config holds the chart tuning thresholds, read from CHARTS_* env vars
+type Config struct {
+	IncompleteThreshold  float64 // Ratio of the previous day's instances below which a trailing day is incomplete
+	TopVersionsCount     int     // Versions shown individually, the others are grouped
+	VersionSelectionDays int     // Rolling window (in days) for the top versions selection
+	PlayerGroupThreshold float64 // Share of the clients below which player types are grouped as "Others"
+	GroupPatchVersions   bool    // Show the patch releases of the versions chart as one series, like "0.54.x"
+}
+
+// DefaultConfig returns the thresholds used when not configured
+func DefaultConfig() Config {
+	return Config{
+		IncompleteThreshold:  consts.DefaultIncompleteThreshold,
+		TopVersionsCount:     consts.DefaultTopVersionsCount,
+		VersionSelectionDays: consts.DefaultVersionSelectionDays,
+		PlayerGroupThreshold: consts.DefaultPlayerGroupThreshold,
+	}
+}
+
+// ConfigFromEnv reads the thresholds from CHARTS_INCOMPLETE_THRESHOLD, CHARTS_TOP_VERSIONS,
+// CHARTS_VERSION_SELECTION_DAYS, CHARTS_PLAYER_GROUP_THRESHOLD and CHARTS_GROUP_PATCHES, using the
+// defaults for the ones not set
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+	if v := os.Getenv("CHARTS_INCOMPLETE_THRESHOLD"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return cfg, fmt.Errorf("invalid CHARTS_INCOMPLETE_THRESHOLD %q, expected a ratio between 0 and 1", v)
+		}
+		cfg.IncompleteThreshold = f
+	}
+	if v := os.Getenv("CHARTS_TOP_VERSIONS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("invalid CHARTS_TOP_VERSIONS %q", v)
+		}
+		cfg.TopVersionsCount = n
+	}
+	if v := os.Getenv("CHARTS_VERSION_SELECTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("invalid CHARTS_VERSION_SELECTION_DAYS %q", v)
+		}
+		cfg.VersionSelectionDays = n
+	}
+	if v := os.Getenv("CHARTS_PLAYER_GROUP_THRESHOLD"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f >= 1 {
+			return cfg, fmt.Errorf("invalid CHARTS_PLAYER_GROUP_THRESHOLD %q, expected a ratio between 0 and 1", v)
+		}
+		cfg.PlayerGroupThreshold = f
+	}
+	if v := os.Getenv("CHARTS_GROUP_PATCHES"); v != "" {
+		group, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid CHARTS_GROUP_PATCHES %q", v)
+		}
+		cfg.GroupPatchVersions = group
+	}
+	return cfg, nil
+}
+
+var (
+	configMu sync.RWMutex
+	config   = DefaultConfig()
+)
+
+// Configure sets the thresholds used by the charts generated from now on
+func Configure(cfg Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = cfg
+}
+
+// CurrentConfig returns the thresholds in use
+func CurrentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// ConfigureFromEnv configures the thresholds from the env vars (see ConfigFromEnv)
+func ConfigureFromEnv() error {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	Configure(cfg)
+	return nil
+}
@@ -0,0 +1,190 @@
+package charts
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	gocharts "github.com/vicanso/go-charts/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+// Image formats supported by RenderChartImage
+const (
+	ImagePNG = gocharts.ChartOutputPNG
+	ImageSVG = gocharts.ChartOutputSVG
+)
+
+// echartsPalette is echarts' default series palette, used by the interactive charts
+var echartsPalette = []string{
+	"#5470c6", "#91cc75", "#fac858", "#ee6666", "#73c0de", "#3ba272", "#fc8452", "#9a60b4", "#ea7ccc",
+}
+
+func init() {
+	seriesColors := make([]drawing.Color, len(echartsPalette))
+	for i, c := range echartsPalette {
+		seriesColors[i] = drawing.ColorFromHex(c[1:])
+	}
+	for _, theme := range []consts.ChartTheme{consts.LightTheme, consts.DarkTheme} {
+		gocharts.AddTheme(imageThemeName(theme), gocharts.ThemeOption{
+			IsDarkMode:         theme == consts.DarkTheme,
+			AxisStrokeColor:    drawing.ColorFromHex(theme.TextColor[1:]),
+			AxisSplitLineColor: drawing.ColorFromHex(theme.GridColor[1:]),
+			BackgroundColor:    drawing.ColorFromHex(theme.BackgroundColor[1:]),
+			TextColor:          drawing.ColorFromHex(theme.TextColor[1:]),
+			SeriesColors:       seriesColors,
+		})
+	}
+}
+
+func imageThemeName(theme consts.ChartTheme) string {
+	return "insights-" + theme.Name
+}
+
+// RenderChartImage renders a chart as a static image (ImagePNG or ImageSVG), for summaries between from
+// and to (inclusive, zero values are unbounded). The image is drawn by go-charts from the chart's echarts
+// options, keeping its title, legend, categories and series. Interactive elements, mark areas/lines/points
+// and stacking are not rendered, and maps are drawn as bars of their top regions. Returns the same errors
+// as GenerateChartJSON, and ErrNoData for charts without series.
+func RenderChartImage(id, format string, theme consts.ChartTheme, from, to time.Time) ([]byte, error) {
+	if format != ImagePNG && format != ImageSVG {
+		return nil, fmt.Errorf("unsupported image format %q", format)
+	}
+	options, err := chartOptions(id, theme, from, to)
+	if err != nil {
+		return nil, err
+	}
+	opt, err := imageOptions(options)
+	if err != nil {
+		return nil, fmt.Errorf("converting chart %s: %w", id, err)
+	}
+	if len(opt.SeriesList) == 0 {
+		// go-charts can't render empty charts
+		return nil, ErrNoData
+	}
+	opt.Type = format
+	opt.Theme = imageThemeName(theme)
+	opt.Width, opt.Height = consts.ChartImageWidth, consts.ChartImageHeight
+	p, err := gocharts.Render(opt)
+	if err != nil {
+		return nil, fmt.Errorf("rendering chart %s: %w", id, err)
+	}
+	return p.Bytes()
+}
+
+// echartsOptions is the subset of the echarts options used to render images
+type echartsOptions struct {
+	Title struct {
+		Text string `json:"text"`
+	} `json:"title"`
+	Legend struct {
+		Show *bool `json:"show"`
+	} `json:"legend"`
+	XAxis  []echartsAxis `json:"xAxis"`
+	YAxis  []echartsAxis `json:"yAxis"`
+	Series []struct {
+		Name string        `json:"name"`
+		Type string        `json:"type"`
+		Data []echartsData `json:"data"`
+	} `json:"series"`
+}
+
+type echartsData struct {
+	Name  string   `json:"name"`
+	Value *float64 `json:"value"`
+}
+
+type echartsAxis struct {
+	Data []string `json:"data"`
+}
+
+// imageOptions converts echarts options, as built by the chart functions, to go-charts options
+func imageOptions(options map[string]interface{}) (gocharts.ChartOption, error) {
+	var opt gocharts.ChartOption
+	data, err := json.Marshal(options)
+	if err != nil {
+		return opt, err
+	}
+	var eo echartsOptions
+	if err := json.Unmarshal(data, &eo); err != nil {
+		return opt, err
+	}
+
+	// Bar charts with categories in the Y axis are horizontal
+	horizontal := len(eo.YAxis) > 0 && len(eo.YAxis[0].Data) > 0
+	var legend []string
+	for _, s := range eo.Series {
+		if s.Type == "map" {
+			// go-charts can't draw maps: the top regions are drawn as horizontal bars instead, the
+			// largest at the top
+			regions := slices.Clone(s.Data)
+			slices.SortStableFunc(regions, func(a, b echartsData) int {
+				return cmp.Compare(valueOr(b.Value, 0), valueOr(a.Value, 0))
+			})
+			regions = regions[:min(len(regions), consts.TopCountriesCount)]
+			slices.Reverse(regions)
+			series := gocharts.Series{Type: gocharts.ChartTypeHorizontalBar, Name: s.Name}
+			var names []string
+			for _, d := range regions {
+				series.Data = append(series.Data, gocharts.SeriesData{Value: valueOr(d.Value, 0)})
+				names = append(names, d.Name)
+			}
+			opt.SeriesList = append(opt.SeriesList, series)
+			opt.YAxisOptions = []gocharts.YAxisOption{{Data: names}}
+			legend = append(legend, s.Name)
+			continue
+		}
+		if s.Type == gocharts.ChartTypePie {
+			// go-charts represents each slice as a series
+			for _, d := range s.Data {
+				opt.SeriesList = append(opt.SeriesList, gocharts.Series{
+					Type:   gocharts.ChartTypePie,
+					Name:   d.Name,
+					Radius: "35%",
+					Data:   []gocharts.SeriesData{{Value: valueOr(d.Value, 0)}},
+					Label:  gocharts.SeriesLabel{Show: true},
+				})
+				legend = append(legend, d.Name)
+			}
+			continue
+		}
+
+		series := gocharts.Series{Type: s.Type, Name: s.Name, Data: make([]gocharts.SeriesData, len(s.Data))}
+		missing := 0.0
+		if s.Type == gocharts.ChartTypeLine {
+			// Lines are interrupted by missing values, like in echarts
+			missing = gocharts.GetNullValue()
+		}
+		if s.Type == gocharts.ChartTypeBar && horizontal {
+			series.Type = gocharts.ChartTypeHorizontalBar
+		}
+		for i, d := range s.Data {
+			series.Data[i] = gocharts.SeriesData{Value: valueOr(d.Value, missing)}
+		}
+		opt.SeriesList = append(opt.SeriesList, series)
+		legend = append(legend, s.Name)
+	}
+
+	opt.Title = gocharts.TitleOption{Text: eo.Title.Text}
+	if eo.Legend.Show == nil || *eo.Legend.Show {
+		// Below the title, which is on the same line otherwise
+		opt.Legend = gocharts.LegendOption{Data: legend, Top: "30"}
+	}
+	if horizontal {
+		opt.YAxisOptions = []gocharts.YAxisOption{{Data: eo.YAxis[0].Data}}
+	} else if len(eo.XAxis) > 0 {
+		opt.XAxis = gocharts.XAxisOption{Data: eo.XAxis[0].Data}
+	}
+	opt.SymbolShow = gocharts.FalseFlag()
+	return opt, nil
+}
+
+func valueOr(v *float64, missing float64) float64 {
+	if v == nil {
+		return missing
+	}
+	return *v
+}
@@ -0,0 +1,167 @@
+package charts
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/summary"
+)
+
+// stableVersionRegex matches the stable release versions of the releases file, like "0.54.2"
+var stableVersionRegex = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// ReleaseAdoption is the share of the instances running the latest stable release on a day
+type ReleaseAdoption struct {
+	Date    time.Time `json:"date"`
+	Release string    `json:"release"` // Empty if no release is known on that day
+	Percent float64   `json:"percent"`
+}
+
+// stableReleases returns the stable releases, sorted by date: the ones of the releases file (with or
+// without a "v" prefix, prereleases are skipped) or, if none were loaded, the first day each stable release
+// appears in the summaries (see detectReleases)
+func stableReleases(summaries []summary.SummaryRecord) []releaseMark {
+	list := currentReleases()
+	if list == nil {
+		return detectReleases(summaries, stableReleaseRegex)
+	}
+	var stable []releaseMark
+	for _, r := range list {
+		version := strings.TrimPrefix(r.Version, "v")
+		if stableVersionRegex.MatchString(version) {
+			stable = append(stable, releaseMark{Date: r.Date, Version: version})
+		}
+	}
+	return stable
+}
+
+// ReleaseDates returns the dates of the stable releases of the releases file (see LoadReleases), by
+// version without the "v" prefix. Empty if no releases were loaded
+func ReleaseDates() map[string]time.Time {
+	dates := map[string]time.Time{}
+	if currentReleases() == nil {
+		return dates
+	}
+	for _, r := range stableReleases(nil) {
+		dates[r.Version] = r.Date
+	}
+	return dates
+}
+
+// releaseAdoption returns the share of the instances running the latest of the releases published on
+// or before the day of s
+func releaseAdoption(releases []releaseMark, s summary.SummaryRecord) ReleaseAdoption {
+	adoption := ReleaseAdoption{Date: s.Time}
+	for _, r := range releases {
+		if r.Date.After(s.Time) {
+			break
+		}
+		if adoption.Release == "" || compareVersions(r.Version, adoption.Release) < 0 {
+			adoption.Release = r.Version
+		}
+	}
+	if adoption.Release != "" {
+		adoption.Percent = versionShare(s.Data.Versions, adoption.Release)
+	}
+	return adoption
+}
+
+// LatestReleaseAdoption returns the share of the instances running the latest stable release on the
+// most recent complete day (see LatestSummary). Returns ErrNoData if there is no summary
+func LatestReleaseAdoption() (ReleaseAdoption, error) {
+	latest, err := LatestSummary()
+	if err != nil {
+		return ReleaseAdoption{}, err
+	}
+	summaries, err := CachedSummaries()
+	if err != nil {
+		return ReleaseAdoption{}, err
+	}
+	return releaseAdoption(stableReleases(summaries), latest), nil
+}
+
+func buildLatestReleaseChart(summaries []summary.SummaryRecord, theme consts.ChartTheme) *charts.Line {
+	ts := buildTimeSeriesData(summaries)
+	releases := stableReleases(summaries)
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:           consts.ChartWidth,
+			Height:          consts.ChartHeight,
+			BackgroundColor: theme.BackgroundColor,
+		}),
+		charts.WithTitleOpts(opts.Title{
+			Title:      "Latest Release Adoption",
+			Subtitle:   "Installations running the latest stable release",
+			TitleStyle: &opts.TextStyle{Color: theme.TextColor},
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{
+			Show:    opts.Bool(true),
+			Trigger: "axis",
+		}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Name:         "Date",
+			NameLocation: "center",
+			NameGap:      30,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithYAxisOpts(opts.YAxis{
+			Name:         "Installations (%)",
+			NameLocation: "center",
+			NameGap:      50,
+			Min:          0,
+			Max:          100,
+			AxisLabel: &opts.AxisLabel{
+				Color: theme.TextColor,
+			},
+			SplitLine: &opts.SplitLine{LineStyle: &opts.LineStyle{Color: theme.GridColor}},
+		}),
+		charts.WithGridOpts(opts.Grid{
+			Left:   "80",
+			Right:  "80",
+			Bottom: "60",
+		}),
+	)
+
+	line.SetXAxis(ts.Dates)
+
+	// Days without data or without a known release are gaps
+	data := make([]opts.LineData, len(ts.Dates))
+	start := summaries[0].Time
+	for i := range ts.Dates {
+		s := ts.Lookup[start.AddDate(0, 0, i)]
+		if s == nil {
+			data[i] = opts.LineData{Value: nil}
+			continue
+		}
+		adoption := releaseAdoption(releases, *s)
+		if adoption.Release == "" {
+			data[i] = opts.LineData{Value: nil}
+			continue
+		}
+		data[i] = opts.LineData{Name: adoption.Release, Value: adoption.Percent}
+	}
+
+	gaps := ts.findGaps()
+	line.AddSeries("Latest release", data,
+		charts.WithMarkAreaData(buildMarkAreaData(gaps, theme)...),
+		charts.WithMarkLineNameXAxisItemOpts(buildReleaseMarkLines(releasesInRange(releases, summaries))...),
+		charts.WithMarkLineStyleOpts(opts.MarkLineStyle{
+			Symbol: []string{"none", "none"},
+			Label:  &opts.Label{Show: opts.Bool(true), Formatter: "{b}"},
+		}),
+	)
+	line.SetSeriesOptions(
+		charts.WithAreaStyleOpts(opts.AreaStyle{Opacity: opts.Float(0.3)}),
+	)
+
+	return line
+}
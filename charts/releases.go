@@ -0,0 +1,116 @@
+package charts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	appconfig "github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/summary"
+)
+
+// releaseEntry is the format of each release in the releases file
+type releaseEntry struct {
+	Date    string `json:"date"` // YYYY-MM-DD
+	Version string `json:"version"`
+}
+
+var (
+	releasesMu sync.RWMutex
+	releases   []releaseMark // Loaded from the releases file, sorted by date
+)
+
+func releasesFilePath() string {
+	return filepath.Join(appconfig.DataFolder(), consts.ReleasesFile)
+}
+
+// LoadReleases loads the Navidrome releases annotated on the time series charts from the releases file in
+// DATA_FOLDER, a JSON list of {"date": "YYYY-MM-DD", "version"} entries. If the file does not exist, the
+// charts annotate the minor releases detected in the summaries instead (see detectReleases). On error,
+// the current list is kept. Returns the number of releases loaded
+func LoadReleases() (int, error) {
+	data, err := os.ReadFile(releasesFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		setReleases(nil)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var entries []releaseEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", consts.ReleasesFile, err)
+	}
+
+	list := make([]releaseMark, 0, len(entries))
+	for i, e := range entries {
+		date, err := time.Parse(consts.DateFormat, e.Date)
+		if err != nil {
+			return 0, fmt.Errorf("parsing %s: release %d has an invalid date %q", consts.ReleasesFile, i+1, e.Date)
+		}
+		if e.Version == "" {
+			return 0, fmt.Errorf("parsing %s: release %d has no version", consts.ReleasesFile, i+1)
+		}
+		list = append(list, releaseMark{Date: date, Version: e.Version})
+	}
+	slices.SortStableFunc(list, func(a, b releaseMark) int { return a.Date.Compare(b.Date) })
+	setReleases(list)
+	return len(list), nil
+}
+
+func setReleases(list []releaseMark) {
+	releasesMu.Lock()
+	defer releasesMu.Unlock()
+	releases = list
+}
+
+func currentReleases() []releaseMark {
+	releasesMu.RLock()
+	defer releasesMu.RUnlock()
+	return releases
+}
+
+// chartReleases returns the releases to annotate on time series charts of the summaries: the loaded
+// releases within the summaries' date range or, if none were loaded, the detected minor releases
+func chartReleases(summaries []summary.SummaryRecord) []releaseMark {
+	list := currentReleases()
+	if list == nil {
+		return detectReleases(summaries, minorReleaseRegex)
+	}
+	if len(summaries) == 0 {
+		return nil
+	}
+	return releasesInRange(list, summaries)
+}
+
+// releasesInRange returns the releases within the summaries' date range
+func releasesInRange(releases []releaseMark, summaries []summary.SummaryRecord) []releaseMark {
+	first, last := summaries[0].Time, summaries[len(summaries)-1].Time
+	var marks []releaseMark
+	for _, r := range releases {
+		if !r.Date.Before(first) && !r.Date.After(last) {
+			marks = append(marks, r)
+		}
+	}
+	return marks
+}
+
+// releaseMarkLineOpts adds the release annotations to a series, as vertical lines labeled with the version
+func releaseMarkLineOpts(summaries []summary.SummaryRecord) []charts.SeriesOpts {
+	return []charts.SeriesOpts{
+		charts.WithMarkLineNameXAxisItemOpts(buildReleaseMarkLines(chartReleases(summaries))...),
+		charts.WithMarkLineStyleOpts(opts.MarkLineStyle{
+			Symbol: []string{"none", "none"},
+			Label:  &opts.Label{Show: opts.Bool(true), Formatter: "{b}"},
+		}),
+	}
+}
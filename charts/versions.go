@@ -0,0 +1,84 @@
+package charts
+
+import (
+	"cmp"
+	"regexp"
+	"strconv"
+)
+
+// semverRegex matches the leading semantic version of a reported version, like "0.54.2 (0b184893)" or
+// "0.55.0-SNAPSHOT (1a2b3c4d)", or of a patchGroup like "0.54.x"
+var semverRegex = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+|x)(?:-([0-9A-Za-z.-]+))?`)
+
+// parsedVersion is the major, minor and patch numbers of a version, and its prerelease if any
+type parsedVersion struct {
+	numbers    [3]int
+	prerelease string
+}
+
+func parseVersion(version string) (parsedVersion, bool) {
+	m := semverRegex.FindStringSubmatch(version)
+	if m == nil {
+		return parsedVersion{}, false
+	}
+	var v parsedVersion
+	for i := range v.numbers {
+		if m[i+1] == "x" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return parsedVersion{}, false
+		}
+		v.numbers[i] = n
+	}
+	v.prerelease = m[4]
+	return v, true
+}
+
+// compareVersions orders versions newest first: by semantic version, a prerelease before its release,
+// then by build. Versions that aren't semantic versions (e.g. "dev") are sorted last, alphabetically
+func compareVersions(a, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	switch {
+	case !okA && !okB:
+		return cmp.Compare(a, b)
+	case !okA:
+		return 1
+	case !okB:
+		return -1
+	}
+	for i := range va.numbers {
+		if c := cmp.Compare(vb.numbers[i], va.numbers[i]); c != 0 {
+			return c
+		}
+	}
+	if (va.prerelease == "") != (vb.prerelease == "") {
+		if va.prerelease == "" {
+			return -1
+		}
+		return 1
+	}
+	return cmp.Or(cmp.Compare(vb.prerelease, va.prerelease), cmp.Compare(a, b))
+}
+
+// patchGroup returns the series of a version when patch releases are grouped (see
+// Config.GroupPatchVersions), like "0.54.x" for all the builds of 0.54.0, 0.54.1... and their
+// prereleases. Versions that aren't semantic versions are kept as they are
+func patchGroup(version string) string {
+	v, ok := parseVersion(version)
+	if !ok {
+		return version
+	}
+	return strconv.Itoa(v.numbers[0]) + "." + strconv.Itoa(v.numbers[1]) + ".x"
+}
+
+// groupPatchVersions sums the counts of the versions of each patchGroup
+func groupPatchVersions(versions map[string]uint64) map[string]uint64 {
+	grouped := make(map[string]uint64, len(versions))
+	for version, count := range versions {
+		grouped[patchGroup(version)] += count
+	}
+	return grouped
+}
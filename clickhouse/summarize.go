@@ -0,0 +1,319 @@
+package clickhouse
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/summary"
+)
+
+// latestReports selects the latest report of each instance for the {date:Date} param, like db.SelectData
+const latestReports = `(SELECT * FROM %[1]s WHERE toDate(time) = {date:Date} ORDER BY id, time DESC LIMIT 1 BY id)`
+
+// counter is a map of the summary counted by a query. The query selects the counted key and the count n
+// from the latest reports (%[2]s), and can select a second key (key2) for nested maps. The keys computed
+// by the summary functions are selected as raw values and mapped with key (rows it rejects aren't
+// counted), or split into key and key2 with split, so they can't drift from SummarizeData
+type counter struct {
+	name  string
+	query string
+	key   func(string) (string, bool)
+	split func(string) (string, string)
+}
+
+var counters = []counter{
+	{name: "versions", query: `SELECT version AS key, count() AS n FROM %[2]s GROUP BY key`},
+	{name: "os", query: `SELECT os AS key, count() AS n FROM %[2]s GROUP BY key`},
+	// mapOS names non-containerized Linux "Linux - <arch>"
+	{name: "distros", query: `SELECT distro AS key, count() AS n FROM %[2]s WHERE startsWith(os, 'Linux - ') GROUP BY key`},
+	{name: "osVersions", query: `SELECT os_name AS key, os_version AS key2, count() AS n FROM %[2]s WHERE os_version != '' GROUP BY key, key2`},
+	{name: "users", query: `SELECT toString(active_users) AS key, count() AS n FROM %[2]s GROUP BY key`},
+	{name: "musicFS", query: `SELECT music_fs AS key, count() AS n FROM %[2]s GROUP BY key`},
+	{name: "dataFS", query: `SELECT data_fs AS key, count() AS n FROM %[2]s GROUP BY key`},
+	{name: "playerTypes", query: `SELECT key, sum(v) AS n FROM %[2]s ARRAY JOIN mapKeys(player_types) AS key, mapValues(player_types) AS v GROUP BY key`},
+	{name: "players", query: `SELECT toString(players) AS key, count() AS n FROM %[2]s GROUP BY key`},
+	{name: "playerVersions", query: `SELECT key, count() AS n FROM %[2]s ARRAY JOIN player_versions AS key GROUP BY key`,
+		split: summary.SplitPlayerVersion},
+	{name: "fileSuffixes", query: `SELECT key, count() AS n FROM %[2]s ARRAY JOIN file_suffixes AS key GROUP BY key`},
+	{name: "plugins", query: `SELECT key, count() AS n FROM %[2]s ARRAY JOIN plugins AS key GROUP BY key`},
+	{name: "pluginVersions", query: `SELECT key, count() AS n FROM %[2]s ARRAY JOIN plugin_versions AS key GROUP BY key`},
+	{name: "configFlags", query: `SELECT key, count() AS n FROM %[2]s ARRAY JOIN config_flags AS key GROUP BY key`},
+	{name: "features", query: `SELECT key, count() AS n FROM %[2]s ARRAY JOIN features AS key GROUP BY key`},
+	{name: "scannerExtractor", query: `SELECT scanner_extractor AS key, count() AS n FROM %[2]s WHERE scanner_extractor != '' GROUP BY key`},
+	{name: "countries", query: `SELECT country AS key, count() AS n FROM %[2]s WHERE country != '' GROUP BY key`},
+	{name: "trackHistogram", query: valueQuery("tracks", ""), key: intKey(summary.HistogramKey)},
+	// Host resources, skipped when not reported
+	{name: "cpus", query: valueQuery("num_cpu", "num_cpu > 0"), key: binKey(summary.CPUBins)},
+	{name: "memory", query: valueQuery("intDiv(mem_sys, 1048576)", "mem_sys > 0"), key: binKey(summary.MemoryBins)},
+}
+
+// binCounters counts the library sizes in the configured bins (see summary.LoadBins)
+func binCounters(bins summary.Bins) []counter {
+	return []counter{
+		{name: "tracks", query: valueQuery("tracks", ""), key: binKey(bins.Tracks)},
+		{name: "albums", query: valueQuery("albums", ""), key: binKey(bins.Albums)},
+		{name: "artists", query: valueQuery("artists", ""), key: binKey(bins.Artists)},
+	}
+}
+
+// valueQuery counts the reports with each value of the expression, to be mapped by the counter's key
+func valueQuery(expr, where string) string {
+	if where != "" {
+		where = " WHERE " + where
+	}
+	return fmt.Sprintf("SELECT toString(%s) AS key, count() AS n FROM %%[2]s%s GROUP BY key", expr, where)
+}
+
+// binKey maps the values to their bins, like summary.mapToBins
+func binKey(bins []int64) func(string) (string, bool) {
+	return intKey(func(v int64) (string, bool) { return summary.BinKey(v, bins) })
+}
+
+// intKey maps integer values with f
+func intKey(f func(int64) (string, bool)) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return "", false
+		}
+		return f(v)
+	}
+}
+
+// stat is a statistic of the summary, computed over the values of an expression, optionally filtered, and
+// excluding the values above the outlier cap of its summary.OutlierCaps key
+type stat struct {
+	name  string
+	expr  string
+	where string
+	cap   string
+}
+
+var stats = []stat{
+	// Only non-zero for tracks, albums, artists
+	{"trackStats", "tracks", "tracks > 0", "track"},
+	{"albumStats", "albums", "albums > 0", "album"},
+	{"artistStats", "artists", "artists > 0", "artist"},
+	{"playlistStats", "playlists", "", "playlist"},
+	{"shareStats", "shares", "", "share"},
+	{"radioStats", "radios", "", "radio"},
+	{"libraryStats", "libraries", "", "library"},
+	{"activeUserStats", "active_users", "", "activeUser"},
+	{"cpuStats", "num_cpu", "num_cpu > 0", "cpu"},
+	{"memStats", "intDiv(mem_sys, 1048576)", "mem_sys > 0", "mem"},
+}
+
+// statsQuery selects the instances, active users, library totals and, for each stat, its count, min, max, mean, median,
+// standard deviation and number of outliers excluded (see summary.OutlierCaps), in a single row
+func statsQuery(caps summary.OutlierCaps) string {
+	cols := []string{"count() AS instances", "sum(active_users) AS activeUsers",
+		"sum(tracks) AS totalTracks", "sum(albums) AS totalAlbums", "sum(artists) AS totalArtists"}
+	for _, s := range stats {
+		cond, outlier := cmp.Or(s.where, "1"), "0"
+		if limit := caps[s.cap]; limit > 0 {
+			outlier = fmt.Sprintf("%s AND %s > %d", cond, s.expr, limit)
+			cond = fmt.Sprintf("%s AND %s <= %d", cond, s.expr, limit)
+		}
+		cols = append(cols,
+			fmt.Sprintf("countIf(%s) AS %s_excluded", outlier, s.name),
+			fmt.Sprintf("countIf(%s) AS %s_n", cond, s.name),
+			fmt.Sprintf("minIf(%s, %s) AS %s_min", s.expr, cond, s.name),
+			fmt.Sprintf("maxIf(%s, %s) AS %s_max", s.expr, cond, s.name),
+			fmt.Sprintf("avgIf(%s, %s) AS %s_mean", s.expr, cond, s.name),
+			// Mean of the two middle values for even counts, like summary.calcStats
+			fmt.Sprintf("(quantileExactLowIf(0.5)(%[1]s, %[2]s) + quantileExactHighIf(0.5)(%[1]s, %[2]s)) / 2 AS %[3]s_median", s.expr, cond, s.name),
+			fmt.Sprintf("stddevPopIf(%s, %s) AS %s_stdDev", s.expr, cond, s.name),
+		)
+	}
+	return "SELECT " + strings.Join(cols, ", ") + " FROM %[2]s"
+}
+
+// New instances are the ones first seen on the date, churned ones were last seen consts.ChurnDays before
+const instancesQuery = `
+SELECT
+	countIf(first = {date:Date}) AS newInstances,
+	countIf(last = {churnDate:Date}) AS churnedInstances
+FROM (SELECT toDate(min(time)) AS first, toDate(max(time)) AS last FROM %[1]s GROUP BY id)`
+
+// Distinct instances that reported in the consts.WeeklyActiveDays and consts.MonthlyActiveDays ending on the date
+var activeInstancesQuery = fmt.Sprintf(`
+SELECT
+	uniqExactIf(id, toDate(time) > {date:Date} - %d) AS weeklyInstances,
+	uniqExact(id) AS monthlyInstances
+FROM %%[1]s WHERE toDate(time) > {date:Date} - %d AND toDate(time) <= {date:Date}`, consts.WeeklyActiveDays, consts.MonthlyActiveDays)
+
+// Summarize computes the summary of the given date from the reports stored in ClickHouse, with the same
+// results as summary.SummarizeData on the SQLite database
+func (s *Store) Summarize(ctx context.Context, date time.Time) (summary.Summary, error) {
+	sum := summary.Summary{Finalized: summary.DayOver(date, time.Now())}
+	params := map[string]string{
+		"date":      date.Format(consts.DateFormat),
+		"churnDate": date.AddDate(0, 0, -consts.ChurnDays).Format(consts.DateFormat),
+	}
+	latest := fmt.Sprintf(latestReports, s.cfg.Table)
+
+	var totals map[string]float64
+	if err := s.queryRow(ctx, statsQuery(summary.CurrentOutlierCaps()), latest, params, &totals); err != nil {
+		return sum, fmt.Errorf("querying stats: %w", err)
+	}
+	sum.NumInstances = int64(totals["instances"])
+	if sum.NumInstances == 0 {
+		return summary.Summary{}, nil
+	}
+	sum.NumActiveUsers = int64(totals["activeUsers"])
+	sum.TotalTracks = int64(totals["totalTracks"])
+	sum.TotalAlbums = int64(totals["totalAlbums"])
+	sum.TotalArtists = int64(totals["totalArtists"])
+	statFields := map[string]**summary.Stats{
+		"trackStats": &sum.TrackStats, "albumStats": &sum.AlbumStats, "artistStats": &sum.ArtistStats,
+		"playlistStats": &sum.PlaylistStats, "shareStats": &sum.ShareStats, "radioStats": &sum.RadioStats,
+		"libraryStats": &sum.LibraryStats, "activeUserStats": &sum.ActiveUserStats,
+		"cpuStats": &sum.CPUStats, "memStats": &sum.MemStats,
+	}
+	for _, st := range stats {
+		if totals[st.name+"_n"] == 0 {
+			continue
+		}
+		*statFields[st.name] = &summary.Stats{
+			Min:    int64(totals[st.name+"_min"]),
+			Max:    int64(totals[st.name+"_max"]),
+			Mean:   totals[st.name+"_mean"],
+			Median: totals[st.name+"_median"],
+			StdDev: totals[st.name+"_stdDev"],
+			// Set only when non-zero, like summary.calcStatsExcluding
+			Excluded: int64(totals[st.name+"_excluded"]),
+		}
+	}
+
+	var instances struct {
+		NewInstances     int64 `json:"newInstances"`
+		ChurnedInstances int64 `json:"churnedInstances"`
+	}
+	if err := s.queryRow(ctx, instancesQuery, latest, params, &instances); err != nil {
+		return sum, fmt.Errorf("counting new and churned instances: %w", err)
+	}
+	sum.NewInstances, sum.ChurnedInstances = instances.NewInstances, instances.ChurnedInstances
+
+	var active struct {
+		WeeklyInstances  int64 `json:"weeklyInstances"`
+		MonthlyInstances int64 `json:"monthlyInstances"`
+	}
+	if err := s.queryRow(ctx, activeInstancesQuery, latest, params, &active); err != nil {
+		return sum, fmt.Errorf("counting active instances: %w", err)
+	}
+	sum.WeeklyInstances, sum.MonthlyInstances = active.WeeklyInstances, active.MonthlyInstances
+
+	all := slices.Concat(counters, binCounters(summary.CurrentBins()))
+	body, err := s.exec(ctx, countsQuery(all, s.cfg.Table, latest), params, nil)
+	if err != nil {
+		return sum, fmt.Errorf("counting reports: %w", err)
+	}
+	return sum, addCounts(&sum, all, body)
+}
+
+// countsQuery is the UNION of the counters' queries, each row tagged with the counter name
+func countsQuery(all []counter, table, latest string) string {
+	queries := make([]string, len(all))
+	for i, c := range all {
+		key2 := "'' AS key2, "
+		if strings.Contains(c.query, "key2") {
+			key2 = "key2, "
+		}
+		queries[i] = fmt.Sprintf("SELECT '%s' AS name, key, %sn FROM (%s)", c.name, key2, c.query)
+	}
+	return fmt.Sprintf(strings.Join(queries, "\nUNION ALL\n")+"\nFORMAT JSONEachRow", table, latest)
+}
+
+// addCounts adds the rows returned by countsQuery for the same counters to the summary maps
+func addCounts(sum *summary.Summary, all []counter, body []byte) error {
+	counts := map[string]*map[string]uint64{
+		"versions": &sum.Versions, "os": &sum.OS, "distros": &sum.Distros, "users": &sum.Users,
+		"musicFS": &sum.MusicFS, "dataFS": &sum.DataFS, "playerTypes": &sum.PlayerTypes, "players": &sum.Players,
+		"fileSuffixes": &sum.FileSuffixes, "plugins": &sum.Plugins, "pluginVersions": &sum.PluginVersions,
+		"configFlags": &sum.ConfigFlags, "features": &sum.Features, "scannerExtractor": &sum.ScannerExtractor,
+		"countries": &sum.Countries, "tracks": &sum.Tracks, "albums": &sum.Albums, "artists": &sum.Artists,
+		"trackHistogram": &sum.TrackHistogram,
+		"cpus":           &sum.CPUs, "memory": &sum.Memory,
+	}
+	sum.OSVersions = map[string]map[string]uint64{}
+	sum.PlayerVersions = map[string]map[string]uint64{}
+	nestedCounts := map[string]map[string]map[string]uint64{"osVersions": sum.OSVersions, "playerVersions": sum.PlayerVersions}
+	byName := map[string]counter{}
+	for _, c := range all {
+		byName[c.name] = c
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var r struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+			Key2 string `json:"key2"`
+			N    uint64 `json:"n"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("parsing counts: %w", err)
+		}
+		// Several values can map to the same key, so the counts are added
+		c := byName[r.Name]
+		if c.split != nil {
+			r.Key, r.Key2 = c.split(r.Key)
+		}
+		if nested, ok := nestedCounts[r.Name]; ok {
+			if nested[r.Key] == nil {
+				nested[r.Key] = map[string]uint64{}
+			}
+			nested[r.Key][r.Key2] += r.N
+			continue
+		}
+		if c.key != nil {
+			key, ok := c.key(r.Key)
+			if !ok {
+				continue
+			}
+			r.Key = key
+		}
+		m := counts[r.Name]
+		if *m == nil {
+			*m = map[string]uint64{}
+		}
+		(*m)[r.Key] += r.N
+	}
+	return scanner.Err()
+}
+
+// queryRow runs a query returning a single row, formatted with the table and latest reports subquery
+func (s *Store) queryRow(ctx context.Context, query, latest string, params map[string]string, v any) error {
+	body, err := s.exec(ctx, fmt.Sprintf(query, s.cfg.Table, latest)+"\nFORMAT JSONEachRow", params, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// SummarizeData summarizes the given date from the reports stored in ClickHouse and saves the summary,
+// returning the number of reports summarized. Used for dates whose reports were already purged from the
+// SQLite database
+func (s *Store) SummarizeData(ctx context.Context, date time.Time) (int64, error) {
+	sum, err := s.Summarize(ctx, date)
+	if err != nil {
+		return 0, fmt.Errorf("summarizing %s from ClickHouse: %w", date.Format(consts.DateFormat), err)
+	}
+	if sum.NumInstances == 0 {
+		log.Printf("No data to summarize for %s", date.Format(consts.DateFormat))
+		return 0, nil
+	}
+	if err := summary.SaveSummary(sum, date); err != nil {
+		return 0, err
+	}
+	return sum.NumInstances, nil
+}
@@ -0,0 +1,131 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/summary"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestClickHouse(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ClickHouse Suite")
+}
+
+// groupRows counts the values of each counter like ClickHouse runs the counter's query, returning the
+// rows of countsQuery
+func groupRows(values map[string][]string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for name, vs := range values {
+		counts := map[string]uint64{}
+		for _, v := range vs {
+			counts[v]++
+		}
+		for key, n := range counts {
+			Expect(enc.Encode(map[string]any{"name": name, "key": key, "key2": "", "n": n})).To(Succeed())
+		}
+	}
+	return buf.Bytes()
+}
+
+var _ = Describe("Summarize", func() {
+	var date time.Time
+	var expected summary.Summary
+	var rows []row
+
+	BeforeEach(func() {
+		ctx := context.Background()
+		dbConn, err := db.OpenDB(filepath.Join(GinkgoT().TempDir(), "insights.db"))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(dbConn.Close)
+
+		date = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+		fixtures := []insights.Data{
+			{InsightsID: "1", Version: "0.54.0"},
+			{InsightsID: "2", Version: "0.54.0"},
+			{InsightsID: "3", Version: "0.55.0"},
+			{InsightsID: "4", Version: "0.55.1"},
+			{InsightsID: "5", Version: "0.55.1"},
+			{InsightsID: "6", Version: "0.56.0"},
+		}
+		libraries := [][3]int64{{0, 0, 0}, {9, 1, 1}, {999, 80, 20}, {1000, 100, 50}, {12345, 999, 456}, {250000, 20000, 5000}}
+		cpus := []int{0, 1, 3, 4, 12, 128}
+		mems := []uint64{0, 50 << 20, 64 << 20, 300 << 20, 2047 << 20, 16 << 30}
+		players := []map[string]int64{
+			nil,
+			{"Symfonium 12.3.1": 1, "NavidromeUI_1.0": 2},
+			{"Symfonium 12.4.0": 1, "DSub v5.4": 1},
+			{"NewClient/1.2": 1, "NewClient/1.3": 1},
+			{"foo/bar 2.0": 1, "Feishin 0.12.1": 1},
+			{"Substreamer 3": 1},
+		}
+		for i := range fixtures {
+			fixtures[i].Library.Tracks, fixtures[i].Library.Albums, fixtures[i].Library.Artists = libraries[i][0], libraries[i][1], libraries[i][2]
+			fixtures[i].OS.NumCPU = cpus[i]
+			fixtures[i].Mem.Sys = mems[i]
+			fixtures[i].Library.ActivePlayers = players[i]
+		}
+		Expect(db.SaveReports(ctx, dbConn, fixtures, date.Add(12*time.Hour), "")).To(Succeed())
+
+		expected, err = summary.ComputeSummary(ctx, dbConn, date)
+		Expect(err).NotTo(HaveOccurred())
+
+		reports, err := db.SelectRawReports(dbConn, date)
+		Expect(err).NotTo(HaveOccurred())
+		rows = nil
+		for r := range reports {
+			var data insights.Data
+			Expect(json.Unmarshal([]byte(r.Data), &data)).To(Succeed())
+			rows = append(rows, newRow(r, data))
+		}
+		Expect(rows).To(HaveLen(len(fixtures)))
+	})
+
+	It("counts the bins, track histogram and player versions like summary.ComputeSummary", func() {
+		values := map[string][]string{}
+		for _, r := range rows {
+			values["tracks"] = append(values["tracks"], strconv.FormatInt(r.Tracks, 10))
+			values["albums"] = append(values["albums"], strconv.FormatInt(r.Albums, 10))
+			values["artists"] = append(values["artists"], strconv.FormatInt(r.Artists, 10))
+			values["trackHistogram"] = append(values["trackHistogram"], strconv.FormatInt(r.Tracks, 10))
+			if r.NumCPU > 0 {
+				values["cpus"] = append(values["cpus"], strconv.FormatInt(r.NumCPU, 10))
+			}
+			if r.MemSys > 0 {
+				values["memory"] = append(values["memory"], strconv.FormatUint(r.MemSys/1048576, 10))
+			}
+			values["playerVersions"] = append(values["playerVersions"], r.PlayerVersions...)
+		}
+
+		var sum summary.Summary
+		Expect(addCounts(&sum, slices.Concat(counters, binCounters(summary.CurrentBins())), groupRows(values))).To(Succeed())
+
+		Expect(sum.Tracks).To(Equal(expected.Tracks))
+		Expect(sum.Albums).To(Equal(expected.Albums))
+		Expect(sum.Artists).To(Equal(expected.Artists))
+		Expect(sum.TrackHistogram).To(Equal(expected.TrackHistogram))
+		Expect(sum.CPUs).To(Equal(expected.CPUs))
+		Expect(sum.Memory).To(Equal(expected.Memory))
+		Expect(sum.PlayerVersions).To(Equal(expected.PlayerVersions))
+		Expect(sum.PlayerVersions).To(HaveKey("foo/bar"))
+	})
+
+	It("queries every counter", func() {
+		all := slices.Concat(counters, binCounters(summary.CurrentBins()))
+		query := countsQuery(all, "reports", latestReports)
+		for _, c := range all {
+			Expect(query).To(ContainSubstring("SELECT '" + c.name + "' AS name"))
+		}
+	})
+})
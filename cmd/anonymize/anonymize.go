@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// anonymizer rewrites reports so they can be shared for research
+type anonymizer struct {
+	salt []byte
+}
+
+// anonymizeID replaces an instance ID with a salted hash, formatted as a UUID. The same ID is always
+// mapped to the same hash, so instances can still be followed over time, but can't be matched to the
+// real IDs without the salt
+func (a *anonymizer) anonymizeID(id string) string {
+	mac := hmac.New(sha256.New, a.salt)
+	mac.Write([]byte(id))
+	h := hex.EncodeToString(mac.Sum(nil))
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// anonymize returns the report without identifying information. Only the fields of insights.Data are
+// kept, dropping any field added by newer Navidrome versions until it is reviewed here. Player, plugin
+// and filesystem names are kept, as they identify software, not users
+func (a *anonymizer) anonymize(data insights.Data) insights.Data {
+	data.InsightsID = a.anonymizeID(data.InsightsID)
+
+	// Build settings include ldflags, paths and VCS info of custom builds
+	data.Build.Settings = nil
+	data.OS.Version = normalizeOSVersion(data.OS.Type, data.OS.Version)
+
+	// Free-form config values
+	data.Config.ScanSchedule = ""
+	data.Config.BackupSchedule = ""
+	data.Config.TranscodingCacheSize = ""
+	data.Config.ImageCacheSize = ""
+	return data
+}
+
+// normalizeOSVersion drops the patch level and build revisions, which can single out an installation.
+// Windows keeps its build number, needed to tell Windows 10 from 11 (see summary.mapOSVersion)
+func normalizeOSVersion(osType, version string) string {
+	keep := 2
+	if osType == "windows" {
+		keep = 3
+	}
+	parts := strings.Split(strings.TrimSpace(version), ".")
+	return strings.Join(parts[:min(keep, len(parts))], ".")
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+const batchSize = 1000 // reports written per transaction, for DB outputs
+
+// jsonlRow is the format of cmd/export -format jsonl, so anonymized datasets can be loaded with cmd/import
+type jsonlRow struct {
+	ID      string          `json:"id"`
+	Time    time.Time       `json:"time"`
+	Country string          `json:"country,omitempty"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// anonymize creates a dataset that can be shared for research from a raw database: instance IDs are replaced
+// with salted hashes, and free-form values are stripped (see anonymizer.anonymize). The output is a new
+// SQLite database, or JSON Lines (as written by cmd/export) if its name ends with .jsonl or .jsonl.gz
+func main() {
+	dbPath := flag.String("db", "", "Path to the raw insights.db (default: $DATA_FOLDER/insights.db or ./insights.db)")
+	outPath := flag.String("out", "", "Output database, or .jsonl/.jsonl.gz file (required, must not exist)")
+	fromStr := flag.String("from", "", "First date to include (YYYY-MM-DD, default: unbounded)")
+	toStr := flag.String("to", "", "Last date to include (YYYY-MM-DD, default: unbounded)")
+	salt := flag.String("salt", "", "Secret salt for the ID hashes, to keep IDs consistent across datasets (default: random)")
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *outPath == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	from, err := parseOptionalDate(*fromStr)
+	if err != nil {
+		log.Fatalf("Error: invalid -from: %v", err)
+	}
+	to, err := parseOptionalDate(*toStr)
+	if err != nil {
+		log.Fatalf("Error: invalid -to: %v", err)
+	}
+	dbFile := cmp.Or(*dbPath, config.DBPath())
+
+	a := &anonymizer{salt: []byte(*salt)}
+	if *salt == "" {
+		// Never stored, so the hashes can't be linked back to the real IDs
+		a.salt = make([]byte, 32)
+		_, _ = rand.Read(a.salt)
+	}
+	if err := run(dbFile, *outPath, from, to, a); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// writer receives the anonymized reports
+type writer interface {
+	write(r db.RawReport) error
+	close() error
+}
+
+func run(dbPath, outPath string, from, to time.Time, a *anonymizer) error {
+	if _, err := os.Stat(outPath); err == nil {
+		return fmt.Errorf("output %s already exists", outPath)
+	}
+	srcDB, err := db.OpenReadOnly(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database %s: %w", dbPath, err)
+	}
+	defer func() { _ = srcDB.Close() }()
+
+	var w writer
+	if strings.HasSuffix(outPath, ".jsonl") || strings.HasSuffix(outPath, ".jsonl.gz") {
+		w, err = newJSONLWriter(outPath)
+	} else {
+		w, err = newDBWriter(outPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	dates, err := db.SelectDates(srcDB)
+	if err != nil {
+		return errors.Join(err, w.close())
+	}
+	var total int
+	for _, date := range dates {
+		if (!from.IsZero() && date.Before(from)) || (!to.IsZero() && date.After(to)) {
+			continue
+		}
+		n, err := anonymizeDay(srcDB, date, a, w)
+		if err != nil {
+			return errors.Join(fmt.Errorf("anonymizing %s: %w", date.Format(consts.DateFormat), err), w.close())
+		}
+		log.Printf("Anonymized %d rows for %s", n, date.Format(consts.DateFormat))
+		total += n
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	log.Printf("Anonymized %d rows into %s", total, outPath) //#nosec G706 -- path is provided by the user running the tool
+	return nil
+}
+
+func anonymizeDay(srcDB *sql.DB, date time.Time, a *anonymizer, w writer) (int, error) {
+	rows, err := db.SelectRawReports(srcDB, date)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for r := range rows {
+		var data insights.Data
+		if err := json.Unmarshal([]byte(r.Data), &data); err != nil {
+			log.Printf("Skipping undecodable report: %v", err)
+			continue
+		}
+		// The row ID is the payload's ID, as stored by SaveReports
+		data.InsightsID = r.ID
+		payload, err := json.Marshal(a.anonymize(data))
+		if err != nil {
+			return n, err
+		}
+		r.ID, r.Data = a.anonymizeID(r.ID), string(payload)
+		if err := w.write(r); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// jsonlWriter writes reports as JSON Lines, gzipped if the file name ends with .gz
+type jsonlWriter struct {
+	f   *os.File
+	bw  *bufio.Writer
+	zw  *gzip.Writer
+	enc *json.Encoder
+}
+
+func newJSONLWriter(path string) (*jsonlWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, consts.FilePermissions) //#nosec G304 -- path is provided by the user running the tool
+	if err != nil {
+		return nil, fmt.Errorf("creating output file: %w", err)
+	}
+	w := &jsonlWriter{f: f, bw: bufio.NewWriter(f)}
+	var out io.Writer = w.bw
+	if strings.HasSuffix(path, ".gz") {
+		w.zw = gzip.NewWriter(w.bw)
+		out = w.zw
+	}
+	w.enc = json.NewEncoder(out)
+	return w, nil
+}
+
+func (w *jsonlWriter) write(r db.RawReport) error {
+	return w.enc.Encode(jsonlRow{ID: r.ID, Time: r.Time.UTC(), Country: r.Country, Data: json.RawMessage(r.Data)})
+}
+
+func (w *jsonlWriter) close() error {
+	var errs []error
+	if w.zw != nil {
+		errs = append(errs, w.zw.Close())
+	}
+	errs = append(errs, w.bw.Flush(), w.f.Close())
+	return errors.Join(errs...)
+}
+
+// dbWriter writes reports to a new database, with the same schema as the server's
+type dbWriter struct {
+	db    *sql.DB
+	batch []db.RawReport
+}
+
+func newDBWriter(path string) (*dbWriter, error) {
+	dbConn, err := db.OpenDB(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating output database: %w", err)
+	}
+	return &dbWriter{db: dbConn, batch: make([]db.RawReport, 0, batchSize)}, nil
+}
+
+func (w *dbWriter) write(r db.RawReport) error {
+	w.batch = append(w.batch, r)
+	if len(w.batch) < batchSize {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *dbWriter) flush() error {
+	_, err := db.ImportReports(context.Background(), w.db, w.batch)
+	w.batch = w.batch[:0]
+	return err
+}
+
+func (w *dbWriter) close() error {
+	return errors.Join(w.flush(), w.db.Close())
+}
+
+func parseOptionalDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(consts.DateFormat, s)
+}
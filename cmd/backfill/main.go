@@ -0,0 +1,129 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/navidrome/insights/clickhouse"
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/summary"
+)
+
+// backfill regenerates the summaries (in $DATA_FOLDER) of a date range from the stored reports, e.g. after
+// fixing a player type or OS mapping. Summaries of dates without reports are left untouched, so dates
+// purged from the database (older than consts.PurgeRetentionDays) keep their summary unless -clickhouse
+// is used. It is safe to run against the database of a running server.
+func main() {
+	dbPath := flag.String("db", "", "Path to insights.db (default: $DATA_FOLDER/insights.db or ./insights.db)")
+	from := flag.String("from", "", "First date to summarize (YYYY-MM-DD, required)")
+	to := flag.String("to", "", "Last date to summarize (YYYY-MM-DD, default: today)")
+	useClickHouse := flag.Bool("clickhouse", false, "Summarize from the ClickHouse store configured by CLICKHOUSE_URL, instead of the database")
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	dataFolder := config.DataFolder()
+	dbFile := cmp.Or(*dbPath, config.DBPath())
+	fromDate, toDate, err := parseRange(*from, *to)
+	if err != nil {
+		flag.Usage()
+		log.Fatalf("Error: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, dbFile, dataFolder, fromDate, toDate, *useClickHouse); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+func parseRange(from, to string) (time.Time, time.Time, error) {
+	if from == "" {
+		return time.Time{}, time.Time{}, errors.New("-from is required")
+	}
+	fromDate, err := time.Parse(consts.DateFormat, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid -from %q, expected YYYY-MM-DD", from)
+	}
+	toDate := time.Now().UTC().Truncate(24 * time.Hour)
+	if to != "" {
+		if toDate, err = time.Parse(consts.DateFormat, to); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid -to %q, expected YYYY-MM-DD", to)
+		}
+	}
+	if toDate.Before(fromDate) {
+		return time.Time{}, time.Time{}, errors.New("-to is before -from")
+	}
+	return fromDate, toDate, nil
+}
+
+func run(ctx context.Context, dbPath, dataFolder string, from, to time.Time, useClickHouse bool) error {
+	dbConn, err := db.OpenDB(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database %s: %w", dbPath, err)
+	}
+	defer func() { _ = dbConn.Close() }()
+
+	summarize := func(ctx context.Context, date time.Time) (int64, error) {
+		return summary.SummarizeData(ctx, dbConn, date)
+	}
+	if useClickHouse {
+		cfg, ok, err := clickhouse.ConfigFromEnv()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("-clickhouse requires CLICKHOUSE_URL")
+		}
+		store, err := clickhouse.New(dbConn, cfg, dataFolder)
+		if err != nil {
+			return fmt.Errorf("configuring ClickHouse: %w", err)
+		}
+		summarize = store.SummarizeData
+	}
+
+	// Same mappings as the server
+	if _, err := summary.LoadPlayerTypes(); err != nil {
+		return fmt.Errorf("loading player types: %w", err)
+	}
+	if _, err := summary.LoadFSTypes(); err != nil {
+		return fmt.Errorf("loading filesystem types: %w", err)
+	}
+	if err := summary.ConfigureOutliersFromEnv(); err != nil {
+		return err
+	}
+	if _, err := summary.LoadBins(); err != nil {
+		return fmt.Errorf("loading bins: %w", err)
+	}
+
+	var dates, empty int
+	var reports int64
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("stopped before %s: %w", date.Format(consts.DateFormat), err)
+		}
+		log.Print("Summarizing data for ", date.Format(consts.DateFormat))
+		n, err := summarize(ctx, date)
+		if err != nil {
+			return fmt.Errorf("summarizing %s: %w", date.Format(consts.DateFormat), err)
+		}
+		dates++
+		reports += n
+		if n == 0 {
+			empty++
+		}
+	}
+	log.Printf("Summarized %d dates (%d reports), %d without reports", dates-empty, reports, empty)
+	return nil
+}
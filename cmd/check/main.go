@@ -0,0 +1,108 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/db"
+)
+
+const batchSize = 5000 // rows scanned per query
+
+// check verifies a database: it runs SQLite's integrity check, then scans all reports for invalid
+// timestamps and payloads that can't be decoded, which cut short the iteration over the reports when
+// summarizing, and prints their counts per day. With -quarantine, the bad reports are moved to the
+// insights_rejected table and their days are summarized again by the next cron run. With -dedup-hourly,
+// it also deletes the earlier reports of an instance in the same hour, stored before only the latest one
+// was kept (see db.DeleteEarlierInHour). It is safe to run against the live database. Exits with status 1
+// if problems were found (and not quarantined).
+func main() {
+	dbPath := flag.String("db", "", "Path to insights.db (default: $DATA_FOLDER/insights.db or ./insights.db)")
+	quarantine := flag.Bool("quarantine", false, "Move the bad reports to the insights_rejected table")
+	dedupHourly := flag.Bool("dedup-hourly", false, "Delete the earlier reports of an instance in the same hour (irreversible)")
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	dbFile := cmp.Or(*dbPath, config.DBPath())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ok, err := run(ctx, dbFile, *quarantine, *dedupHourly)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dbPath string, quarantine, dedupHourly bool) (bool, error) {
+	dbConn, err := db.OpenDB(dbPath)
+	if err != nil {
+		return false, fmt.Errorf("opening database %s: %w", dbPath, err)
+	}
+	defer func() { _ = dbConn.Close() }()
+
+	problems, err := db.IntegrityCheck(ctx, dbConn)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range problems {
+		fmt.Printf("integrity: %s\n", p)
+	}
+	if len(problems) > 0 {
+		log.Printf("Integrity check failed: %d problems", len(problems))
+	} else {
+		log.Print("Integrity check ok")
+	}
+
+	bad, err := db.FindBadReports(ctx, dbConn, batchSize)
+	if err != nil {
+		return false, fmt.Errorf("scanning reports: %w", err)
+	}
+	printCounts(bad)
+	log.Printf("Found %d bad reports", len(bad))
+
+	if quarantine && len(bad) > 0 {
+		n, err := db.QuarantineReports(ctx, dbConn, bad)
+		if err != nil {
+			return false, fmt.Errorf("quarantining reports: %w", err)
+		}
+		log.Printf("Moved %d reports to insights_rejected", n)
+		bad = nil
+	}
+
+	if dedupHourly {
+		n, err := db.DeleteEarlierInHour(ctx, dbConn)
+		if err != nil {
+			return false, fmt.Errorf("deleting earlier reports in the hour: %w", err)
+		}
+		log.Printf("Deleted %d earlier reports in the same hour", n)
+	}
+	return len(problems) == 0 && len(bad) == 0, nil
+}
+
+// printCounts prints the number of bad reports per day and reason, the ones with an invalid time first
+func printCounts(bad []db.BadReport) {
+	type key struct{ date, reason string }
+	counts := map[key]int{}
+	for _, r := range bad {
+		counts[key{r.Date, r.Reason}]++
+	}
+	keys := slices.SortedFunc(maps.Keys(counts), func(a, b key) int {
+		return cmp.Or(cmp.Compare(a.date, b.date), cmp.Compare(a.reason, b.reason))
+	})
+	for _, k := range keys {
+		fmt.Printf("%s: %s: %d\n", cmp.Or(k.date, "(no date)"), k.reason, counts[k])
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/db"
+)
+
+const batchSize = 5000 // rows compressed per transaction
+
+// compress-data compresses the report payloads stored as plain JSON by versions before compression
+// was introduced. It is safe to run against the live database, and to run more than once.
+func main() {
+	dbPath := flag.String("db", "", "Path to insights.db (default: $DATA_FOLDER/insights.db or ./insights.db)")
+	vacuum := flag.Bool("vacuum", true, "Run VACUUM afterwards to reclaim the freed space")
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	dbFile := cmp.Or(*dbPath, config.DBPath())
+	if err := run(dbFile, *vacuum); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+func run(dbPath string, vacuum bool) error {
+	sizeBefore, err := fileSize(dbPath)
+	if err != nil {
+		return err
+	}
+	dbConn, err := db.OpenDB(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database %s: %w", dbPath, err)
+	}
+	defer func() { _ = dbConn.Close() }()
+
+	log.Printf("Compressing report payloads in %s", dbPath) //#nosec G706 -- path is provided by the user running the tool
+	n, err := db.CompressData(dbConn, batchSize)
+	if err != nil {
+		return fmt.Errorf("compressing data (%d rows done): %w", n, err)
+	}
+	log.Printf("Compressed %d rows", n)
+
+	if !vacuum {
+		return nil
+	}
+	log.Print("Vacuuming database")
+	if _, err := dbConn.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("vacuuming database: %w", err)
+	}
+	if _, err := dbConn.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("checkpointing database: %w", err)
+	}
+	sizeAfter, err := fileSize(dbPath)
+	if err != nil {
+		return err
+	}
+	log.Printf("Database size: %.1f MB -> %.1f MB", float64(sizeBefore)/(1<<20), float64(sizeAfter)/(1<<20))
+	return nil
+}
+
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -1,20 +1,23 @@
 package main
 
 import (
-	"archive/zip"
-	"crypto/md5" //#nosec G501 -- used only for deduplication, not security
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
-	"io"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/navidrome/insights/backup"
+	"github.com/navidrome/insights/config"
 	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/dedup"
 	"github.com/navidrome/insights/summary"
 	"github.com/schollz/progressbar/v3"
 )
@@ -23,6 +26,9 @@ func main() {
 	backupsPath := flag.String("backups", "", "Path to the folder containing backup zip files (required for merge)")
 	destPath := flag.String("dest", "", "Destination folder for consolidated DB and summaries (required)")
 	summariesOnly := flag.Bool("summaries-only", false, "Skip DB merge and only regenerate summaries from existing DB")
+	lowMemory := flag.Bool("low-memory", false, "Deduplicate using an on-disk key set instead of memory (slower, for very large merges)")
+	verify := flag.Bool("verify", false, "After merging, cross-check per-day instance counts of each backup against the consolidated DB")
+	configFile := config.Flag()
 	flag.Parse()
 
 	if *destPath == "" {
@@ -36,23 +42,50 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := run(*backupsPath, *destPath, *summariesOnly); err != nil {
+	if *summariesOnly && *verify {
+		fmt.Fprintf(os.Stderr, "Error: -verify cannot be used with -summaries-only\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// Same summary settings (outlier caps...) as the server. The data folder of the config is replaced by -dest
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if err := run(*backupsPath, *destPath, *summariesOnly, *lowMemory, *verify); err != nil {
 		log.Fatalf("Error: %v", err)
 	}
 }
 
-func run(backupsPath, destPath string, summariesOnly bool) error {
+func run(backupsPath, destPath string, summariesOnly, lowMemory, verify bool) error {
 	// Ensure destination folder exists
 	if err := os.MkdirAll(destPath, 0750); err != nil {
 		return fmt.Errorf("creating destination folder: %w", err)
 	}
 
-	// Set DATA_FOLDER for summary storage
-	if err := os.Setenv("DATA_FOLDER", destPath); err != nil {
-		return fmt.Errorf("setting DATA_FOLDER: %w", err)
+	// Store the summaries (and read the mappings) in the destination folder
+	if err := config.SetDataFolder(destPath); err != nil {
+		return err
+	}
+	if n, err := summary.LoadPlayerTypes(); err != nil {
+		return fmt.Errorf("loading player types: %w", err)
+	} else if n > 0 {
+		log.Printf("Loaded %d custom player type mappings", n)
+	}
+	if n, err := summary.LoadFSTypes(); err != nil {
+		return fmt.Errorf("loading filesystem types: %w", err)
+	} else if n > 0 {
+		log.Printf("Loaded %d custom filesystem type mappings", n)
+	}
+	if err := summary.ConfigureOutliersFromEnv(); err != nil {
+		return err
+	}
+	if _, err := summary.LoadBins(); err != nil {
+		return fmt.Errorf("loading bins: %w", err)
 	}
 
-	consolidatedDBPath := filepath.Join(destPath, "insights.db")
+	consolidatedDBPath := config.DBPath()
 
 	// If summaries-only mode, just regenerate summaries from existing DB
 	if summariesOnly {
@@ -71,13 +104,27 @@ func run(backupsPath, destPath string, summariesOnly bool) error {
 		return nil
 	}
 
-	// Check if output database already exists
-	if _, err := os.Stat(consolidatedDBPath); err == nil {
-		return fmt.Errorf("destination database already exists: %s", consolidatedDBPath)
+	// Load the merge manifest from previous runs. Without it, an existing database can't be safely appended to
+	manifestPath := filepath.Join(destPath, manifestFileName)
+	m, err := loadManifest(manifestPath)
+	if err != nil {
+		return fmt.Errorf("loading merge manifest: %w", err)
+	}
+	_, statErr := os.Stat(consolidatedDBPath)
+	dbExists := statErr == nil
+	if dbExists && m == nil {
+		return fmt.Errorf("destination database already exists and has no merge manifest: %s", consolidatedDBPath)
+	}
+	if m == nil {
+		m = &manifest{}
 	}
 
 	// Create consolidated database (without indexes for faster inserts)
-	log.Printf("Creating consolidated database: %s", consolidatedDBPath)
+	if dbExists {
+		log.Printf("Appending to consolidated database: %s", consolidatedDBPath)
+	} else {
+		log.Printf("Creating consolidated database: %s", consolidatedDBPath)
+	}
 	destDB, err := openDestDB(consolidatedDBPath)
 	if err != nil {
 		return fmt.Errorf("creating consolidated database: %w", err)
@@ -99,32 +146,101 @@ func run(backupsPath, destPath string, summariesOnly bool) error {
 	}
 	log.Printf("Found %d backup files", len(zipFiles))
 
-	// Track seen (id, time) pairs to avoid duplicates across backups
-	seenKeys := make(map[[16]byte]struct{})
+	// Track seen (id, time) pairs to avoid duplicates across backups, including rows merged in previous runs
+	var seenKeys dedup.KeySet = dedup.MemoryKeySet{}
+	if lowMemory {
+		log.Printf("Low-memory mode: deduplicating with an on-disk key set")
+		diskKeys, err := dedup.NewDiskKeySet()
+		if err != nil {
+			return fmt.Errorf("creating on-disk key set: %w", err)
+		}
+		seenKeys = diskKeys
+	}
+	defer func() { _ = seenKeys.Close() }()
+	if dbExists {
+		if err := dedup.LoadKeys(destDB, seenKeys); err != nil {
+			return fmt.Errorf("loading existing rows: %w", err)
+		}
+		log.Printf("Loaded %d existing rows", seenKeys.Len())
+	}
 
-	// Process each backup
+	// Process each backup not yet merged, tracking the dates that received new rows
 	var totalImported int64
+	var merged int
+	importedDates := make(map[string]struct{})
 	for i, zipFile := range zipFiles {
-		log.Printf("Processing backup %d/%d: %s", i+1, len(zipFiles), filepath.Base(zipFile))
-		imported, err := processBackup(zipFile, destDB, seenKeys)
+		name := filepath.Base(zipFile)
+		checksum, err := fileChecksum(zipFile)
 		if err != nil {
-			log.Printf("Warning: error processing %s: %v", filepath.Base(zipFile), err)
+			log.Printf("Warning: error reading %s: %v", name, err)
+			continue
 		}
+		if m.contains(name, checksum) {
+			log.Printf("Skipping backup %d/%d: %s (already merged)", i+1, len(zipFiles), name)
+			continue
+		}
+
+		log.Printf("Processing backup %d/%d: %s", i+1, len(zipFiles), name)
+		imported, err := processBackup(zipFile, destDB, seenKeys, importedDates)
 		totalImported += imported
+		if err != nil {
+			log.Printf("Warning: error processing %s: %v", name, err)
+			continue
+		}
+		merged++
+		m.add(name, checksum, imported)
+		if err := m.save(manifestPath); err != nil {
+			return fmt.Errorf("saving merge manifest: %w", err)
+		}
+	}
+	log.Printf("Total rows imported: %d from %d backups (dedup set size: %d)", totalImported, merged, seenKeys.Len())
+	if totalImported == 0 && dbExists {
+		log.Printf("Nothing new to merge")
+	} else {
+		if err := finalizeMerge(destDB, importedDates); err != nil {
+			return err
+		}
+		log.Printf("Consolidation complete!")
+	}
+
+	if verify {
+		return verifyBackups(zipFiles, destDB)
 	}
-	log.Printf("Total rows imported: %d (dedup set size: %d)", totalImported, len(seenKeys))
+	return nil
+}
 
-	// Create indexes after all imports
-	if err := createIndexes(destDB); err != nil {
-		return fmt.Errorf("creating indexes: %w", err)
+// finalizeMerge migrates the schema (creating the indexes and tables missing from the bulk import table),
+// rebuilds the instances table and regenerates summaries for the imported dates
+func finalizeMerge(destDB *sql.DB, importedDates map[string]struct{}) error {
+	// Create indexes and tables after all imports
+	log.Printf("Migrating schema...")
+	if err := db.Migrate(destDB); err != nil {
+		return fmt.Errorf("migrating schema: %w", err)
 	}
 
-	// Generate summaries for all dates in the consolidated database
-	if err := generateAllSummaries(destDB); err != nil {
-		return fmt.Errorf("generating summaries: %w", err)
+	// Imported rows only carry the payload, extract the columns used by queries
+	log.Printf("Extracting report columns...")
+	if _, err := db.BackfillReportColumns(destDB); err != nil {
+		return fmt.Errorf("extracting report columns: %w", err)
 	}
 
-	log.Printf("Consolidation complete!")
+	// Track first/last seen times for all instances, used for new/churned counts
+	log.Printf("Building instances table...")
+	if err := db.RebuildInstances(destDB); err != nil {
+		return fmt.Errorf("building instances table: %w", err)
+	}
+
+	// Track the latest report of each instance per day, read by SelectData
+	log.Printf("Building latest reports table...")
+	if err := db.RebuildLatestReports(destDB); err != nil {
+		return fmt.Errorf("building latest reports table: %w", err)
+	}
+
+	// Generate summaries for all dates that received new rows
+	dates := slices.Sorted(maps.Keys(importedDates))
+	if err := generateSummaries(destDB, dates); err != nil {
+		return fmt.Errorf("generating summaries: %w", err)
+	}
 	return nil
 }
 
@@ -149,90 +265,15 @@ func findBackupZips(backupsPath string) ([]string, error) {
 	return zipFiles, nil
 }
 
-func processBackup(zipPath string, destDB *sql.DB, seenKeys map[[16]byte]struct{}) (int64, error) {
-	// Create temp directory for extraction
-	tempDir, err := os.MkdirTemp("", "insights-backup-*")
-	log.Printf("Extracting backup to temp dir: %s", tempDir)
-	if err != nil {
-		return 0, fmt.Errorf("creating temp directory: %w", err)
-	}
-	defer func() { _ = os.RemoveAll(tempDir) }()
-
-	// Extract insights.db from zip
-	dbPath, err := extractDB(zipPath, tempDir)
-	if err != nil {
-		return 0, fmt.Errorf("extracting database: %w", err)
-	}
-
-	// Open source database
-	srcDB, err := db.OpenDB(dbPath)
+func processBackup(zipPath string, destDB *sql.DB, seenKeys dedup.KeySet, importedDates map[string]struct{}) (int64, error) {
+	srcDB, cleanup, err := backup.Open(zipPath)
 	if err != nil {
-		return 0, fmt.Errorf("opening source database: %w", err)
+		return 0, err
 	}
-	defer func() { _ = srcDB.Close() }()
+	defer cleanup()
 
 	// Import data
-	return importData(zipPath, srcDB, destDB, seenKeys)
-}
-
-func extractDB(zipPath, destDir string) (string, error) {
-	r, err := zip.OpenReader(zipPath)
-	if err != nil {
-		return "", err
-	}
-	defer func() { _ = r.Close() }()
-
-	var dbFile *zip.File
-	for _, f := range r.File {
-		// Skip macOS metadata files and look for insights.db
-		if strings.HasPrefix(f.Name, "__MACOSX") {
-			continue
-		}
-		if filepath.Base(f.Name) == "insights.db" {
-			dbFile = f
-			break
-		}
-	}
-
-	if dbFile == nil {
-		return "", fmt.Errorf("insights.db not found in zip")
-	}
-
-	// Extract the database file
-	destPath := filepath.Join(destDir, "insights.db")
-	if err := extractFile(dbFile, destPath); err != nil {
-		return "", err
-	}
-
-	// Also extract WAL and SHM files if present (for consistency)
-	for _, f := range r.File {
-		if strings.HasPrefix(f.Name, "__MACOSX") {
-			continue
-		}
-		base := filepath.Base(f.Name)
-		if base == "insights.db-wal" || base == "insights.db-shm" {
-			_ = extractFile(f, filepath.Join(destDir, base))
-		}
-	}
-
-	return destPath, nil
-}
-
-func extractFile(f *zip.File, destPath string) error {
-	rc, err := f.Open()
-	if err != nil {
-		return err
-	}
-	defer func() { _ = rc.Close() }()
-
-	outFile, err := os.Create(destPath) //#nosec G304 -- destPath is controlled
-	if err != nil {
-		return err
-	}
-	defer func() { _ = outFile.Close() }()
-
-	_, err = io.Copy(outFile, rc) //#nosec G110 -- src is controlled
-	return err
+	return importData(zipPath, srcDB, destDB, seenKeys, importedDates)
 }
 
 const (
@@ -240,7 +281,11 @@ const (
 	insertBatchSize = 5000  // rows per multi-value INSERT statement
 )
 
-type row struct{ id, t, data string }
+type row struct {
+	id, t   string
+	data    []byte // As stored, possibly compressed (see db.EncodeData)
+	country sql.NullString
+}
 
 func applyBulkPragmas(db *sql.DB) error {
 	pragmas := []string{
@@ -274,7 +319,8 @@ func openDestDB(fileName string) (*sql.DB, error) {
 CREATE TABLE IF NOT EXISTS insights (
 	id VARCHAR NOT NULL,
 	time DATETIME default CURRENT_TIMESTAMP,
-	data JSONB
+	data JSONB,
+	country VARCHAR
 )`
 	if _, err := db.Exec(createTableQuery); err != nil {
 		return nil, fmt.Errorf("creating table: %w", err)
@@ -284,21 +330,7 @@ CREATE TABLE IF NOT EXISTS insights (
 	return db, nil
 }
 
-func createIndexes(db *sql.DB) error {
-	log.Printf("Creating indexes...")
-	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS insights_time ON insights(time)"); err != nil {
-		return err
-	}
-	_, err := db.Exec("CREATE INDEX IF NOT EXISTS insights_id_time ON insights(id, time)")
-	return err
-}
-
-// hashKey creates an MD5 hash of the (id, time) pair for deduplication
-func hashKey(id, t string) [16]byte {
-	return md5.Sum([]byte(id + "\x00" + t)) //#nosec G401 -- used only for deduplication, not security
-}
-
-func importData(srcName string, srcDB, destDB *sql.DB, seenKeys map[[16]byte]struct{}) (int64, error) {
+func importData(srcName string, srcDB, destDB *sql.DB, seenKeys dedup.KeySet, importedDates map[string]struct{}) (int64, error) {
 	// Get row count for progress bar
 	var rowCount int64
 	countSQL := "SELECT COUNT(*) FROM insights"
@@ -307,7 +339,7 @@ func importData(srcName string, srcDB, destDB *sql.DB, seenKeys map[[16]byte]str
 	}
 
 	// Query all data from source
-	rows, err := srcDB.Query("SELECT id, time, data FROM insights")
+	rows, err := srcDB.Query("SELECT id, time, data, country FROM insights")
 	if err != nil {
 		return 0, fmt.Errorf("querying source database: %w", err)
 	}
@@ -328,21 +360,26 @@ func importData(srcName string, srcDB, destDB *sql.DB, seenKeys map[[16]byte]str
 
 	for rows.Next() {
 		var r row
-		if err := rows.Scan(&r.id, &r.t, &r.data); err != nil {
+		if err := rows.Scan(&r.id, &r.t, &r.data, &r.country); err != nil {
 			log.Printf("\nWarning: error scanning row: %v", err)
 			continue
 		}
 		totalScanned++
 
 		// Skip duplicates using hash set
-		key := hashKey(r.id, r.t)
-		if _, seen := seenKeys[key]; seen {
+		isNew, err := seenKeys.Add(dedup.NewKey(r.id, r.t))
+		if err != nil {
+			return totalImported, fmt.Errorf("deduplicating rows: %w", err)
+		}
+		if !isNew {
 			if totalScanned%int64(batchSize) == 0 {
 				_ = bar.Add(batchSize)
 			}
 			continue
 		}
-		seenKeys[key] = struct{}{}
+		if len(r.t) >= len("2006-01-02") {
+			importedDates[r.t[:len("2006-01-02")]] = struct{}{}
+		}
 
 		batch = append(batch, r)
 
@@ -374,12 +411,12 @@ func importData(srcName string, srcDB, destDB *sql.DB, seenKeys map[[16]byte]str
 // buildMultiInsertSQL builds a multi-value INSERT statement for n rows
 func buildMultiInsertSQL(n int) string {
 	var sb strings.Builder
-	sb.WriteString("INSERT INTO insights (id, time, data) VALUES ")
+	sb.WriteString("INSERT INTO insights (id, time, data, country) VALUES ")
 	for i := range n {
 		if i > 0 {
 			sb.WriteByte(',')
 		}
-		sb.WriteString("(?,?,?)")
+		sb.WriteString("(?,?,?,?)")
 	}
 	return sb.String()
 }
@@ -427,9 +464,9 @@ func insertBatch(db *sql.DB, batch []row) (int64, error) {
 			return totalImported, fmt.Errorf("preparing statement: %w", err)
 		}
 
-		args := make([]any, 0, len(chunk)*3)
+		args := make([]any, 0, len(chunk)*4)
 		for _, r := range chunk {
-			args = append(args, r.id, r.t, r.data)
+			args = append(args, r.id, r.t, r.data, r.country)
 		}
 
 		result, err := stmt.Exec(args...)
@@ -466,6 +503,10 @@ func generateAllSummaries(db *sql.DB) error {
 		return err
 	}
 
+	return generateSummaries(db, dates)
+}
+
+func generateSummaries(db *sql.DB, dates []string) error {
 	bar := progressbar.NewOptions(len(dates),
 		progressbar.OptionSetDescription("Generating summaries"),
 		progressbar.OptionShowCount(),
@@ -481,7 +522,7 @@ func generateAllSummaries(db *sql.DB) error {
 			continue
 		}
 
-		if err := summary.SummarizeData(db, date); err != nil {
+		if _, err := summary.SummarizeData(context.Background(), db, date); err != nil {
 			log.Printf("\nWarning: error summarizing %s: %v", dateStr, err)
 		}
 		_ = bar.Add(1)
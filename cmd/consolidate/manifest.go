@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+)
+
+const manifestFileName = "consolidate-manifest.json"
+
+// manifest records which backup zips were already merged into the consolidated database,
+// so subsequent runs only process new backups
+type manifest struct {
+	Backups []manifestEntry `json:"backups"`
+}
+
+type manifestEntry struct {
+	Name     string    `json:"name"`
+	SHA256   string    `json:"sha256"`
+	Rows     int64     `json:"rows"`
+	MergedAt time.Time `json:"mergedAt"`
+}
+
+// loadManifest reads the manifest file. Returns nil if it does not exist.
+func loadManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path) //#nosec G304 -- path is built from the destination folder
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *manifest) save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, consts.FilePermissions)
+}
+
+func (m *manifest) contains(name, checksum string) bool {
+	for _, e := range m.Backups {
+		if e.Name == name && e.SHA256 == checksum {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *manifest) add(name, checksum string, rows int64) {
+	m.Backups = append(m.Backups, manifestEntry{
+		Name:     name,
+		SHA256:   checksum,
+		Rows:     rows,
+		MergedAt: time.Now().UTC(),
+	})
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path) //#nosec G304 -- path is from the backups folder listing
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/navidrome/insights/backup"
+)
+
+// discrepancy is a day where the consolidated DB has fewer distinct instances than a source backup.
+// Since the consolidated DB is a union of all backups, this should never happen
+type discrepancy struct {
+	backup       string
+	date         string
+	source       int64
+	consolidated int64
+}
+
+// verifyBackups compares the per-day distinct instance counts of each backup against the consolidated DB
+// and prints a report of all discrepancies found
+func verifyBackups(zipFiles []string, destDB *sql.DB) error {
+	log.Printf("Verifying %d backups against consolidated database...", len(zipFiles))
+	destCounts, err := dailyInstanceCounts(destDB)
+	if err != nil {
+		return fmt.Errorf("counting consolidated instances: %w", err)
+	}
+
+	var found []discrepancy
+	var checked int
+	for _, zipFile := range zipFiles {
+		name := filepath.Base(zipFile)
+		srcCounts, err := backupDailyInstanceCounts(zipFile)
+		if err != nil {
+			log.Printf("Warning: error verifying %s: %v", name, err)
+			continue
+		}
+		checked++
+		for _, date := range slices.Sorted(maps.Keys(srcCounts)) {
+			if destCounts[date] < srcCounts[date] {
+				found = append(found, discrepancy{
+					backup:       name,
+					date:         date,
+					source:       srcCounts[date],
+					consolidated: destCounts[date],
+				})
+			}
+		}
+	}
+
+	fmt.Printf("\n=== Verification Report ===\n")
+	fmt.Printf("Backups checked: %d/%d\n", checked, len(zipFiles))
+	fmt.Printf("Days in consolidated DB: %d\n", len(destCounts))
+	if len(found) == 0 {
+		fmt.Printf("No discrepancies found\n")
+		return nil
+	}
+
+	fmt.Printf("Discrepancies: %d\n\n", len(found))
+	fmt.Printf("  %-30s %-10s %8s %12s\n", "Backup", "Date", "Source", "Consolidated")
+	for _, d := range found {
+		fmt.Printf("  %-30s %-10s %8d %12d\n", d.backup, d.date, d.source, d.consolidated)
+	}
+	return fmt.Errorf("verification found %d discrepancies", len(found))
+}
+
+func backupDailyInstanceCounts(zipPath string) (map[string]int64, error) {
+	srcDB, cleanup, err := backup.Open(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return dailyInstanceCounts(srcDB)
+}
+
+// dailyInstanceCounts returns the number of distinct instances per day (YYYY-MM-DD)
+func dailyInstanceCounts(dbConn *sql.DB) (map[string]int64, error) {
+	rows, err := dbConn.Query(`SELECT date(time), COUNT(DISTINCT id) FROM insights
+WHERE date(time) IS NOT NULL GROUP BY date(time)`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var date string
+		var count int64
+		if err := rows.Scan(&date, &count); err != nil {
+			return nil, err
+		}
+		counts[date] = count
+	}
+	return counts, rows.Err()
+}
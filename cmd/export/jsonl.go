@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+)
+
+// jsonlRow is a raw report, one per line in the JSONL export. Read back by cmd/import
+type jsonlRow struct {
+	ID      string          `json:"id"`
+	Time    time.Time       `json:"time"`
+	Country string          `json:"country,omitempty"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// runJSONL streams the raw reports in the range to outPath (default: stdout) as JSON Lines, ordered by time.
+// The output is gzipped if outPath ends with .gz
+func runJSONL(dbPath, outPath string, from, to time.Time) error {
+	dbConn, err := db.OpenReadOnly(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database %s: %w", dbPath, err)
+	}
+	defer func() { _ = dbConn.Close() }()
+
+	var out io.Writer = os.Stdout
+	if outPath != "" {
+		f, err := os.Create(outPath) //#nosec G304 -- path is provided by the user running the tool
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+	out = bw
+	var zw *gzip.Writer
+	if strings.HasSuffix(outPath, ".gz") {
+		zw = gzip.NewWriter(bw)
+		out = zw
+	}
+
+	if err := exportJSONL(dbConn, out, from, to); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func exportJSONL(dbConn *sql.DB, out io.Writer, from, to time.Time) error {
+	dates, err := db.SelectDates(dbConn)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	var total int
+	for _, date := range dates {
+		if (!from.IsZero() && date.Before(from)) || (!to.IsZero() && date.After(to)) {
+			continue
+		}
+		rows, err := db.SelectRawReports(dbConn, date)
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", date.Format(consts.DateFormat), err)
+		}
+		var n int
+		for r := range rows {
+			row := jsonlRow{ID: r.ID, Time: r.Time.UTC(), Country: r.Country, Data: json.RawMessage(r.Data)}
+			if err := enc.Encode(row); err != nil {
+				return fmt.Errorf("exporting %s: %w", date.Format(consts.DateFormat), err)
+			}
+			n++
+		}
+		log.Printf("Exported %d rows for %s", n, date.Format(consts.DateFormat))
+		total += n
+	}
+	log.Printf("Exported %d rows", total)
+	return nil
+}
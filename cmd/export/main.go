@@ -0,0 +1,110 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/summary"
+)
+
+func main() {
+	format := flag.String("format", "csv", "Export format: csv (summaries), parquet or jsonl (raw reports)")
+	outPath := flag.String("out", "", "Output file for csv and jsonl (default: stdout, gzipped if it ends with .gz), or output folder for parquet (required)")
+	dbPath := flag.String("db", "", "Path to insights.db, for parquet and jsonl (default: $DATA_FOLDER/insights.db or ./insights.db)")
+	fromStr := flag.String("from", "", "First date to export (YYYY-MM-DD, default: unbounded)")
+	toStr := flag.String("to", "", "Last date to export (YYYY-MM-DD, default: unbounded)")
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	from, err := parseOptionalDate(*fromStr)
+	if err != nil {
+		log.Fatalf("Error: invalid -from: %v", err)
+	}
+	to, err := parseOptionalDate(*toStr)
+	if err != nil {
+		log.Fatalf("Error: invalid -to: %v", err)
+	}
+
+	// The format can be omitted when exporting to a .jsonl or .jsonl.gz file
+	formatSet := false
+	flag.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "format" })
+	if !formatSet && (strings.HasSuffix(*outPath, ".jsonl") || strings.HasSuffix(*outPath, ".jsonl.gz")) {
+		*format = "jsonl"
+	}
+
+	dbFile := cmp.Or(*dbPath, config.DBPath())
+	switch *format {
+	case "parquet":
+		if *outPath == "" {
+			fmt.Fprintf(os.Stderr, "Error: -out is required for parquet format\n")
+			flag.Usage()
+			os.Exit(1)
+		}
+		if err := runParquet(dbFile, *outPath, from, to); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	case "jsonl":
+		if err := runJSONL(dbFile, *outPath, from, to); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if err := run(*format, *outPath, from, to); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+func runParquet(dbPath, outDir string, from, to time.Time) error {
+	dbConn, err := db.OpenReadOnly(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database %s: %w", dbPath, err)
+	}
+	defer func() { _ = dbConn.Close() }()
+
+	return exportParquet(dbConn, outDir, from, to)
+}
+
+func run(format, outPath string, from, to time.Time) error {
+	var out io.Writer = os.Stdout
+	if outPath != "" {
+		f, err := os.Create(outPath) //#nosec G304 -- path is provided by the user running the tool
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+
+	switch format {
+	case "csv":
+		summaries, err := summary.GetSummaries()
+		if err != nil {
+			return fmt.Errorf("loading summaries: %w", err)
+		}
+		summaries = summary.FilterSummaries(summaries, from, to)
+		return summary.WriteCSV(out, summaries)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func parseOptionalDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(consts.DateFormat, s)
+}
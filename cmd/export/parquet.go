@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/parquet-go/parquet-go"
+)
+
+const parquetBatchSize = 1000
+
+type parquetRow struct {
+	ID      string    `parquet:"id"`
+	Time    time.Time `parquet:"time,timestamp(millisecond)"`
+	Country string    `parquet:"country"`
+	Data    string    `parquet:"data"`
+}
+
+// exportParquet writes the raw reports for each day in the range to its own Parquet file,
+// using Hive-style partitioning: <outDir>/date=YYYY-MM-DD/insights.parquet
+func exportParquet(dbConn *sql.DB, outDir string, from, to time.Time) error {
+	dates, err := db.SelectDates(dbConn)
+	if err != nil {
+		return err
+	}
+
+	var exported int
+	for _, date := range dates {
+		if (!from.IsZero() && date.Before(from)) || (!to.IsZero() && date.After(to)) {
+			continue
+		}
+		n, err := exportParquetDay(dbConn, outDir, date)
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", date.Format(consts.DateFormat), err)
+		}
+		log.Printf("Exported %d rows for %s", n, date.Format(consts.DateFormat))
+		exported++
+	}
+	log.Printf("Exported %d days to %s", exported, outDir)
+	return nil
+}
+
+func exportParquetDay(dbConn *sql.DB, outDir string, date time.Time) (int, error) {
+	rows, err := db.SelectRawReports(dbConn, date)
+	if err != nil {
+		return 0, err
+	}
+
+	dir := filepath.Join(outDir, "date="+date.Format(consts.DateFormat))
+	if err := os.MkdirAll(dir, consts.DirPermissions); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(filepath.Join(dir, "insights.parquet")) //#nosec G304 -- path is built from user provided output folder
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	writer := parquet.NewGenericWriter[parquetRow](f, parquet.Compression(&parquet.Zstd))
+	var total int
+	batch := make([]parquetRow, 0, parquetBatchSize)
+	for r := range rows {
+		batch = append(batch, parquetRow{ID: r.ID, Time: r.Time, Country: r.Country, Data: r.Data})
+		if len(batch) == parquetBatchSize {
+			if _, err := writer.Write(batch); err != nil {
+				return total, err
+			}
+			total += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if _, err := writer.Write(batch); err != nil {
+		return total, err
+	}
+	total += len(batch)
+
+	if err := writer.Close(); err != nil {
+		return total, err
+	}
+	return total, f.Close()
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/dedup"
+	"github.com/navidrome/insights/summary"
+)
+
+const batchSize = 1000 // reports inserted per transaction
+
+// jsonlRow is a raw report, one per line, as written by cmd/export -format jsonl
+type jsonlRow struct {
+	ID      string          `json:"id"`
+	Time    time.Time       `json:"time"`
+	Country string          `json:"country,omitempty"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// import reads raw reports exported by cmd/export (JSON Lines, gzipped if the file name ends with .gz) into
+// a database, skipping the reports already present (same (id, time) deduplication as cmd/consolidate). It is
+// safe to run against the database of a running server, and to import the same files more than once.
+// Note that a running server still purges the reports older than consts.PurgeRetentionDays, keeping the summaries.
+func main() {
+	dbPath := flag.String("db", "", "Path to insights.db (default: $DATA_FOLDER/insights.db or ./insights.db)")
+	summarize := flag.Bool("summarize", true, "Regenerate the summaries (in $DATA_FOLDER) of the dates that received new reports")
+	lowMemory := flag.Bool("low-memory", false, "Deduplicate using an on-disk key set instead of memory (slower, for very large databases)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] file.jsonl[.gz]...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	dbFile := cmp.Or(*dbPath, config.DBPath())
+	if err := run(dbFile, flag.Args(), *summarize, *lowMemory); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+func run(dbPath string, files []string, summarize, lowMemory bool) error {
+	ctx := context.Background()
+	dbConn, err := db.OpenDB(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening database %s: %w", dbPath, err)
+	}
+	defer func() { _ = dbConn.Close() }()
+
+	var seenKeys dedup.KeySet = dedup.MemoryKeySet{}
+	if lowMemory {
+		log.Printf("Low-memory mode: deduplicating with an on-disk key set")
+		diskKeys, err := dedup.NewDiskKeySet()
+		if err != nil {
+			return fmt.Errorf("creating on-disk key set: %w", err)
+		}
+		seenKeys = diskKeys
+	}
+	defer func() { _ = seenKeys.Close() }()
+	if err := dedup.LoadKeys(dbConn, seenKeys); err != nil {
+		return fmt.Errorf("loading existing rows: %w", err)
+	}
+	log.Printf("Loaded %d existing rows", seenKeys.Len())
+
+	importedDates := make(map[time.Time]struct{})
+	var totalImported int64
+	for _, file := range files {
+		imported, skipped, err := importFile(ctx, dbConn, file, seenKeys, importedDates)
+		totalImported += imported
+		if err != nil {
+			return fmt.Errorf("importing %s: %w", file, err)
+		}
+		log.Printf("Imported %d reports from %s (%d duplicates skipped)", imported, file, skipped) //#nosec G706 -- path is provided by the user running the tool
+	}
+	log.Printf("Total reports imported: %d", totalImported)
+	if !summarize || len(importedDates) == 0 {
+		return nil
+	}
+
+	if _, err := summary.LoadPlayerTypes(); err != nil {
+		return fmt.Errorf("loading player types: %w", err)
+	}
+	if _, err := summary.LoadFSTypes(); err != nil {
+		return fmt.Errorf("loading filesystem types: %w", err)
+	}
+	if err := summary.ConfigureOutliersFromEnv(); err != nil {
+		return err
+	}
+	if _, err := summary.LoadBins(); err != nil {
+		return fmt.Errorf("loading bins: %w", err)
+	}
+	dates := slices.SortedFunc(maps.Keys(importedDates), func(a, b time.Time) int { return a.Compare(b) })
+	for _, date := range dates {
+		log.Print("Summarizing data for ", date.Format(consts.DateFormat))
+		if _, err := summary.SummarizeData(ctx, dbConn, date); err != nil {
+			return fmt.Errorf("summarizing %s: %w", date.Format(consts.DateFormat), err)
+		}
+	}
+	return nil
+}
+
+// importFile imports the reports of a JSONL file not present in seenKeys, recording the dates of the
+// imported reports. Returns the number of imported and skipped (duplicate) reports
+func importFile(ctx context.Context, dbConn *sql.DB, path string, seenKeys dedup.KeySet, importedDates map[time.Time]struct{}) (imported, skipped int64, err error) {
+	f, err := os.Open(path) //#nosec G304 -- path is provided by the user running the tool
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = f.Close() }()
+	var in io.Reader = bufio.NewReader(f)
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(in)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer func() { _ = zr.Close() }()
+		in = zr
+	}
+
+	batch := make([]db.RawReport, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		stored, err := db.ImportReports(ctx, dbConn, batch)
+		if err != nil {
+			return err
+		}
+		imported += stored
+		batch = batch[:0]
+		return nil
+	}
+
+	dec := json.NewDecoder(in)
+	for n := 1; ; n++ {
+		var row jsonlRow
+		if err := dec.Decode(&row); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return imported, skipped, fmt.Errorf("row %d: %w", n, err)
+		}
+		if row.ID == "" || row.Time.IsZero() || len(row.Data) == 0 {
+			return imported, skipped, fmt.Errorf("row %d: id, time and data are required", n)
+		}
+
+		// Keys are built from the time as read back from the database (see dedup.LoadKeys)
+		t := row.Time.UTC()
+		isNew, err := seenKeys.Add(dedup.NewKey(row.ID, t.Format(time.RFC3339Nano)))
+		if err != nil {
+			return imported, skipped, fmt.Errorf("deduplicating rows: %w", err)
+		}
+		if !isNew {
+			skipped++
+			continue
+		}
+		importedDates[t.Truncate(24*time.Hour)] = struct{}{}
+		batch = append(batch, db.RawReport{ID: row.ID, Time: t, Data: string(row.Data), Country: row.Country})
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return imported, skipped, err
+			}
+		}
+	}
+	return imported, skipped, flush()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// loadgen POSTs synthetic reports of a set of instances to a /collect endpoint at a given rate, for load
+// testing and validating staging deployments. Each request is sent with a different X-Forwarded-For address
+// per instance, so the per-IP rate limit of the server (which trusts that header) doesn't reject them.
+// Never point it to the production server: the reports would be stored like real ones.
+func main() {
+	url := flag.String("url", "http://localhost:8080/collect", "Target /collect endpoint")
+	instances := flag.Int("instances", 1000, "Number of distinct instances reporting")
+	rate := flag.Float64("rate", 10, "Reports per second")
+	count := flag.Int("count", 0, "Total reports to send (default: one per instance)")
+	duration := flag.Duration("duration", 0, "Stop after this duration, even if -count reports were not sent")
+	workers := flag.Int("workers", 8, "Concurrent requests")
+	versions := flag.String("versions", "0.58.0=60,0.57.0=25,0.56.1=10,0.55.2=5", "Version mix, as version=weight pairs")
+	tracks := flag.Float64("tracks", 8000, "Median library size, in tracks")
+	seed := flag.Uint64("seed", 1, "Seed for the synthetic data. The same seed generates the same instances")
+	compress := flag.Bool("gzip", false, "Send gzip-compressed payloads")
+	flag.Parse()
+
+	if *instances < 1 || *rate <= 0 || *workers < 1 || *tracks < 1 {
+		log.Fatal("Error: -instances, -rate, -workers and -tracks must be positive")
+	}
+	mix, err := parseVersionMix(*versions)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	total := *count
+	if total <= 0 {
+		total = *instances
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	l := &loadgen{
+		url:      *url,
+		gen:      &generator{seed: *seed, versions: mix, medianTrack: *tracks},
+		compress: *compress,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		statuses: map[string]int{},
+	}
+	log.Printf("Sending %d reports from %d instances to %s at %g/s", total, *instances, *url, *rate) //#nosec G706 -- url is provided by the user running the tool
+	start := time.Now()
+	l.run(ctx, total, *instances, *rate, *workers)
+	l.printStats(time.Since(start))
+}
+
+type loadgen struct {
+	url      string
+	gen      *generator
+	compress bool
+	client   *http.Client
+
+	mu        sync.Mutex
+	statuses  map[string]int // Responses by status code, or "error" for requests that failed
+	latencies []time.Duration
+}
+
+// run sends total reports, cycling through the instances, paced at rate reports per second.
+// Stops early when the context is done
+func (l *loadgen) run(ctx context.Context, total, instances int, rate float64, workers int) {
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			for i := range queue {
+				l.send(ctx, i%instances)
+			}
+		})
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	progress := time.NewTicker(10 * time.Second)
+	defer progress.Stop()
+	for i := 0; i < total; {
+		select {
+		case <-ctx.Done():
+			i = total
+		case <-progress.C:
+			log.Printf("Sent %d/%d reports", i, total)
+		case <-ticker.C:
+			queue <- i
+			i++
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (l *loadgen) send(ctx context.Context, index int) {
+	body, err := json.Marshal(l.gen.instanceData(index))
+	if err != nil {
+		log.Printf("Error encoding report: %v", err)
+		return
+	}
+	if l.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		body = buf.Bytes()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error creating request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	// A stable private address per instance, so each instance is rate limited on its own
+	req.Header.Set("X-Forwarded-For", fmt.Sprintf("10.%d.%d.%d", index>>16&0xff, index>>8&0xff, index&0xff))
+
+	start := time.Now()
+	resp, err := l.client.Do(req) //#nosec G704 -- URL is provided by the user running the tool
+	elapsed := time.Since(start)
+	status := "error"
+	if err == nil {
+		status = resp.Status
+		_ = resp.Body.Close()
+	} else if ctx.Err() != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.statuses[status]++
+	l.latencies = append(l.latencies, elapsed)
+}
+
+func (l *loadgen) printStats(elapsed time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Printf("Sent %d reports in %s (%.1f/s)\n", len(l.latencies), elapsed.Round(time.Millisecond),
+		float64(len(l.latencies))/elapsed.Seconds())
+	statuses := make([]string, 0, len(l.statuses))
+	for s := range l.statuses {
+		statuses = append(statuses, s)
+	}
+	slices.Sort(statuses)
+	for _, s := range statuses {
+		fmt.Printf("  %-25s %d\n", s, l.statuses[s])
+	}
+	if len(l.latencies) == 0 {
+		return
+	}
+	slices.Sort(l.latencies)
+	percentile := func(p float64) time.Duration {
+		return l.latencies[int(p*float64(len(l.latencies)-1))].Round(time.Microsecond)
+	}
+	fmt.Printf("Latency: p50 %s, p95 %s, p99 %s, max %s\n", percentile(0.5), percentile(0.95), percentile(0.99),
+		l.latencies[len(l.latencies)-1].Round(time.Microsecond))
+}
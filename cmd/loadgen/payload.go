@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// choice is a value picked with a probability proportional to its weight
+type choice struct {
+	value  string
+	weight float64
+}
+
+func pick(r *rand.Rand, choices []choice) string {
+	var total float64
+	for _, c := range choices {
+		total += c.weight
+	}
+	n := r.Float64() * total
+	for _, c := range choices {
+		if n < c.weight {
+			return c.value
+		}
+		n -= c.weight
+	}
+	return choices[len(choices)-1].value
+}
+
+// parseVersionMix parses a version mix like "0.58.0=60,0.57.0=30,0.56.1=10"
+func parseVersionMix(s string) ([]choice, error) {
+	var choices []choice
+	for entry := range strings.SplitSeq(s, ",") {
+		version, weight, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || version == "" {
+			return nil, fmt.Errorf("invalid version mix entry %q, expected version=weight", entry)
+		}
+		w, err := strconv.ParseFloat(weight, 64)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("invalid weight in version mix entry %q", entry)
+		}
+		choices = append(choices, choice{value: version, weight: w})
+	}
+	return choices, nil
+}
+
+// Distributions of the values that don't need to be configurable, roughly matching the real data
+var (
+	osTypes = []choice{{"linux", 80}, {"windows", 8}, {"darwin", 5}, {"freebsd", 5}, {"openbsd", 2}}
+	distros = []choice{{"debian", 40}, {"ubuntu", 25}, {"alpine", 15}, {"arch", 10}, {"fedora", 10}}
+	arches  = []choice{{"amd64", 70}, {"arm64", 22}, {"arm", 8}}
+	fsTypes = []choice{{"ext4", 45}, {"btrfs", 12}, {"zfs", 12}, {"nfs", 10}, {"cifs", 8}, {"xfs", 6}, {"fuse", 4}, {"ntfs", 3}}
+	players = []choice{
+		{"NavidromeUI_1.0", 90}, {"Symfonium", 35}, {"DSub", 15}, {"Substreamer", 12}, {"supersonic", 8},
+		{"Tempo", 8}, {"play:Sub", 6}, {"Ultrasonic", 6}, {"SubMusic", 3}, {"multi-scrobbler", 2},
+	}
+	suffixes = []string{"mp3", "flac", "m4a", "opus", "ogg"}
+	numCPUs  = []choice{{"1", 5}, {"2", 20}, {"4", 40}, {"8", 25}, {"16", 10}}
+)
+
+// generator synthesizes the reports of a fixed set of instances. Each instance's attributes are derived
+// from the seed and its index, so all reports of an instance are consistent, as in real data
+type generator struct {
+	seed        uint64
+	versions    []choice
+	medianTrack float64
+}
+
+// instanceData returns the report of the instance with the given index
+func (g *generator) instanceData(index int) insights.Data {
+	r := rand.New(rand.NewPCG(g.seed, uint64(index))) //#nosec G404 -- synthetic data, not security sensitive
+
+	var data insights.Data
+	data.InsightsID = fmt.Sprintf("%08x-%04x-4%03x-8%03x-%012x", r.Uint32(), r.Uint32()&0xffff, r.Uint32()&0xfff,
+		r.Uint32()&0xfff, r.Uint64()&0xffffffffffff)
+	version := pick(r, g.versions)
+	data.Version = fmt.Sprintf("%s (%08x)", version, crc32.ChecksumIEEE([]byte(version))) // Same fake commit per version
+	data.Uptime = r.Int64N(30 * 24 * 3600)
+	data.Build.GoVersion = "go1.24.4"
+
+	data.OS.Type = pick(r, osTypes)
+	if data.OS.Type == "linux" {
+		data.OS.Containerized = r.Float64() < 0.75
+		if !data.OS.Containerized {
+			data.OS.Distro = pick(r, distros)
+		}
+	}
+	data.OS.Arch = pick(r, arches)
+	data.OS.NumCPU, _ = strconv.Atoi(pick(r, numCPUs))
+	data.Mem.Sys = uint64(50+r.IntN(400)) << 20
+	data.Mem.Alloc = data.Mem.Sys / 2
+	data.Mem.TotalAlloc = data.Mem.Sys * uint64(10+r.IntN(1000))
+	data.Mem.NumGC = uint32(r.IntN(100000))
+
+	data.FS.Music = &insights.FSInfo{Type: pick(r, fsTypes)}
+	data.FS.Data = &insights.FSInfo{Type: pick(r, fsTypes)}
+
+	// Library sizes are log-normally distributed around the median
+	tracks := int64(g.medianTrack * math.Exp(1.2*r.NormFloat64()))
+	data.Library.Tracks = max(tracks, 1)
+	data.Library.Albums = max(data.Library.Tracks/int64(8+r.IntN(8)), 1)
+	data.Library.Artists = max(data.Library.Albums/int64(2+r.IntN(3)), 1)
+	data.Library.Playlists = r.Int64N(50)
+	data.Library.Shares = r.Int64N(5)
+	data.Library.Radios = r.Int64N(10)
+	data.Library.Libraries = 1 + r.Int64N(2)
+	data.Library.ActiveUsers = 1 + r.Int64N(4)
+	data.Library.ActivePlayers = map[string]int64{}
+	for range 1 + r.IntN(3) {
+		data.Library.ActivePlayers[pick(r, players)] = 1 + r.Int64N(data.Library.ActiveUsers)
+	}
+	data.Library.FileSuffixes = map[string]int64{}
+	remaining := data.Library.Tracks
+	for i, suffix := range suffixes {
+		if remaining == 0 {
+			break
+		}
+		n := remaining
+		if i < len(suffixes)-1 {
+			n = remaining * int64(r.IntN(100)) / 100
+		}
+		if n > 0 {
+			data.Library.FileSuffixes[suffix] = n
+			remaining -= n
+		}
+	}
+
+	data.Config.ScannerEnabled = r.Float64() < 0.9
+	data.Config.ScannerExtractor = "taglib"
+	data.Config.EnableDownloads = r.Float64() < 0.8
+	data.Config.EnableSharing = r.Float64() < 0.3
+	data.Config.EnableLastFM = r.Float64() < 0.4
+	data.Config.EnableListenBrainz = r.Float64() < 0.2
+	data.Config.ReverseProxyConfigured = r.Float64() < 0.6
+	data.Config.HasSmartPlaylists = r.Float64() < 0.3
+	return data
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// thresholds configures alerting. Zero values disable the corresponding check
+type thresholds struct {
+	minInstances int64
+	maxDropPct   float64
+}
+
+// alertError is returned when the current stats violate the configured thresholds
+type alertError struct {
+	reasons []string
+}
+
+func (e *alertError) Error() string {
+	return "threshold violated: " + strings.Join(e.reasons, "; ")
+}
+
+// check returns the reasons the current stats violate the thresholds, if any. The drop check is
+// skipped when there is no comparison or the baseline window has no instances
+func (t thresholds) check(current stats, c *comparison) []string {
+	var reasons []string
+	if t.minInstances > 0 && current.numInstances < t.minInstances {
+		reasons = append(reasons, fmt.Sprintf("%d instances in the last 24 hours, below minimum of %d",
+			current.numInstances, t.minInstances))
+	}
+	if t.maxDropPct > 0 && c != nil && c.baseline.numInstances > 0 {
+		drop := float64(c.baseline.numInstances-current.numInstances) / float64(c.baseline.numInstances) * 100
+		if drop > t.maxDropPct {
+			reasons = append(reasons, fmt.Sprintf("instances dropped %.1f%% (%d -> %d), above maximum of %.1f%%",
+				drop, c.baseline.numInstances, current.numInstances, t.maxDropPct))
+		}
+	}
+	return reasons
+}
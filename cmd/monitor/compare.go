@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// comparison holds the stats of the current window and of the baseline window it is compared against
+type comparison struct {
+	current  stats
+	baseline stats
+	since    time.Time
+	until    time.Time
+}
+
+type delta struct {
+	Key      string `json:"name"`
+	Current  uint64 `json:"current"`
+	Baseline uint64 `json:"baseline"`
+	Delta    int64  `json:"delta"`
+}
+
+// parseWindow parses the -since/-until flags. Both must be given, or neither
+func parseWindow(sinceStr, untilStr string) (since, until time.Time, err error) {
+	if sinceStr == "" && untilStr == "" {
+		return since, until, nil
+	}
+	if sinceStr == "" || untilStr == "" {
+		return since, until, fmt.Errorf("-since and -until must be used together")
+	}
+	if since, err = parseTime(sinceStr); err != nil {
+		return since, until, fmt.Errorf("invalid -since: %w", err)
+	}
+	if until, err = parseTime(untilStr); err != nil {
+		return since, until, fmt.Errorf("invalid -until: %w", err)
+	}
+	if !since.Before(until) {
+		return since, until, fmt.Errorf("-since must be before -until")
+	}
+	return since, until, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(consts.DateFormat, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// computeDeltas returns the per-key differences between two maps, sorted by absolute delta descending
+func computeDeltas(current, baseline map[string]uint64) []delta {
+	keys := make(map[string]struct{}, len(current))
+	for k := range current {
+		keys[k] = struct{}{}
+	}
+	for k := range baseline {
+		keys[k] = struct{}{}
+	}
+
+	deltas := make([]delta, 0, len(keys))
+	for k := range keys {
+		deltas = append(deltas, delta{
+			Key:      k,
+			Current:  current[k],
+			Baseline: baseline[k],
+			Delta:    int64(current[k]) - int64(baseline[k]), //#nosec G115 -- counts are far below MaxInt64
+		})
+	}
+	slices.SortFunc(deltas, func(a, b delta) int {
+		return cmp.Or(cmp.Compare(abs(b.Delta), abs(a.Delta)), cmp.Compare(b.Current, a.Current), cmp.Compare(a.Key, b.Key))
+	})
+	return deltas
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func printComparison(c comparison) {
+	fmt.Printf("Comparing last 24h with %s - %s\n\n",
+		c.since.UTC().Format(consts.DateTimeFormat), c.until.UTC().Format(consts.DateTimeFormat))
+	fmt.Printf("Total instances: %d (was %d, %+d)\n\n",
+		c.current.numInstances, c.baseline.numInstances, c.current.numInstances-c.baseline.numInstances)
+
+	fmt.Println("By Version:")
+	printDeltas(computeDeltas(c.current.versions, c.baseline.versions), 30)
+	fmt.Println()
+
+	fmt.Println("By OS:")
+	printDeltas(computeDeltas(c.current.osTypes, c.baseline.osTypes), 20)
+	fmt.Println()
+
+	fmt.Println("By OS/Architecture:")
+	printDeltas(computeDeltas(c.current.osArch, c.baseline.osArch), 20)
+}
+
+func printDeltas(deltas []delta, n int) {
+	limit := min(n, len(deltas))
+	for i := 0; i < limit; i++ {
+		d := deltas[i]
+		fmt.Printf("%+7d | %6d (was %6d) | %s\n", d.Delta, d.Current, d.Baseline, d.Key)
+	}
+}
+
+type jsonComparison struct {
+	BaselineWindow jsonWindow `json:"baselineWindow"`
+	Current        jsonStats  `json:"current"`
+	Baseline       jsonStats  `json:"baseline"`
+	Deltas         jsonDeltas `json:"deltas"`
+}
+
+type jsonWindow struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+}
+
+type jsonDeltas struct {
+	Instances int64   `json:"instances"`
+	Versions  []delta `json:"versions"`
+	OS        []delta `json:"os"`
+	OSArch    []delta `json:"osArch"`
+}
+
+func printCompareJSON(w io.Writer, c comparison) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jsonComparison{
+		BaselineWindow: jsonWindow{Since: c.since.UTC(), Until: c.until.UTC()},
+		Current:        toJSONStats(c.current),
+		Baseline:       toJSONStats(c.baseline),
+		Deltas: jsonDeltas{
+			Instances: c.current.numInstances - c.baseline.numInstances,
+			Versions:  computeDeltas(c.current.versions, c.baseline.versions),
+			OS:        computeDeltas(c.current.osTypes, c.baseline.osTypes),
+			OSArch:    computeDeltas(c.current.osArch, c.baseline.osArch),
+		},
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import "strings"
+
+// filter restricts the analyzed instances. Empty fields match everything
+type filter struct {
+	version string
+	os      string
+	arch    string
+}
+
+// newFilter normalizes the filter flags. A trailing ".x" or "*" in the version is treated as a wildcard
+func newFilter(version, os, arch string) filter {
+	version = strings.TrimSuffix(version, "*")
+	if strings.HasSuffix(version, ".x") {
+		version = strings.TrimSuffix(version, "x")
+	}
+	return filter{
+		version: version,
+		os:      strings.ToLower(os),
+		arch:    strings.ToLower(arch),
+	}
+}
+
+// matches reports whether the instance passes all filters. Versions match by whole segments, so "0.54"
+// matches all 0.54 releases (and builds like "0.54.2 (0b184893)") but not 0.540. OS matches either the raw OS type ("darwin") or its display name
+// ("macOS"), and "docker" matches containerized instances
+func (f filter) matches(r report) bool {
+	if f.version != "" && !f.matchesVersion(r.Version) {
+		return false
+	}
+	if f.os != "" && !f.matchesOS(r) {
+		return false
+	}
+	if f.arch != "" && strings.ToLower(r.Arch) != f.arch {
+		return false
+	}
+	return true
+}
+
+// matchesVersion reports whether the version starts with the filter, followed by the end of the version or
+// a separator. A filter ending with "." (from "0.54.x") already ends at a separator
+func (f filter) matchesVersion(version string) bool {
+	rest, ok := strings.CutPrefix(version, f.version)
+	if !ok {
+		return false
+	}
+	if rest == "" || strings.HasSuffix(f.version, ".") {
+		return true
+	}
+	return strings.ContainsRune(".-+ ", rune(rest[0]))
+}
+
+func (f filter) matchesOS(r report) bool {
+	if f.os == "docker" || f.os == "containerized" {
+		return r.Containerized
+	}
+	osType, _ := mapOSAndArch(r)
+	return strings.ToLower(r.OSType) == f.os || strings.ToLower(osType) == f.os
+}
+
+func (f filter) String() string {
+	var parts []string
+	if f.version != "" {
+		parts = append(parts, "version="+f.version+"*")
+	}
+	if f.os != "" {
+		parts = append(parts, "os="+f.os)
+	}
+	if f.arch != "" {
+		parts = append(parts, "arch="+f.arch)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+)
+
+// jsonStats is the machine-readable representation of the collected stats, used by -format json
+type jsonStats struct {
+	Instances       int64       `json:"instances"`
+	Versions        []kv        `json:"versions"`
+	OS              []kv        `json:"os"`
+	OSArch          []kv        `json:"osArch"`
+	Library         jsonLibrary `json:"library"`
+	UnmappedPlayers []kv        `json:"unmappedPlayers,omitempty"` // Only with -unmapped
+	UnmappedFS      []kv        `json:"unmappedFilesystems,omitempty"`
+}
+
+type jsonLibrary struct {
+	Largest     *int64 `json:"largest,omitempty"`
+	Average     *int64 `json:"average,omitempty"`
+	ZeroTracks  uint64 `json:"zeroTracks"`
+	MillionPlus uint64 `json:"millionPlus"`
+}
+
+func toJSONStats(s stats) jsonStats {
+	js := jsonStats{
+		Instances:       s.numInstances,
+		Versions:        sortedPairs(s.versions),
+		OS:              sortedPairs(s.osTypes),
+		OSArch:          sortedPairs(s.osArch),
+		UnmappedPlayers: sortedPairs(s.unmappedPlayers),
+		UnmappedFS:      sortedPairs(s.unmappedFS),
+		Library: jsonLibrary{
+			ZeroTracks:  s.zeroTracks,
+			MillionPlus: s.millionPlus,
+		},
+	}
+	if s.trackStats != nil {
+		largest := s.trackStats.Max
+		average := int64(math.Round(s.trackStats.Mean))
+		js.Library.Largest = &largest
+		js.Library.Average = &average
+	}
+	return js
+}
+
+func printJSON(w io.Writer, s stats) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(toJSONStats(s))
+}
@@ -2,47 +2,134 @@ package main
 
 import (
 	"cmp"
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"iter"
 	"log"
 	"math"
 	"os"
-	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 
+	"github.com/navidrome/insights/config"
 	"github.com/navidrome/insights/db"
-	"github.com/navidrome/navidrome/core/metrics/insights"
+	"github.com/navidrome/insights/summary"
 )
 
 func main() {
 	dbPath := flag.String("db", "", "Path to insights.db (default: $DATA_FOLDER/insights.db or ./insights.db)")
+	serverURL := flag.String("url", "", "Read the reports from this insights server (e.g. https://insights.navidrome.org) instead of the database")
+	apiKey := flag.String("api-key", "", "Admin API key for -url (default: $INSIGHTS_API_KEY)")
+	format := flag.String("format", "text", "Output format: text or json")
+	compare := flag.Bool("compare", false, "Compare the last 24h with a baseline window and print deltas")
+	sinceStr := flag.String("since", "", "Start of the baseline window for -compare (YYYY-MM-DD or RFC3339, default: 48h ago)")
+	untilStr := flag.String("until", "", "End of the baseline window for -compare (YYYY-MM-DD or RFC3339, default: 24h ago)")
+	versionFilter := flag.String("version", "", "Only analyze instances of this version or its releases (e.g. 0.54 or 0.54.x, matching 0.54.2 but not 0.540)")
+	osFilter := flag.String("os", "", "Only analyze instances running this OS (e.g. linux, macOS, windows, docker)")
+	archFilter := flag.String("arch", "", "Only analyze instances with this architecture (e.g. amd64, arm64)")
+	minInstances := flag.Int64("min-instances", 0, "Alert (exit 2) if the last 24h has fewer instances than this")
+	maxDropPct := flag.Float64("max-drop-pct", 0, "Alert (exit 2) if instances dropped more than this percentage from the baseline window")
+	unmapped := flag.Bool("unmapped", false, "Also list players and filesystems without a mapping (slower, decodes every report)")
+	aggregate := flag.Bool("aggregate", true, "Compute the stats with SQL aggregates when reading the database, instead of iterating the reports (ignored with -url or -unmapped)")
+	configFile := config.Flag()
 	flag.Parse()
 
-	// Determine database path
-	dbFile := *dbPath
-	if dbFile == "" {
-		dataFolder := cmp.Or(os.Getenv("DATA_FOLDER"), ".")
-		dbFile = filepath.Join(dataFolder, "insights.db")
+	if err := config.Load(*configFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
+	*apiKey = cmp.Or(*apiKey, os.Getenv("INSIGHTS_API_KEY"))
 
-	if err := run(dbFile); err != nil {
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: invalid format %q (must be text or json)\n", *format)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	since, until, err := parseWindow(*sinceStr, *untilStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !since.IsZero() && !*compare {
+		fmt.Fprintf(os.Stderr, "Error: -since/-until require -compare\n")
+		os.Exit(1)
+	}
+
+	if *serverURL != "" && *dbPath != "" {
+		fmt.Fprintf(os.Stderr, "Error: -url and -db are mutually exclusive\n")
+		os.Exit(1)
+	}
+	if *serverURL != "" && *apiKey == "" {
+		fmt.Fprintf(os.Stderr, "Error: -url requires an admin API key (-api-key or $INSIGHTS_API_KEY)\n")
+		os.Exit(1)
+	}
+
+	// Use the same player and filesystem type mappings as the server, so unmapped values are reported consistently
+	if _, err := summary.LoadPlayerTypes(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := summary.LoadFSTypes(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := options{
+		format:     *format,
+		compare:    *compare,
+		unmapped:   *unmapped,
+		since:      since,
+		until:      until,
+		filter:     newFilter(*versionFilter, *osFilter, *archFilter),
+		thresholds: thresholds{minInstances: *minInstances, maxDropPct: *maxDropPct},
+	}
+	src, count, closeSrc, err := openSource(*serverURL, *apiKey, *dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !*aggregate {
+		count = nil
+	}
+	err = run(src, count, opts)
+	closeSrc()
+	if err != nil {
+		var alert *alertError
+		if errors.As(err, &alert) {
+			for _, reason := range alert.reasons {
+				fmt.Fprintf(os.Stderr, "ALERT: %s\n", reason)
+			}
+			os.Exit(2)
+		}
 		log.Fatalf("Error: %v", err)
 	}
 }
 
 type stats struct {
-	numInstances int64
-	versions     map[string]uint64
-	osTypes      map[string]uint64
-	osArch       map[string]uint64
-	trackStats   *trackStats
-	zeroTracks   uint64
-	millionPlus  uint64
+	numInstances    int64
+	versions        map[string]uint64
+	osTypes         map[string]uint64
+	osArch          map[string]uint64
+	trackStats      *trackStats
+	zeroTracks      uint64
+	millionPlus     uint64
+	unmappedPlayers map[string]uint64 // Raw ActivePlayers names not matching any player type mapping, by instance count
+	unmappedFS      map[string]uint64 // Unrecognized filesystem types without a mapping, by instance count
+}
+
+type options struct {
+	format       string
+	compare      bool
+	unmapped     bool
+	since, until time.Time
+	filter       filter
+	thresholds   thresholds
 }
 
 type trackStats struct {
@@ -50,58 +137,170 @@ type trackStats struct {
 	Mean float64
 }
 
-func run(dbPath string) error {
-	// Open database
-	dbConn, err := db.OpenDB(dbPath)
+// run collects and prints the stats, aggregated by count when set, unless the unmapped players and
+// filesystems are requested, as they need the full reports
+func run(src source, count counter, opts options) error {
+	f := opts.filter
+	collect := func(from, to time.Time) (stats, error) {
+		if count != nil && !opts.unmapped {
+			return countStats(count, from, to, f)
+		}
+		return collectStats(src, from, to, f, opts.unmapped)
+	}
+
+	// Collect statistics for the last 24 hours
+	now := time.Now().UTC()
+	s, err := collect(now.Add(-24*time.Hour), now)
 	if err != nil {
-		return fmt.Errorf("opening database %s: %w", dbPath, err)
+		return err
+	}
+
+	// Collect statistics for the baseline window, needed for comparisons and drop alerts.
+	// Default baseline is the 24h window immediately before the current one
+	var c *comparison
+	if opts.compare || opts.thresholds.maxDropPct > 0 {
+		since, until := opts.since, opts.until
+		if since.IsZero() {
+			until = now.Add(-24 * time.Hour)
+			since = until.Add(-24 * time.Hour)
+		}
+		baseline, err := collect(since, until)
+		if err != nil {
+			return err
+		}
+		c = &comparison{current: s, baseline: baseline, since: since, until: until}
+	}
+
+	violations := opts.thresholds.check(s, c)
+	if s.numInstances == 0 {
+		if len(violations) > 0 {
+			return &alertError{reasons: violations}
+		}
+		if f != (filter{}) {
+			return fmt.Errorf("no data found in the last 24 hours matching %s", f)
+		}
+		return fmt.Errorf("no data found in the last 24 hours")
+	}
+	if f != (filter{}) && opts.format == "text" {
+		fmt.Printf("Filter: %s\n", f)
+	}
+
+	// Print output
+	if err := printOutput(s, c, opts); err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &alertError{reasons: violations}
+	}
+	return nil
+}
+
+func printOutput(s stats, c *comparison, opts options) error {
+	if opts.compare {
+		if opts.format == "json" {
+			return printCompareJSON(os.Stdout, *c)
+		}
+		printComparison(*c)
+		return nil
+	}
+	if opts.format == "json" {
+		return printJSON(os.Stdout, s)
 	}
-	defer func() { _ = dbConn.Close() }()
+	printStats(s)
+	return nil
+}
 
-	// Query for last 24 hours - get the latest entry per instance ID
-	rows, err := selectLast24Hours(dbConn)
+// collectStats computes the stats for the latest entry of each instance reporting in the (from, to] window,
+// skipping instances that don't match the filter. Unmapped players and filesystems are only counted
+// when withUnmapped is set, as they require decoding the full reports
+func collectStats(src source, from, to time.Time, f filter, withUnmapped bool) (stats, error) {
+	rows, err := src(from, to, withUnmapped)
 	if err != nil {
-		return fmt.Errorf("selecting data: %w", err)
+		return stats{}, fmt.Errorf("selecting data: %w", err)
 	}
 
-	// Collect statistics
 	s := stats{
-		versions: make(map[string]uint64),
-		osTypes:  make(map[string]uint64),
-		osArch:   make(map[string]uint64),
+		versions:        make(map[string]uint64),
+		osTypes:         make(map[string]uint64),
+		osArch:          make(map[string]uint64),
+		unmappedPlayers: make(map[string]uint64),
+		unmappedFS:      make(map[string]uint64),
 	}
 
 	var trackValues []int64
 
-	for data := range rows {
+	for r := range rows {
+		if !f.matches(r) {
+			continue
+		}
 		s.numInstances++
-		s.versions[mapVersion(data)]++
+		s.versions[mapVersion(r.Version)]++
 
-		osType, osArch := mapOSAndArch(data)
+		osType, osArch := mapOSAndArch(r)
 		s.osTypes[osType]++
 		s.osArch[osArch]++
+		if r.Data != nil {
+			summary.CountUnmappedPlayers(*r.Data, s.unmappedPlayers)
+			summary.CountUnmappedFS(*r.Data, s.unmappedFS)
+		}
 
 		// Track library size
-		if data.Library.Tracks > 0 {
-			trackValues = append(trackValues, data.Library.Tracks)
+		if r.Tracks > 0 {
+			trackValues = append(trackValues, r.Tracks)
 		}
-		if data.Library.Tracks == 0 {
+		if r.Tracks == 0 {
 			s.zeroTracks++
 		}
-		if data.Library.Tracks >= 1000000 {
+		if r.Tracks >= 1000000 {
 			s.millionPlus++
 		}
 	}
 
-	if s.numInstances == 0 {
-		return fmt.Errorf("no data found in the last 24 hours")
+	s.trackStats = calcTrackStats(trackValues)
+	return s, nil
+}
+
+// countStats computes the same stats as collectStats (without the unmapped players and filesystems) from
+// the instance counts aggregated by the database, filtering and mapping each group instead of each report
+func countStats(count counter, from, to time.Time, f filter) (stats, error) {
+	counts, err := count(from, to)
+	if err != nil {
+		return stats{}, fmt.Errorf("counting data: %w", err)
 	}
 
-	s.trackStats = calcTrackStats(trackValues)
+	s := stats{
+		versions:        make(map[string]uint64),
+		osTypes:         make(map[string]uint64),
+		osArch:          make(map[string]uint64),
+		unmappedPlayers: make(map[string]uint64),
+		unmappedFS:      make(map[string]uint64),
+	}
 
-	// Print output
-	printStats(s)
-	return nil
+	var withTracks, tracksSum, tracksMax int64
+	for _, c := range counts {
+		r := report{Version: c.Version, OSType: c.OSType, Arch: c.Arch, Containerized: c.Containerized}
+		if !f.matches(r) {
+			continue
+		}
+		n := uint64(c.Instances) //#nosec G115 -- counts are never negative
+		s.numInstances += c.Instances
+		s.versions[mapVersion(r.Version)] += n
+
+		osType, osArch := mapOSAndArch(r)
+		s.osTypes[osType] += n
+		s.osArch[osArch] += n
+
+		s.zeroTracks += c.ZeroTracks
+		s.millionPlus += c.MillionPlus
+		withTracks += c.WithTracks
+		tracksSum += c.TracksSum
+		tracksMax = max(tracksMax, c.TracksMax)
+	}
+
+	if withTracks > 0 {
+		s.trackStats = &trackStats{Max: tracksMax, Mean: float64(tracksSum) / float64(withTracks)}
+	}
+	return s, nil
 }
 
 func printStats(s stats) {
@@ -134,22 +333,39 @@ func printStats(s stats) {
 	fmt.Println("Library size distribution:")
 	fmt.Printf("%6d | = 0 tracks\n", s.zeroTracks)
 	fmt.Printf("%6d | > 1000000 tracks\n", s.millionPlus)
+
+	// Players and filesystems not matching any mapping, candidates for new mappings
+	if len(s.unmappedPlayers) > 0 {
+		fmt.Println()
+		fmt.Println("Unmapped players:")
+		printTopN(s.unmappedPlayers, 20)
+	}
+	if len(s.unmappedFS) > 0 {
+		fmt.Println()
+		fmt.Println("Unmapped filesystems:")
+		printTopN(s.unmappedFS, 20)
+	}
 }
 
 type kv struct {
-	Key   string
-	Value uint64
+	Key   string `json:"name"`
+	Value uint64 `json:"count"`
 }
 
-func printTopN(m map[string]uint64, n int) {
+// sortedPairs returns the map entries sorted by value descending, then by key
+func sortedPairs(m map[string]uint64) []kv {
 	pairs := make([]kv, 0, len(m))
 	for k, v := range m {
 		pairs = append(pairs, kv{k, v})
 	}
 	slices.SortFunc(pairs, func(a, b kv) int {
-		return cmp.Compare(b.Value, a.Value)
+		return cmp.Or(cmp.Compare(b.Value, a.Value), cmp.Compare(a.Key, b.Key))
 	})
+	return pairs
+}
 
+func printTopN(m map[string]uint64, n int) {
+	pairs := sortedPairs(m)
 	limit := min(n, len(pairs))
 	for i := 0; i < limit; i++ {
 		fmt.Printf("%6d | %s\n", pairs[i].Value, pairs[i].Key)
@@ -160,17 +376,17 @@ func printTopN(m map[string]uint64, n int) {
 var versionRegex = regexp.MustCompile(`\(([0-9a-fA-F]{8})[0-9a-fA-F]*\)`)
 
 // mapVersion normalizes version strings (truncate git sha to 8 chars)
-func mapVersion(data insights.Data) string {
-	return versionRegex.ReplaceAllString(data.Version, "($1)")
+func mapVersion(version string) string {
+	return versionRegex.ReplaceAllString(version, "($1)")
 }
 
 // mapOSAndArch returns the OS type and OS/Arch combination
-func mapOSAndArch(data insights.Data) (osType, osArch string) {
-	switch data.OS.Type {
+func mapOSAndArch(r report) (osType, osArch string) {
+	switch r.OSType {
 	case "darwin":
 		osType = "macOS"
 	case "linux":
-		if data.OS.Containerized {
+		if r.Containerized {
 			osType = "Linux (containerized)"
 		} else {
 			osType = "Linux"
@@ -184,7 +400,7 @@ func mapOSAndArch(data insights.Data) (osType, osArch string) {
 	case "openbsd":
 		osType = "OpenBSD"
 	default:
-		osType = strings.Title(data.OS.Type) //nolint:staticcheck
+		osType = strings.Title(r.OSType) //nolint:staticcheck
 	}
 
 	// For arch, remove "(containerized)" suffix
@@ -192,7 +408,7 @@ func mapOSAndArch(data insights.Data) (osType, osArch string) {
 	if strings.Contains(archOS, "(containerized)") {
 		archOS = "Linux"
 	}
-	osArch = archOS + " " + data.OS.Arch
+	osArch = archOS + " " + r.Arch
 
 	return osType, osArch
 }
@@ -217,41 +433,42 @@ func calcTrackStats(values []int64) *trackStats {
 	}
 }
 
-// selectLast24Hours returns the latest entry per instance ID from the last 24 hours
-func selectLast24Hours(dbConn *sql.DB) (iter.Seq[insights.Data], error) {
-	query := `
-SELECT i1.id, i1.time, i1.data
-FROM insights i1
-INNER JOIN (
-    SELECT id, MAX(time) as max_time
-    FROM insights
-    WHERE time > datetime('now', '-24 hours')
-    GROUP BY id
-) i2 ON i1.id = i2.id AND i1.time = i2.max_time
-WHERE i1.time > datetime('now', '-24 hours')
-ORDER BY i1.id, i1.time DESC;`
-
-	rows, err := dbConn.Query(query)
+// report holds the fields of a stored report analyzed by the monitor, read from the columns extracted
+// on insert. The full report is only decoded when needed (see collectStats)
+type report = db.WindowReport
+
+// source returns the latest report of each instance reporting in the (from, to] window, with the full
+// reports only when withData is set. It reads either the database (dbSource) or the server API (remoteSource)
+type source func(from, to time.Time, withData bool) (iter.Seq[report], error)
+
+// counter returns the number of instances reporting in the (from, to] window, grouped by the columns of
+// their latest report (see db.CountWindow). Only available when reading the database
+type counter func(from, to time.Time) ([]db.WindowCount, error)
+
+// openSource returns the remote source when serverURL is set, otherwise the database source and counter,
+// reading dbPath or $DATA_FOLDER/insights.db. The returned function releases the source
+func openSource(serverURL, apiKey, dbPath string) (source, counter, func(), error) {
+	if serverURL != "" {
+		return remoteSource(serverURL, apiKey), nil, func() {}, nil
+	}
+	dbPath = cmp.Or(dbPath, config.DBPath())
+	dbConn, err := db.OpenDB(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("querying data: %w", err)
+		return nil, nil, nil, fmt.Errorf("opening database %s: %w", dbPath, err)
 	}
+	return dbSource(dbConn), dbCounter(dbConn), func() { _ = dbConn.Close() }, nil
+}
 
-	return func(yield func(insights.Data) bool) {
-		defer func() { _ = rows.Close() }()
-		for rows.Next() {
-			var id, t, j string
-			if err := rows.Scan(&id, &t, &j); err != nil {
-				log.Printf("Error scanning row: %s", err)
-				return
-			}
-			var data insights.Data
-			if err := json.Unmarshal([]byte(j), &data); err != nil {
-				log.Printf("Error unmarshalling data: %s", err)
-				return
-			}
-			if !yield(data) {
-				return
-			}
-		}
-	}, nil
+// dbSource reads the reports from the database file
+func dbSource(dbConn *sql.DB) source {
+	return func(from, to time.Time, withData bool) (iter.Seq[report], error) {
+		return db.SelectWindow(context.Background(), dbConn, from, to, withData)
+	}
+}
+
+// dbCounter aggregates the reports in the database file
+func dbCounter(dbConn *sql.DB) counter {
+	return func(from, to time.Time) ([]db.WindowCount, error) {
+		return db.CountWindow(context.Background(), dbConn, from, to)
+	}
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// remoteSource reads the reports from the latest reports endpoint of an insights server, authenticated
+// with an admin API key, so the monitor can run without access to the production database file
+func remoteSource(serverURL, apiKey string) source {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	endpoint := strings.TrimSuffix(serverURL, "/") + consts.APIPrefix + "/admin/reports/latest"
+	return func(from, to time.Time, withData bool) (iter.Seq[report], error) {
+		params := url.Values{
+			"from": {from.UTC().Format(time.RFC3339)},
+			"to":   {to.UTC().Format(time.RFC3339)},
+		}
+		if withData {
+			params.Set("data", "true")
+		}
+		req, err := http.NewRequest(http.MethodGet, endpoint+"?"+params.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		resp, err := client.Do(req) //#nosec G704 -- the server URL is provided by the user running the tool
+		if err != nil {
+			return nil, fmt.Errorf("requesting reports: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("requesting reports: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+
+		// The response is a JSON Lines stream, one report per line
+		return func(yield func(report) bool) {
+			defer func() { _ = resp.Body.Close() }()
+			dec := json.NewDecoder(resp.Body)
+			for {
+				var r report
+				if err := dec.Decode(&r); err != nil {
+					if !errors.Is(err, io.EOF) {
+						log.Printf("Error reading reports: %s", err)
+					}
+					return
+				}
+				if !yield(r) {
+					return
+				}
+			}
+		}, nil
+	}
+}
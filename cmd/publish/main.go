@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/navidrome/insights/charts"
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/summary"
+	"github.com/navidrome/insights/web"
+)
+
+const downloadTimeout = time.Minute
+
+// assets are the files index.html loads from the CDN, bundled in the site unless -cdn is set
+var assets = []struct{ url, path string }{
+	{consts.EChartsURL, "assets/echarts.min.js"},
+	{consts.TopoJSONURL, "assets/topojson-client.min.js"},
+	{consts.WorldMapURL, "assets/countries-110m.json"},
+}
+
+// publish renders the public dashboard as a fully static site (index.html, chartdata/charts.json, and the
+// echarts library and world map of the countries chart), that can be deployed to GitHub Pages or a CDN without running the collector. Charts
+// are generated from the summaries in $DATA_FOLDER, like the server does.
+func main() {
+	outDir := flag.String("out", "", "Output folder for the site (required)")
+	cdn := flag.Bool("cdn", false, "Load echarts and the world map from their CDN instead of bundling them in the site")
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *outDir == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := run(*outDir, *cdn); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+func run(outDir string, cdn bool) error {
+	log.Printf("Generating charts from the summaries in %s", config.DataFolder()) //#nosec G706 -- DATA_FOLDER is set by the user running the tool
+	if err := charts.ConfigureFromEnv(); err != nil {
+		return err
+	}
+	if _, err := summary.LoadBins(); err != nil {
+		return fmt.Errorf("loading bins: %w", err)
+	}
+	if _, err := charts.LoadReleases(); err != nil {
+		return fmt.Errorf("loading releases: %w", err)
+	}
+	chartsJSON, err := charts.GenerateChartsJSON(time.Time{}, time.Time{})
+	if errors.Is(err, charts.ErrNoData) {
+		return errors.New("no summaries found, set DATA_FOLDER to the folder containing the summaries")
+	}
+	if err != nil {
+		return fmt.Errorf("generating charts: %w", err)
+	}
+
+	index, err := fs.ReadFile(web.FS, "index.html")
+	if err != nil {
+		return err
+	}
+	if !cdn {
+		for _, asset := range assets {
+			url := []byte(`"` + asset.url + `"`)
+			if !bytes.Contains(index, url) {
+				return fmt.Errorf("index.html does not load %s, use -cdn", asset.url)
+			}
+			index = bytes.ReplaceAll(index, url, []byte(`"`+asset.path+`"`))
+			data, err := download(asset.url)
+			if err != nil {
+				return fmt.Errorf("downloading %s: %w", asset.path, err)
+			}
+			if err := writeFile(outDir, asset.path, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writeFile(outDir, "index.html", index); err != nil {
+		return err
+	}
+	if err := writeFile(outDir, filepath.Join("chartdata", consts.ChartsJSONFile), chartsJSON); err != nil {
+		return err
+	}
+	log.Printf("Published site to %s", outDir) //#nosec G706 -- path is provided by the user running the tool
+	return nil
+}
+
+func download(url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req) //#nosec G704 -- URL is a constant
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// writeFile writes a file of the site, readable by the web server serving it
+func writeFile(outDir, name string, data []byte) error {
+	path := filepath.Join(outDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { //#nosec G301 -- public site
+		return err
+	}
+	return os.WriteFile(path, data, 0644) //#nosec G306 -- public site
+}
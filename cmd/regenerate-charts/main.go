@@ -1,21 +1,35 @@
 package main
 
 import (
+	"flag"
 	"log"
-	"os"
+	"path/filepath"
 
 	"github.com/navidrome/insights/charts"
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/summary"
 )
 
 func main() {
-	dataFolder := os.Getenv("DATA_FOLDER")
-	if dataFolder == "" {
-		dataFolder = "."
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatal(err)
 	}
 
-	chartDataDir := dataFolder + "/web/chartdata"
+	chartDataDir := filepath.Join(config.DataFolder(), consts.ChartDataDir)
 
-	log.Printf("Generating charts.json in %s", chartDataDir) //#nosec G706 -- chartDataDir is from controlled env var
+	if err := charts.ConfigureFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := summary.LoadBins(); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := charts.LoadReleases(); err != nil {
+		log.Fatalf("Error loading releases: %v", err)
+	}
+	log.Printf("Generating charts.json in %s", chartDataDir) //#nosec G706 -- chartDataDir is from controlled config
 	if err := charts.ExportChartsJSON(chartDataDir); err != nil {
 		log.Fatalf("Error exporting charts JSON: %v", err)
 	}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/navidrome/insights/backup"
+	"github.com/navidrome/insights/charts"
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/summary"
+)
+
+// restore sets up a fresh data folder from a backup zip (see backup.Create): it copies the backup's database
+// to <data>/insights.db, then regenerates the summaries of all its dates and charts.json, like a server
+// running from that folder would. It never overwrites a database: the data folder must not have one, so an
+// existing live database can't be replaced by mistake. To merge a backup into a live database, use the
+// server's /api/v1/admin/restore endpoint or cmd/consolidate instead.
+func main() {
+	zipPath := flag.String("zip", "", "Backup zip to restore (required)")
+	dataFolder := flag.String("data", "", "Data folder to restore into, without a database (default: $DATA_FOLDER)")
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Not config.DataFolder: the folder must be explicit, not the current directory by default
+	if dir, ok := config.LookupDataFolder(); ok && *dataFolder == "" {
+		*dataFolder = dir
+	}
+	if *zipPath == "" || *dataFolder == "" {
+		fmt.Fprintf(os.Stderr, "Error: -zip and -data (or $DATA_FOLDER) are required\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, *zipPath, *dataFolder); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+func run(ctx context.Context, zipPath, dataFolder string) error {
+	dbPath := filepath.Join(dataFolder, consts.DBFile)
+	if err := checkNoDatabase(dbPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataFolder, consts.DirPermissions); err != nil {
+		return fmt.Errorf("creating data folder: %w", err)
+	}
+
+	// Summaries and charts are stored in the data folder, with its player and filesystem type mappings
+	if err := config.SetDataFolder(dataFolder); err != nil {
+		return err
+	}
+	if _, err := summary.LoadPlayerTypes(); err != nil {
+		return fmt.Errorf("loading player types: %w", err)
+	}
+	if _, err := summary.LoadFSTypes(); err != nil {
+		return fmt.Errorf("loading filesystem types: %w", err)
+	}
+	if err := summary.ConfigureOutliersFromEnv(); err != nil {
+		return err
+	}
+	if _, err := summary.LoadBins(); err != nil {
+		return fmt.Errorf("loading bins: %w", err)
+	}
+
+	if err := copyDatabase(ctx, zipPath, dbPath); err != nil {
+		return err
+	}
+	log.Printf("Restored database to %s", dbPath) //#nosec G706 -- path is provided by the user running the tool
+
+	dbConn, err := db.OpenDB(dbPath)
+	if err != nil {
+		return fmt.Errorf("opening restored database: %w", err)
+	}
+	defer func() { _ = dbConn.Close() }()
+
+	dates, err := db.SelectDates(dbConn)
+	if err != nil {
+		return err
+	}
+	for _, date := range dates {
+		if _, err := summary.SummarizeData(ctx, dbConn, date); err != nil {
+			return fmt.Errorf("summarizing %s: %w", date.Format(consts.DateFormat), err)
+		}
+	}
+	log.Printf("Generated summaries for %d dates", len(dates))
+
+	if err := charts.ConfigureFromEnv(); err != nil {
+		return err
+	}
+	if _, err := charts.LoadReleases(); err != nil {
+		return fmt.Errorf("loading releases: %w", err)
+	}
+	chartDataDir := filepath.Join(dataFolder, consts.ChartDataDir)
+	if err := charts.ExportChartsJSON(chartDataDir); err != nil && !errors.Is(err, charts.ErrNoData) {
+		return fmt.Errorf("exporting charts: %w", err)
+	}
+	log.Printf("Restore complete")
+	return nil
+}
+
+// checkNoDatabase fails if a database (or its WAL file, left by a running or crashed server) exists at dbPath
+func checkNoDatabase(dbPath string) error {
+	for _, path := range []string{dbPath, dbPath + "-wal"} {
+		_, err := os.Stat(path)
+		if err == nil {
+			return fmt.Errorf("%s already exists. Refusing to overwrite it: restore into an empty data folder, or move it away first", path)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("checking %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// copyDatabase writes a consistent copy of the backup's database to dbPath. VACUUM INTO fails if dbPath
+// exists, so a database created since checkNoDatabase is not overwritten either
+func copyDatabase(ctx context.Context, zipPath, dbPath string) error {
+	src, cleanup, err := backup.Open(zipPath)
+	if err != nil {
+		return fmt.Errorf("opening backup: %w", err)
+	}
+	defer cleanup()
+	if _, err := src.ExecContext(ctx, "VACUUM INTO ?", dbPath); err != nil {
+		return fmt.Errorf("copying database: %w", err)
+	}
+	return nil
+}
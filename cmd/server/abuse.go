@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// ipTracker counts the distinct instance IDs reporting from each IP, in fixed windows of
+// consts.AbuseIPWindow. A single IP sending reports of thousands of IDs is generating them. IPs are only
+// kept in memory, never persisted, and at most consts.AbuseMaxIDsPerIP IDs are kept per IP
+type ipTracker struct {
+	mu    sync.Mutex
+	start time.Time
+	ids   map[string]map[string]struct{}
+}
+
+// reportIPs tracks the IPs of the /collect reports
+var reportIPs = &ipTracker{}
+
+// flood counts a report of id from remoteAddr at now, returning true if the IP already reported the max
+// number of other IDs in the window. The IDs it reported before reaching the max are not flagged
+func (t *ipTracker) flood(remoteAddr, id string, now time.Time) bool {
+	ip, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		ip = remoteAddr
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ids == nil || now.Sub(t.start) >= consts.AbuseIPWindow {
+		t.start, t.ids = now, map[string]map[string]struct{}{}
+	}
+	ids := t.ids[ip]
+	if ids == nil {
+		ids = map[string]struct{}{}
+		t.ids[ip] = ids
+	}
+	if _, ok := ids[id]; ok {
+		return false
+	}
+	if len(ids) >= consts.AbuseMaxIDsPerIP {
+		return true
+	}
+	ids[id] = struct{}{}
+	return false
+}
+
+// implausibleLibrary reports whether any library count is negative or absurdly large, like the MaxInt
+// values sent by joke payloads
+func implausibleLibrary(data insights.Data) bool {
+	l := data.Library
+	for _, n := range []int64{l.Tracks, l.Albums, l.Artists, l.Playlists, l.Shares, l.Radios, l.Libraries, l.ActiveUsers} {
+		if n < 0 || n >= consts.MaxPlausibleLibrary {
+			return true
+		}
+	}
+	return false
+}
+
+// flagReason returns why a report received from remoteAddr must be flagged instead of stored, or an empty
+// string. Checked after the blocked instances, whose reports are dropped rather than flagged. The IP check
+// is skipped if remoteAddr is empty, for reports relayed by a proxy (/collect/batch) and queued reports,
+// whose IP is checked before publishing them (see ipFlagReason). The platform consistency is checked when
+// storing the reports (see db.SaveReports)
+func flagReason(data insights.Data, remoteAddr string, now time.Time) string {
+	if implausibleLibrary(data) {
+		return db.FlaggedImplausibleLibrary
+	}
+	return ipFlagReason(remoteAddr, data.InsightsID, now)
+}
+
+// ipFlagReason returns db.FlaggedIPFlood if remoteAddr reports too many IDs (see ipTracker.flood), or an
+// empty string. Always empty if remoteAddr is empty
+func ipFlagReason(remoteAddr, id string, now time.Time) string {
+	if remoteAddr != "" && reportIPs.flood(remoteAddr, id, now) {
+		return db.FlaggedIPFlood
+	}
+	return ""
+}
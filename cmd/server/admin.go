@@ -0,0 +1,639 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/navidrome/insights/backup"
+	"github.com/navidrome/insights/charts"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/dedup"
+	"github.com/navidrome/insights/summary"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// adminRoutes lists the admin endpoints of the API, under /admin. Endpoints only reading reports use
+// readConn (see db.OpenReadDB)
+func adminRoutes(dbConn, readConn *sql.DB) []apiRoute {
+	unmappedParams := []apiParam{
+		{"date", "Date of the reports (YYYY-MM-DD, default: yesterday)"},
+		{"limit", "Max entries listed"},
+	}
+	return []apiRoute{
+		{
+			method: http.MethodGet, path: "/admin/blocked", legacyPath: "/api/admin/blocked", tag: "admin",
+			summary: "List the blocked instances",
+			access:  accessAdmin, response: []db.BlockedInstance{},
+			handler: listBlockedHandler(dbConn),
+		},
+		{
+			method: http.MethodPost, path: "/admin/blocked", legacyPath: "/api/admin/blocked", tag: "admin",
+			summary: "Block an instance, deleting its stored reports",
+			access:  accessAdmin, request: blockRequest{}, response: blockResponse{},
+			errors:  []int{http.StatusBadRequest},
+			handler: blockInstanceHandler(dbConn),
+		},
+		{
+			method: http.MethodDelete, path: "/admin/blocked/{id}", legacyPath: "/api/admin/blocked/{id}", tag: "admin",
+			summary: "Unblock an instance",
+			access:  accessAdmin, status: http.StatusNoContent,
+			errors:  []int{http.StatusNotFound},
+			handler: unblockInstanceHandler(dbConn),
+		},
+		{
+			method: http.MethodPost, path: "/admin/tasks/{task}", legacyPath: "/api/admin/tasks/{task}", tag: "admin",
+			summary: "Run a task now: summarize, charts or cleanup",
+			access:  accessAdmin, response: taskResult{},
+			params:      []apiParam{{"date", "Single date to summarize (YYYY-MM-DD), for the summarize task"}},
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+			longRunning: true,
+			handler:     runTaskHandler(dbConn, readConn),
+		},
+		{
+			method: http.MethodGet, path: "/admin/jobs", legacyPath: "/api/admin/jobs", tag: "admin",
+			summary: "Get the state and recent runs of each task",
+			access:  accessAdmin, response: jobsResponse{},
+			handler: jobsHandler(),
+		},
+		{
+			method: http.MethodGet, path: "/admin/players/unmapped", legacyPath: "/api/admin/players/unmapped", tag: "admin",
+			summary: "List the reported players that don't match any player type",
+			access:  accessAdmin, params: unmappedParams, response: unmappedResponseSchema("players"),
+			errors:  []int{http.StatusBadRequest},
+			handler: unmappedHandler(readConn, "players", summary.CountUnmappedPlayers),
+		},
+		{
+			method: http.MethodGet, path: "/admin/filesystems/unmapped", legacyPath: "/api/admin/filesystems/unmapped", tag: "admin",
+			summary: "List the reported filesystems that don't match any filesystem type",
+			access:  accessAdmin, params: unmappedParams, response: unmappedResponseSchema("filesystems"),
+			errors:  []int{http.StatusBadRequest},
+			handler: unmappedHandler(readConn, "filesystems", summary.CountUnmappedFS),
+		},
+		{
+			method: http.MethodGet, path: "/admin/players/others", tag: "admin",
+			summary: "List the player types grouped as \"Others\" in the player types chart, ranked by number of instances",
+			access:  accessAdmin, response: otherPlayersResponse{},
+			params:  []apiParam{{"date", "Date of the summary (YYYY-MM-DD, default: the last complete day)"}},
+			errors:  []int{http.StatusBadRequest, http.StatusNotFound},
+			handler: otherPlayersHandler(),
+		},
+		{
+			method: http.MethodGet, path: "/admin/flagged", tag: "admin",
+			summary: "Count the reports flagged as implausible or spoofed, or otherwise rejected, per day and reason",
+			access:  accessAdmin, response: []db.FlaggedActivity{},
+			params: []apiParam{
+				{"from", fmt.Sprintf("First date (YYYY-MM-DD, default: %d days before `to`)", consts.DefaultFlaggedDays)},
+				{"to", "Last date (YYYY-MM-DD, default: today)"},
+			},
+			errors:  []int{http.StatusBadRequest},
+			handler: flaggedHandler(readConn),
+		},
+		{
+			method: http.MethodGet, path: "/admin/reports/latest", tag: "admin",
+			summary: "Stream the latest report of each instance reporting in a time window, as JSON Lines of db.WindowReport",
+			access:  accessAdmin, contentType: "application/x-ndjson",
+			params: []apiParam{
+				{"from", "Start of the window, exclusive (RFC3339, default: 24h before `to`)"},
+				{"to", "End of the window, inclusive (RFC3339, default: now)"},
+				{"data", "Include the full reports (true or false, default: false)"},
+			},
+			errors:      []int{http.StatusBadRequest},
+			longRunning: true,
+			middlewares: []func(http.Handler) http.Handler{compress},
+			handler:     latestReportsHandler(readConn),
+		},
+		{
+			method: http.MethodGet, path: "/admin/raw", tag: "admin",
+			summary: "Get a page of the raw reports of a date range, as JSON Lines (the cmd/export format). " +
+				"The X-Next-Cursor response header has the cursor of the next page, and is missing on the last one",
+			access: accessAdmin, contentType: "application/x-ndjson",
+			params: append(slices.Clone(dateRangeParams),
+				apiParam{"cursor", "X-Next-Cursor of the previous page (default: first page)"},
+				apiParam{"limit", fmt.Sprintf("Max reports in the page (default: %d, max: %d)", consts.DefaultRawPageSize, consts.MaxRawPageSize)},
+			),
+			errors:      []int{http.StatusBadRequest},
+			longRunning: true,
+			middlewares: []func(http.Handler) http.Handler{compress},
+			handler:     rawReportsHandler(readConn),
+		},
+		{
+			method: http.MethodPost, path: "/admin/restore", tag: "admin",
+			summary: "Merge the reports of an uploaded backup zip missing from the database, and re-summarize their dates",
+			access:  accessAdmin, requestType: "application/zip", response: restoreResponse{},
+			errors:      []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge},
+			maxBodySize: limits.maxRestoreSize, longRunning: true,
+			handler: restoreHandler(dbConn, readConn),
+		},
+	}
+}
+
+func listBlockedHandler(dbConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		blocked, err := db.ListBlockedInstances(dbConn)
+		if err != nil {
+			log.Printf("Error listing blocked instances: %v", err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, blocked)
+	}
+}
+
+type blockRequest struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+type blockResponse struct {
+	ID      string `json:"id"`
+	Deleted int64  `json:"deleted"` // Reports deleted
+}
+
+func blockInstanceHandler(dbConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req blockRequest
+		if err := decodeJSONBody(w, r, &req); err != nil {
+			var mr *malformedRequest
+			if errors.As(err, &mr) {
+				http.Error(w, mr.msg, mr.status)
+			} else {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			}
+			return
+		}
+		req.ID = strings.TrimSpace(req.ID)
+		if req.ID == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+
+		deleted, err := db.BlockInstance(dbConn, req.ID, req.Reason)
+		if err != nil {
+			log.Printf("Error blocking instance: %v", err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Blocked instance %s, deleted %d reports", req.ID, deleted) //#nosec G706 -- admin-provided ID
+		writeJSON(w, http.StatusOK, blockResponse{ID: req.ID, Deleted: deleted})
+	}
+}
+
+func unblockInstanceHandler(dbConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		found, err := db.UnblockInstance(dbConn, id)
+		if err != nil {
+			log.Printf("Error unblocking instance: %v", err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "Instance not blocked", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type taskResult struct {
+	Task     string   `json:"task"`
+	Duration string   `json:"duration"`
+	Dates    []string `json:"dates,omitempty"`
+	Deleted  *int64   `json:"deleted,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// onDemandTasks are the tasks runTaskHandler can run
+var onDemandTasks = []string{jobSummarize, jobCharts, jobCleanup}
+
+// runTaskHandler runs a scheduled task immediately: summarize (optionally for a single `date`
+// query param, YYYY-MM-DD), charts or cleanup. Responds with 409 if the task is already running
+func runTaskHandler(dbConn, readConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		task := chi.URLParam(r, "task")
+		if !slices.Contains(onDemandTasks, task) {
+			http.Error(w, "Unknown task", http.StatusNotFound)
+			return
+		}
+		dateParam := r.URL.Query().Get("date")
+		if dateParam != "" && task != jobSummarize {
+			http.Error(w, "date is only supported by the summarize task", http.StatusBadRequest)
+			return
+		}
+
+		start := time.Now()
+		result := taskResult{Task: task}
+		var err error
+		switch task {
+		case jobSummarize:
+			var dates []time.Time
+			if dateParam != "" {
+				date, perr := time.Parse(consts.DateFormat, dateParam)
+				if perr != nil {
+					http.Error(w, "invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+					return
+				}
+				dates = []time.Time{date}
+			} else {
+				dates = staleDates(r.Context(), dbConn)
+			}
+			for _, d := range dates {
+				result.Dates = append(result.Dates, d.Format(consts.DateFormat))
+			}
+			err = runSummarize(r.Context(), dbConn, readConn, dates)
+		case jobCharts:
+			err = runGenerateCharts(r.Context())
+		case jobCleanup:
+			var deleted int64
+			deleted, err = runCleanup(r.Context(), dbConn, readConn)
+			result.Deleted = &deleted
+		}
+		result.Duration = time.Since(start).Round(time.Millisecond).String()
+
+		if errors.Is(err, errJobRunning) {
+			result.Deleted = nil
+			result.Error = err.Error()
+			writeJSON(w, http.StatusConflict, result)
+			return
+		}
+		log.Printf("Ran task %s on demand in %s", task, result.Duration) //#nosec G706 -- task is one of the known names
+		if err != nil {
+			log.Printf("Error running task %s: %v", task, err) //#nosec G706 -- task is one of the known names
+			result.Error = err.Error()
+			writeJSON(w, http.StatusInternalServerError, result)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+type jobsResponse struct {
+	Jobs []jobStatus `json:"jobs"`
+}
+
+// jobsHandler reports the state and recent runs of each task (cron or on demand), since the server started
+func jobsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, jobsResponse{Jobs: jobs.status()})
+	}
+}
+
+type otherPlayersResponse struct {
+	Date      string          `json:"date"`      // YYYY-MM-DD
+	Total     uint64          `json:"total"`     // Instances using each player type, summed over all types
+	Threshold float64         `json:"threshold"` // Instances below which a player type is grouped as "Others"
+	Players   []unmappedCount `json:"players"`
+}
+
+// otherPlayersHandler expands the "Others" slice of the player types chart of a day, so emerging clients
+// can be noticed before they cross charts.Config.PlayerGroupThreshold
+func otherPlayersHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var record summary.SummaryRecord
+		var err error
+		if v := r.URL.Query().Get("date"); v != "" {
+			record.Time, err = time.Parse(consts.DateFormat, v)
+			if err != nil {
+				http.Error(w, "invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+			record.Data, err = summary.LoadSummary(record.Time)
+			if errors.Is(err, os.ErrNotExist) {
+				err = charts.ErrNoData
+			}
+		} else {
+			record, err = charts.LatestSummary()
+		}
+		if errors.Is(err, charts.ErrNoData) {
+			http.Error(w, "No data available", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading summary: %v", err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		resp := otherPlayersResponse{Date: record.Time.Format(consts.DateFormat), Players: []unmappedCount{}}
+		for _, n := range record.Data.PlayerTypes {
+			resp.Total += n
+		}
+		resp.Threshold = float64(resp.Total) * charts.CurrentConfig().PlayerGroupThreshold
+		for name, n := range charts.OtherPlayerTypes(record.Data.PlayerTypes) {
+			resp.Players = append(resp.Players, unmappedCount{Name: name, Instances: n})
+		}
+		slices.SortFunc(resp.Players, func(a, b unmappedCount) int {
+			return cmp.Or(cmp.Compare(b.Instances, a.Instances), cmp.Compare(a.Name, b.Name))
+		})
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+type unmappedCount struct {
+	Name      string `json:"name"`
+	Instances uint64 `json:"instances"`
+}
+
+// unmappedResponseSchema describes the response of unmappedHandler, with the list under the given key
+func unmappedResponseSchema(key string) openAPISchema {
+	return openAPISchema{
+		"type": "object",
+		"properties": map[string]any{
+			"date":      map[string]any{"type": "string", "format": "date"},
+			"instances": map[string]any{"type": "integer", "description": "Instances that reported on that date"},
+			key: map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"name":      map[string]any{"type": "string"},
+						"instances": map[string]any{"type": "integer"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// unmappedHandler lists the values reported by instances that don't match any mapping, as counted by
+// the count function, ranked by the number of instances reporting them. The list is returned under
+// the given key. Uses the latest report of each instance for the `date` query param (YYYY-MM-DD,
+// default: yesterday, the last complete day). The optional `limit` query param caps the list size
+func unmappedHandler(dbConn *sql.DB, key string, count func(insights.Data, map[string]uint64)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		date := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+		if v := r.URL.Query().Get("date"); v != "" {
+			d, err := time.Parse(consts.DateFormat, v)
+			if err != nil {
+				http.Error(w, "invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+			date = d
+		}
+		limit := consts.DefaultUnmappedLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			l, err := strconv.Atoi(v)
+			if err != nil || l < 1 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = l
+		}
+
+		reports, err := db.SelectData(r.Context(), dbConn, date)
+		if err != nil {
+			log.Printf("Error selecting data: %v", err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		var instances int64
+		counts := map[string]uint64{}
+		for report := range reports {
+			instances++
+			count(report.Data, counts)
+		}
+		if err := r.Context().Err(); err != nil {
+			return
+		}
+		list := make([]unmappedCount, 0, len(counts))
+		for name, n := range counts {
+			list = append(list, unmappedCount{Name: name, Instances: n})
+		}
+		slices.SortFunc(list, func(a, b unmappedCount) int {
+			return cmp.Or(cmp.Compare(b.Instances, a.Instances), cmp.Compare(a.Name, b.Name))
+		})
+		writeJSON(w, http.StatusOK, map[string]any{
+			"date":      date.Format(consts.DateFormat),
+			"instances": instances,
+			key:         list[:min(limit, len(list))],
+		})
+	}
+}
+
+// flaggedHandler lists the activity flagged by the abuse detection (see flagReason and db.SaveReports), and
+// the other rejected reports, for the days from `from` to `to`, so admins can decide what to block
+func flaggedHandler(dbConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseDateRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if to.IsZero() {
+			to = time.Now().UTC()
+		}
+		if from.IsZero() {
+			from = to.AddDate(0, 0, -consts.DefaultFlaggedDays)
+		}
+		activity, err := db.ListFlaggedActivity(r.Context(), dbConn, from, to)
+		if err != nil {
+			log.Printf("Error listing flagged activity: %v", err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, activity)
+	}
+}
+
+// latestReportsHandler streams the latest report of each instance in the (`from`, `to`] window (see
+// db.SelectWindow), one JSON object per line, for remote tooling like cmd/monitor -url
+func latestReportsHandler(dbConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		to := time.Now().UTC()
+		if v := r.URL.Query().Get("to"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid 'to' time, expected RFC3339", http.StatusBadRequest)
+				return
+			}
+			to = t
+		}
+		from := to.Add(-24 * time.Hour)
+		if v := r.URL.Query().Get("from"); v != "" {
+			f, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid 'from' time, expected RFC3339", http.StatusBadRequest)
+				return
+			}
+			from = f
+		}
+		if !from.Before(to) {
+			http.Error(w, "'from' must be before 'to'", http.StatusBadRequest)
+			return
+		}
+		withData := r.URL.Query().Get("data") == "true"
+
+		reports, err := db.SelectWindow(r.Context(), dbConn, from, to, withData)
+		if err != nil {
+			log.Printf("Error selecting reports: %v", err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for report := range reports {
+			if err := enc.Encode(report); err != nil {
+				log.Printf("Error writing reports: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// rawReport is a raw report in the rawReportsHandler response, in the JSON Lines format of cmd/export, so
+// pages can be imported with cmd/import
+type rawReport struct {
+	ID      string          `json:"id"`
+	Time    time.Time       `json:"time"`
+	Country string          `json:"country,omitempty"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// rawReportsHandler serves a page of the raw reports stored between the `from` and `to` dates, one per
+// line, in insertion order. The cursor is the rowid of the last report of the previous page, so pages
+// are stable while new reports are stored, but not across a VACUUM
+func rawReportsHandler(dbConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseDateRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var cursor int64
+		if v := r.URL.Query().Get("cursor"); v != "" {
+			if cursor, err = strconv.ParseInt(v, 10, 64); err != nil || cursor < 0 {
+				http.Error(w, "invalid cursor", http.StatusBadRequest)
+				return
+			}
+		}
+		limit := consts.DefaultRawPageSize
+		if v := r.URL.Query().Get("limit"); v != "" {
+			l, err := strconv.Atoi(v)
+			if err != nil || l < 1 || l > consts.MaxRawPageSize {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = l
+		}
+
+		reports, next, err := db.SelectRawReportsPage(r.Context(), dbConn, from, to, cursor, limit)
+		if err != nil {
+			log.Printf("Error selecting raw reports: %v", err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if next > 0 {
+			w.Header().Set("X-Next-Cursor", strconv.FormatInt(next, 10))
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, rr := range reports {
+			row := rawReport{ID: rr.ID, Time: rr.Time.UTC(), Country: rr.Country, Data: json.RawMessage(rr.Data)}
+			if err := enc.Encode(row); err != nil {
+				log.Printf("Error writing raw reports: %v", err)
+				return
+			}
+		}
+	}
+}
+
+type restoreResponse struct {
+	Merged int64    `json:"merged"`
+	Dates  []string `json:"dates,omitempty"` // Dates that received reports, re-summarized
+	Error  string   `json:"error,omitempty"` // Summarize error. The merged reports are kept, and summarized by the cron task
+}
+
+// restoreHandler merges an uploaded backup zip (see backup.Create) into the database, skipping the reports
+// already stored (backup.Merge), and re-summarizes the dates that received reports. Useful to recover the
+// gaps of a database from an old host's backups
+func restoreHandler(dbConn, readConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zipPath, err := saveUpload(http.MaxBytesReader(w, r.Body, limits.maxRestoreSize))
+		if zipPath != "" {
+			defer func() { _ = os.Remove(zipPath) }()
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Backup too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Error reading upload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		src, cleanup, err := backup.Open(zipPath)
+		if err != nil {
+			http.Error(w, "Invalid backup: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer cleanup()
+
+		keys := dedup.MemoryKeySet{}
+		if err := dedup.LoadKeys(readConn, keys); err != nil {
+			log.Printf("Error loading existing rows: %v", err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		merged, dates, err := backup.Merge(r.Context(), src, dbConn, keys)
+		if err != nil {
+			log.Printf("Error merging backup, %d reports merged: %v", merged, err)
+			reporter.Error(err, map[string]string{"handler": "admin"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Merged %d reports from an uploaded backup", merged)
+
+		result := restoreResponse{Merged: merged}
+		for _, d := range dates {
+			result.Dates = append(result.Dates, d.Format(consts.DateFormat))
+		}
+		if len(dates) > 0 {
+			if err := runSummarize(r.Context(), dbConn, readConn, dates); err != nil {
+				result.Error = err.Error()
+			}
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// saveUpload writes the request body to a temp file, returning its path (empty if it could not be created)
+func saveUpload(body io.Reader) (string, error) {
+	f, err := os.CreateTemp("", "insights-restore-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(f, body); err != nil {
+		return f.Name(), err
+	}
+	return f.Name(), f.Close()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-chi/chi/v5"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("runTaskHandler", func() {
+	DescribeTable("validates the task before running it",
+		func(target string, status int) {
+			router := chi.NewRouter()
+			router.Post("/admin/tasks/{task}", runTaskHandler(nil, nil))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, target, nil))
+			Expect(w.Code).To(Equal(status))
+		},
+		Entry("unknown task", "/admin/tasks/nope", http.StatusNotFound),
+		Entry("unknown task with a date", "/admin/tasks/nope?date=2025-06-01", http.StatusNotFound),
+		Entry("backup, not run on demand", "/admin/tasks/backup", http.StatusNotFound),
+		Entry("date for another task", "/admin/tasks/charts?date=2025-06-01", http.StatusBadRequest),
+		Entry("invalid date", "/admin/tasks/summarize?date=06/01/2025", http.StatusBadRequest),
+	)
+})
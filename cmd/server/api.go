@@ -0,0 +1,224 @@
+package main
+
+import (
+	"cmp"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/httprate"
+	"github.com/navidrome/insights/charts"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/geoip"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// apiAccess is the API key scope required by an API route
+type apiAccess int
+
+const (
+	accessPublic apiAccess = iota // No key required
+	accessRead                    // A read key, if any keys are configured (apiKeyMiddleware)
+	accessAdmin                   // An admin key (adminKeyMiddleware)
+)
+
+// apiParam is a query param of an API route
+type apiParam struct {
+	name        string
+	description string
+}
+
+// apiRoute is an endpoint of the HTTP API, served under consts.APIPrefix and described in the OpenAPI
+// document (see openAPIDocument). Endpoints that existed before the API was versioned are also served
+// at their legacy path, for existing clients (e.g. all Navidrome releases POST to /collect)
+type apiRoute struct {
+	method      string
+	path        string // Relative to consts.APIPrefix, with chi URL params
+	legacyPath  string // Unversioned path, empty for endpoints added after versioning
+	tag         string
+	summary     string
+	access      apiAccess
+	params      []apiParam
+	request     any    // Zero value of the JSON request body, nil if requestType is set or there is no body
+	requestType string // Content type of non-JSON request bodies
+	response    any    // Zero value of the JSON response body, nil if contentType is set or there is no body
+	contentType string // Content type of non-JSON responses
+	status      int    // Success status, default 200
+	errors      []int  // Error statuses, besides the ones of the access checks
+	maxBodySize int64  // Max request body size, default limits.maxBodySize
+	longRunning bool   // Not bound by the server's read and write timeouts (see withoutDeadlines)
+	middlewares []func(http.Handler) http.Handler
+	handler     http.Handler
+}
+
+var (
+	dateRangeParams = []apiParam{
+		{"from", "First date (YYYY-MM-DD, default: unbounded)"},
+		{"to", "Last date (YYYY-MM-DD, default: unbounded)"},
+	}
+	chartParams = slices.Concat(dateRangeParams, []apiParam{{"theme", "Chart colors: light (default) or dark"}})
+)
+
+// chartsDocument is the format of charts.json (see charts.GenerateChartsJSON), for the OpenAPI document
+type chartsDocument struct {
+	TotalInstances int64       `json:"totalInstances"`
+	LastUpdated    time.Time   `json:"lastUpdated"`
+	Anomalies      []anomaly   `json:"anomalies"`
+	Charts         []chartJSON `json:"charts"`
+}
+
+type anomaly struct {
+	Date      string  `json:"date"`
+	Kind      string  `json:"kind"` // drop or spike
+	Instances int64   `json:"instances"`
+	Mean      float64 `json:"mean"`
+	Deviation float64 `json:"deviation"`
+}
+
+type chartJSON struct {
+	ID          string         `json:"id"`
+	Options     map[string]any `json:"options"`
+	DarkOptions map[string]any `json:"darkOptions"`
+}
+
+// apiRoutes lists the public endpoints of the API. Admin endpoints are listed by adminRoutes. Reports are
+// stored through dbConn, and statistics read from readConn (see db.OpenReadDB)
+func apiRoutes(dbConn, readConn *sql.DB, geo *geoip.Resolver) []apiRoute {
+	// Shared by the versioned and legacy paths, so clients can't double their quota by using both
+	limiter := httprate.NewRateLimiter(limits.rateLimitRequests, limits.rateLimitWindow, httprate.WithKeyByIP())
+	batchLimiter := httprate.NewRateLimiter(limits.rateLimitRequests, limits.rateLimitWindow, httprate.WithKeyByIP())
+
+	return []apiRoute{
+		{
+			method: http.MethodPost, path: "/collect", legacyPath: "/collect", tag: "collect",
+			summary: "Send an instance's report. The body can be compressed (Content-Encoding: gzip or zstd)",
+			request: insights.Data{}, response: collectResponse{}, maxBodySize: limits.maxCollectBodySize,
+			errors:      []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusTooManyRequests},
+			middlewares: []func(http.Handler) http.Handler{limiter.Handler},
+			handler:     handler(dbConn, geo),
+		},
+		{
+			method: http.MethodPost, path: "/collect/batch", legacyPath: "/collect/batch", tag: "collect",
+			summary: fmt.Sprintf("Send up to %d reports in one request, stored in a single transaction", consts.MaxBatchReports),
+			request: []insights.Data{}, response: batchResponse{}, maxBodySize: limits.maxBatchBodySize,
+			errors:      []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusTooManyRequests},
+			middlewares: []func(http.Handler) http.Handler{batchLimiter.Handler},
+			handler:     batchHandler(dbConn),
+		},
+		{
+			method: http.MethodGet, path: "/charts", legacyPath: "/api/charts", tag: "charts",
+			summary: "Get the options of all charts, generated on demand when a date range is requested",
+			access:  accessRead, params: dateRangeParams, response: chartsDocument{},
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+			middlewares: []func(http.Handler) http.Handler{compress},
+			handler:     chartsJSONHandler(),
+		},
+		{
+			method: http.MethodGet, path: "/charts/{id}", legacyPath: "/api/charts/{id}", tag: "charts",
+			summary: "Get the echarts options of a single chart",
+			access:  accessRead, params: chartParams, response: map[string]any{},
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+			middlewares: []func(http.Handler) http.Handler{compress},
+			handler:     chartHandler(),
+		},
+		{
+			method: http.MethodGet, path: "/charts/{id}.png", legacyPath: "/api/charts/{id}.png", tag: "charts",
+			summary: "Render a chart as a PNG image",
+			access:  accessRead, params: chartParams, contentType: "image/png",
+			errors:  []int{http.StatusBadRequest, http.StatusNotFound},
+			handler: chartImageHandler(charts.ImagePNG),
+		},
+		{
+			method: http.MethodGet, path: "/charts/{id}.svg", legacyPath: "/api/charts/{id}.svg", tag: "charts",
+			summary: "Render a chart as an SVG image",
+			access:  accessRead, params: chartParams, contentType: "image/svg+xml",
+			errors:      []int{http.StatusBadRequest, http.StatusNotFound},
+			middlewares: []func(http.Handler) http.Handler{compress},
+			handler:     chartImageHandler(charts.ImageSVG),
+		},
+		{
+			method: http.MethodGet, path: "/stats/latest", legacyPath: "/api/stats/latest", tag: "stats",
+			summary: "Get the summary of the last complete day",
+			access:  accessRead, response: latestStats{},
+			errors:      []int{http.StatusNotFound},
+			middlewares: []func(http.Handler) http.Handler{compress},
+			handler:     latestStatsHandler(),
+		},
+		{
+			method: http.MethodGet, path: "/query", tag: "stats",
+			summary: "Get the daily values of a summary metric",
+			access:  accessRead, response: []queryPoint{},
+			params: append([]apiParam{
+				{"metric", "Dotted path into the summary, with map keys as the last parts (e.g. numInstances, trackStats.mean, versions.0.55.0)"},
+			}, dateRangeParams...),
+			errors:      []int{http.StatusBadRequest},
+			middlewares: []func(http.Handler) http.Handler{compress},
+			handler:     queryHandler(),
+		},
+		{
+			method: http.MethodGet, path: "/stats/upgrades", tag: "stats",
+			summary: "Get how many days the instances took to upgrade to each stable release, from the first time each instance reported each release",
+			access:  accessRead, response: []db.ReleaseUpgrades{},
+			middlewares: []func(http.Handler) http.Handler{compress},
+			handler:     upgradesHandler(readConn),
+		},
+		{
+			method: http.MethodGet, path: "/export/summaries.csv", legacyPath: "/api/export/summaries.csv", tag: "export",
+			summary: "Export the daily summaries as CSV",
+			access:  accessRead, params: dateRangeParams, contentType: "text/csv",
+			errors:      []int{http.StatusBadRequest},
+			middlewares: []func(http.Handler) http.Handler{compress},
+			handler:     summariesCSVHandler(),
+		},
+	}
+}
+
+// registerAPIRoutes serves the API routes under consts.APIPrefix and at their legacy paths, with the
+// OpenAPI document describing them at consts.APIPrefix/openapi.json. When cors is set, the read endpoints
+// and the OpenAPI document can be fetched by browsers on the allowed origins
+func registerAPIRoutes(r chi.Router, keys *keyStore, cors *corsPolicy, routes []apiRoute) error {
+	doc, err := openAPIDocument(routes)
+	if err != nil {
+		return err
+	}
+	docMiddlewares := []func(http.Handler) http.Handler{compress}
+	if cors != nil {
+		docMiddlewares = append(docMiddlewares, cors.middleware)
+		r.Method(http.MethodOptions, consts.APIPrefix+"/openapi.json", cors.preflight())
+	}
+	r.With(docMiddlewares...).Get(consts.APIPrefix+"/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		serveGenerated(w, r, "application/json", doc)
+	})
+
+	for _, route := range routes {
+		middlewares := []func(http.Handler) http.Handler{limitBody(cmp.Or(route.maxBodySize, limits.maxBodySize))}
+		switch route.access {
+		case accessRead:
+			if cors != nil {
+				middlewares = append(middlewares, cors.middleware)
+			}
+			middlewares = append(middlewares, apiKeyMiddleware(keys))
+		case accessAdmin:
+			middlewares = append(middlewares, adminKeyMiddleware(keys))
+		}
+		if route.longRunning {
+			middlewares = append(middlewares, withoutDeadlines)
+		}
+		h := chi.Chain(append(middlewares, route.middlewares...)...).Handler(route.handler)
+		r.Method(route.method, consts.APIPrefix+route.path, h)
+		if route.legacyPath != "" {
+			r.Method(route.method, route.legacyPath, h)
+		}
+		if route.access == accessRead && cors != nil {
+			r.Method(http.MethodOptions, consts.APIPrefix+route.path, cors.preflight())
+			if route.legacyPath != "" {
+				r.Method(http.MethodOptions, route.legacyPath, cors.preflight())
+			}
+		}
+	}
+	return nil
+}
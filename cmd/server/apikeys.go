@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// API key scopes. Admin keys can also access read endpoints
+const (
+	scopeRead  = "read"
+	scopeAdmin = "admin"
+)
+
+type apiKey struct {
+	Name   string   `json:"name"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+}
+
+func (k apiKey) hasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, scopeAdmin)
+}
+
+// keyStore holds the configured API keys. Keys are loaded from (in order, all combined):
+//   - API_KEYS_FILE: JSON file with a list of {"name", "key", "scopes"} objects
+//   - API_KEYS: comma-separated list of name:key:scope1|scope2 entries
+//   - API_KEY: legacy single key, with read and admin scopes
+//
+// Multiple keys can be active at the same time, so keys can be rotated by adding the new key,
+// reloading (SIGHUP), updating clients and then removing the old key.
+type keyStore struct {
+	mu   sync.RWMutex
+	keys []apiKey
+}
+
+func newKeyStore() (*keyStore, error) {
+	s := &keyStore{}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload reads the keys from the environment and keys file, replacing the current keys only if all are valid
+func (s *keyStore) reload() error {
+	var keys []apiKey
+	if path := os.Getenv("API_KEYS_FILE"); path != "" {
+		data, err := os.ReadFile(path) //#nosec G304 -- path is from controlled env var
+		if err != nil {
+			return fmt.Errorf("reading API_KEYS_FILE: %w", err)
+		}
+		var fileKeys []apiKey
+		if err := json.Unmarshal(data, &fileKeys); err != nil {
+			return fmt.Errorf("parsing API_KEYS_FILE: %w", err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+	envKeys, err := parseAPIKeys(os.Getenv("API_KEYS"))
+	if err != nil {
+		return fmt.Errorf("parsing API_KEYS: %w", err)
+	}
+	keys = append(keys, envKeys...)
+	if key := os.Getenv("API_KEY"); key != "" {
+		keys = append(keys, apiKey{Name: "default", Key: key, Scopes: []string{scopeRead, scopeAdmin}})
+	}
+
+	for _, k := range keys {
+		if k.Name == "" || k.Key == "" {
+			return fmt.Errorf("API key entries require a name and a key")
+		}
+		// A key without scopes would authorize nothing
+		if len(k.Scopes) == 0 {
+			return fmt.Errorf("API key %q has no scopes", k.Name)
+		}
+		for _, scope := range k.Scopes {
+			if scope != scopeRead && scope != scopeAdmin {
+				return fmt.Errorf("API key %q has unknown scope %q", k.Name, scope)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	return nil
+}
+
+// parseAPIKeys parses a comma-separated list of name:key:scope1|scope2 entries
+func parseAPIKeys(s string) ([]apiKey, error) {
+	var keys []apiKey
+	for i, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid entry #%d, expected name:key:scopes", i+1)
+		}
+		keys = append(keys, apiKey{Name: parts[0], Key: parts[1], Scopes: strings.Split(parts[2], "|")})
+	}
+	return keys, nil
+}
+
+func (s *keyStore) empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys) == 0
+}
+
+// authorize returns the name of the key matching the token, if it has the required scope.
+// All keys are compared in constant time, to not leak which keys exist
+func (s *keyStore) authorize(token, scope string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var name string
+	var ok bool
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(k.Key)) == 1 && k.hasScope(scope) {
+			name, ok = k.Name, true
+		}
+	}
+	return name, ok
+}
+
+// authorizeRequest authorizes the API key of the Authorization header or, if it doesn't match a key
+// with the scope, the api_key query param
+func (s *keyStore) authorizeRequest(r *http.Request, scope string) (string, bool) {
+	for _, token := range requestTokens(r) {
+		if name, ok := s.authorize(token, scope); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// requestTokens returns the API keys passed in the Authorization header and the api_key query param, in
+// that order
+func requestTokens(r *http.Request) []string {
+	var tokens []string
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, consts.AuthHeaderPrefix) {
+		tokens = append(tokens, strings.TrimPrefix(authHeader, consts.AuthHeaderPrefix))
+	}
+	if key := r.URL.Query().Get(consts.APIKeyQueryParam); key != "" {
+		tokens = append(tokens, key)
+	}
+	return tokens
+}
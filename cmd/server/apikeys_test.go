@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/navidrome/insights/consts"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestServer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Server Suite")
+}
+
+// authorizedKey returns the name of the authorized key, empty if not authorized
+func authorizedKey(name string, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+var _ = Describe("API keys", func() {
+	BeforeEach(func() {
+		GinkgoT().Setenv("API_KEYS_FILE", "")
+		GinkgoT().Setenv("API_KEYS", "")
+		GinkgoT().Setenv("API_KEY", "")
+	})
+
+	Describe("parseAPIKeys", func() {
+		It("parses name:key:scopes entries", func() {
+			keys, err := parseAPIKeys(" dashboard:abc:read , ops:xyz:read|admin,")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]apiKey{
+				{Name: "dashboard", Key: "abc", Scopes: []string{"read"}},
+				{Name: "ops", Key: "xyz", Scopes: []string{"read", "admin"}},
+			}))
+		})
+
+		It("returns no keys for an empty list", func() {
+			Expect(parseAPIKeys("")).To(BeEmpty())
+		})
+
+		It("rejects entries without three parts", func() {
+			_, err := parseAPIKeys("dashboard:abc:read,ops:xyz")
+			Expect(err).To(MatchError(ContainSubstring("invalid entry #2")))
+		})
+	})
+
+	Describe("keyStore", func() {
+		It("authorizes the keys with the required scope", func() {
+			GinkgoT().Setenv("API_KEYS", "dashboard:abc:read,ops:xyz:admin")
+			keys, err := newKeyStore()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(authorizedKey(keys.authorize("abc", scopeRead))).To(Equal("dashboard"))
+			_, ok := keys.authorize("abc", scopeAdmin)
+			Expect(ok).To(BeFalse())
+			// Admin keys can also access read endpoints
+			Expect(authorizedKey(keys.authorize("xyz", scopeRead))).To(Equal("ops"))
+			Expect(authorizedKey(keys.authorize("xyz", scopeAdmin))).To(Equal("ops"))
+			_, ok = keys.authorize("nope", scopeRead)
+			Expect(ok).To(BeFalse())
+			_, ok = keys.authorize("", scopeRead)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("combines the keys file, API_KEYS and the legacy API_KEY", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "keys.json")
+			Expect(os.WriteFile(path, []byte(`[{"name": "file", "key": "f1", "scopes": ["read"]}]`), 0600)).To(Succeed())
+			GinkgoT().Setenv("API_KEYS_FILE", path)
+			GinkgoT().Setenv("API_KEYS", "env:e1:read")
+			GinkgoT().Setenv("API_KEY", "legacy")
+			keys, err := newKeyStore()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(authorizedKey(keys.authorize("f1", scopeRead))).To(Equal("file"))
+			Expect(authorizedKey(keys.authorize("e1", scopeRead))).To(Equal("env"))
+			Expect(authorizedKey(keys.authorize("legacy", scopeAdmin))).To(Equal("default"))
+		})
+
+		It("rotates the keys on reload", func() {
+			GinkgoT().Setenv("API_KEYS", "old:k1:read")
+			keys, err := newKeyStore()
+			Expect(err).NotTo(HaveOccurred())
+
+			// Both keys are accepted while the clients are updated
+			GinkgoT().Setenv("API_KEYS", "old:k1:read,new:k2:read")
+			Expect(keys.reload()).To(Succeed())
+			Expect(authorizedKey(keys.authorize("k1", scopeRead))).To(Equal("old"))
+			Expect(authorizedKey(keys.authorize("k2", scopeRead))).To(Equal("new"))
+
+			GinkgoT().Setenv("API_KEYS", "new:k2:read")
+			Expect(keys.reload()).To(Succeed())
+			_, ok := keys.authorize("k1", scopeRead)
+			Expect(ok).To(BeFalse())
+			Expect(authorizedKey(keys.authorize("k2", scopeRead))).To(Equal("new"))
+		})
+
+		DescribeTable("keeps the current keys when reloading invalid ones",
+			func(env, value, expectedErr string) {
+				GinkgoT().Setenv("API_KEYS", "current:k1:read")
+				keys, err := newKeyStore()
+				Expect(err).NotTo(HaveOccurred())
+
+				GinkgoT().Setenv(env, value)
+				Expect(keys.reload()).To(MatchError(ContainSubstring(expectedErr)))
+				Expect(authorizedKey(keys.authorize("k1", scopeRead))).To(Equal("current"))
+			},
+			Entry("unknown scope", "API_KEYS", "current:k1:read,new:k2:write", `unknown scope "write"`),
+			Entry("missing key", "API_KEYS", "current:k1:read,new::read", "require a name and a key"),
+			Entry("invalid entry", "API_KEYS", "current:k1", "invalid entry #1"),
+			Entry("missing keys file", "API_KEYS_FILE", "/nonexistent/keys.json", "reading API_KEYS_FILE"),
+		)
+
+		It("rejects keys without scopes", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "keys.json")
+			Expect(os.WriteFile(path, []byte(`[{"name": "noscope", "key": "k1"}]`), 0600)).To(Succeed())
+			GinkgoT().Setenv("API_KEYS_FILE", path)
+			_, err := newKeyStore()
+			Expect(err).To(MatchError(`API key "noscope" has no scopes`))
+		})
+	})
+
+	Describe("authorizeRequest", func() {
+		var keys *keyStore
+
+		BeforeEach(func() {
+			GinkgoT().Setenv("API_KEYS", "dashboard:abc:read")
+			var err error
+			keys, err = newKeyStore()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("accepts the key in the Authorization header", func() {
+			r := httptest.NewRequest("GET", "/api/charts", nil)
+			r.Header.Set("Authorization", consts.AuthHeaderPrefix+"abc")
+			Expect(authorizedKey(keys.authorizeRequest(r, scopeRead))).To(Equal("dashboard"))
+		})
+
+		It("accepts the key in the api_key query param", func() {
+			r := httptest.NewRequest("GET", "/api/charts?"+consts.APIKeyQueryParam+"=abc", nil)
+			Expect(authorizedKey(keys.authorizeRequest(r, scopeRead))).To(Equal("dashboard"))
+		})
+
+		It("falls back to the query param when the header doesn't match", func() {
+			r := httptest.NewRequest("GET", "/api/charts?"+consts.APIKeyQueryParam+"=abc", nil)
+			r.Header.Set("Authorization", consts.AuthHeaderPrefix+"other")
+			Expect(authorizedKey(keys.authorizeRequest(r, scopeRead))).To(Equal("dashboard"))
+		})
+
+		It("rejects requests without a matching key", func() {
+			r := httptest.NewRequest("GET", "/api/charts?"+consts.APIKeyQueryParam+"=nope", nil)
+			r.Header.Set("Authorization", consts.AuthHeaderPrefix+"other")
+			_, ok := keys.authorizeRequest(r, scopeRead)
+			Expect(ok).To(BeFalse())
+			_, ok = keys.authorizeRequest(httptest.NewRequest("GET", "/api/charts", nil), scopeRead)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
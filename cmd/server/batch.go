@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// Per-item statuses returned by the batch endpoint
+const (
+	batchStatusStored  = "stored"
+	batchStatusBlocked = "blocked"
+	batchStatusSampled = "sampled" // Left out of the sample during a traffic spike (see sampler)
+	batchStatusQueued  = "queued"  // Published to the queue, stored later (see ingestQueue)
+	batchStatusFlagged = "flagged" // Implausible, stored apart for review (see flagReason)
+	batchStatusInvalid = "invalid"
+)
+
+type batchItemResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	collectResponse
+	Stored   int               `json:"stored"`
+	Rejected int               `json:"rejected"`
+	Results  []batchItemResult `json:"results"`
+}
+
+// batchHandler accepts an array of reports (e.g. from an aggregating proxy or a buffered offline
+// client) and stores all valid ones in a single transaction. Reports from blocked instances, or left
+// out of the sample during traffic spikes, are accepted but not stored, like in /collect. The sender's
+// IP is not necessarily the instances' IP, so no country is recorded for batched reports, and they are
+// not counted by the per-IP abuse detection
+func batchHandler(dbConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reports []insights.Data
+		err := decodeJSONBodyLimit(w, r, &reports, limits.maxBatchBodySize)
+		if err != nil {
+			var mr *malformedRequest
+			if errors.As(err, &mr) {
+				http.Error(w, mr.msg, mr.status)
+			} else {
+				log.Printf("error decoding batch payload: %s", err.Error()) //#nosec G706 -- error message is safe
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			}
+			malformedPayloads.add()
+			return
+		}
+		if len(reports) == 0 {
+			http.Error(w, "Request body must contain at least one report", http.StatusBadRequest)
+			return
+		}
+		if len(reports) > consts.MaxBatchReports {
+			msg := fmt.Sprintf("Request body must not contain more than %d reports", consts.MaxBatchReports)
+			http.Error(w, msg, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		resp := batchResponse{
+			collectResponse: newCollectResponse(),
+			Results:         make([]batchItemResult, len(reports)),
+		}
+		now := time.Now()
+		ctx, cancel := context.WithTimeout(r.Context(), consts.SaveReportTimeout)
+		defer cancel()
+		var valid []insights.Data
+		flagged := map[string][]insights.Data{}
+		for i, data := range reports {
+			result := batchItemResult{Index: i, Status: batchStatusStored}
+			if strings.TrimSpace(data.InsightsID) == "" {
+				result.Status, result.Error = batchStatusInvalid, "missing id"
+				resp.Rejected++
+				resp.Results[i] = result
+				continue
+			}
+			// With a queue, the consumer checks the blocked instances, the flags and the sampling
+			if ingestQueue != nil {
+				result.Status = batchStatusQueued
+				valid = append(valid, data)
+				resp.Stored++
+				resp.Results[i] = result
+				continue
+			}
+			blocked, err := db.IsBlocked(dbConn, data.InsightsID)
+			if err != nil {
+				log.Printf("Error checking blocked instances: %s", err.Error()) //#nosec G706 -- error message is safe
+				reporter.Error(err, map[string]string{"handler": "batch"})
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if blocked {
+				result.Status = batchStatusBlocked
+				resp.Results[i] = result
+				continue
+			}
+			if flag := flagReason(data, "", now); flag != "" {
+				result.Status = batchStatusFlagged
+				flagged[flag] = append(flagged[flag], data)
+				resp.Results[i] = result
+				continue
+			}
+			keep, err := ingestSampler.keep(ctx, data.InsightsID)
+			if err != nil {
+				log.Printf("Error sampling report: %s", err.Error()) //#nosec G706 -- error message is safe
+				reporter.Error(err, map[string]string{"handler": "batch"})
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if keep {
+				valid = append(valid, data)
+				resp.Stored++
+			} else {
+				result.Status = batchStatusSampled
+			}
+			resp.Results[i] = result
+		}
+
+		if len(valid) > 0 {
+			var err error
+			if ingestQueue != nil {
+				err = publishReports(ctx, valid, now, "", "")
+			} else {
+				err = db.SaveReports(ctx, dbConn, valid, now, "")
+			}
+			if err != nil {
+				log.Printf("Error handling batch request: %s", err.Error()) //#nosec G706 -- error message is safe
+				reporter.Error(err, map[string]string{"handler": "batch"})
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		for flag, data := range flagged {
+			if err := db.FlagReports(ctx, dbConn, data, now, "", flag); err != nil {
+				log.Printf("Error flagging batch reports: %s", err.Error()) //#nosec G706 -- error message is safe
+				reporter.Error(err, map[string]string{"handler": "batch"})
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// corsPolicy lets browser apps on other origins (e.g. the navidrome.org website or third-party
+// dashboards) fetch the read endpoints of the API. Admin and collect endpoints never allow other origins
+type corsPolicy struct {
+	anyOrigin bool                // CORS_ALLOWED_ORIGINS is "*"
+	origins   map[string]struct{} // Allowed origins, like "https://www.navidrome.org"
+}
+
+// corsFromEnv configures the allowed origins from CORS_ALLOWED_ORIGINS: comma separated origins
+// (scheme://host[:port]), or "*" for any origin. Returns nil if unset, disabling CORS
+func corsFromEnv() (*corsPolicy, error) {
+	v := strings.TrimSpace(os.Getenv("CORS_ALLOWED_ORIGINS"))
+	if v == "" {
+		return nil, nil
+	}
+	if v == "*" {
+		return &corsPolicy{anyOrigin: true}, nil
+	}
+	c := &corsPolicy{origins: map[string]struct{}{}}
+	for origin := range strings.SplitSeq(v, ",") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return nil, fmt.Errorf("invalid origin %q in CORS_ALLOWED_ORIGINS, expected scheme://host[:port]", origin)
+		}
+		c.origins[strings.ToLower(origin)] = struct{}{}
+	}
+	if len(c.origins) == 0 {
+		return nil, fmt.Errorf("invalid CORS_ALLOWED_ORIGINS %q", v)
+	}
+	return c, nil
+}
+
+// allowOrigin sets the Access-Control-Allow-Origin header if the request's origin is allowed, returning
+// whether it is
+func (c *corsPolicy) allowOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if c.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return origin != ""
+	}
+	// The response depends on the origin, so caches must not share it between origins
+	w.Header().Add("Vary", "Origin")
+	if _, ok := c.origins[strings.ToLower(origin)]; !ok {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	return true
+}
+
+// middleware adds the CORS headers to the responses of a read endpoint, before the access checks so
+// browsers can read the 401 of a missing or invalid read key too
+func (c *corsPolicy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.allowOrigin(w, r) {
+			// Last-Modified is readable by default, but not the ETag clients need for If-None-Match
+			w.Header().Set("Access-Control-Expose-Headers", "ETag")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflight answers the OPTIONS requests browsers send before a GET with an Authorization header (a read
+// key). Preflights carry no credentials, so they are answered without access checks
+func (c *corsPolicy) preflight() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.allowOrigin(w, r) {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, If-None-Match")
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(consts.CORSMaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/web"
+)
+
+// registerDashboardRoutes serves the public dashboard embedded in the binary, and the charts.json it loads,
+// when SERVE_DASHBOARD is enabled, so the public site can be hosted by the collector itself. In dev builds,
+// the dev routes registered afterwards override these, serving index.html from disk
+func registerDashboardRoutes(r chi.Router) error {
+	v := os.Getenv("SERVE_DASHBOARD")
+	if v == "" {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("invalid SERVE_DASHBOARD %q", v)
+	}
+	if !enabled {
+		return nil
+	}
+
+	chartData := http.StripPrefix("/chartdata/", http.FileServer(http.Dir(consts.ChartDataDir)))
+	r.With(compress).Get("/chartdata/*", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", consts.ChartsCacheControl)
+		chartData.ServeHTTP(w, r)
+	})
+	r.With(htmlPage, compress).Get("/", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, web.FS, "index.html")
+	})
+	log.Print("Serving the public dashboard")
+	return nil
+}
@@ -20,6 +20,11 @@ func (mr *malformedRequest) Error() string {
 
 // decodeJSONBody from https://www.alexedwards.net/blog/how-to-properly-parse-a-json-request-body
 func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	return decodeJSONBodyLimit(w, r, dst, limits.maxCollectBodySize)
+}
+
+// decodeJSONBodyLimit is like decodeJSONBody, with a custom body size limit (in bytes)
+func decodeJSONBodyLimit(w http.ResponseWriter, r *http.Request, dst interface{}, maxBytes int64) error {
 	ct := r.Header.Get("Content-Type")
 	if ct != "" {
 		mediaType := strings.ToLower(strings.TrimSpace(strings.Split(ct, ";")[0]))
@@ -29,14 +34,20 @@ func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) err
 		}
 	}
 
-	// Limit the size of the request body to 100KB
-	r.Body = http.MaxBytesReader(w, r.Body, 100*1024)
+	// Limit the size of the request body, both before and after decompression
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	body, err := decompressBody(r)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+	body = http.MaxBytesReader(w, body, maxBytes)
 
-	dec := json.NewDecoder(r.Body)
+	dec := json.NewDecoder(body)
 
 	//dec.DisallowUnknownFields()
 
-	err := dec.Decode(&dst)
+	err = dec.Decode(&dst)
 	if err != nil {
 		var syntaxError *json.SyntaxError
 		var unmarshalTypeError *json.UnmarshalTypeError
@@ -64,7 +75,7 @@ func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}) err
 			return &malformedRequest{status: http.StatusBadRequest, msg: msg}
 
 		case err.Error() == "http: request body too large":
-			msg := "Request body must not be larger than 1MB"
+			msg := fmt.Sprintf("Request body must not be larger than %dKB", maxBytes/1024)
 			return &malformedRequest{status: http.StatusRequestEntityTooLarge, msg: msg}
 
 		default:
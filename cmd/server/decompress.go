@@ -0,0 +1,38 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMaxWindow caps the memory a zstd frame can make the decoder allocate. The decompressed
+// size itself is limited by the caller
+const zstdMaxWindow = 8 << 20
+
+// decompressBody wraps the request body with a decoder matching its Content-Encoding header.
+// Bodies without Content-Encoding (or "identity") are returned as is.
+func decompressBody(r *http.Request) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return r.Body, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, &malformedRequest{status: http.StatusBadRequest, msg: "Request body is not valid gzip"}
+		}
+		return zr, nil
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body, zstd.WithDecoderMaxWindow(zstdMaxWindow), zstd.WithDecoderLowmem(true), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, &malformedRequest{status: http.StatusBadRequest, msg: "Request body is not valid zstd"}
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, &malformedRequest{status: http.StatusUnsupportedMediaType, msg: "Unsupported Content-Encoding: " + encoding}
+	}
+}
@@ -12,11 +12,11 @@ import (
 
 func registerDevRoutes(r chi.Router) {
 	// Static files for charts
-	r.Handle("/chartdata/*", http.StripPrefix("/chartdata/", http.FileServer(http.Dir(consts.ChartDataDir))))
-	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+	r.With(compress).Handle("/chartdata/*", http.StripPrefix("/chartdata/", http.FileServer(http.Dir(consts.ChartDataDir))))
+	r.With(htmlPage).Get("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, consts.WebIndexPath)
 	})
 
 	// Charts endpoint (no rate limiting) - legacy, renders server-side
-	r.Get("/charts", charts.ChartsHandler())
+	r.With(htmlPage, compress).Get("/charts", charts.ChartsHandler())
 }
@@ -1,21 +1,35 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/navidrome/insights/charts"
 	"github.com/navidrome/insights/consts"
 	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/geoip"
+	"github.com/navidrome/insights/summary"
 	"github.com/navidrome/navidrome/core/metrics/insights"
 )
 
-func handler(dbConn *sql.DB) http.HandlerFunc {
+// compress gzips responses of the large documents served by the charts and export endpoints,
+// which compress extremely well
+var compress = middleware.Compress(consts.CompressionLevel, "application/json", "text/html", "text/csv", "image/svg+xml")
+
+func handler(dbConn *sql.DB, geo *geoip.Resolver) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var data insights.Data
 
@@ -28,60 +42,377 @@ func handler(dbConn *sql.DB) http.HandlerFunc {
 				log.Printf("error decoding payload: %s", err.Error()) //#nosec G706 -- error message is safe
 				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 			}
+			malformedPayloads.add()
+			return
+		}
+
+		// Only the country code is persisted, never the IP address
+		country := geo.Country(r.RemoteAddr)
+		now := time.Now()
+		ctx, cancel := context.WithTimeout(r.Context(), consts.SaveReportTimeout)
+		defer cancel()
+
+		// With a queue, the consumer checks the blocked instances, the flags and the sampling when storing the
+		// report. Only the IP, which is never queued, is checked here
+		if ingestQueue != nil {
+			flag := ipFlagReason(r.RemoteAddr, data.InsightsID, now)
+			if err := publishReports(ctx, []insights.Data{data}, now, country, flag); err != nil {
+				log.Printf("Error queueing report: %s", err.Error()) //#nosec G706 -- error message is safe
+				reporter.Error(err, map[string]string{"handler": "collect"})
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, newCollectResponse())
+			return
+		}
+
+		// Reports from blocked instances are accepted but not stored, so clients don't retry
+		blocked, err := db.IsBlocked(dbConn, data.InsightsID)
+		if err != nil {
+			log.Printf("Error checking blocked instances: %s", err.Error()) //#nosec G706 -- error message is safe
+			reporter.Error(err, map[string]string{"handler": "collect"})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if blocked {
+			writeJSON(w, http.StatusOK, newCollectResponse())
+			return
+		}
+		// Implausible or spoofed reports are accepted too, but stored apart for review
+		if flag := flagReason(data, r.RemoteAddr, now); flag != "" {
+			if err := db.FlagReports(ctx, dbConn, []insights.Data{data}, now, country, flag); err != nil {
+				log.Printf("Error flagging report: %s", err.Error()) //#nosec G706 -- error message is safe
+				reporter.Error(err, map[string]string{"handler": "collect"})
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, newCollectResponse())
+			return
+		}
+		// Reports left out of the sample during traffic spikes are accepted but not stored either
+		keep, err := ingestSampler.keep(ctx, data.InsightsID)
+		if err != nil {
+			log.Printf("Error sampling report: %s", err.Error()) //#nosec G706 -- error message is safe
+			reporter.Error(err, map[string]string{"handler": "collect"})
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !keep {
+			writeJSON(w, http.StatusOK, newCollectResponse())
 			return
 		}
 
-		err = db.SaveReport(dbConn, data, time.Now())
+		err = db.SaveReport(ctx, dbConn, data, now, country)
 		if err != nil {
 			log.Printf("Error handling request: %s", err.Error()) //#nosec G706 -- error message is safe
+			reporter.Error(err, map[string]string{"handler": "collect"})
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		writeJSON(w, http.StatusOK, newCollectResponse())
 	}
 }
 
-// apiKeyMiddleware validates the API key if API_KEY env var is set.
-// If API_KEY is empty, all requests are allowed (public access).
-// Otherwise, requires Authorization: Bearer <key> header or api_key query param.
-func apiKeyMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiKey := os.Getenv("API_KEY")
-		if apiKey == "" {
-			// No API key configured, allow public access
-			next.ServeHTTP(w, r)
-			return
-		}
+// collectResponse tells clients when to send their next report, so the reporting interval
+// can be adjusted server-side
+type collectResponse struct {
+	NextReportAfter int64 `json:"nextReportAfter"` // Seconds
+}
+
+// newCollectResponse derives the reporting hint from the rate limit (the window divided by the
+// requests allowed in it), as reports sent more often would be rejected anyway
+func newCollectResponse() collectResponse {
+	interval := limits.rateLimitWindow / time.Duration(limits.rateLimitRequests)
+	return collectResponse{NextReportAfter: int64(interval.Seconds())}
+}
 
-		// Check Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if strings.HasPrefix(authHeader, consts.AuthHeaderPrefix) {
-			if strings.TrimPrefix(authHeader, consts.AuthHeaderPrefix) == apiKey {
+// apiKeyMiddleware requires a key with the read scope, if any API keys are configured.
+// If no keys are configured, all requests are allowed (public access).
+// Keys are passed with the Authorization: Bearer <key> header or api_key query param.
+func apiKeyMiddleware(keys *keyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if keys.empty() {
+				// No API key configured, allow public access
 				next.ServeHTTP(w, r)
 				return
 			}
-		}
 
-		// Check query parameter
-		if r.URL.Query().Get(consts.APIKeyQueryParam) == apiKey {
-			next.ServeHTTP(w, r)
-			return
-		}
+			if _, ok := keys.authorizeRequest(r, scopeRead); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-	})
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		})
+	}
 }
 
-// chartsJSONHandler serves the charts.json file directly.
+// adminKeyMiddleware always requires a key with the admin scope. If no keys are configured,
+// admin endpoints are disabled.
+func adminKeyMiddleware(keys *keyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if keys.empty() {
+				http.Error(w, "Admin API disabled", http.StatusForbidden)
+				return
+			}
+
+			if name, ok := keys.authorizeRequest(r, scopeAdmin); ok {
+				log.Printf("Admin request %s %s with key %q", r.Method, r.URL.Path, name) //#nosec G706 -- key name is from controlled config
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		})
+	}
+}
+
+// chartsJSONHandler serves the charts.json file directly. If `from` and/or `to` query params
+// (YYYY-MM-DD) are provided, the charts are generated on demand for that date range instead.
 func chartsJSONHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseDateRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !from.IsZero() || !to.IsZero() {
+			data, err := charts.GenerateChartsJSON(from, to)
+			if errors.Is(err, charts.ErrNoData) {
+				http.Error(w, "No data available for the requested range", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("Error generating charts JSON: %v", err)
+				reporter.Error(err, map[string]string{"handler": "charts"})
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			serveGenerated(w, r, "application/json", data)
+			return
+		}
+
 		chartsPath := filepath.Join(consts.ChartDataDir, consts.ChartsJSONFile)
-		if _, err := os.Stat(chartsPath); os.IsNotExist(err) {
+		info, err := os.Stat(chartsPath)
+		if os.IsNotExist(err) {
 			http.Error(w, "Charts data not available", http.StatusNotFound)
 			return
 		}
+		// ServeFile honors If-None-Match against this ETag, and If-Modified-Since against the file mtime
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", consts.ChartsCacheControl)
+		if err == nil {
+			w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+		}
 		http.ServeFile(w, r, chartsPath)
 	}
 }
+
+// chartHandler serves the options of a single chart, generated on demand. Accepts the same `from`/`to`
+// query params as chartsJSONHandler, and `theme` (light or dark).
+func chartHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseDateRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		theme := charts.ThemeByName(r.URL.Query().Get("theme"))
+		data, err := charts.GenerateChartJSON(chi.URLParam(r, "id"), theme, from, to)
+		if errors.Is(err, charts.ErrUnknownChart) {
+			http.Error(w, "Chart not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, charts.ErrNoData) {
+			http.Error(w, "No data available for the requested range", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error generating chart JSON: %v", err)
+			reporter.Error(err, map[string]string{"handler": "chart"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		serveGenerated(w, r, "application/json", data)
+	}
+}
+
+// chartImageHandler serves a single chart rendered as an image (charts.ImagePNG or charts.ImageSVG), for
+// embedding in READMEs, forum posts and release announcements. Accepts the same query params as chartHandler.
+func chartImageHandler(format string) http.HandlerFunc {
+	contentType := "image/png"
+	if format == charts.ImageSVG {
+		contentType = "image/svg+xml"
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseDateRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		theme := charts.ThemeByName(r.URL.Query().Get("theme"))
+		data, err := charts.RenderChartImage(chi.URLParam(r, "id"), format, theme, from, to)
+		if errors.Is(err, charts.ErrUnknownChart) {
+			http.Error(w, "Chart not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, charts.ErrNoData) {
+			http.Error(w, "No data available for the requested range", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error rendering chart image: %v", err)
+			reporter.Error(err, map[string]string{"handler": "chartImage"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		serveGenerated(w, r, contentType, data)
+	}
+}
+
+type latestStats struct {
+	Date    string          `json:"date"` // YYYY-MM-DD
+	Summary summary.Summary `json:"summary"`
+}
+
+// latestStatsHandler serves the summary of the most recent complete day (see charts.LatestSummary), so
+// integrations can get headline numbers without parsing chart options
+func latestStatsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		latest, err := charts.LatestSummary()
+		if errors.Is(err, charts.ErrNoData) {
+			http.Error(w, "No data available", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading latest summary: %v", err)
+			reporter.Error(err, map[string]string{"handler": "latestStats"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		data, err := json.Marshal(latestStats{Date: latest.Time.Format(consts.DateFormat), Summary: latest.Data})
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		serveGenerated(w, r, "application/json", data)
+	}
+}
+
+// serveGenerated serves a document generated on demand, with an ETag derived from the content
+func serveGenerated(w http.ResponseWriter, r *http.Request, contentType string, data []byte) {
+	sum := sha256.Sum256(data)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", consts.ChartsCacheControl)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// parseDateRange parses the optional `from` and `to` query params. Missing params are returned as zero values.
+func parseDateRange(r *http.Request) (from, to time.Time, err error) {
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.Parse(consts.DateFormat, v); err != nil {
+			return from, to, fmt.Errorf("invalid 'from' date %q, expected YYYY-MM-DD", v)
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.Parse(consts.DateFormat, v); err != nil {
+			return from, to, fmt.Errorf("invalid 'to' date %q, expected YYYY-MM-DD", v)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return from, to, errors.New("'to' date must not be before 'from' date")
+	}
+	return from, to, nil
+}
+
+// summariesCSVHandler exports the daily summaries as CSV, optionally filtered by `from`/`to` query params.
+func summariesCSVHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseDateRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		summaries, err := charts.CachedSummaries()
+		if err != nil {
+			log.Printf("Error loading summaries: %v", err)
+			reporter.Error(err, map[string]string{"handler": "export"})
+			http.Error(w, "Failed to load data", http.StatusInternalServerError)
+			return
+		}
+		summaries = summary.FilterSummaries(summaries, from, to)
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="summaries.csv"`)
+		if err := summary.WriteCSV(w, summaries); err != nil {
+			log.Printf("Error writing CSV: %v", err)
+		}
+	}
+}
+
+type queryPoint struct {
+	Date  string   `json:"date"`  // YYYY-MM-DD
+	Value *float64 `json:"value"` // Null when the summary has no value for the metric
+}
+
+// queryHandler serves the values of the `metric` query param (a dotted path into the summary, see
+// summary.MetricValue) for each daily summary, optionally filtered by `from`/`to` query params
+func queryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, err := parseDateRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		metric := r.URL.Query().Get("metric")
+		// Validate the metric before loading the summaries, against an empty summary
+		if _, err := summary.MetricValue(summary.Summary{}, metric); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		summaries, err := charts.CachedSummaries()
+		if err != nil {
+			log.Printf("Error loading summaries: %v", err)
+			reporter.Error(err, map[string]string{"handler": "query"})
+			http.Error(w, "Failed to load data", http.StatusInternalServerError)
+			return
+		}
+		summaries = summary.FilterSummaries(summaries, from, to)
+
+		points := make([]queryPoint, len(summaries))
+		for i, s := range summaries {
+			value, _ := summary.MetricValue(s.Data, metric)
+			points[i] = queryPoint{Date: s.Time.Format(consts.DateFormat), Value: value}
+		}
+		data, err := json.Marshal(points)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		serveGenerated(w, r, "application/json", data)
+	}
+}
+
+// upgradesHandler serves how long the instances took to upgrade to each stable release (see
+// db.UpgradeTimes), using the release dates of the releases file when loaded
+func upgradesHandler(dbConn *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		upgrades, err := db.UpgradeTimes(r.Context(), dbConn, charts.ReleaseDates())
+		if err != nil {
+			log.Printf("Error computing upgrade times: %v", err) //#nosec G706 -- error message is safe
+			reporter.Error(err, map[string]string{"handler": "upgrades"})
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		data, err := json.Marshal(upgrades)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		serveGenerated(w, r, "application/json", data)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Job names, as used by the admin API
+const (
+	jobSummarize = "summarize"
+	jobCharts    = "charts"
+	jobCleanup   = "cleanup"
+	jobBackup    = "backup"
+)
+
+// jobNames lists all jobs, in the order reported by /api/admin/jobs
+var jobNames = []string{jobSummarize, jobCharts, jobCleanup, jobBackup}
+
+var errJobRunning = errors.New("previous run still in progress")
+
+// jobRun records a single run of a job
+type jobRun struct {
+	Start time.Time
+	End   time.Time
+	Rows  int64 // Rows processed: reports summarized, entries deleted. 0 for jobs that don't process rows
+	Err   error
+}
+
+type jobState struct {
+	waiting bool     // Whether a run is waiting for its lock, so it isn't started twice
+	running *jobRun  // Current run, nil if not running
+	history []jobRun // Finished runs, most recent first, up to consts.JobHistorySize
+}
+
+// jobRunner records the runs of each job and prevents a job from starting while its previous run is still
+// going or waiting to start, e.g. when a summarize run on a large DB takes longer than the cron interval.
+// The jobs touching the summaries share tasksMu, so they run one at a time; the others (backup) run
+// concurrently with them
+type jobRunner struct {
+	mu   sync.Mutex
+	jobs map[string]*jobState
+}
+
+var jobs = &jobRunner{jobs: map[string]*jobState{}}
+
+// run calls fn as a run of the named job while holding lock (if not nil), or returns errJobRunning without
+// calling it if the job is running or waiting for the lock. The run's start is recorded once the lock is
+// held. fn is called with a context carrying the run's trace span, and returns the number of rows processed
+func (r *jobRunner) run(ctx context.Context, name string, lock sync.Locker, fn func(ctx context.Context) (int64, error)) error {
+	r.mu.Lock()
+	state := r.state(name)
+	if state.waiting {
+		r.mu.Unlock()
+		log.Printf("Skipping %s, previous run is waiting for another task to finish", name) //#nosec G706 -- name is one of the job constants
+		return errJobRunning
+	}
+	if state.running != nil {
+		started := state.running.Start
+		r.mu.Unlock()
+		log.Printf("Skipping %s, previous run started at %s is still in progress", name, started.Format(time.RFC3339)) //#nosec G706 -- name is one of the job constants
+		return errJobRunning
+	}
+	state.waiting = true
+	r.mu.Unlock()
+
+	if lock != nil {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	r.mu.Lock()
+	state.waiting = false
+	run := &jobRun{Start: time.Now()}
+	state.running = run
+	r.mu.Unlock()
+
+	ctx, span := tracing.Tracer().Start(ctx, "task "+name, trace.WithAttributes(attribute.String("task", name)))
+	rows, err := fn(ctx)
+	span.SetAttributes(attribute.Int64("rows", rows))
+	// Runs cancelled on shutdown are not failures
+	if err != nil && !errors.Is(err, context.Canceled) {
+		reporter.Error(fmt.Errorf("%s: %w", name, err), map[string]string{"task": name})
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run.End = time.Now()
+	run.Rows = rows
+	run.Err = err
+	state.running = nil
+	state.history = append([]jobRun{*run}, state.history[:min(len(state.history), consts.JobHistorySize-1)]...)
+	return err
+}
+
+func (r *jobRunner) state(name string) *jobState {
+	state, ok := r.jobs[name]
+	if !ok {
+		state = &jobState{}
+		r.jobs[name] = state
+	}
+	return state
+}
+
+// jobStatus is the state of a job, as reported by /api/admin/jobs
+type jobStatus struct {
+	Name         string         `json:"name"`
+	Waiting      bool           `json:"waiting,omitempty"` // Waiting for another task to finish (see tasksMu)
+	RunningSince *time.Time     `json:"runningSince,omitempty"`
+	LastRun      *jobRunStatus  `json:"lastRun"` // null if the job didn't run since the server started
+	History      []jobRunStatus `json:"history"` // Most recent first, including the last run
+}
+
+type jobRunStatus struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration string    `json:"duration"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+	Rows     int64     `json:"rows"`
+}
+
+// status returns the state of all jobs
+func (r *jobRunner) status() []jobStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]jobStatus, 0, len(jobNames))
+	for _, name := range jobNames {
+		state := r.state(name)
+		s := jobStatus{Name: name, Waiting: state.waiting, History: make([]jobRunStatus, 0, len(state.history))}
+		if state.running != nil {
+			start := state.running.Start.UTC()
+			s.RunningSince = &start
+		}
+		for _, run := range state.history {
+			rs := jobRunStatus{
+				Start:    run.Start.UTC(),
+				End:      run.End.UTC(),
+				Duration: run.End.Sub(run.Start).Round(time.Millisecond).String(),
+				Success:  run.Err == nil,
+				Rows:     run.Rows,
+			}
+			if run.Err != nil {
+				rs.Error = run.Err.Error()
+			}
+			s.History = append(s.History, rs)
+		}
+		if len(s.History) > 0 {
+			s.LastRun = &s.History[0]
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
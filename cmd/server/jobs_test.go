@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("jobRunner", func() {
+	var r *jobRunner
+	var lock sync.Mutex
+
+	BeforeEach(func() {
+		r = &jobRunner{jobs: map[string]*jobState{}}
+	})
+
+	statusOf := func(name string) jobStatus {
+		for _, s := range r.status() {
+			if s.Name == name {
+				return s
+			}
+		}
+		Fail("unknown job " + name)
+		return jobStatus{}
+	}
+
+	It("records the start of a run once it holds the lock, and skips the job while it waits", func() {
+		release := make(chan struct{})
+		started := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			Expect(r.run(context.Background(), jobCleanup, &lock, func(context.Context) (int64, error) {
+				close(started)
+				<-release
+				return 0, nil
+			})).To(Succeed())
+		}()
+		<-started
+
+		done := make(chan error)
+		go func() {
+			done <- r.run(context.Background(), jobSummarize, &lock, func(context.Context) (int64, error) { return 3, nil })
+		}()
+		Eventually(func() bool { return statusOf(jobSummarize).Waiting }).Should(BeTrue())
+		Expect(statusOf(jobSummarize).RunningSince).To(BeNil())
+		Expect(r.run(context.Background(), jobSummarize, &lock, func(context.Context) (int64, error) {
+			Fail("should not run")
+			return 0, nil
+		})).To(MatchError(errJobRunning))
+
+		close(release)
+		Expect(<-done).To(Succeed())
+		summarize, cleanup := statusOf(jobSummarize), statusOf(jobCleanup)
+		Expect(summarize.Waiting).To(BeFalse())
+		Expect(summarize.LastRun.Rows).To(Equal(int64(3)))
+		Expect(summarize.LastRun.Start).NotTo(BeTemporally("<", cleanup.LastRun.End))
+	})
+
+	It("runs the jobs without a lock concurrently", func() {
+		release := make(chan struct{})
+		started := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			Expect(r.run(context.Background(), jobCleanup, &lock, func(context.Context) (int64, error) {
+				close(started)
+				<-release
+				return 0, nil
+			})).To(Succeed())
+		}()
+		<-started
+		defer close(release)
+
+		Expect(r.run(context.Background(), jobBackup, nil, func(context.Context) (int64, error) { return 0, nil })).To(Succeed())
+		Expect(statusOf(jobBackup).LastRun.Success).To(BeTrue())
+	})
+})
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"golang.org/x/net/netutil"
+)
+
+// serverLimits protect the server from oversized requests, slow or idle clients and instances reporting
+// too often. Each limit is configured by an env var (see limitsFromEnv), defaulting to its consts value
+type serverLimits struct {
+	maxBodySize        int64         // HTTP_MAX_BODY_SIZE: API requests without a limit of their own (see apiRoute.maxBodySize)
+	maxCollectBodySize int64         // HTTP_MAX_COLLECT_BODY_SIZE: /collect, before and after decompression
+	maxBatchBodySize   int64         // HTTP_MAX_BATCH_BODY_SIZE: /collect/batch
+	maxRestoreSize     int64         // HTTP_MAX_RESTORE_SIZE: backup zips uploaded to /admin/restore
+	readHeaderTimeout  time.Duration // HTTP_READ_HEADER_TIMEOUT
+	readTimeout        time.Duration // HTTP_READ_TIMEOUT, 0 to disable
+	writeTimeout       time.Duration // HTTP_WRITE_TIMEOUT, 0 to disable
+	idleTimeout        time.Duration // HTTP_IDLE_TIMEOUT, 0 to use the read timeout
+	maxConns           int           // HTTP_MAX_CONNS, 0 to disable
+	rateLimitRequests  int           // RATE_LIMIT_REQUESTS: reports per IP per window, on /collect and /collect/batch
+	rateLimitWindow    time.Duration // RATE_LIMIT_WINDOW, also the reporting interval sent to clients
+}
+
+// limits are the limits of the running server, set by main before the routes are registered
+var limits = serverLimits{
+	maxBodySize:        consts.MaxBodySize,
+	maxCollectBodySize: consts.MaxCollectBodySize,
+	maxBatchBodySize:   consts.MaxBatchBodySize,
+	maxRestoreSize:     consts.MaxRestoreSize,
+	readHeaderTimeout:  consts.ReadHeaderTimeout,
+	readTimeout:        consts.ReadTimeout,
+	writeTimeout:       consts.WriteTimeout,
+	idleTimeout:        consts.IdleTimeout,
+	maxConns:           consts.DefaultMaxConns,
+	rateLimitRequests:  consts.DefaultRateLimitRequests,
+	rateLimitWindow:    consts.DefaultRateLimitWindow,
+}
+
+// limitsFromEnv returns the default limits, overridden by the HTTP_* and RATE_LIMIT_* env vars: sizes in
+// bytes, timeouts and windows as Go durations (e.g. 30s, 2m)
+func limitsFromEnv() (serverLimits, error) {
+	l := limits
+	sizes := []struct {
+		env   string
+		value *int64
+	}{
+		{"HTTP_MAX_BODY_SIZE", &l.maxBodySize},
+		{"HTTP_MAX_COLLECT_BODY_SIZE", &l.maxCollectBodySize},
+		{"HTTP_MAX_BATCH_BODY_SIZE", &l.maxBatchBodySize},
+		{"HTTP_MAX_RESTORE_SIZE", &l.maxRestoreSize},
+	}
+	for _, s := range sizes {
+		v := os.Getenv(s.env)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			return l, fmt.Errorf("invalid %s %q, expected a size in bytes", s.env, v)
+		}
+		*s.value = n
+	}
+	timeouts := []struct {
+		env   string
+		value *time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", &l.readHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", &l.readTimeout},
+		{"HTTP_WRITE_TIMEOUT", &l.writeTimeout},
+		{"HTTP_IDLE_TIMEOUT", &l.idleTimeout},
+	}
+	for _, t := range timeouts {
+		v := os.Getenv(t.env)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return l, fmt.Errorf("invalid %s %q, expected a duration like 30s", t.env, v)
+		}
+		*t.value = d
+	}
+	if l.readHeaderTimeout == 0 {
+		return l, fmt.Errorf("HTTP_READ_HEADER_TIMEOUT can't be disabled")
+	}
+	if v := os.Getenv("HTTP_MAX_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return l, fmt.Errorf("invalid HTTP_MAX_CONNS %q", v)
+		}
+		l.maxConns = n
+	}
+	if v := os.Getenv("RATE_LIMIT_REQUESTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return l, fmt.Errorf("invalid RATE_LIMIT_REQUESTS %q, expected at least 1", v)
+		}
+		l.rateLimitRequests = n
+	}
+	if v := os.Getenv("RATE_LIMIT_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			return l, fmt.Errorf("invalid RATE_LIMIT_WINDOW %q, expected a duration of at least 1s", v)
+		}
+		l.rateLimitWindow = d
+	}
+	return l, nil
+}
+
+// apply sets the timeouts of the server
+func (l serverLimits) apply(server *http.Server) {
+	server.ReadHeaderTimeout = l.readHeaderTimeout
+	server.ReadTimeout = l.readTimeout
+	server.WriteTimeout = l.writeTimeout
+	server.IdleTimeout = l.idleTimeout
+}
+
+// listen listens on addr, accepting up to maxConns concurrent connections
+func (l serverLimits) listen(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if l.maxConns > 0 {
+		ln = netutil.LimitListener(ln, l.maxConns)
+	}
+	return ln, nil
+}
+
+func (l serverLimits) String() string {
+	return fmt.Sprintf("body %d bytes (collect %d, batch %d, restore %d), timeouts read header %s, read %s, write %s, idle %s, max connections %d, rate limit %d per %s",
+		l.maxBodySize, l.maxCollectBodySize, l.maxBatchBodySize, l.maxRestoreSize,
+		l.readHeaderTimeout, l.readTimeout, l.writeTimeout, l.idleTimeout, l.maxConns,
+		l.rateLimitRequests, l.rateLimitWindow)
+}
+
+// limitBody rejects requests with a declared body larger than maxBytes with a 413, and stops reading the
+// others after maxBytes, so handlers that don't check the size can't be sent an unbounded body
+func limitBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withoutDeadlines lifts the read and write timeouts of the server for long-running routes (see
+// apiRoute.longRunning), like uploads, streamed exports and tasks run on demand. Only used after the
+// access checks, so unauthenticated clients are still bound by the timeouts
+func withoutDeadlines(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.SetReadDeadline(time.Time{}); err != nil {
+			log.Printf("Error lifting read deadline: %v", err)
+		}
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			log.Printf("Error lifting write deadline: %v", err)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
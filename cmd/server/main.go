@@ -1,87 +1,332 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/httprate"
+	"github.com/navidrome/insights/charts"
+	"github.com/navidrome/insights/clickhouse"
+	"github.com/navidrome/insights/config"
 	"github.com/navidrome/insights/consts"
 	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/errreport"
+	"github.com/navidrome/insights/geoip"
+	"github.com/navidrome/insights/notify"
+	"github.com/navidrome/insights/queue"
+	"github.com/navidrome/insights/replica"
+	"github.com/navidrome/insights/summary"
+	"github.com/navidrome/insights/tracing"
 	"github.com/robfig/cron/v3"
 )
 
-func startTasks(ctx context.Context, dbConn *sql.DB) error {
+func startTasks(ctx context.Context, dbConn, readConn *sql.DB, rep *replica.Replicator, schedules taskSchedules) (*cron.Cron, error) {
 	c := cron.New(cron.WithLocation(time.UTC))
-	// Run summarize every 2 hours
-	_, err := c.AddFunc(consts.CronSummarize, summarize(ctx, dbConn))
+	_, err := c.AddFunc(schedules.summarize, summarize(ctx, dbConn, readConn))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	// Generate charts JSON once a day at 00:05 UTC
-	_, err = c.AddFunc(consts.CronGenerateChart, generateCharts(ctx))
+	// Generate charts JSON, after the summaries of the previous day are complete
+	_, err = c.AddFunc(schedules.charts, generateCharts(ctx))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = c.AddFunc(consts.CronCleanup, cleanup(ctx, dbConn))
+	_, err = c.AddFunc(schedules.cleanup, cleanup(ctx, dbConn, readConn))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	// Backup the database, keeping the last BACKUP_COUNT backups
+	backupDir := cmp.Or(os.Getenv("BACKUP_FOLDER"), filepath.Join(config.DataFolder(), consts.BackupsDir))
+	backupCount := consts.DefaultBackupCount
+	if v := os.Getenv("BACKUP_COUNT"); v != "" {
+		backupCount, err = strconv.Atoi(v)
+		if err != nil || backupCount < 1 {
+			return nil, fmt.Errorf("invalid BACKUP_COUNT %q", v)
+		}
+	}
+	_, err = c.AddFunc(schedules.backup, backupDB(ctx, dbConn, backupDir, backupCount, rep))
+	if err != nil {
+		return nil, err
 	}
 	c.Start()
-	return nil
+	return c, nil
+}
+
+// shutdown stops accepting requests, then waits for in-flight requests and running tasks (already
+// cancelled through the root context) to finish, up to consts.ShutdownTimeout
+func shutdown(server *http.Server, c *cron.Cron) {
+	ctx, cancel := context.WithTimeout(context.Background(), consts.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	select {
+	case <-c.Stop().Done():
+	case <-ctx.Done():
+		log.Print("Timed out waiting for running tasks")
+	}
+}
+
+// reloadOnSignal reloads the API keys, player/filesystem type mappings and releases on SIGHUP, allowing keys to be rotated
+// and new clients to be mapped without a restart
+func reloadOnSignal(keys *keyStore) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			if err := keys.reload(); err != nil {
+				log.Printf("Error reloading API keys, keeping previous keys: %v", err)
+			} else {
+				log.Print("API keys reloaded")
+			}
+			loadMappings()
+		}
+	}()
+}
+
+// loadMappings loads the custom player and filesystem type mappings, library size bins and the chart
+// release annotations, keeping the current ones on error
+func loadMappings() {
+	if n, err := summary.LoadPlayerTypes(); err != nil {
+		log.Printf("Error loading player types, keeping previous mappings: %v", err)
+	} else {
+		log.Printf("Loaded %d custom player type mappings", n)
+	}
+	if n, err := summary.LoadFSTypes(); err != nil {
+		log.Printf("Error loading filesystem types, keeping previous mappings: %v", err)
+	} else {
+		log.Printf("Loaded %d custom filesystem type mappings", n)
+	}
+	if loaded, err := summary.LoadBins(); err != nil {
+		log.Printf("Error loading bins, keeping previous ones: %v", err)
+	} else if loaded {
+		log.Print("Loaded custom library size bins")
+	}
+	if n, err := charts.LoadReleases(); err != nil {
+		log.Printf("Error loading releases, keeping previous ones: %v", err)
+	} else {
+		log.Printf("Loaded %d releases", n)
+	}
 }
 
 func main() {
-	ctx := context.Background()
-	dataFolder := os.Getenv("DATA_FOLDER")
-	dbConn, err := db.OpenDB(filepath.Join(dataFolder, "insights.db"))
+	configFile := config.Flag()
+	flag.Parse()
+	// Settings of the config file, for the env vars not set
+	if err := config.Load(*configFile); err != nil {
+		log.Fatal(err)
+	}
+
+	// Cancelled on SIGINT/SIGTERM, interrupting running tasks
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Optional tracing, set up first so the DB calls are traced too
+	tp, err := tracing.FromEnv(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tp.Shutdown(consts.ShutdownTimeout)
+	dataFolder := config.DataFolder()
+	dbConn, err := db.OpenDB(config.DBPath())
+	if err != nil {
+		log.Fatal(err)
+	}
+	// A single writer, as SQLite serializes writes anyway: concurrent writes wait in the pool instead of
+	// failing as locked. Long reads (summaries, read endpoints, shipping) use a separate read-only pool
+	dbConn.SetMaxOpenConns(consts.DBWriteConns)
+	readConn, err := db.OpenReadDB(config.DBPath())
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Connected to database at %s", config.DBPath()) //#nosec G706 -- the data folder is from controlled config
+
+	var geo *geoip.Resolver
+	if geoDBPath := os.Getenv("GEOIP_DB"); geoDBPath != "" {
+		geo, err = geoip.Open(geoDBPath)
+		if err != nil {
+			log.Fatalf("Error opening GeoIP database: %v", err)
+		}
+		defer func() { _ = geo.Close() }()
+		log.Printf("Using GeoIP database at %s", geoDBPath) //#nosec G706 -- path is from controlled env var
+	}
+
+	// Optional error reporting to Sentry and/or a webhook
+	if reporter, err = errreport.FromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	defer reporter.Flush(consts.ErrorReportTimeout)
+	// Optional notifications of pipeline events to chat webhooks
+	if notifier, err = notify.FromEnv(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Optional chart thresholds tuning
+	if err := charts.ConfigureFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	// Optional outlier caps of the summary statistics
+	if err := summary.ConfigureOutliersFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Request size, timeouts, connection and rate limits, needed by the routes
+	if limits, err = limitsFromEnv(); err != nil {
+		log.Fatal(err)
+	}
+	// Optional cross-origin access to the read endpoints from browsers
+	cors, err := corsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Schedules of the periodic tasks
+	schedules, err := schedulesFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Optional sampling of the stored reports during traffic spikes
+	if ingestSampler, err = samplerFromEnv(dbConn); err != nil {
+		log.Fatal(err)
+	}
+
+	keys, err := newKeyStore()
+	if err != nil {
+		log.Fatalf("Error loading API keys: %v", err)
+	}
+	loadMappings()
+	reloadOnSignal(keys)
+
+	// Optional continuous replication of the reports to S3
+	var rep *replica.Replicator
+	replicaCfg, replicate, err := replica.ConfigFromEnv()
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Printf("Connected to database at %s", filepath.Join(dataFolder, "insights.db")) //#nosec G706 -- dataFolder is from controlled env var
+	if replicate {
+		if rep, err = replica.New(readConn, replicaCfg, dataFolder); err != nil {
+			log.Fatalf("Error configuring replica: %v", err)
+		}
+		go rep.Run(ctx)
+	}
 
-	if err := startTasks(ctx, dbConn); err != nil {
+	// Optional long-term storage of the reports in ClickHouse
+	clickhouseCfg, useClickHouse, err := clickhouse.ConfigFromEnv()
+	if err != nil {
 		log.Fatal(err)
 	}
+	if useClickHouse {
+		if warehouse, err = clickhouse.New(readConn, clickhouseCfg, dataFolder); err != nil {
+			log.Fatalf("Error configuring ClickHouse: %v", err)
+		}
+		go warehouse.Run(ctx)
+	}
+
+	// Optional message queue between the /collect handlers and the database
+	queueCfg, useQueue, err := queue.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if useQueue {
+		if ingestQueue, err = queue.Connect(ctx, queueCfg); err != nil {
+			log.Fatalf("Error configuring queue: %v", err)
+		}
+		defer ingestQueue.Close()
+		if queueCfg.Consume {
+			go func() {
+				if err := ingestQueue.Consume(ctx, storeQueuedReports(dbConn)); !errors.Is(err, context.Canceled) {
+					log.Printf("Error consuming queued reports: %v", err)
+				}
+			}()
+		}
+	}
+
+	c, err := startTasks(ctx, dbConn, readConn, rep, schedules)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Task schedules: %s", schedules)
 
 	go func() {
-		summarize(ctx, dbConn)()
+		summarize(ctx, dbConn, readConn)()
 		generateCharts(ctx)()
 	}()
 
 	r := chi.NewRouter()
 	r.Use(middleware.RealIP)
+	r.Use(traceRequests)
 	r.Use(middleware.Logger)
+	r.Use(recoverer)
+	r.Use(securityHeaders)
+	r.Get("/robots.txt", robotsHandler)
 
+	// Optional public dashboard, embedded in the binary
+	if err := registerDashboardRoutes(r); err != nil {
+		log.Fatal(err)
+	}
 	// Dev-only routes (static files and charts endpoint)
 	registerDevRoutes(r)
 
-	// API endpoint to serve charts.json (protected by API_KEY if set)
-	r.With(apiKeyMiddleware).Get("/api/charts", chartsJSONHandler())
-
-	// Rate-limited collect endpoint
-	limiter := httprate.NewRateLimiter(consts.RateLimitRequests, consts.RateLimitWindow, httprate.WithKeyByIP())
-	r.With(limiter.Handler).Post("/collect", handler(dbConn))
+	// Versioned API (read endpoints are protected by a read key if any are configured, admin ones
+	// always require an admin key), also served at the legacy paths
+	if err := registerAPIRoutes(r, keys, cors, slices.Concat(apiRoutes(dbConn, readConn, geo), adminRoutes(dbConn, readConn))); err != nil {
+		log.Fatalf("Error registering API routes: %v", err)
+	}
+	r.With(apiKeyMiddleware(keys)).Method(http.MethodGet, "/metrics", metricsHandler())
+	registerPprofRoutes(r, keys)
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = consts.DefaultPort
 	}
 
-	log.Print("Starting Insights server on :" + port) //#nosec G706 -- port is from controlled env var or constant
 	server := &http.Server{
-		Addr:              ":" + port,
-		ReadHeaderTimeout: consts.ReadHeaderTimeout,
-		Handler:           r,
+		Addr:    ":" + port,
+		Handler: r,
 	}
-	err = server.ListenAndServe()
+	limits.apply(server)
+	certFile, keyFile, useTLS, err := configureTLS(server, dataFolder)
 	if err != nil {
-		log.Fatal("ListenAndServe: ", err)
+		log.Fatal(err)
+	}
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		log.Print("Shutting down")
+		shutdown(server, c)
+	}()
+
+	ln, err := limits.listen(server.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Starting Insights server on :" + port) //#nosec G706 -- port is from controlled env var or constant
+	log.Printf("HTTP limits: %s", limits)
+	if useTLS {
+		err = server.ServeTLS(ln, certFile, keyFile)
+	} else {
+		err = server.Serve(ln)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal("Serve: ", err)
 	}
+	<-shutdownDone
+	_ = readConn.Close()
+	_ = dbConn.Close()
 }
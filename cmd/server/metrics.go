@@ -0,0 +1,109 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/navidrome/insights/charts"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Gauges of the latest complete daily summary, for alerting rules on adoption metrics
+var (
+	summaryDate = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "insights", Subsystem: "summary", Name: "date_seconds",
+		Help: "Date of the summary reported by the other insights_summary gauges, as a Unix timestamp",
+	})
+	summaryInstances = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "insights", Subsystem: "summary", Name: "instances",
+		Help: "Number of instances reporting",
+	})
+	summaryInstancesByOS = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "insights", Subsystem: "summary", Name: "instances_by_os",
+		Help: "Number of instances by OS",
+	}, []string{"os"})
+	summaryInstancesByVersion = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "insights", Subsystem: "summary", Name: "instances_by_version",
+		Help: "Number of instances of the most reported versions",
+	}, []string{"version"})
+	summaryActiveClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "insights", Subsystem: "summary", Name: "active_clients",
+		Help: "Number of active clients (players) across all instances",
+	})
+	summaryLatestReleaseAdoption = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "insights", Subsystem: "summary", Name: "latest_release_adoption_percent",
+		Help: "Percentage of the instances running the latest stable release",
+	}, []string{"release"})
+)
+
+// metricsRegistry holds the server metrics (Go runtime and process) and the summary gauges
+var metricsRegistry = newMetricsRegistry()
+
+func newMetricsRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		summaryDate, summaryInstances, summaryInstancesByOS, summaryInstancesByVersion, summaryActiveClients,
+		summaryLatestReleaseAdoption,
+	)
+	return reg
+}
+
+// metricsHandler serves all metrics in the Prometheus exposition format
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// updateSummaryMetrics sets the summary gauges from the summary of the last complete day (see
+// charts.LatestSummary). Called after each summarize run
+func updateSummaryMetrics() {
+	latest, err := charts.LatestSummary()
+	if errors.Is(err, charts.ErrNoData) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading summaries for metrics: %v", err)
+		return
+	}
+
+	summaryDate.Set(float64(latest.Time.Unix()))
+	summaryInstances.Set(float64(latest.Data.NumInstances))
+	summaryInstancesByOS.Reset()
+	for os, count := range latest.Data.OS {
+		summaryInstancesByOS.WithLabelValues(os).Set(float64(count))
+	}
+	// Only the top versions, so the number of series doesn't grow with every release
+	summaryInstancesByVersion.Reset()
+	for _, version := range topCounts(latest.Data.Versions, charts.CurrentConfig().TopVersionsCount) {
+		summaryInstancesByVersion.WithLabelValues(version).Set(float64(latest.Data.Versions[version]))
+	}
+	var clients uint64
+	for _, count := range latest.Data.PlayerTypes {
+		clients += count
+	}
+	summaryActiveClients.Set(float64(clients))
+	summaryLatestReleaseAdoption.Reset()
+	if adoption, err := charts.LatestReleaseAdoption(); err != nil {
+		log.Printf("Error computing the latest release adoption for metrics: %v", err)
+	} else if adoption.Release != "" {
+		summaryLatestReleaseAdoption.WithLabelValues(adoption.Release).Set(adoption.Percent)
+	}
+}
+
+// topCounts returns the n keys with the highest counts, highest first
+func topCounts(counts map[string]uint64, n int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+	return keys[:min(n, len(keys))]
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/navidrome/insights/charts"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/notify"
+	"github.com/navidrome/insights/summary"
+)
+
+// notifier posts pipeline events to chat webhooks, when configured (see notify.FromEnv)
+var notifier *notify.Notifier
+
+var (
+	dailyNotificationMu   sync.Mutex
+	lastDailyNotification string // Date of the last daily summary notified, YYYY-MM-DD
+)
+
+// notifyDailySummary notifies the summary of yesterday (the last complete day) once it is finalized,
+// along with its change from the day before, warning when the number of instances dropped more than
+// notifier.DropPct(), or when the day is flagged by charts.DetectAnomalies. Sent at most once per day, by
+// the first summarize run after midnight UTC
+func notifyDailySummary() {
+	if notifier == nil {
+		return
+	}
+	dailyNotificationMu.Lock()
+	defer dailyNotificationMu.Unlock()
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	if lastDailyNotification == yesterday.Format(consts.DateFormat) {
+		return
+	}
+
+	summaries, err := charts.CachedSummaries()
+	if err != nil {
+		log.Printf("Error loading summaries for notifications: %v", err)
+		return
+	}
+	current, previous := findSummary(summaries, yesterday), findSummary(summaries, yesterday.AddDate(0, 0, -1))
+	if current == nil || !current.Finalized {
+		return
+	}
+	lastDailyNotification = yesterday.Format(consts.DateFormat)
+
+	msg := fmt.Sprintf("Daily summary for %s: %d instances", lastDailyNotification, current.NumInstances)
+	if current.Estimated {
+		msg += fmt.Sprintf(" (estimated from a %g%% sample)", current.SamplingRate*100)
+	}
+	// Sampled days have estimated counts (see summary.Summary.Scaled), not compared to detect drops
+	if previous != nil && previous.NumInstances > 0 && current.SamplingRate == 0 && previous.SamplingRate == 0 {
+		change := float64(current.NumInstances-previous.NumInstances) / float64(previous.NumInstances) * 100
+		msg = fmt.Sprintf("%s (%+.1f%% from the day before)", msg, change)
+		if -change > notifier.DropPct() {
+			msg += fmt.Sprintf("\n:warning: Instances dropped more than %g%% (%d -> %d), check for collection problems",
+				notifier.DropPct(), previous.NumInstances, current.NumInstances)
+		}
+	}
+	if a := findAnomaly(charts.DetectAnomalies(summaries), yesterday); a != nil {
+		msg += fmt.Sprintf("\n:warning: Anomalous %s: %d instances, %+.1f%% from the %d-day mean of %.0f",
+			a.Kind(), a.Instances, a.Deviation, consts.AnomalyWindowDays, a.Mean)
+	}
+	notifier.Send(msg)
+}
+
+func findAnomaly(anomalies []charts.Anomaly, date time.Time) *charts.Anomaly {
+	for i := range anomalies {
+		if anomalies[i].Date.Equal(date) {
+			return &anomalies[i]
+		}
+	}
+	return nil
+}
+
+func findSummary(summaries []summary.SummaryRecord, date time.Time) *summary.Summary {
+	for i := len(summaries) - 1; i >= 0; i-- {
+		if summaries[i].Time.Equal(date) {
+			return &summaries[i].Data
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// openAPISchema is a hand-written schema, used for responses that don't map to a Go type
+type openAPISchema map[string]any
+
+var urlParamRegex = regexp.MustCompile(`\{(\w+)\}`)
+
+// openAPIDocument builds the OpenAPI 3 document describing the API routes. Request and response schemas
+// are derived from the Go types the handlers decode and encode, so the document follows the code
+func openAPIDocument(routes []apiRoute) ([]byte, error) {
+	sb := &schemaBuilder{names: map[reflect.Type]string{}, schemas: map[string]any{}}
+	paths := map[string]map[string]any{}
+	for _, route := range routes {
+		path := paths[route.path]
+		if path == nil {
+			path = map[string]any{}
+			paths[route.path] = path
+		}
+		path[strings.ToLower(route.method)] = sb.operation(route)
+	}
+	paths["/openapi.json"] = map[string]any{
+		"get": map[string]any{
+			"tags":    []string{"meta"},
+			"summary": "Get this document",
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "OpenAPI document",
+					"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"type": "object"}}},
+				},
+			},
+		},
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Navidrome Insights API",
+			"version": consts.APIVersion,
+			"description": "Collects anonymous usage reports from Navidrome instances, and serves the aggregated statistics. " +
+				"Read endpoints require a read key only if the server is configured with API keys. " +
+				"Endpoints that existed before versioning are also served at their unversioned paths.",
+		},
+		"servers": []map[string]any{{"url": consts.APIPrefix}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": sb.schemas,
+			"securitySchemes": map[string]any{
+				"bearer":      map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKeyQuery": map[string]any{"type": "apiKey", "in": "query", "name": consts.APIKeyQueryParam},
+			},
+		},
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (sb *schemaBuilder) operation(route apiRoute) map[string]any {
+	op := map[string]any{
+		"tags":        []string{route.tag},
+		"summary":     route.summary,
+		"operationId": operationID(route),
+	}
+
+	var params []map[string]any
+	for _, m := range urlParamRegex.FindAllStringSubmatch(route.path, -1) {
+		params = append(params, map[string]any{
+			"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+		})
+	}
+	for _, p := range route.params {
+		params = append(params, map[string]any{
+			"name": p.name, "in": "query", "description": p.description, "schema": map[string]any{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	switch {
+	case route.request != nil:
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": sb.schema(route.request)}},
+		}
+	case route.requestType != "":
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{route.requestType: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}},
+		}
+	}
+
+	status := route.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case route.response != nil:
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": sb.schema(route.response)}}
+	case route.contentType != "":
+		success["content"] = map[string]any{route.contentType: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}}
+	}
+	responses := map[string]any{strconv.Itoa(status): success}
+	errs := slices.Clone(route.errors)
+	switch route.access {
+	case accessRead:
+		errs = append(errs, http.StatusUnauthorized)
+		// Keys are only required if the server is configured with keys
+		op["security"] = []map[string]any{{"bearer": []string{}}, {"apiKeyQuery": []string{}}, {}}
+	case accessAdmin:
+		// Forbidden if the server is configured without keys
+		errs = append(errs, http.StatusUnauthorized, http.StatusForbidden)
+		op["security"] = []map[string]any{{"bearer": []string{}}, {"apiKeyQuery": []string{}}}
+	}
+	for _, code := range errs {
+		responses[strconv.Itoa(code)] = map[string]any{"description": http.StatusText(code)}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// operationID derives a unique operation ID from the method and path, e.g. getChartsIdPng
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.method)
+	for _, part := range strings.FieldsFunc(route.path, func(r rune) bool { return !isAlnum(r) }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func isAlnum(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// schemaBuilder derives JSON schemas from Go types, following encoding/json rules. Named struct types
+// are added to the document's components and referenced
+type schemaBuilder struct {
+	names   map[reflect.Type]string
+	schemas map[string]any
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+func (sb *schemaBuilder) schema(v any) any {
+	if s, ok := v.(openAPISchema); ok {
+		return s
+	}
+	return sb.typeSchema(reflect.TypeOf(v))
+}
+
+func (sb *schemaBuilder) typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return sb.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + sb.component(t)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": sb.typeSchema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": sb.typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		// Any JSON value
+		return map[string]any{}
+	}
+}
+
+// component adds the schema of a named struct type to the components, returning its name. Types with the
+// same name from different packages are prefixed with their package name
+func (sb *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := sb.names[t]; ok {
+		return name
+	}
+	name := capitalize(t.Name())
+	if _, taken := sb.schemas[name]; taken {
+		name = capitalize(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]) + name
+	}
+	// Registered before building the schema, for recursive types
+	sb.names[t] = name
+	sb.schemas[name] = nil // Reserves the name
+	sb.schemas[name] = sb.structSchema(t)
+	return name
+}
+
+func (sb *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	sb.addFields(t, properties)
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// addFields adds the JSON fields of a struct, including the ones promoted from embedded structs
+func (sb *schemaBuilder) addFields(t reflect.Type, properties map[string]any) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			sb.addFields(f.Type, properties)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		properties[cmp.Or(name, f.Name)] = sb.typeSchema(f.Type)
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
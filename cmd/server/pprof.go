@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof" //#nosec G108 -- only served behind the admin key, see registerPprofRoutes
+
+	"github.com/go-chi/chi/v5"
+	"github.com/navidrome/insights/consts"
+)
+
+// registerPprofRoutes serves the net/http/pprof profiles under /admin/debug/pprof, requiring an admin key,
+// so CPU and heap profiles can be captured when summarization or ingestion misbehave in production, e.g.
+// `go tool pprof -http=: "https://<server>/api/v1/admin/debug/pprof/profile?seconds=30&api_key=<key>"`
+func registerPprofRoutes(r chi.Router, keys *keyStore) {
+	r.With(adminKeyMiddleware(keys)).Route(consts.APIPrefix+"/admin/debug/pprof", func(r chi.Router) {
+		r.Get("/", pprof.Index)
+		r.Get("/cmdline", pprof.Cmdline)
+		r.Get("/profile", pprof.Profile)
+		r.Get("/symbol", pprof.Symbol)
+		r.Post("/symbol", pprof.Symbol)
+		r.Get("/trace", pprof.Trace)
+		// Named profiles: heap, goroutine, allocs, block, mutex, threadcreate
+		r.Get("/{profile}", func(w http.ResponseWriter, r *http.Request) {
+			pprof.Handler(chi.URLParam(r, "profile")).ServeHTTP(w, r)
+		})
+	})
+}
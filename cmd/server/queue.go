@@ -0,0 +1,84 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/queue"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// ingestQueue is the optional message queue the /collect handlers publish the reports to, instead of
+// storing them (see queue.ConfigFromEnv)
+var ingestQueue *queue.Queue
+
+// publishReports queues reports received at the same time, to be stored by storeQueuedReports. A non-empty
+// flag is the reason the reports were flagged before queueing them (see ipFlagReason)
+func publishReports(ctx context.Context, reports []insights.Data, t time.Time, country, flag string) error {
+	msgs := make([]queue.Message, 0, len(reports))
+	for _, data := range reports {
+		j, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		// Stored with the same precision as db.SaveReports
+		msgs = append(msgs, queue.Message{Time: t.UTC().Truncate(time.Second), Country: country, Flag: flag, Data: j})
+	}
+	return ingestQueue.Publish(ctx, msgs...)
+}
+
+// storeQueuedReports stores a batch of queued reports in a single transaction, with the time they were
+// received at. Like in the /collect handlers, the reports of blocked instances or left out of the sample
+// are not stored, and the flagged ones (see flagReason, or flagged before queueing them) are stored in
+// insights_rejected. Times ahead of this server's clock (a front-end with a skewed clock) are clamped to
+// now, so db.ImportReports doesn't reject them
+func storeQueuedReports(dbConn *sql.DB) func(context.Context, []queue.Message) error {
+	return func(ctx context.Context, msgs []queue.Message) error {
+		reports := make([]db.RawReport, 0, len(msgs))
+		now := time.Now().UTC().Truncate(time.Second)
+		for _, m := range msgs {
+			if m.Time.After(now) {
+				m.Time = now
+			}
+			var data insights.Data
+			if err := json.Unmarshal(m.Data, &data); err != nil {
+				return err
+			}
+			blocked, err := db.IsBlocked(dbConn, data.InsightsID)
+			if err != nil {
+				return err
+			}
+			if blocked {
+				continue
+			}
+			if flag := cmp.Or(flagReason(data, "", now), m.Flag); flag != "" {
+				if err := db.FlagReports(ctx, dbConn, []insights.Data{data}, m.Time, m.Country, flag); err != nil {
+					return err
+				}
+				continue
+			}
+			keep, err := ingestSampler.keep(ctx, data.InsightsID)
+			if err != nil {
+				return err
+			}
+			if keep {
+				reports = append(reports, db.RawReport{ID: data.InsightsID, Time: m.Time, Data: string(m.Data), Country: m.Country})
+			}
+		}
+		if len(reports) == 0 {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, consts.SaveReportTimeout)
+		defer cancel()
+		if _, err := db.ImportReports(ctx, dbConn, reports); err != nil {
+			reporter.Error(err, map[string]string{"handler": "queue"})
+			return err
+		}
+		return nil
+	}
+}
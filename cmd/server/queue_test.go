@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/queue"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// storedIn returns where the report of id was stored: "insights", the reason it was rejected, or empty
+func storedIn(dbConn *sql.DB, id string) string {
+	var where string
+	err := dbConn.QueryRow(`SELECT 'insights' FROM insights WHERE id = ?
+UNION ALL SELECT reason FROM insights_rejected WHERE id = ?`, id, id).Scan(&where)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ""
+	}
+	Expect(err).NotTo(HaveOccurred())
+	return where
+}
+
+var _ = Describe("storeQueuedReports", func() {
+	var dbConn *sql.DB
+
+	BeforeEach(func() {
+		var err error
+		dbConn, err = db.OpenDB(filepath.Join(GinkgoT().TempDir(), "insights.db"))
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(dbConn.Close)
+		_, err = db.BlockInstance(dbConn, "blocked", "spam")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	DescribeTable("flags the reports of the instances that are not blocked",
+		func(id string, tracks int64, flag, expected string) {
+			var data insights.Data
+			data.InsightsID = id
+			data.Library.Tracks = tracks
+			j, err := json.Marshal(data)
+			Expect(err).NotTo(HaveOccurred())
+			msg := queue.Message{Time: time.Now().UTC().Truncate(time.Second), Flag: flag, Data: j}
+
+			Expect(storeQueuedReports(dbConn)(context.Background(), []queue.Message{msg})).To(Succeed())
+			Expect(storedIn(dbConn, id)).To(Equal(expected))
+		},
+		Entry("plausible report", "a", int64(100), "", "insights"),
+		Entry("implausible library", "a", int64(-1), "", db.FlaggedImplausibleLibrary),
+		Entry("flagged before queueing", "a", int64(100), db.FlaggedIPFlood, db.FlaggedIPFlood),
+		Entry("implausible report of a blocked instance", "blocked", int64(-1), "", ""),
+		Entry("flagged report of a blocked instance", "blocked", int64(100), db.FlaggedIPFlood, ""),
+	)
+})
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/errreport"
+)
+
+// reporter forwards handler errors, task failures and panics, when configured (see errreport.FromEnv)
+var reporter *errreport.Reporter
+
+// recoverer recovers from panics in handlers, logging and reporting them, and responds with a 500
+func recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			// Used by net/http to abort a response, not an actual error
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
+			stack := debug.Stack()
+			log.Printf("Panic handling %s %s: %v\n%s", r.Method, r.URL.Path, rvr, stack) //#nosec G706 -- request path is only logged
+			reporter.Panic(rvr, stack, map[string]string{"method": r.Method, "path": r.URL.Path})
+			w.WriteHeader(http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// spikeCounter counts events in fixed windows of consts.MalformedSpikeWindow, and reports when their
+// number reaches consts.MalformedSpikeThreshold in a window. Used to detect floods of malformed payloads,
+// usually caused by a broken client release, without reporting every single one
+type spikeCounter struct {
+	name        string
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+var malformedPayloads = &spikeCounter{name: "malformed payloads"}
+
+func (c *spikeCounter) add() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.windowStart) >= consts.MalformedSpikeWindow {
+		c.windowStart = now
+		c.count = 0
+	}
+	c.count++
+	if c.count == consts.MalformedSpikeThreshold {
+		err := fmt.Errorf("%d %s received since %s", c.count, c.name, c.windowStart.UTC().Format(time.RFC3339))
+		log.Print(err)
+		reporter.Error(err, map[string]string{"spike": c.name})
+	}
+}
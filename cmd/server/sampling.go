@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/summary"
+)
+
+// sampler limits the reports stored during traffic spikes: once more than dailyCap reports were received
+// in the UTC day, only the reports of a deterministic sample of the instances (see summary.InSample) are
+// stored for the rest of the day. The day is marked as sampled in the database, so its summary only counts
+// the sample and records the rate. A nil sampler keeps all reports
+type sampler struct {
+	dbConn   *sql.DB
+	dailyCap int64
+	rate     float64
+
+	mu      sync.Mutex
+	day     string
+	count   int64
+	dayRate float64 // Sample rate of the day, 0 until sampled
+}
+
+// ingestSampler is the optional sampling of the stored reports (see samplerFromEnv)
+var ingestSampler *sampler
+
+// samplerFromEnv configures the sampling from SAMPLING_DAILY_CAP (reports per day, disabled if unset) and
+// SAMPLING_RATE (default consts.DefaultSamplingRate)
+func samplerFromEnv(dbConn *sql.DB) (*sampler, error) {
+	v := os.Getenv("SAMPLING_DAILY_CAP")
+	if v == "" {
+		return nil, nil
+	}
+	dailyCap, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || dailyCap < 1 {
+		return nil, fmt.Errorf("invalid SAMPLING_DAILY_CAP %q", v)
+	}
+	rate := consts.DefaultSamplingRate
+	if v := os.Getenv("SAMPLING_RATE"); v != "" {
+		rate, err = strconv.ParseFloat(v, 64)
+		if err != nil || rate <= 0 || rate >= 1 {
+			return nil, fmt.Errorf("invalid SAMPLING_RATE %q, expected a number between 0 and 1", v)
+		}
+	}
+	return &sampler{dbConn: dbConn, dailyCap: dailyCap, rate: rate}, nil
+}
+
+// keep counts a report received now, returning whether it must be stored
+func (s *sampler) keep(ctx context.Context, id string) (bool, error) {
+	if s == nil {
+		return true, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	if day := now.Format(consts.DateFormat); day != s.day {
+		if err := s.startDay(ctx, now); err != nil {
+			return false, err
+		}
+		s.day = day
+	}
+	s.count++
+	if s.dayRate == 0 && s.count > s.dailyCap {
+		if err := db.MarkSampled(ctx, s.dbConn, now, s.rate); err != nil {
+			s.count--
+			return false, err
+		}
+		s.dayRate = s.rate
+		msg := fmt.Sprintf("More than %d reports received today, sampling %g%% of the instances until the end of the day", s.dailyCap, s.rate*100)
+		log.Print(msg)
+		notifier.Send(":warning: " + msg)
+	}
+	return s.dayRate == 0 || summary.InSample(id, s.dayRate), nil
+}
+
+// startDay resets the count and rate to the reports already stored for the day and its sample rate, so a
+// restart doesn't reset them
+func (s *sampler) startDay(ctx context.Context, now time.Time) error {
+	date := now.Truncate(24 * time.Hour)
+	count, err := db.CountReports(ctx, s.dbConn, date)
+	if err != nil {
+		return fmt.Errorf("counting reports: %w", err)
+	}
+	rate, err := db.SampleRate(ctx, s.dbConn, date)
+	if err != nil {
+		return fmt.Errorf("reading sample rate: %w", err)
+	}
+	s.count, s.dayRate = count, rate
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/robfig/cron/v3"
+)
+
+// taskSchedules are the cron schedules (in UTC) of the periodic tasks, each configured by an env var (see
+// schedulesFromEnv), defaulting to its consts value
+type taskSchedules struct {
+	summarize string // CRON_SUMMARIZE
+	charts    string // CRON_CHARTS
+	cleanup   string // CRON_CLEANUP
+	backup    string // CRON_BACKUP
+}
+
+// schedulesFromEnv returns the default schedules, overridden by the CRON_* env vars: standard 5-field
+// cron expressions (e.g. "0 */2 * * *") or descriptors like @daily and @every 6h
+func schedulesFromEnv() (taskSchedules, error) {
+	s := taskSchedules{
+		summarize: consts.DefaultCronSummarize,
+		charts:    consts.DefaultCronGenerateChart,
+		cleanup:   consts.DefaultCronCleanup,
+		backup:    consts.DefaultCronBackup,
+	}
+	schedules := []struct {
+		env   string
+		value *string
+	}{
+		{"CRON_SUMMARIZE", &s.summarize},
+		{"CRON_CHARTS", &s.charts},
+		{"CRON_CLEANUP", &s.cleanup},
+		{"CRON_BACKUP", &s.backup},
+	}
+	for _, c := range schedules {
+		v := os.Getenv(c.env)
+		if v == "" {
+			continue
+		}
+		if _, err := cron.ParseStandard(v); err != nil {
+			return s, fmt.Errorf("invalid %s %q: %w", c.env, v, err)
+		}
+		*c.value = v
+	}
+	return s, nil
+}
+
+func (s taskSchedules) String() string {
+	return fmt.Sprintf("summarize %q, charts %q, cleanup %q, backup %q", s.summarize, s.charts, s.cleanup, s.backup)
+}
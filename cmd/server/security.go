@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// Content security policies. Responses default to defaultCSP, which loads nothing, so e.g. a script
+// injected into an SVG chart can't run. The HTML chart pages (dashboard and dev pages) get pageCSP:
+// echarts from its CDNs, the pages' own inline scripts and styles (the go-echarts pages are generated),
+// and fetches to the server and to the CDN of the dashboard's world map only
+const (
+	defaultCSP = "default-src 'none'; frame-ancestors 'none'"
+	pageCSP    = "default-src 'none'; " +
+		"script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://go-echarts.github.io; " +
+		"style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self' https://cdn.jsdelivr.net; " +
+		"base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+)
+
+// securityHeaders sets the security headers of all responses: no content type sniffing, defaultCSP
+// (see htmlPage for the pages), and HSTS on HTTPS requests, either served with TLS or forwarded by a
+// TLS terminating proxy
+func securityHeaders(next http.Handler) http.Handler {
+	hsts := "max-age=" + strconv.Itoa(int(consts.HSTSMaxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Content-Security-Policy", defaultCSP)
+		if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// htmlPage replaces defaultCSP with pageCSP, for the routes serving the HTML chart pages
+func htmlPage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", pageCSP)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// robotsTxt keeps crawlers away from the collect and admin endpoints, in all their versioned and legacy
+// paths, and from the metrics
+var robotsTxt = strings.Join([]string{
+	"User-agent: *",
+	"Disallow: /collect",
+	"Disallow: " + consts.APIPrefix + "/collect",
+	"Disallow: " + consts.APIPrefix + "/admin/",
+	"Disallow: /api/admin/",
+	"Disallow: /metrics",
+	"",
+}, "\n")
+
+func robotsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(robotsTxt))
+}
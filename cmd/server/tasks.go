@@ -3,41 +3,205 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/navidrome/insights/archive"
+	"github.com/navidrome/insights/backup"
 	"github.com/navidrome/insights/charts"
+	"github.com/navidrome/insights/clickhouse"
+	"github.com/navidrome/insights/config"
 	"github.com/navidrome/insights/consts"
 	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/replica"
 	"github.com/navidrome/insights/summary"
 )
 
-func cleanup(_ context.Context, dbConn *sql.DB) func() {
+// tasksMu serializes runs of different tasks touching the summaries, so on-demand runs (admin API) don't
+// overlap with cron runs. Held by jobRunner.run, a task that is already running or waiting for it is
+// skipped instead
+var tasksMu sync.Mutex
+
+// warehouse is the optional ClickHouse store, keeping the reports purged from the database
+var warehouse *clickhouse.Store
+
+func cleanup(ctx context.Context, dbConn, readConn *sql.DB) func() {
 	return func() {
 		log.Print("Cleaning old data")
-		if err := db.PurgeOldEntries(dbConn); err != nil {
+		if _, err := runCleanup(ctx, dbConn, readConn); err != nil && !errors.Is(err, errJobRunning) {
 			log.Printf("Error cleaning old data: %v", err)
 		}
 	}
 }
 
-func summarize(_ context.Context, dbConn *sql.DB) func() {
+func runCleanup(ctx context.Context, dbConn, readConn *sql.DB) (int64, error) {
+	var deleted int64
+	err := jobs.run(ctx, jobCleanup, &tasksMu, func(ctx context.Context) (int64, error) {
+		ctx, cancel := context.WithTimeout(ctx, consts.CleanupTimeout)
+		defer cancel()
+		var err error
+		deleted, err = purgeOldEntries(ctx, dbConn, readConn)
+		return deleted, err
+	})
+	return deleted, err
+}
+
+// purgeOldEntries archives the reports older than the retention period to $DATA_FOLDER/archive, then
+// deletes them from the database. Nothing is deleted if archiving fails. The entries are archived from
+// readConn, so the writer connection isn't held while they are read
+func purgeOldEntries(ctx context.Context, dbConn, readConn *sql.DB) (int64, error) {
+	cutoff := db.PurgeCutoff()
+	maxRowID, err := db.MaxRowID(dbConn)
+	if err != nil {
+		return 0, err
+	}
+	archived, err := archive.Write(ctx, readConn, filepath.Join(config.DataFolder(), consts.ArchiveDir), cutoff, maxRowID)
+	if err != nil {
+		return 0, fmt.Errorf("archiving old entries: %w", err)
+	}
+	log.Printf("Archived %d old entries", archived)
+	return db.PurgeOldEntries(ctx, dbConn, cutoff, maxRowID)
+}
+
+func summarize(ctx context.Context, dbConn, readConn *sql.DB) func() {
 	return func() {
 		log.Print("Summarizing data")
-		now := time.Now().Truncate(24 * time.Hour).UTC()
-		for d := 0; d < consts.SummarizeLookbackDays; d++ {
-			date := now.AddDate(0, 0, -d)
-			log.Print("Summarizing data for ", date.Format(consts.DateFormat))
-			_ = summary.SummarizeData(dbConn, date)
+		if err := runSummarize(ctx, dbConn, readConn, staleDates(ctx, dbConn)); err == nil {
+			notifyDailySummary()
+		}
+	}
+}
+
+// staleDates returns the dates summarized by the cron task: the ones with new or late reports since they
+// were last summarized, or not finalized yet (see db.SelectStaleDates). Falls back to lookbackDates if
+// they can't be read
+func staleDates(ctx context.Context, dbConn *sql.DB) []time.Time {
+	dates, err := db.SelectStaleDates(ctx, dbConn)
+	if err != nil {
+		log.Printf("Error selecting dates to summarize, using the last %d days: %v", consts.SummarizeLookbackDays, err)
+		return lookbackDates()
+	}
+	return dates
+}
+
+// lookbackDates returns the last consts.SummarizeLookbackDays dates, from today backwards
+func lookbackDates() []time.Time {
+	now := time.Now().Truncate(24 * time.Hour).UTC()
+	dates := make([]time.Time, 0, consts.SummarizeLookbackDays)
+	for d := 0; d < consts.SummarizeLookbackDays; d++ {
+		dates = append(dates, now.AddDate(0, 0, -d))
+	}
+	return dates
+}
+
+// runSummarize summarizes all given dates, up to consts.SummarizeWorkers at a time, returning the combined
+// errors. Dates not started before the context is done or consts.SummarizeTimeout expires are skipped.
+// The summary metrics are refreshed after each run, even if some dates failed
+func runSummarize(ctx context.Context, dbConn, readConn *sql.DB, dates []time.Time) error {
+	err := jobs.run(ctx, jobSummarize, &tasksMu, func(ctx context.Context) (int64, error) {
+		ctx, cancel := context.WithTimeout(ctx, consts.SummarizeTimeout)
+		defer cancel()
+		var mu sync.Mutex
+		var summarized int64
+		var errs []error
+		queue := make(chan time.Time)
+		var wg sync.WaitGroup
+		for range min(consts.SummarizeWorkers, len(dates)) {
+			wg.Go(func() {
+				for date := range queue {
+					start := time.Now()
+					n, err := summarizeDate(ctx, dbConn, readConn, date)
+					log.Printf("Summarized %s: %d instances in %s", date.Format(consts.DateFormat), n, time.Since(start).Round(time.Millisecond))
+					mu.Lock()
+					if err != nil {
+						errs = append(errs, err)
+					}
+					summarized += n
+					mu.Unlock()
+				}
+			})
+		}
+	feed:
+		for _, date := range dates {
+			select {
+			case queue <- date:
+			case <-ctx.Done():
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("summarize stopped before %s: %w", date.Format(consts.DateFormat), ctx.Err()))
+				mu.Unlock()
+				break feed
+			}
 		}
+		close(queue)
+		wg.Wait()
+		return summarized, errors.Join(errs...)
+	})
+	if !errors.Is(err, errJobRunning) {
+		updateSummaryMetrics()
+	}
+	return err
+}
+
+// summarizeDate summarizes a date from the database, or from ClickHouse if configured and the date's
+// reports were (even partially) purged from the database
+func summarizeDate(ctx context.Context, dbConn, readConn *sql.DB, date time.Time) (int64, error) {
+	if warehouse != nil && date.Before(time.Now().UTC().AddDate(0, 0, -consts.PurgeRetentionDays)) {
+		return warehouse.SummarizeData(ctx, date)
 	}
+	return summary.SummarizeDataFrom(ctx, readConn, dbConn, date)
 }
 
-func generateCharts(_ context.Context) func() {
+func generateCharts(ctx context.Context) func() {
 	return func() {
 		log.Print("Exporting charts JSON")
-		if err := charts.ExportChartsJSON(consts.ChartDataDir); err != nil {
+		if err := runGenerateCharts(ctx); err != nil && !errors.Is(err, errJobRunning) {
 			log.Printf("Error exporting charts JSON: %v", err)
 		}
 	}
 }
+
+func runGenerateCharts(ctx context.Context) error {
+	return jobs.run(ctx, jobCharts, &tasksMu, func(context.Context) (int64, error) {
+		err := charts.ExportChartsJSON(consts.ChartDataDir)
+		if err != nil {
+			notifier.Send(fmt.Sprintf(":x: Chart export failed: %v", err))
+		}
+		return 0, err
+	})
+}
+
+func backupDB(ctx context.Context, dbConn *sql.DB, dir string, keep int, rep *replica.Replicator) func() {
+	return func() {
+		log.Print("Backing up database")
+		_ = jobs.run(ctx, jobBackup, nil, func(ctx context.Context) (int64, error) {
+			return 0, runBackup(ctx, dbConn, dir, keep, rep)
+		})
+	}
+}
+
+// runBackup creates a backup, uploads it to the replica and prunes old backups. Upload and prune
+// errors are returned after completing the other steps
+func runBackup(ctx context.Context, dbConn *sql.DB, dir string, keep int, rep *replica.Replicator) error {
+	ctx, cancel := context.WithTimeout(ctx, consts.BackupTimeout)
+	defer cancel()
+	path, err := backup.Create(ctx, dbConn, dir, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error backing up database: %v", err)
+		return err
+	}
+	log.Printf("Database backed up to %s", path)
+	var errs []error
+	if err := rep.UploadBackup(ctx, path); err != nil {
+		log.Printf("Error uploading backup to the replica: %v", err)
+		errs = append(errs, err)
+	}
+	if err := backup.Prune(dir, keep); err != nil {
+		log.Printf("Error pruning old backups: %v", err)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
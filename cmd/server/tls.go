@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/navidrome/insights/consts"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// configureTLS enables HTTPS on the server when configured through env vars. Either TLS_CERT and
+// TLS_KEY point to a certificate/key pair, or TLS_DOMAINS lists the domains (comma separated) to
+// request certificates for from Let's Encrypt, cached in $DATA_FOLDER/autocert. Returns the cert and
+// key files to pass to ListenAndServeTLS (empty for autocert), and whether TLS is enabled
+func configureTLS(server *http.Server, dataFolder string) (certFile, keyFile string, enabled bool, err error) {
+	certFile, keyFile = os.Getenv("TLS_CERT"), os.Getenv("TLS_KEY")
+	domains := parseDomains(os.Getenv("TLS_DOMAINS"))
+
+	if (certFile == "") != (keyFile == "") {
+		return "", "", false, fmt.Errorf("TLS_CERT and TLS_KEY must be set together")
+	}
+	if certFile != "" && len(domains) > 0 {
+		return "", "", false, fmt.Errorf("TLS_CERT/TLS_KEY and TLS_DOMAINS are mutually exclusive")
+	}
+
+	switch {
+	case certFile != "":
+		log.Printf("TLS enabled with certificate %s", certFile) //#nosec G706 -- path is from controlled env var
+		return certFile, keyFile, true, nil
+	case len(domains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(filepath.Join(dataFolder, consts.AutocertDir)),
+			Email:      os.Getenv("TLS_EMAIL"),
+		}
+		server.TLSConfig = m.TLSConfig()
+		log.Printf("TLS enabled with automatic certificates for %s", strings.Join(domains, ", ")) //#nosec G706 -- domains are from controlled env var
+		return "", "", true, nil
+	}
+	return "", "", false, nil
+}
+
+func parseDomains(s string) []string {
+	var domains []string
+	for d := range strings.SplitSeq(s, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
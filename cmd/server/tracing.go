@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceRequests creates a span for each request, named after its chi route pattern (e.g. "GET /api/charts/{id}")
+// once routed, so requests to the same endpoint are grouped regardless of their parameters
+func traceRequests(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || rctx.RoutePattern() == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + rctx.RoutePattern())
+		span.SetAttributes(attribute.String("http.route", rctx.RoutePattern()))
+	}), "http.request")
+}
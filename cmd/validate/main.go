@@ -0,0 +1,112 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/insights/summary"
+)
+
+// ignoredFields change after the date is summarized, so they always drift: an instance is churned on a
+// date if its last report is consts.ChurnDays before it, which stops being true when it reports again
+var ignoredFields = map[string]bool{"churnedInstances": true}
+
+// validate recomputes the summary of a date from the reports in the database and compares it field by
+// field with the stored summary file, listing the drift caused by code or mapping changes, or by partial
+// summarize runs. Exits with status 1 if the summaries differ. The reports of the date must not have been
+// purged yet (consts.PurgeRetentionDays). Use cmd/backfill to regenerate the summaries.
+func main() {
+	dbPath := flag.String("db", "", "Path to insights.db (default: $DATA_FOLDER/insights.db or ./insights.db)")
+	date := flag.String("date", "", "Date to validate (YYYY-MM-DD, default: yesterday)")
+	configFile := config.Flag()
+	flag.Parse()
+	if err := config.Load(*configFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	dbFile := cmp.Or(*dbPath, config.DBPath())
+	d := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	if *date != "" {
+		var err error
+		if d, err = time.Parse(consts.DateFormat, *date); err != nil {
+			log.Fatalf("Error: invalid -date %q, expected YYYY-MM-DD", *date)
+		}
+	}
+
+	drift, err := run(context.Background(), dbFile, d)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if drift {
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dbPath string, date time.Time) (bool, error) {
+	dateStr := date.Format(consts.DateFormat)
+	stored, err := summary.LoadSummary(date)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("no stored summary for %s", dateStr)
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading stored summary: %w", err)
+	}
+
+	dbConn, err := db.OpenReadOnly(dbPath)
+	if err != nil {
+		return false, fmt.Errorf("opening database %s: %w", dbPath, err)
+	}
+	defer func() { _ = dbConn.Close() }()
+	// Same mappings as the server
+	if _, err := summary.LoadPlayerTypes(); err != nil {
+		return false, fmt.Errorf("loading player types: %w", err)
+	}
+	if _, err := summary.LoadFSTypes(); err != nil {
+		return false, fmt.Errorf("loading filesystem types: %w", err)
+	}
+	if err := summary.ConfigureOutliersFromEnv(); err != nil {
+		return false, err
+	}
+	if _, err := summary.LoadBins(); err != nil {
+		return false, fmt.Errorf("loading bins: %w", err)
+	}
+	computed, err := summary.ComputeSummary(ctx, dbConn, date)
+	if err != nil {
+		return false, fmt.Errorf("summarizing %s: %w", dateStr, err)
+	}
+	if computed.NumInstances == 0 {
+		return false, fmt.Errorf("no reports for %s in the database (purged after %d days?)", dateStr, consts.PurgeRetentionDays)
+	}
+
+	var drift int
+	for _, d := range summary.Diff(stored, computed) {
+		if ignoredFields[d.Field] {
+			continue
+		}
+		drift++
+		fmt.Printf("%s: stored %s, computed %s\n", d.Field, format(d.A), format(d.B))
+	}
+	if drift > 0 {
+		log.Printf("Summary of %s drifted: %d fields differ", dateStr, drift)
+		return true, nil
+	}
+	log.Printf("Summary of %s matches the %d reports in the database", dateStr, computed.NumInstances)
+	return false, nil
+}
+
+func format(v any) string {
+	if v == nil {
+		return "(missing)"
+	}
+	return fmt.Sprintf("%+v", v)
+}
@@ -0,0 +1,150 @@
+// Package config loads the configuration shared by the server and the tools. Settings are env vars (DATA_FOLDER,
+// CRON_SUMMARIZE, STATS_OUTLIER_CAPS...), read where they are used; they can also be set in a YAML config file
+// (-config flag or $INSIGHTS_CONFIG), so the server and the tools run against the same data folder with the
+// same summary and chart settings. Precedence: a tool's own flags (e.g. -db), then the env vars, then the file
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/insights/consts"
+	"go.yaml.in/yaml/v3"
+)
+
+// FileEnv is the env var with the path of the config file, when not passed with -config
+const FileEnv = "INSIGHTS_CONFIG"
+
+// settings are the env vars that can be set in the config file. Add new settings here, so they aren't
+// rejected as typos. The standard OTEL_* variables are also accepted
+var settings = []string{
+	"DATA_FOLDER", "PORT", "API_KEY", "API_KEYS", "API_KEYS_FILE", "INSIGHTS_API_KEY", "GEOIP_DB",
+	"BACKUP_FOLDER", "BACKUP_COUNT",
+	"QUEUE_NATS_URL", "QUEUE_STREAM", "QUEUE_SUBJECT", "QUEUE_CONSUMER", "QUEUE_CONSUME",
+	"SAMPLING_DAILY_CAP", "SAMPLING_RATE",
+	"TLS_CERT", "TLS_KEY", "TLS_DOMAINS", "TLS_EMAIL",
+	"REPLICA_S3_BUCKET", "REPLICA_S3_ENDPOINT", "REPLICA_S3_REGION", "REPLICA_S3_PREFIX",
+	"REPLICA_S3_ACCESS_KEY", "REPLICA_S3_SECRET_KEY", "REPLICA_S3_INSECURE", "REPLICA_INTERVAL",
+	"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY",
+	"CLICKHOUSE_URL", "CLICKHOUSE_TABLE", "CLICKHOUSE_INTERVAL",
+	"SENTRY_DSN", "SENTRY_ENVIRONMENT", "ERROR_WEBHOOK_URL", "NOTIFY_WEBHOOK_URLS", "NOTIFY_DROP_PCT",
+	"SERVE_DASHBOARD", "CORS_ALLOWED_ORIGINS",
+	"CHARTS_INCOMPLETE_THRESHOLD", "CHARTS_TOP_VERSIONS", "CHARTS_VERSION_SELECTION_DAYS",
+	"CHARTS_PLAYER_GROUP_THRESHOLD", "CHARTS_GROUP_PATCHES", "STATS_OUTLIER_CAPS",
+	"HTTP_MAX_BODY_SIZE", "HTTP_MAX_COLLECT_BODY_SIZE", "HTTP_MAX_BATCH_BODY_SIZE", "HTTP_MAX_RESTORE_SIZE",
+	"HTTP_READ_HEADER_TIMEOUT", "HTTP_READ_TIMEOUT", "HTTP_WRITE_TIMEOUT", "HTTP_IDLE_TIMEOUT", "HTTP_MAX_CONNS",
+	"RATE_LIMIT_REQUESTS", "RATE_LIMIT_WINDOW",
+	"CRON_SUMMARIZE", "CRON_CHARTS", "CRON_CLEANUP", "CRON_BACKUP",
+}
+
+// Flag registers the -config flag, defaulting to $INSIGHTS_CONFIG. Pass its value to Load after parsing
+// the flags
+func Flag() *string {
+	return flag.String("config", os.Getenv(FileEnv), "YAML config file, with env var names as keys (default: $"+FileEnv+")")
+}
+
+// Load reads the config file, if any, and sets its settings in the environment, unless the env var is
+// already set. The file is a YAML mapping of env var names to scalars (e.g. RATE_LIMIT_WINDOW: 30m), or to
+// lists for the comma-separated settings (e.g. NOTIFY_WEBHOOK_URLS). Unknown names are rejected
+func Load(file string) error {
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	values, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		if os.Getenv(name) != "" {
+			continue
+		}
+		if err := os.Setenv(name, values[name]); err != nil {
+			return fmt.Errorf("setting %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// parse decodes the settings of a config file, converting their values to env var strings
+func parse(data []byte) (map[string]string, error) {
+	var doc map[string]any
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	values := make(map[string]string, len(doc))
+	for name, v := range doc {
+		if !slices.Contains(settings, name) && !strings.HasPrefix(name, "OTEL_") {
+			return nil, fmt.Errorf("unknown setting %q", name)
+		}
+		switch v := v.(type) {
+		case nil:
+			continue
+		case map[string]any:
+			return nil, fmt.Errorf("%s must be a value or a list", name)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				if _, ok := item.(map[string]any); ok {
+					return nil, fmt.Errorf("%s must be a list of values", name)
+				}
+				items[i] = scalar(item)
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			values[name] = scalar(v)
+		}
+	}
+	return values, nil
+}
+
+// scalar converts a YAML scalar to its env var string. Floats are written without an exponent, so a size
+// like 1e6 is read as 1000000 by the settings parsing integers
+func scalar(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// DataFolder returns the folder of the database and summaries (DATA_FOLDER), the current directory by
+// default
+func DataFolder() string {
+	dir, _ := LookupDataFolder()
+	return cmp.Or(dir, ".")
+}
+
+// LookupDataFolder returns DATA_FOLDER and whether it is set, for tools requiring an explicit folder
+// instead of the current directory
+func LookupDataFolder() (string, bool) {
+	dir := os.Getenv("DATA_FOLDER")
+	return dir, dir != ""
+}
+
+// SetDataFolder changes the data folder, for tools writing to another folder than DATA_FOLDER (e.g.
+// consolidate -dest)
+func SetDataFolder(dir string) error {
+	if err := os.Setenv("DATA_FOLDER", dir); err != nil {
+		return fmt.Errorf("setting DATA_FOLDER: %w", err)
+	}
+	return nil
+}
+
+// DBPath returns the path of the database in the data folder
+func DBPath() string {
+	return filepath.Join(DataFolder(), consts.DBFile)
+}
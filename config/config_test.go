@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestConfig(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Config Suite")
+}
+
+var _ = Describe("Config", func() {
+	Describe("parse", func() {
+		It("converts the scalars to env var strings", func() {
+			values, err := parse([]byte(`
+PORT: 8080
+RATE_LIMIT_WINDOW: 30m
+CHARTS_INCOMPLETE_THRESHOLD: 0.8
+CHARTS_GROUP_PATCHES: true
+HTTP_MAX_BODY_SIZE: 1e6
+HTTP_MAX_RESTORE_SIZE: 4.0e+9
+GEOIP_DB:
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values).To(Equal(map[string]string{
+				"PORT":                        "8080",
+				"RATE_LIMIT_WINDOW":           "30m",
+				"CHARTS_INCOMPLETE_THRESHOLD": "0.8",
+				"CHARTS_GROUP_PATCHES":        "true",
+				"HTTP_MAX_BODY_SIZE":          "1000000",
+				"HTTP_MAX_RESTORE_SIZE":       "4000000000",
+			}))
+		})
+
+		It("joins the lists with commas", func() {
+			values, err := parse([]byte(`
+NOTIFY_WEBHOOK_URLS:
+  - https://discord.example/a
+  - https://slack.example/b
+TLS_DOMAINS: [insights.example, 1e3]
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values).To(HaveKeyWithValue("NOTIFY_WEBHOOK_URLS", "https://discord.example/a,https://slack.example/b"))
+			Expect(values).To(HaveKeyWithValue("TLS_DOMAINS", "insights.example,1000"))
+		})
+
+		It("accepts the OTEL_ settings", func() {
+			values, err := parse([]byte("OTEL_TRACES_SAMPLER: always_on\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values).To(HaveKeyWithValue("OTEL_TRACES_SAMPLER", "always_on"))
+		})
+
+		It("accepts an empty file", func() {
+			Expect(parse(nil)).To(BeEmpty())
+		})
+
+		It("rejects unknown settings", func() {
+			_, err := parse([]byte("DATA_FOLDR: /data\n"))
+			Expect(err).To(MatchError(`unknown setting "DATA_FOLDR"`))
+		})
+
+		It("rejects mappings", func() {
+			_, err := parse([]byte("STATS_OUTLIER_CAPS:\n  track: 5000\n"))
+			Expect(err).To(MatchError("STATS_OUTLIER_CAPS must be a value or a list"))
+			_, err = parse([]byte("NOTIFY_WEBHOOK_URLS:\n  - url: https://discord.example/a\n"))
+			Expect(err).To(MatchError("NOTIFY_WEBHOOK_URLS must be a list of values"))
+		})
+	})
+
+	Describe("Load", func() {
+		var file string
+
+		BeforeEach(func() {
+			file = filepath.Join(GinkgoT().TempDir(), "insights.yaml")
+			Expect(os.WriteFile(file, []byte("PORT: 9090\nBACKUP_COUNT: 3\nSENTRY_ENVIRONMENT: staging\n"), 0600)).To(Succeed())
+			// Cleaned up after each test, including the settings set by Load
+			GinkgoT().Setenv("PORT", "8081")
+			GinkgoT().Setenv("BACKUP_COUNT", "")
+			GinkgoT().Setenv("SENTRY_ENVIRONMENT", "")
+		})
+
+		It("sets the settings of the file, without overriding the env vars", func() {
+			Expect(Load(file)).To(Succeed())
+			Expect(os.Getenv("PORT")).To(Equal("8081"))
+			Expect(os.Getenv("BACKUP_COUNT")).To(Equal("3"))
+			Expect(os.Getenv("SENTRY_ENVIRONMENT")).To(Equal("staging"))
+		})
+
+		It("does nothing without a file", func() {
+			Expect(Load("")).To(Succeed())
+			Expect(os.Getenv("BACKUP_COUNT")).To(BeEmpty())
+		})
+
+		It("fails on a missing or invalid file", func() {
+			Expect(Load(filepath.Join(GinkgoT().TempDir(), "missing.yaml"))).To(MatchError(ContainSubstring("reading config file")))
+			Expect(os.WriteFile(file, []byte("NOPE: 1\nBACKUP_COUNT: 3\n"), 0600)).To(Succeed())
+			Expect(Load(file)).To(MatchError(ContainSubstring("invalid config file")))
+			Expect(os.Getenv("BACKUP_COUNT")).To(BeEmpty())
+		})
+	})
+
+	Describe("DataFolder", func() {
+		It("defaults to the current directory, unset for LookupDataFolder", func() {
+			GinkgoT().Setenv("DATA_FOLDER", "")
+			Expect(DataFolder()).To(Equal("."))
+			_, ok := LookupDataFolder()
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns DATA_FOLDER when set", func() {
+			GinkgoT().Setenv("DATA_FOLDER", "/data")
+			Expect(DataFolder()).To(Equal("/data"))
+			Expect(DBPath()).To(Equal(filepath.Join("/data", "insights.db")))
+			dir, ok := LookupDataFolder()
+			Expect(ok).To(BeTrue())
+			Expect(dir).To(Equal("/data"))
+		})
+	})
+})
@@ -2,33 +2,137 @@ package consts
 
 import "time"
 
-// Server configuration
+// Server configuration. The HTTP and rate limits are the defaults of the HTTP_* and RATE_LIMIT_* env vars (see
+// limitsFromEnv in cmd/server)
 const (
-	DefaultPort       = "8080"
-	ReadHeaderTimeout = 3 * time.Second
-	RateLimitRequests = 1
-	RateLimitWindow   = 30 * time.Minute
+	DefaultPort              = "8080"
+	ReadHeaderTimeout        = 3 * time.Second
+	ReadTimeout              = 30 * time.Second // Reading a whole request, body included
+	WriteTimeout             = time.Minute      // From the end of the request headers to the end of the response
+	IdleTimeout              = 2 * time.Minute  // Keep-alive connections waiting for their next request
+	DefaultMaxConns          = 1000             // Concurrent connections, the next ones wait to be accepted
+	MaxBodySize              = 64 * 1024        // Max body size of the API requests without a limit of their own
+	DefaultRateLimitRequests = 1                // Reports per IP per window, on /collect and /collect/batch (each its own quota)
+	DefaultRateLimitWindow   = 30 * time.Minute
+	MaxCollectBodySize       = 100 * 1024      // Max /collect body size, enforced before and after decompression
+	MaxBatchBodySize         = 1024 * 1024     // Max /collect/batch body size
+	MaxBatchReports          = 100             // Max reports per /collect/batch request
+	MaxRestoreSize           = 4 << 30         // Max backup zip size uploaded to /admin/restore
+	MaxBackupExtractSize     = 64 << 30        // Max size of each file extracted from a backup zip
+	MaxClockSkew             = 5 * time.Minute // Imported reports with a later time than now plus this are rejected
+	CompressionLevel         = 5               // gzip level for compressed responses
 )
 
-// Cron schedules
+// Ingestion sampling (SAMPLING_DAILY_CAP, SAMPLING_RATE)
 const (
-	CronSummarize     = "0 */2 * * *" // Every 2 hours
-	CronGenerateChart = "5 0 * * *"   // Daily at 00:05 UTC
-	CronCleanup       = "30 0 * * *"  // Daily at 00:30 UTC
+	DefaultSamplingRate = 0.1 // Fraction of the instances stored once the daily cap is exceeded
+)
+
+// Abuse detection (see db.FlaggedInconsistentPlatform, db.FlaggedIPFlood and db.FlaggedImplausibleLibrary)
+const (
+	AbusePlatformWindow = 6 * time.Hour  // Reports of an ID with another OS/arch within this window are flagged
+	AbuseIPWindow       = 24 * time.Hour // Window of the distinct IDs counted per IP
+	AbuseMaxIDsPerIP    = 20             // Reports of new IDs from an IP with this many IDs in the window are flagged (the default rate limit allows 48 reports per IP per day)
+	MaxPlausibleLibrary = 1 << 31        // Library counts from this value on (e.g. MaxInt) are flagged
+	DefaultFlaggedDays  = 7              // Days listed by /admin/flagged by default
+)
+
+// Default cron schedules, in UTC (CRON_* env vars, see schedulesFromEnv in cmd/server)
+const (
+	DefaultCronSummarize     = "0 */2 * * *" // Every 2 hours
+	DefaultCronGenerateChart = "5 0 * * *"   // Daily at 00:05
+	DefaultCronCleanup       = "30 0 * * *"  // Daily at 00:30
+	DefaultCronBackup        = "0 1 * * *"   // Daily at 01:00
+)
+
+// Timeouts, so a stuck query or task can't block shutdown or pile up cron runs
+const (
+	SaveReportTimeout = 5 * time.Second  // Storing the reports of a /collect request
+	SummarizeTimeout  = 30 * time.Minute // A whole summarize run, shorter than the DefaultCronSummarize interval
+	CleanupTimeout    = 10 * time.Minute
+	BackupTimeout     = 30 * time.Minute
+	ShutdownTimeout   = 30 * time.Second // Wait for in-flight requests and running tasks on shutdown
+)
+
+// Error reporting (SENTRY_DSN, ERROR_WEBHOOK_URL)
+const (
+	ErrorReportInterval     = 5 * time.Minute  // The same error is forwarded at most once per interval
+	ErrorReportTimeout      = 10 * time.Second // Sending a report to the webhook
+	MalformedSpikeWindow    = 10 * time.Minute
+	MalformedSpikeThreshold = 100 // Malformed /collect payloads per window that are reported as a spike
+)
+
+// Pipeline notifications (NOTIFY_WEBHOOK_URLS)
+const (
+	DefaultNotifyDropPct   = 20 // Notify when instances drop more than this percentage day-over-day
+	NotifyTimeout          = 10 * time.Second
+	MaxNotifyMessageLength = 2000 // Discord's limit
+)
+
+// Tracing (OTEL_EXPORTER_OTLP_ENDPOINT)
+const (
+	TracingServiceName = "navidrome-insights" // Default service name of the exported spans
+)
+
+// SQLite connection pools of the server: a single writer and a read-only pool (see db.OpenReadDB)
+const (
+	DBWriteConns = 1 // SQLite serializes writes anyway, so they wait in the pool instead of failing as locked
+	DBReadConns  = 4 // Summaries, read endpoints and shipping, so long reads don't hold the writer
 )
 
 // Data retention and summarization
 const (
 	SummarizeLookbackDays = 5
+	SummarizeWorkers      = 2           // Dates summarized in parallel, each using up to 2 of the DBReadConns connections
+	LateReportsMargin     = time.Minute // Reports stored this long before a summarize run started are summarized again
 	PurgeRetentionDays    = 15
+	ChurnDays             = 7  // Days without reports before an instance is considered churned
+	WeeklyActiveDays      = 7  // Window of the distinct instances counted as weekly active
+	MonthlyActiveDays     = 30 // Window of the distinct instances counted as monthly active
+	DefaultBackupCount    = 7  // Number of daily backups to keep
+	JobHistorySize        = 20 // Runs of each task kept in memory for /api/admin/jobs
+)
+
+// Replication
+const (
+	DefaultReplicaInterval = time.Minute // How often new reports are shipped to the S3 replica
+	ReplicaSegmentRows     = 10000       // Max reports per replica segment
+)
+
+// Message queue ingestion (QUEUE_NATS_URL)
+const (
+	DefaultQueueStream   = "INSIGHTS"
+	DefaultQueueSubject  = "insights.reports"
+	DefaultQueueConsumer = "insights-db"
+	QueueBatchSize       = 500              // Max reports stored per transaction by the consumer
+	QueueFetchWait       = 5 * time.Second  // Max wait for a batch, stored even if incomplete
+	QueueAckWait         = 30 * time.Second // Reports not acknowledged in time are redelivered
+)
+
+// ClickHouse
+const (
+	DefaultClickHouseTable    = "reports"
+	DefaultClickHouseInterval = time.Minute     // How often new reports are inserted in ClickHouse
+	ClickHouseBatchRows       = 10000           // Max reports per insert
+	ClickHouseTimeout         = 5 * time.Minute // A single ClickHouse request
 )
 
 // File paths and directories
 const (
-	ChartDataDir   = "web/chartdata"
-	WebIndexPath   = "web/index.html"
-	ChartsJSONFile = "charts.json"
-	SummariesDir   = "summaries"
+	DBFile              = "insights.db"
+	ChartDataDir        = "web/chartdata"
+	WebIndexPath        = "web/index.html"
+	ChartsJSONFile      = "charts.json"
+	SummariesDir        = "summaries"
+	BackupsDir          = "backups"
+	ArchiveDir          = "archive"
+	AutocertDir         = "autocert"
+	PlayerTypesFile     = "player_types.json"
+	FSTypesFile         = "fs_types.json"
+	BinsFile            = "bins.json"
+	ReleasesFile        = "releases.json"
+	ReplicaStateFile    = "replica-state.json"
+	ClickHouseStateFile = "clickhouse-state.json"
 )
 
 // File permissions
@@ -46,24 +150,87 @@ const (
 
 // Chart configuration
 const (
-	ChartWidth           = "1400px"
-	ChartHeight          = "500px"
-	TopVersionsCount     = 15
-	VersionSelectionDays = 60    // Rolling window (in days) for top-N version selection
-	IncompleteThreshold  = 0.8   // 20% drop indicates incomplete data
-	PlayerGroupThreshold = 0.002 // 0.2% threshold for grouping players
+	ChartWidth          = "1400px"
+	ChartHeight         = "500px"
+	ChartImageWidth     = 1000 // Size in pixels of the images rendered by /api/charts/{id}.png and .svg
+	ChartImageHeight    = 500
+	TopCountriesCount   = 20 // Countries shown in the images of the countries map, which are drawn as bars
+	TopFilesystemsCount = 10
+	TopOSVersionsCount  = 15
+	AdoptionReleases    = 5 // Number of most recent releases shown in the adoption curve chart
+	MovingAverageDays   = 7 // Window of the moving average series on the installations and clients charts
+	SummariesCacheTTL   = 10 * time.Minute
+	EChartsURL          = "https://cdn.jsdelivr.net/npm/echarts@5/dist/echarts.min.js"                 // Loaded by web/index.html, bundled by cmd/publish
+	TopoJSONURL         = "https://cdn.jsdelivr.net/npm/topojson-client@3/dist/topojson-client.min.js" // Reads WorldMapURL in web/index.html, bundled by cmd/publish like echarts
+	WorldMapURL         = "https://cdn.jsdelivr.net/npm/world-atlas@2/countries-110m.json"             // Countries of the countries chart, with ISO 3166-1 numeric ids
+	WorldMapName        = "world"                                                                      // Name web/index.html registers WorldMapURL under, for the countries chart
 )
 
-// Chart colors and styling
+// Default chart thresholds, configurable with CHARTS_* env vars (see charts.ConfigFromEnv)
 const (
-	ChartBackgroundColor = "#ffffff"
-	ChartTextColor       = "#000000"
-	GapHighlightColor    = "rgba(200, 200, 200, 0.3)"
-	GapLabelColor        = "#888888"
+	DefaultTopVersionsCount     = 15
+	DefaultVersionSelectionDays = 60    // Rolling window (in days) for top-N version selection
+	DefaultIncompleteThreshold  = 0.8   // 20% drop indicates incomplete data
+	DefaultPlayerGroupThreshold = 0.002 // 0.2% threshold for grouping players
+)
+
+// Anomaly detection on daily instance counts (charts.DetectAnomalies)
+const (
+	AnomalyWindowDays      = 14  // Rolling window of previous days the instance count is compared to
+	AnomalyMinDays         = 7   // Min days with data in the window to evaluate a day
+	AnomalyStdDevs         = 3   // Deviation from the rolling mean, in standard deviations, flagged as anomaly
+	AnomalyMinDeviationPct = 5.0 // Ignore deviations smaller than this percentage of the mean, for very stable series
+)
+
+// ChartTheme holds the colors used to render charts
+type ChartTheme struct {
+	Name              string
+	BackgroundColor   string
+	TextColor         string
+	GridColor         string
+	GapHighlightColor string
+	GapLabelColor     string
+	AnomalyColor      string
+	ProvisionalColor  string
+}
+
+// Chart colors and styling. Exported charts include options for both themes
+var (
+	LightTheme = ChartTheme{
+		Name:              "light",
+		BackgroundColor:   "#ffffff",
+		TextColor:         "#000000",
+		GridColor:         "#e0e6f1",
+		GapHighlightColor: "rgba(200, 200, 200, 0.3)",
+		GapLabelColor:     "#888888",
+		AnomalyColor:      "#d9534f",
+		ProvisionalColor:  "rgba(240, 173, 78, 0.15)",
+	}
+	DarkTheme = ChartTheme{
+		Name:              "dark",
+		BackgroundColor:   "#1e1e1e",
+		TextColor:         "#e0e0e0",
+		GridColor:         "#3a3a3a",
+		GapHighlightColor: "rgba(120, 120, 120, 0.3)",
+		GapLabelColor:     "#aaaaaa",
+		AnomalyColor:      "#ff6b6b",
+		ProvisionalColor:  "rgba(240, 173, 78, 0.2)",
+	}
 )
 
 // API configuration
 const (
+	APIPrefix        = "/api/v1" // Versioned API routes. Their legacy unversioned paths are kept for existing clients
+	APIVersion       = "1.0.0"   // Version of the API contract, in the OpenAPI document
 	AuthHeaderPrefix = "Bearer "
 	APIKeyQueryParam = "api_key"
+	CORSMaxAge       = time.Hour // How long browsers cache the CORS preflight responses
+	HSTSMaxAge       = 365 * 24 * time.Hour
+	// Default number of entries listed by the unmapped players/filesystems admin endpoints
+	DefaultUnmappedLimit = 50
+	// Default and max number of reports per page of the raw reports admin endpoint
+	DefaultRawPageSize = 1000
+	MaxRawPageSize     = 10000
+	// Clients may cache charts.json, but must revalidate it (cheap 304s thanks to ETag/Last-Modified)
+	ChartsCacheControl = "no-cache"
 )
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// Reasons for a report to be flagged as implausible or spoofed. Flagged reports are stored in
+// insights_rejected instead of insights, so they're left out of the summaries
+const (
+	FlaggedInconsistentPlatform = "inconsistent platform" // OS or arch differs from a recent report of the same ID
+	FlaggedIPFlood              = "ip flood"              // Too many IDs reporting from the same IP
+	FlaggedImplausibleLibrary   = "implausible library"   // Negative or overflowing library counts
+)
+
+const insertRejectedQuery = `INSERT INTO insights_rejected (id, time, data, country, reason) VALUES (?, ?, ?, ?, ?)`
+
+// platformChangedQuery finds reports of the instance in the consts.AbusePlatformWindow before a report, with
+// another OS or arch. A real instance can't switch platform that fast, so the report is likely spoofed.
+// Rows stored before the report columns were introduced have NULL columns, never matching
+const platformChangedQuery = `SELECT EXISTS (SELECT 1 FROM insights
+WHERE id = ? AND time >= datetime(?, ?) AND time <= ? AND (os_type != ? OR arch != ?))`
+
+// platformChanged reports whether the report of data at ts has an inconsistent platform
+func platformChanged(ctx context.Context, tx *sql.Tx, data insights.Data, ts string) (bool, error) {
+	window := fmt.Sprintf("-%d seconds", int(consts.AbusePlatformWindow.Seconds()))
+	var changed bool
+	err := tx.QueryRowContext(ctx, platformChangedQuery, data.InsightsID, ts, window, ts, data.OS.Type, data.OS.Arch).Scan(&changed)
+	return changed, err
+}
+
+// FlagReports stores reports received at the same time in insights_rejected, with the reason they were
+// flagged. Used for the reports flagged before storing (see FlaggedIPFlood and FlaggedImplausibleLibrary)
+func FlagReports(ctx context.Context, db *sql.DB, reports []insights.Data, t time.Time, country, reason string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ts := t.Format(consts.DateTimeFormat)
+	for _, data := range reports {
+		dataJSON, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, insertRejectedQuery, data.InsightsID, ts, EncodeData(dataJSON),
+			sql.NullString{String: country, Valid: country != ""}, reason)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// FlaggedActivity counts the reports rejected in a day for a reason, including the ones quarantined by
+// cmd/check and the imports with invalid times
+type FlaggedActivity struct {
+	Date      string `json:"date"` // Day the reports were rejected (YYYY-MM-DD)
+	Reason    string `json:"reason"`
+	Reports   int64  `json:"reports"`
+	Instances int64  `json:"instances"`
+	TopID     string `json:"topId"`    // Instance with the most rejected reports
+	TopCount  int64  `json:"topCount"` // Reports of TopID
+}
+
+// ListFlaggedActivity returns the rejected reports per day and reason, for the days in [from, to]
+func ListFlaggedActivity(ctx context.Context, db *sql.DB, from, to time.Time) ([]FlaggedActivity, error) {
+	rows, err := db.QueryContext(ctx, `
+WITH counts AS (
+	SELECT date(rejected) AS day, reason, COALESCE(id, '') AS id, count(*) AS n FROM insights_rejected
+	WHERE date(rejected) BETWEEN ? AND ?
+	GROUP BY day, reason, id
+)
+SELECT day, reason, sum(n), count(*),
+	(SELECT id FROM counts c2 WHERE c2.day = c.day AND c2.reason = c.reason ORDER BY n DESC, id LIMIT 1),
+	max(n)
+FROM counts c
+GROUP BY day, reason
+ORDER BY day, reason`, from.Format(consts.DateFormat), to.Format(consts.DateFormat))
+	if err != nil {
+		return nil, fmt.Errorf("querying flagged activity: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	activity := []FlaggedActivity{}
+	for rows.Next() {
+		var a FlaggedActivity
+		if err := rows.Scan(&a.Date, &a.Reason, &a.Reports, &a.Instances, &a.TopID, &a.TopCount); err != nil {
+			return nil, fmt.Errorf("scanning flagged activity: %w", err)
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// insertActiveDayQuery records that an instance reported on the day of a report
+const insertActiveDayQuery = `INSERT OR IGNORE INTO active_days (date, id) VALUES (date(?), ?)`
+
+// backfillActiveDays populates the active_days table from existing reports, when it is empty
+func backfillActiveDays(db *sql.DB) error {
+	var count int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM active_days`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec(`INSERT INTO active_days (date, id) SELECT DISTINCT date(time), id FROM insights`)
+	return err
+}
+
+// purgeActiveDays deletes the days no longer needed to count the active instances of the dates whose
+// reports are kept after cutoff (see PurgeOldEntries)
+func purgeActiveDays(ctx context.Context, db *sql.DB, cutoff time.Time) error {
+	keep := cutoff.UTC().AddDate(0, 0, -consts.MonthlyActiveDays).Format(consts.DateFormat)
+	_, err := db.ExecContext(ctx, `DELETE FROM active_days WHERE date < date(?)`, keep)
+	return err
+}
+
+// CountActiveInstances returns the number of distinct instances that reported in the given number of days
+// ending on date (inclusive), like the weekly and monthly active instances
+func CountActiveInstances(ctx context.Context, db *sql.DB, date time.Time, days int) (int64, error) {
+	var count int64
+	d := date.Format(consts.DateFormat)
+	err := db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT id) FROM active_days WHERE date > date(?, ?) AND date <= date(?)`,
+		d, fmt.Sprintf("-%d days", days), d).Scan(&count)
+	return count, err
+}
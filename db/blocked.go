@@ -0,0 +1,95 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BlockedInstance is an instance ID whose reports are no longer accepted
+type BlockedInstance struct {
+	ID     string    `json:"id"`
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// BlockInstance adds the ID to the blocked list and removes all reports already stored for it.
+// Returns the number of deleted reports.
+func BlockInstance(db *sql.DB, id, reason string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `INSERT INTO blocked_instances (id, reason) VALUES (?, ?)
+ON CONFLICT(id) DO UPDATE SET reason = excluded.reason`
+	if _, err := tx.Exec(query, id, reason); err != nil {
+		return 0, fmt.Errorf("inserting blocked instance: %w", err)
+	}
+
+	res, err := tx.Exec(`DELETE FROM insights WHERE id = ?`, id)
+	if err != nil {
+		return 0, fmt.Errorf("deleting reports: %w", err)
+	}
+	deleted, _ := res.RowsAffected()
+
+	if _, err := tx.Exec(`DELETE FROM instances WHERE id = ?`, id); err != nil {
+		return 0, fmt.Errorf("deleting instance: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM latest_reports WHERE id = ?`, id); err != nil {
+		return 0, fmt.Errorf("deleting latest reports: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM instance_versions WHERE id = ?`, id); err != nil {
+		return 0, fmt.Errorf("deleting instance versions: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM active_days WHERE id = ?`, id); err != nil {
+		return 0, fmt.Errorf("deleting active days: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+	return deleted, nil
+}
+
+// UnblockInstance removes the ID from the blocked list. Returns false if it was not blocked.
+func UnblockInstance(db *sql.DB, id string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM blocked_instances WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func IsBlocked(db *sql.DB, id string) (bool, error) {
+	var found int
+	err := db.QueryRow(`SELECT 1 FROM blocked_instances WHERE id = ?`, id).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func ListBlockedInstances(db *sql.DB) ([]BlockedInstance, error) {
+	rows, err := db.Query(`SELECT id, COALESCE(reason, ''), time FROM blocked_instances ORDER BY time`)
+	if err != nil {
+		return nil, fmt.Errorf("querying blocked instances: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	blocked := []BlockedInstance{}
+	for rows.Next() {
+		var b BlockedInstance
+		if err := rows.Scan(&b.ID, &b.Reason, &b.Time); err != nil {
+			return nil, fmt.Errorf("scanning blocked instance: %w", err)
+		}
+		blocked = append(blocked, b)
+	}
+	return blocked, rows.Err()
+}
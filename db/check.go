@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// Reasons for a report to be rejected by FindBadReports
+const (
+	RejectedInvalidTime = "invalid time"
+	RejectedInvalidData = "invalid data"
+)
+
+// BadReport is a stored report that can't be summarized: its time is not a valid date or is implausible
+// (see validReportTime), or its payload can't be decoded into an insights.Data
+type BadReport struct {
+	RowID  int64
+	Date   string // Day of the report, empty if its time is not a valid date
+	Reason string
+}
+
+// IntegrityCheck runs SQLite's integrity check, returning the problems found (none if the database is ok)
+func IntegrityCheck(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("checking integrity: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, fmt.Errorf("scanning integrity check: %w", err)
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// FindBadReports scans all reports, batchSize rows at a time so it can run against a live database,
+// and returns the ones that can't be summarized
+func FindBadReports(ctx context.Context, db *sql.DB, batchSize int) ([]BadReport, error) {
+	var bad []BadReport
+	var lastRowID int64
+	for {
+		found, last, err := findBadReportsBatch(ctx, db, lastRowID, batchSize)
+		if err != nil {
+			return bad, err
+		}
+		bad = append(bad, found...)
+		if last == lastRowID {
+			return bad, nil
+		}
+		lastRowID = last
+	}
+}
+
+// findBadReportsBatch checks the next batchSize rows after afterRowID, returning the bad ones and the last
+// rowid scanned. The time is read through date(), as invalid values can't be scanned into a time.Time
+func findBadReportsBatch(ctx context.Context, db *sql.DB, afterRowID int64, batchSize int) ([]BadReport, int64, error) {
+	rows, err := db.QueryContext(ctx, `SELECT rowid, COALESCE(date(time), ''), data FROM insights WHERE rowid > ? ORDER BY rowid LIMIT ?`,
+		afterRowID, batchSize)
+	if err != nil {
+		return nil, afterRowID, fmt.Errorf("querying data: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	// Compared by day, the way summaries see them
+	minDate := minReportTime.Format(consts.DateFormat)
+	maxDate := time.Now().UTC().Add(consts.MaxClockSkew).Format(consts.DateFormat)
+	var bad []BadReport
+	lastRowID := afterRowID
+	for rows.Next() {
+		var r BadReport
+		var data []byte
+		if err := rows.Scan(&r.RowID, &r.Date, &data); err != nil {
+			return nil, afterRowID, fmt.Errorf("scanning row: %w", err)
+		}
+		lastRowID = r.RowID
+		switch {
+		case r.Date == "", r.Date < minDate, r.Date > maxDate:
+			r.Reason = RejectedInvalidTime
+		case !validData(data):
+			r.Reason = RejectedInvalidData
+		default:
+			continue
+		}
+		bad = append(bad, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, afterRowID, err
+	}
+	return bad, lastRowID, nil
+}
+
+// validData reports whether a stored payload decodes like SelectData does
+func validData(stored []byte) bool {
+	j, err := DecodeData(stored)
+	if err != nil {
+		return false
+	}
+	var data insights.Data
+	return json.Unmarshal(j, &data) == nil
+}
+
+// QuarantineReports moves the bad reports to the insights_rejected table, in a single transaction. The days
+// they were removed from are marked as ingested, so their summaries are computed again without them, and
+// latest_reports is rebuilt, as it may reference removed reports. Returns the number of reports moved
+func QuarantineReports(ctx context.Context, db *sql.DB, reports []BadReport) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var moved int64
+	var days []string
+	for _, r := range reports {
+		res, err := tx.ExecContext(ctx, `
+INSERT INTO insights_rejected (id, time, data, country, reason)
+SELECT id, time, data, country, ? FROM insights WHERE rowid = ?`, r.Reason, r.RowID)
+		if err != nil {
+			return 0, fmt.Errorf("copying row %d: %w", r.RowID, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM insights WHERE rowid = ?`, r.RowID); err != nil {
+			return 0, fmt.Errorf("deleting row %d: %w", r.RowID, err)
+		}
+		n, _ := res.RowsAffected()
+		moved += n
+		if r.Date != "" {
+			days = append(days, r.Date)
+		}
+	}
+	if err := markIngested(ctx, tx, days...); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	if err := RebuildLatestReports(db); err != nil {
+		return moved, fmt.Errorf("rebuilding latest reports: %w", err)
+	}
+	return moved, nil
+}
+
+const deleteAllEarlierInHourQuery = `DELETE FROM insights
+WHERE EXISTS (
+	SELECT 1 FROM insights later
+	WHERE later.id = insights.id
+	  AND later.time > insights.time
+	  AND later.time < strftime('%Y-%m-%d %H:00:00', insights.time, '+1 hour')
+)`
+
+// DeleteEarlierInHour deletes the reports stored before only the latest report of an instance per hour
+// was kept (see replaceEarlierInHour) that have a later report in the same hour, returning the number of
+// reports deleted. The latest report of each day is never one of them, so latest_reports doesn't change.
+// Irreversible, only run on demand (cmd/check -dedup-hourly)
+func DeleteEarlierInHour(ctx context.Context, db *sql.DB) (int64, error) {
+	res, err := db.ExecContext(ctx, deleteAllEarlierInHourQuery)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
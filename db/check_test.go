@@ -0,0 +1,48 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/navidrome/insights/db"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DeleteEarlierInHour", func() {
+	var dbConn *sql.DB
+
+	BeforeEach(func() {
+		dbConn = openMemoryDB()
+		Expect(db.Migrate(dbConn)).To(Succeed())
+	})
+
+	DescribeTable("deletes the reports stored before a later one of their hour",
+		func(times []string, expected []string) {
+			// Stored directly, like the reports saved before only the latest one per hour was kept
+			for _, t := range times {
+				_, err := dbConn.Exec(`INSERT INTO insights (id, time, data) VALUES ('a', ?, '{}'), ('b', ?, '{}')`, t, t)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(db.RebuildLatestReports(dbConn)).To(Succeed())
+			latest := queryStrings(dbConn, `SELECT date || ' ' || id || ' ' || time FROM latest_reports ORDER BY date, id`)
+
+			Expect(db.DeleteEarlierInHour(context.Background(), dbConn)).To(Equal(int64(2 * (len(times) - len(expected)))))
+			Expect(queryStrings(dbConn, `SELECT CAST(time AS TEXT) FROM insights WHERE id = 'a' ORDER BY time`)).To(Equal(expected))
+			Expect(queryStrings(dbConn, `SELECT CAST(time AS TEXT) FROM insights WHERE id = 'b' ORDER BY time`)).To(Equal(expected))
+			Expect(queryStrings(dbConn, `SELECT date || ' ' || id || ' ' || time FROM latest_reports ORDER BY date, id`)).To(Equal(latest))
+		},
+		Entry("several reports in an hour",
+			[]string{"2025-06-01 10:05:00", "2025-06-01 10:40:00", "2025-06-01 10:50:00"},
+			[]string{"2025-06-01 10:50:00"}),
+		Entry("reports in consecutive hours",
+			[]string{"2025-06-01 10:59:59", "2025-06-01 11:00:00"},
+			[]string{"2025-06-01 10:59:59", "2025-06-01 11:00:00"}),
+		Entry("reports at the same hour of different days",
+			[]string{"2025-06-01 23:30:00", "2025-06-02 23:10:00"},
+			[]string{"2025-06-01 23:30:00", "2025-06-02 23:10:00"}),
+		Entry("the latest report of a day, last in its hour",
+			[]string{"2025-06-01 09:00:00", "2025-06-01 23:15:00", "2025-06-01 23:45:00", "2025-06-02 00:05:00"},
+			[]string{"2025-06-01 09:00:00", "2025-06-01 23:45:00", "2025-06-02 00:05:00"}),
+	)
+})
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// reportColumns are extracted from the payload on insert, so queries can filter and aggregate the
+// most used fields without decoding every payload. Payloads are compressed, so SQLite generated
+// columns can't extract them. Part of the baseline migration, listed here for legacy databases
+var reportColumns = []struct{ name, definition string }{
+	{"version", "VARCHAR"},
+	{"os_type", "VARCHAR"},
+	{"arch", "VARCHAR"},
+	{"containerized", "BOOLEAN"},
+	{"tracks", "INTEGER"},
+}
+
+const backfillBatchSize = 5000
+
+// reportColumnValues returns the values of the reportColumns for a report, in order
+func reportColumnValues(data insights.Data) []any {
+	return []any{data.Version, data.OS.Type, data.OS.Arch, data.OS.Containerized, data.Library.Tracks}
+}
+
+// BackfillReportColumns extracts the reportColumns of the reports stored before they were introduced
+// (or inserted without them, like cmd/consolidate does). Returns the number of updated rows
+func BackfillReportColumns(db *sql.DB) (int64, error) {
+	var total int64
+	for {
+		n, err := backfillBatch(db)
+		if err != nil {
+			return total, err
+		}
+		if n == 0 {
+			if total > 0 {
+				log.Printf("Extracted report columns for %d rows", total)
+			}
+			return total, nil
+		}
+		total += n
+	}
+}
+
+func backfillBatch(db *sql.DB) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.Query(`SELECT rowid, data FROM insights WHERE version IS NULL LIMIT ?`, backfillBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("querying data: %w", err)
+	}
+	type pending struct {
+		rowID int64
+		data  insights.Data
+	}
+	var updates []pending
+	for rows.Next() {
+		var p pending
+		var stored []byte
+		if err := rows.Scan(&p.rowID, &stored); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("scanning row: %w", err)
+		}
+		// Rows that can't be decoded still get (empty) columns, so they are not retried forever
+		if j, err := DecodeData(stored); err != nil {
+			log.Printf("Error decoding data of row %d: %s", p.rowID, err)
+		} else if err := json.Unmarshal(j, &p.data); err != nil {
+			log.Printf("Error unmarshalling data of row %d: %s", p.rowID, err)
+		}
+		updates = append(updates, p)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	query := `UPDATE insights SET version = ?, os_type = ?, arch = ?, containerized = ?, tracks = ? WHERE rowid = ?`
+	for _, p := range updates {
+		if _, err := tx.Exec(query, append(reportColumnValues(p.data), p.rowID)...); err != nil {
+			return 0, fmt.Errorf("updating row %d: %w", p.rowID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(updates)), nil
+}
@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	_ "embed"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdMarker prefixes payloads stored compressed with zstd and the v1 dictionary. Plain JSON payloads
+// always start with '{', so rows written before compression was introduced are still readable.
+// Changing the dictionary requires a new marker, as existing rows can only be decoded with the old one
+const zstdMarker = 0x01
+
+// zstdDictV1 is a representative report, used as a raw zstd dictionary. Reports are small and share
+// most of their keys and many values, so each one compresses much better against it than on its own.
+// This file must never change (see zstdMarker)
+//
+//go:embed zstd_dict_v1.json
+var zstdDictV1 []byte
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderDictRaw(zstdMarker, zstdDictV1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderDictRaw(zstdMarker, zstdDictV1))
+)
+
+// EncodeData compresses a JSON payload for storage in the insights data column
+func EncodeData(jsonData []byte) []byte {
+	out := make([]byte, 1, len(jsonData)/4+1)
+	out[0] = zstdMarker
+	return zstdEncoder.EncodeAll(jsonData, out)
+}
+
+// DecodeData returns the JSON payload stored in the insights data column, decompressing it if needed
+func DecodeData(stored []byte) ([]byte, error) {
+	payload, _, err := decodeDataBuffer(stored, nil)
+	return payload, err
+}
+
+// decodeDataBuffer is DecodeData decompressing into buf, returning the payload and the (possibly grown)
+// buffer to reuse for the next row. Plain payloads are returned as is, so the payload is only valid as long
+// as both stored and buf are
+func decodeDataBuffer(stored, buf []byte) (payload, grown []byte, err error) {
+	if !IsCompressed(stored) {
+		return stored, buf, nil
+	}
+	out, err := zstdDecoder.DecodeAll(stored[1:], buf[:0])
+	if err != nil {
+		return nil, buf, fmt.Errorf("decompressing data: %w", err)
+	}
+	return out, out, nil
+}
+
+// IsCompressed reports whether a stored payload is already compressed
+func IsCompressed(stored []byte) bool {
+	return len(stored) > 0 && stored[0] == zstdMarker
+}
+
+// CompressData compresses all payloads still stored as plain JSON, in transactions of batchSize rows,
+// so it can run against a live database. Returns the number of rows compressed
+func CompressData(db *sql.DB, batchSize int) (int64, error) {
+	var total, lastRowID int64
+	for {
+		n, last, err := compressBatch(db, lastRowID, batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if last == lastRowID {
+			return total, nil
+		}
+		lastRowID = last
+	}
+}
+
+// compressBatch compresses the plain payloads among the next batchSize rows after afterRowID,
+// returning the number of rows compressed and the last rowid scanned
+func compressBatch(db *sql.DB, afterRowID int64, batchSize int) (int64, int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, afterRowID, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.Query(`SELECT rowid, data FROM insights WHERE rowid > ? ORDER BY rowid LIMIT ?`, afterRowID, batchSize)
+	if err != nil {
+		return 0, afterRowID, fmt.Errorf("querying data: %w", err)
+	}
+	type pending struct {
+		rowID int64
+		data  []byte
+	}
+	var updates []pending
+	lastRowID := afterRowID
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&lastRowID, &p.data); err != nil {
+			_ = rows.Close()
+			return 0, afterRowID, fmt.Errorf("scanning row: %w", err)
+		}
+		if len(p.data) > 0 && !IsCompressed(p.data) {
+			p.rowID = lastRowID
+			updates = append(updates, p)
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, afterRowID, err
+	}
+
+	for _, p := range updates {
+		if _, err := tx.Exec(`UPDATE insights SET data = ? WHERE rowid = ?`, EncodeData(p.data), p.rowID); err != nil {
+			return 0, afterRowID, fmt.Errorf("updating row %d: %w", p.rowID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, afterRowID, err
+	}
+	return int64(len(updates)), lastRowID, nil
+}
@@ -1,110 +1,597 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"iter"
 	"log"
+	"maps"
 	"net/url"
+	"os"
+	"slices"
 	"time"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/navidrome/insights/consts"
 	"github.com/navidrome/navidrome/core/metrics/insights"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func OpenDB(fileName string) (*sql.DB, error) {
-	params := url.Values{
+	db, err := open(fileName, url.Values{
 		"_journal_mode": []string{"WAL"},
 		"_synchronous":  []string{"NORMAL"},
 		"_busy_timeout": []string{"5000"},
-	}
-	dataSourceName := fmt.Sprintf("file:%s?%s", fileName, params.Encode())
-	db, err := sql.Open("sqlite3", dataSourceName)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Create schema if not exists
-	createTableQuery := `
-CREATE TABLE IF NOT EXISTS insights (
-	id VARCHAR NOT NULL,
-	time DATETIME default CURRENT_TIMESTAMP,
-	data JSONB
-);
-CREATE INDEX IF NOT EXISTS insights_time ON insights(time);
-CREATE INDEX IF NOT EXISTS insights_id_time ON insights(id, time);
-`
-	_, err = db.Exec(createTableQuery)
+	if err := Migrate(db); err != nil {
+		return nil, fmt.Errorf("migrating schema: %w", err)
+	}
+
+	// Data kept in columns and tables introduced after the reports were stored
+	if _, err := BackfillReportColumns(db); err != nil {
+		return nil, fmt.Errorf("extracting report columns: %w", err)
+	}
+
+	if err := backfillInstances(db); err != nil {
+		return nil, fmt.Errorf("backfilling instances: %w", err)
+	}
+	if err := backfillInstanceVersions(db); err != nil {
+		return nil, fmt.Errorf("backfilling instance versions: %w", err)
+	}
+	if err := backfillActiveDays(db); err != nil {
+		return nil, fmt.Errorf("backfilling active days: %w", err)
+	}
+	if err := backfillLatestReports(db); err != nil {
+		return nil, fmt.Errorf("backfilling latest reports: %w", err)
+	}
+	if err := backfillIngestedDays(db); err != nil {
+		return nil, fmt.Errorf("backfilling ingested days: %w", err)
+	}
+
+	db.SetMaxOpenConns(3)
+	return db, nil
+}
+
+// OpenReadDB opens a pool of consts.DBReadConns read-only connections (query_only) to a database already
+// opened with OpenDB, which creates, migrates and switches it to WAL mode, so reads don't block writes.
+// Used by the server to keep long reads (summaries, read endpoints) off its single writer connection
+func OpenReadDB(fileName string) (*sql.DB, error) {
+	db, err := open(fileName, url.Values{
+		"_query_only":   []string{"true"},
+		"_busy_timeout": []string{"5000"},
+	})
 	if err != nil {
 		return nil, err
 	}
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	db.SetMaxOpenConns(consts.DBReadConns)
+	return db, nil
+}
 
-	db.SetMaxOpenConns(3)
+// OpenReadOnly opens an existing database read-only (mode=ro), for the tools that must not modify it, like
+// exports run against a copy of the production database. Unlike OpenDB, it doesn't create, migrate or
+// backfill the database, so databases not migrated to the latest schema are refused
+func OpenReadOnly(fileName string) (*sql.DB, error) {
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, err
+	}
+	db, err := open(fileName, url.Values{
+		"mode":          []string{"ro"},
+		"_busy_timeout": []string{"5000"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaCurrent(db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
 	return db, nil
 }
 
-func SaveReport(db *sql.DB, data insights.Data, t time.Time) error {
-	dataJSON, err := json.Marshal(data)
+// open opens the database with the given connection params. Queries are traced when tracing is enabled
+// (see tracing.FromEnv). Rows iterations are not traced, as SelectData iterates over all reports of a day
+func open(fileName string, params url.Values) (*sql.DB, error) {
+	dataSourceName := fmt.Sprintf("file:%s?%s", fileName, params.Encode())
+	return otelsql.Open("sqlite3", dataSourceName,
+		otelsql.WithAttributes(attribute.String("db.system", "sqlite")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitRows: true, OmitConnResetSession: true}),
+	)
+}
+
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
 		return err
 	}
-
-	query := `INSERT INTO insights (id, data, time) VALUES (?, ?, ?)`
-	_, err = db.Exec(query, data.InsightsID, dataJSON, t.Format(consts.DateTimeFormat))
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
 }
 
-func PurgeOldEntries(db *sql.DB) error {
-	// Delete entries older than configured retention period
-	query := `DELETE FROM insights WHERE time < ?`
-	cnt, err := db.Exec(query, time.Now().Add(-consts.PurgeRetentionDays*24*time.Hour))
+// Report is an insights payload along with the metadata stored by the server
+type Report struct {
+	insights.Data
+	Country string // ISO 3166-1 alpha-2 code, empty if unknown
+}
+
+func SaveReport(ctx context.Context, db *sql.DB, data insights.Data, t time.Time, country string) error {
+	return SaveReports(ctx, db, []insights.Data{data}, t, country)
+}
+
+// SaveReports stores multiple reports received at the same time in a single transaction. Reports with an
+// inconsistent platform (see platformChangedQuery) are flagged into insights_rejected instead
+func SaveReports(ctx context.Context, db *sql.DB, reports []insights.Data, t time.Time, country string) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer func() { _ = tx.Rollback() }()
+
+	ts := t.Format(consts.DateTimeFormat)
+	query := `INSERT INTO insights (id, data, time, received, country, version, os_type, arch, containerized, tracks)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	for _, data := range reports {
+		dataJSON, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		changed, err := platformChanged(ctx, tx, data, ts)
+		if err != nil {
+			return err
+		}
+		if changed {
+			_, err := tx.ExecContext(ctx, insertRejectedQuery, data.InsightsID, ts, EncodeData(dataJSON),
+				sql.NullString{String: country, Valid: country != ""}, FlaggedInconsistentPlatform)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		skip, err := replaceEarlierInHour(ctx, tx, data.InsightsID, ts)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
+		args := []any{data.InsightsID, EncodeData(dataJSON), ts, ts, sql.NullString{String: country, Valid: country != ""}}
+		_, err = tx.ExecContext(ctx, query, append(args, reportColumnValues(data)...)...)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, upsertInstanceQuery, data.InsightsID, ts, ts); err != nil {
+			return err
+		}
+		if err = recordInstanceVersion(ctx, tx, data.InsightsID, data.Version, ts); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, insertActiveDayQuery, ts, data.InsightsID); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, upsertLatestReportQuery, ts, data.InsightsID, ts); err != nil {
+			return err
+		}
+	}
+	if err := markIngested(ctx, tx, ts); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Only the latest report of an instance per calendar hour is kept, as instances retrying a report would
+// otherwise store several rows. Reports with the same time are all kept, the last inserted being the one
+// read (see upsertLatestReportQuery)
+const (
+	newerInHourQuery = `SELECT EXISTS (SELECT 1 FROM insights
+WHERE id = ? AND time > ? AND time < strftime('%Y-%m-%d %H:00:00', ?, '+1 hour'))`
+	deleteEarlierInHourQuery = `DELETE FROM insights
+WHERE id = ? AND time < ? AND time >= strftime('%Y-%m-%d %H:00:00', ?)`
+)
+
+// replaceEarlierInHour deletes the reports of the instance stored earlier in the hour of ts, before
+// inserting the report at ts. Returns true if the report must be skipped instead, as a later one of the
+// same hour is already stored (e.g. when importing)
+func replaceEarlierInHour(ctx context.Context, tx *sql.Tx, id, ts string) (bool, error) {
+	var newer bool
+	if err := tx.QueryRowContext(ctx, newerInHourQuery, id, ts, ts).Scan(&newer); err != nil {
+		return false, err
+	}
+	if newer {
+		return true, nil
+	}
+	_, err := tx.ExecContext(ctx, deleteEarlierInHourQuery, id, ts, ts)
+	return false, err
+}
+
+// importTimeFormat is consts.DateTimeFormat, keeping the fractional seconds of reports stored by older versions
+const importTimeFormat = "2006-01-02 15:04:05.999999999"
+
+// minReportTime is when Navidrome started sending reports. Imported reports with an earlier time come
+// from a broken clock or a corrupted export
+var minReportTime = time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+// validReportTime reports whether a report time is plausible at now: not before minReportTime and not in
+// the future, allowing for consts.MaxClockSkew
+func validReportTime(t, now time.Time) bool {
+	return !t.Before(minReportTime) && !t.After(now.Add(consts.MaxClockSkew))
+}
+
+// ImportReports stores reports exported from another database (see cmd/export and cmd/import) in a single
+// transaction, keeping their original time (in UTC), country and payload. Reports with an implausible time
+// (see validReportTime) would pollute the summaries of other days, so they are moved to insights_rejected,
+// like the ones with an inconsistent platform (see SaveReports). Returns the number of reports stored,
+// without the rejected ones and the ones skipped for a later report of their hour (see replaceEarlierInHour)
+func ImportReports(ctx context.Context, db *sql.DB, reports []RawReport) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `INSERT INTO insights (id, data, time, received, country, version, os_type, arch, containerized, tracks)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	now := time.Now().UTC()
+	received := now.Format(consts.DateTimeFormat)
+	days := map[string]struct{}{}
+	rejected := map[string]int{}
+	var stored int64
+	for _, r := range reports {
+		var data insights.Data
+		if err := json.Unmarshal([]byte(r.Data), &data); err != nil {
+			return 0, fmt.Errorf("decoding report %s at %s: %w", r.ID, r.Time.Format(time.RFC3339), err)
+		}
+		ts := r.Time.UTC().Format(importTimeFormat)
+		var reason string
+		if !validReportTime(r.Time, now) {
+			reason = RejectedInvalidTime
+		} else if changed, err := platformChanged(ctx, tx, data, ts); err != nil {
+			return 0, err
+		} else if changed {
+			reason = FlaggedInconsistentPlatform
+		}
+		if reason != "" {
+			_, err := tx.ExecContext(ctx, insertRejectedQuery, r.ID, ts, EncodeData([]byte(r.Data)),
+				sql.NullString{String: r.Country, Valid: r.Country != ""}, reason)
+			if err != nil {
+				return 0, err
+			}
+			rejected[reason]++
+			continue
+		}
+		skip, err := replaceEarlierInHour(ctx, tx, r.ID, ts)
+		if err != nil {
+			return 0, err
+		}
+		if skip {
+			continue
+		}
+		args := []any{r.ID, EncodeData([]byte(r.Data)), ts, received, sql.NullString{String: r.Country, Valid: r.Country != ""}}
+		if _, err := tx.ExecContext(ctx, query, append(args, reportColumnValues(data)...)...); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, upsertInstanceQuery, r.ID, ts, ts); err != nil {
+			return 0, err
+		}
+		if err := recordInstanceVersion(ctx, tx, r.ID, data.Version, ts); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, insertActiveDayQuery, ts, r.ID); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, upsertLatestReportQuery, ts, r.ID, ts); err != nil {
+			return 0, err
+		}
+		days[r.Time.UTC().Format(consts.DateFormat)] = struct{}{}
+		stored++
+	}
+	// Reports of past days are late for their summaries
+	if err := markIngested(ctx, tx, slices.Collect(maps.Keys(days))...); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for reason, n := range rejected {
+		log.Printf("Rejected %d imported reports: %s", n, reason)
+	}
+	return stored, nil
+}
+
+// PurgeCutoff returns the time before which reports are purged, per the retention period
+func PurgeCutoff() time.Time {
+	return time.Now().Add(-consts.PurgeRetentionDays * 24 * time.Hour)
+}
+
+// PurgeOldEntries deletes entries older than cutoff (see PurgeCutoff) with a rowid up to maxRowID, returning
+// the number of deleted rows. Bounding the rowid keeps the reports stored (imported) after they were
+// archived (see archive.Write), which are purged by the next run
+func PurgeOldEntries(ctx context.Context, db *sql.DB, cutoff time.Time, maxRowID int64) (int64, error) {
+	cnt, err := db.ExecContext(ctx, `DELETE FROM insights WHERE time < ? AND rowid <= ?`, cutoff, maxRowID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM latest_reports WHERE time < ?`, cutoff); err != nil {
+		return 0, err
+	}
+	if err := purgeActiveDays(ctx, db, cutoff); err != nil {
+		return 0, err
+	}
+	// The cutoff day is partially purged, so it can't be summarized again
+	if _, err := db.ExecContext(ctx, `DELETE FROM ingested_days WHERE date <= date(?)`, cutoff.UTC().Format(consts.DateFormat)); err != nil {
+		return 0, err
+	}
 	deleted, _ := cnt.RowsAffected()
 	log.Printf("Deleted %d old entries\n", deleted)
-	return nil
+	return deleted, nil
 }
 
-func SelectData(db *sql.DB, date time.Time) (iter.Seq[insights.Data], error) {
+// SelectData returns the latest report of each instance for the given date, streamed from latest_reports
+// one row at a time. The query holds a DB connection until the iteration ends, so callers must iterate the
+// results. The iteration stops early if the context is done, so callers must check ctx.Err() before using
+// the results
+func SelectData(ctx context.Context, db *sql.DB, date time.Time) (iter.Seq[Report], error) {
 	query := `
-SELECT i1.id, i1.time, i1.data
-FROM insights i1
-INNER JOIN (
-    SELECT id, MAX(time) as max_time
-    FROM insights
-    WHERE time >= date(?) AND time < date(?, '+1 day')
-    GROUP BY id
-) i2 ON i1.id = i2.id AND i1.time = i2.max_time
-WHERE i1.time >= date(?) AND time < date(?, '+1 day')
-ORDER BY i1.id, i1.time DESC;`
+SELECT i.id, i.time, i.data, COALESCE(i.country, '')
+FROM latest_reports l
+INNER JOIN insights i ON i.id = l.id AND i.time = l.time
+WHERE l.date = date(?)
+ORDER BY l.id;`
 	d := date.Format(consts.DateFormat)
-	rows, err := db.Query(query, d, d, d, d)
+	rows, err := db.QueryContext(ctx, query, d)
 	if err != nil {
 		return nil, fmt.Errorf("querying data: %w", err)
 	}
-	return func(yield func(insights.Data) bool) {
+	return func(yield func(Report) bool) {
 		defer func() { _ = rows.Close() }()
+		// The stored payload is scanned without copying it and decompressed into a buffer reused across rows,
+		// so the memory used doesn't grow with the number of reports of the day
+		var buf []byte
 		for rows.Next() {
-			var j string
+			var stored sql.RawBytes
 			var id string
 			var t time.Time
-			err := rows.Scan(&id, &t, &j)
+			var report Report
+			err := rows.Scan(&id, &t, &stored, &report.Country)
 			if err != nil {
 				log.Printf("Error scanning row: %s", err)
 				return
 			}
-			var data insights.Data
-			err = json.Unmarshal([]byte(j), &data)
+			var j []byte
+			if j, buf, err = decodeDataBuffer(stored, buf); err != nil {
+				log.Printf("Error decoding data: %s", err)
+				return
+			}
+			err = json.Unmarshal(j, &report.Data)
 			if err != nil {
 				log.Printf("Error unmarshalling data: %s", err)
 				return
 			}
-			if !yield(data) {
+			if !yield(report) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("Error reading rows: %s", err)
+		}
+	}, nil
+}
+
+// RawReport is a report as stored in the database, with the payload kept as raw (decompressed) JSON
+type RawReport struct {
+	ID      string
+	Time    time.Time
+	Data    string
+	Country string
+}
+
+// SelectRawReports returns all reports stored for the given date, without decoding their payloads
+func SelectRawReports(db *sql.DB, date time.Time) (iter.Seq[RawReport], error) {
+	query := `
+SELECT id, time, data, COALESCE(country, '')
+FROM insights
+WHERE time >= date(?) AND time < date(?, '+1 day')
+ORDER BY time, id`
+	d := date.Format(consts.DateFormat)
+	rows, err := db.Query(query, d, d)
+	if err != nil {
+		return nil, fmt.Errorf("querying data: %w", err)
+	}
+	return func(yield func(RawReport) bool) {
+		defer func() { _ = rows.Close() }()
+		for rows.Next() {
+			var r RawReport
+			var data []byte
+			if err := rows.Scan(&r.ID, &r.Time, &data, &r.Country); err != nil {
+				log.Printf("Error scanning row: %s", err)
+				return
+			}
+			data, err := DecodeData(data)
+			if err != nil {
+				log.Printf("Error decoding data: %s", err)
+				return
+			}
+			r.Data = string(data)
+			if !yield(r) {
 				return
 			}
 		}
 	}, nil
 }
+
+// SelectRawReportsAfter returns up to limit reports with a rowid greater than afterRowID, in insertion
+// order, and the rowid of the last one returned (afterRowID if none). Used to ship new reports incrementally
+func SelectRawReportsAfter(db *sql.DB, afterRowID int64, limit int) ([]RawReport, int64, error) {
+	query := `
+SELECT rowid, id, time, data, COALESCE(country, '')
+FROM insights
+WHERE rowid > ?
+ORDER BY rowid
+LIMIT ?`
+	rows, err := db.Query(query, afterRowID, limit)
+	if err != nil {
+		return nil, afterRowID, fmt.Errorf("querying data: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	lastRowID := afterRowID
+	var reports []RawReport
+	for rows.Next() {
+		var r RawReport
+		var data []byte
+		if err := rows.Scan(&lastRowID, &r.ID, &r.Time, &data, &r.Country); err != nil {
+			return nil, afterRowID, fmt.Errorf("scanning row: %w", err)
+		}
+		data, err := DecodeData(data)
+		if err != nil {
+			return nil, afterRowID, err
+		}
+		r.Data = string(data)
+		reports = append(reports, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, afterRowID, err
+	}
+	return reports, lastRowID, nil
+}
+
+// SelectRawReportsPage returns up to limit reports stored between the from and to dates (inclusive, zero
+// values are unbounded) with a rowid greater than afterRowID, in insertion order, and the rowid of the last
+// one returned (0 if there are no more reports). Used to page through the reports of a date range
+func SelectRawReportsPage(ctx context.Context, db *sql.DB, from, to time.Time, afterRowID int64, limit int) ([]RawReport, int64, error) {
+	query := `
+SELECT rowid, id, time, data, COALESCE(country, '')
+FROM insights
+WHERE rowid > ?`
+	args := []any{afterRowID}
+	if !from.IsZero() {
+		query += ` AND time >= date(?)`
+		args = append(args, from.Format(consts.DateFormat))
+	}
+	if !to.IsZero() {
+		query += ` AND time < date(?, '+1 day')`
+		args = append(args, to.Format(consts.DateFormat))
+	}
+	query += `
+ORDER BY rowid
+LIMIT ?`
+	rows, err := db.QueryContext(ctx, query, append(args, limit)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("querying data: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var lastRowID int64
+	var reports []RawReport
+	for rows.Next() {
+		var r RawReport
+		var data []byte
+		if err := rows.Scan(&lastRowID, &r.ID, &r.Time, &data, &r.Country); err != nil {
+			return nil, 0, fmt.Errorf("scanning row: %w", err)
+		}
+		data, err := DecodeData(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		r.Data = string(data)
+		reports = append(reports, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if len(reports) < limit {
+		lastRowID = 0
+	}
+	return reports, lastRowID, nil
+}
+
+// SelectOldReports returns up to limit reports older than cutoff with a rowid in (afterRowID, maxRowID], in
+// insertion order, and the rowid of the last one returned (afterRowID if none). Used to archive the reports
+// before purging them
+func SelectOldReports(ctx context.Context, db *sql.DB, cutoff time.Time, afterRowID, maxRowID int64, limit int) ([]RawReport, int64, error) {
+	query := `
+SELECT rowid, id, time, data, COALESCE(country, '')
+FROM insights
+WHERE time < ? AND rowid > ? AND rowid <= ?
+ORDER BY rowid
+LIMIT ?`
+	rows, err := db.QueryContext(ctx, query, cutoff, afterRowID, maxRowID, limit)
+	if err != nil {
+		return nil, afterRowID, fmt.Errorf("querying data: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	lastRowID := afterRowID
+	var reports []RawReport
+	for rows.Next() {
+		var r RawReport
+		var data []byte
+		if err := rows.Scan(&lastRowID, &r.ID, &r.Time, &data, &r.Country); err != nil {
+			return nil, afterRowID, fmt.Errorf("scanning row: %w", err)
+		}
+		data, err := DecodeData(data)
+		if err != nil {
+			return nil, afterRowID, err
+		}
+		r.Data = string(data)
+		reports = append(reports, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, afterRowID, err
+	}
+	return reports, lastRowID, nil
+}
+
+// MaxRowID returns the highest rowid in the insights table, or 0 if it is empty
+func MaxRowID(db *sql.DB) (int64, error) {
+	var maxRowID int64
+	err := db.QueryRow(`SELECT COALESCE(MAX(rowid), 0) FROM insights`).Scan(&maxRowID)
+	return maxRowID, err
+}
+
+// SelectDates returns all distinct dates with stored reports, in ascending order
+func SelectDates(db *sql.DB) ([]time.Time, error) {
+	rows, err := db.Query(`SELECT DISTINCT date(time) AS d FROM insights ORDER BY d`)
+	if err != nil {
+		return nil, fmt.Errorf("querying dates: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var dates []time.Time
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, fmt.Errorf("scanning date: %w", err)
+		}
+		t, err := time.Parse(consts.DateFormat, d)
+		if err != nil {
+			return nil, fmt.Errorf("parsing date %s: %w", d, err)
+		}
+		dates = append(dates, t)
+	}
+	return dates, rows.Err()
+}
@@ -0,0 +1,221 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDB(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "DB Suite")
+}
+
+// openMemoryDB opens an in-memory database, closed after the test. It keeps a single connection, as each
+// connection to :memory: opens a database of its own
+func openMemoryDB() *sql.DB {
+	dbConn, err := sql.Open("sqlite3", "file::memory:")
+	Expect(err).NotTo(HaveOccurred())
+	dbConn.SetMaxOpenConns(1)
+	DeferCleanup(dbConn.Close)
+	return dbConn
+}
+
+// queryStrings returns the first column of the rows of query, as strings
+func queryStrings(dbConn *sql.DB, query string, args ...any) []string {
+	rows, err := dbConn.Query(query, args...)
+	Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = rows.Close() }()
+	var values []string
+	for rows.Next() {
+		var v string
+		Expect(rows.Scan(&v)).To(Succeed())
+		values = append(values, v)
+	}
+	Expect(rows.Err()).NotTo(HaveOccurred())
+	return values
+}
+
+// report returns the data of a report of id, with a stable release and the given platform
+func report(id, version, osType, arch string) insights.Data {
+	var data insights.Data
+	data.InsightsID = id
+	data.Version = version + " (0b184893)"
+	data.OS.Type = osType
+	data.OS.Arch = arch
+	return data
+}
+
+// at parses a time in consts.DateTimeFormat, in UTC
+func at(ts string) time.Time {
+	t, err := time.Parse(consts.DateTimeFormat, ts)
+	Expect(err).NotTo(HaveOccurred())
+	return t
+}
+
+var _ = Describe("DB", func() {
+	var ctx context.Context
+	var dbConn *sql.DB
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dbConn = openMemoryDB()
+		Expect(db.Migrate(dbConn)).To(Succeed())
+	})
+
+	Describe("SaveReports", func() {
+		DescribeTable("flags the reports of an instance switching platform",
+			func(later string, osType, arch string, flagged bool) {
+				Expect(db.SaveReport(ctx, dbConn, report("a", "0.55.0", "linux", "amd64"), at("2025-06-01 10:00:00"), "")).To(Succeed())
+				Expect(db.SaveReport(ctx, dbConn, report("a", "0.55.0", osType, arch), at(later), "FR")).To(Succeed())
+
+				rejected := queryStrings(dbConn, `SELECT reason || ' ' || country FROM insights_rejected WHERE id = 'a'`)
+				stored := queryStrings(dbConn, `SELECT CAST(time AS TEXT) FROM insights WHERE id = 'a' ORDER BY time`)
+				if flagged {
+					Expect(rejected).To(Equal([]string{db.FlaggedInconsistentPlatform + " FR"}))
+					Expect(stored).To(Equal([]string{"2025-06-01 10:00:00"}))
+				} else {
+					Expect(rejected).To(BeEmpty())
+					Expect(stored).To(Equal([]string{"2025-06-01 10:00:00", later}))
+				}
+			},
+			Entry("same platform", "2025-06-01 11:00:00", "linux", "amd64", false),
+			Entry("other OS within the window", "2025-06-01 11:00:00", "darwin", "amd64", true),
+			Entry("other arch within the window", "2025-06-01 16:00:00", "linux", "arm64", true),
+			Entry("other platform after the window", "2025-06-01 16:00:01", "darwin", "arm64", false),
+		)
+
+		DescribeTable("keeps only the latest report of an instance per hour",
+			func(times []string, expected []string) {
+				for _, t := range times {
+					Expect(db.SaveReport(ctx, dbConn, report("a", "0.55.0", "linux", "amd64"), at(t), "")).To(Succeed())
+				}
+				Expect(db.SaveReport(ctx, dbConn, report("b", "0.55.0", "linux", "amd64"), at(times[0]), "")).To(Succeed())
+
+				Expect(queryStrings(dbConn, `SELECT CAST(time AS TEXT) FROM insights WHERE id = 'a' ORDER BY time`)).To(Equal(expected))
+				Expect(queryStrings(dbConn, `SELECT CAST(time AS TEXT) FROM latest_reports WHERE id = 'a'`)).To(Equal(expected[len(expected)-1:]))
+				Expect(queryStrings(dbConn, `SELECT COUNT(*) FROM insights WHERE id = 'b'`)).To(Equal([]string{"1"}))
+			},
+			Entry("a later report in the hour replaces the earlier one",
+				[]string{"2025-06-01 10:05:00", "2025-06-01 10:40:00"}, []string{"2025-06-01 10:40:00"}),
+			Entry("an earlier report in the hour is skipped",
+				[]string{"2025-06-01 10:40:00", "2025-06-01 10:05:00"}, []string{"2025-06-01 10:40:00"}),
+			Entry("reports in consecutive hours are kept",
+				[]string{"2025-06-01 10:59:59", "2025-06-01 11:00:00"}, []string{"2025-06-01 10:59:59", "2025-06-01 11:00:00"}),
+			Entry("reports with the same time are kept",
+				[]string{"2025-06-01 10:05:00", "2025-06-01 10:05:00"}, []string{"2025-06-01 10:05:00", "2025-06-01 10:05:00"}),
+		)
+
+		Describe("derived tables", func() {
+			BeforeEach(func() {
+				Expect(db.SaveReports(ctx, dbConn, []insights.Data{
+					report("a", "0.54.0", "linux", "amd64"),
+					report("b", "0.55.0", "linux", "amd64"),
+				}, at("2025-06-01 10:00:00"), "")).To(Succeed())
+				Expect(db.SaveReport(ctx, dbConn, report("a", "0.55.0", "linux", "amd64"), at("2025-06-01 15:00:00"), "")).To(Succeed())
+				Expect(db.SaveReport(ctx, dbConn, report("a", "0.55.0", "linux", "amd64"), at("2025-06-02 09:00:00"), "")).To(Succeed())
+			})
+
+			DescribeTable("are upserted with each report",
+				func(query string, expected []string) {
+					Expect(queryStrings(dbConn, query)).To(Equal(expected))
+				},
+				Entry("instances", `SELECT id || ' ' || first_seen || ' ' || last_seen FROM instances ORDER BY id`, []string{
+					"a 2025-06-01 10:00:00 2025-06-02 09:00:00",
+					"b 2025-06-01 10:00:00 2025-06-01 10:00:00",
+				}),
+				Entry("instance_versions", `SELECT id || ' ' || release || ' ' || first_seen FROM instance_versions ORDER BY id, release`, []string{
+					"a 0.54.0 2025-06-01 10:00:00",
+					"a 0.55.0 2025-06-01 15:00:00",
+					"b 0.55.0 2025-06-01 10:00:00",
+				}),
+				Entry("active_days", `SELECT date || ' ' || id FROM active_days ORDER BY date, id`, []string{
+					"2025-06-01 a",
+					"2025-06-01 b",
+					"2025-06-02 a",
+				}),
+				Entry("latest_reports", `SELECT date || ' ' || id || ' ' || time FROM latest_reports ORDER BY date, id`, []string{
+					"2025-06-01 a 2025-06-01 15:00:00",
+					"2025-06-01 b 2025-06-01 10:00:00",
+					"2025-06-02 a 2025-06-02 09:00:00",
+				}),
+			)
+		})
+	})
+
+	Describe("ImportReports", func() {
+		DescribeTable("rejects the reports with an implausible time",
+			func(t time.Time, valid bool) {
+				data, err := json.Marshal(report("a", "0.55.0", "linux", "amd64"))
+				Expect(err).NotTo(HaveOccurred())
+				stored, err := db.ImportReports(ctx, dbConn, []db.RawReport{{ID: "a", Time: t, Data: string(data), Country: "FR"}})
+				Expect(err).NotTo(HaveOccurred())
+
+				rejected := queryStrings(dbConn, `SELECT reason || ' ' || country FROM insights_rejected`)
+				count := queryStrings(dbConn, `SELECT COUNT(*) FROM insights`)
+				if valid {
+					Expect(stored).To(Equal(int64(1)))
+					Expect(count).To(Equal([]string{"1"}))
+					Expect(rejected).To(BeEmpty())
+				} else {
+					Expect(stored).To(BeZero())
+					Expect(count).To(Equal([]string{"0"}))
+					Expect(rejected).To(Equal([]string{db.RejectedInvalidTime + " FR"}))
+				}
+			},
+			Entry("now", time.Now(), true),
+			Entry("ahead, within the clock skew", time.Now().Add(consts.MaxClockSkew-time.Minute), true),
+			Entry("ahead, beyond the clock skew", time.Now().Add(consts.MaxClockSkew+time.Minute), false),
+			Entry("when Navidrome started sending reports", time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), true),
+			Entry("before Navidrome started sending reports", time.Date(2024, 11, 30, 23, 59, 59, 0, time.UTC), false),
+		)
+	})
+
+	Describe("SelectRawReportsPage", func() {
+		BeforeEach(func() {
+			for _, r := range []struct{ id, time string }{
+				{"a", "2025-06-01 10:00:00"},
+				{"b", "2025-06-02 10:00:00"},
+				{"c", "2025-06-01 12:00:00"},
+				{"d", "2025-06-03 10:00:00"},
+				{"e", "2025-06-02 23:59:59"},
+			} {
+				Expect(db.SaveReport(ctx, dbConn, report(r.id, "0.55.0", "linux", "amd64"), at(r.time), "")).To(Succeed())
+			}
+		})
+
+		DescribeTable("pages through the reports of a date range, in insertion order",
+			func(from, to time.Time, limit int, expected [][]string) {
+				var pages [][]string
+				var after int64
+				for {
+					reports, last, err := db.SelectRawReportsPage(ctx, dbConn, from, to, after, limit)
+					Expect(err).NotTo(HaveOccurred())
+					var ids []string
+					for _, r := range reports {
+						ids = append(ids, r.ID)
+					}
+					pages = append(pages, ids)
+					if last == 0 {
+						break
+					}
+					after = last
+				}
+				Expect(pages).To(Equal(expected))
+			},
+			Entry("unbounded", time.Time{}, time.Time{}, 2, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}),
+			Entry("from a date", time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), time.Time{}, 2, [][]string{{"b", "d"}, {"e"}}),
+			Entry("up to a date, inclusive", time.Time{}, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), 2, [][]string{{"a", "b"}, {"c", "e"}, nil}),
+			Entry("a single day", time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), 5, [][]string{{"b", "e"}}),
+			Entry("no reports", time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), time.Time{}, 2, [][]string{nil}),
+		)
+	})
+})
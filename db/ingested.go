@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// ingested_days tracks, for each day with reports, when its last report was stored (ingested) and when it
+// was last summarized. Reports are usually stored on the day they are received, but imports (cmd/import,
+// cmd/anonymize) store reports for past days, which must then be summarized again (see SelectStaleDates).
+// Created by the baseline migration (migrations/0001_baseline.sql)
+
+// upsertIngestedDayQuery records that a report of a day was stored at the given time
+const upsertIngestedDayQuery = `
+INSERT INTO ingested_days (date, ingested) VALUES (date(?), ?)
+ON CONFLICT(date) DO UPDATE SET ingested = MAX(ingested, excluded.ingested)`
+
+// markIngested records that reports of the days of the given times were stored now. Called right before
+// committing, as the reports are only visible to summarize runs once committed
+func markIngested(ctx context.Context, tx *sql.Tx, times ...string) error {
+	now := time.Now().UTC().Format(consts.DateTimeFormat)
+	for _, t := range times {
+		if _, err := tx.ExecContext(ctx, upsertIngestedDayQuery, t, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillIngestedDays populates the ingested_days table from existing reports, when it is empty. Only the
+// last consts.SummarizeLookbackDays days are left to be summarized again, the older ones are considered
+// finalized, as the oldest can be partially purged
+func backfillIngestedDays(db *sql.DB) error {
+	var count int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM ingested_days`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec(`
+INSERT INTO ingested_days (date, ingested, summarized)
+SELECT date(time), MAX(time), CASE WHEN date(time) < date('now', ?) THEN datetime(date(time), '+1 day') END
+FROM insights GROUP BY date(time)`, fmt.Sprintf("-%d days", consts.SummarizeLookbackDays-1))
+	return err
+}
+
+// MarkSummarized records that the reports of a date were summarized, by a run started at the given time
+func MarkSummarized(ctx context.Context, db *sql.DB, date, started time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE ingested_days SET summarized = ? WHERE date = date(?)`,
+		started.UTC().Format(consts.DateTimeFormat), date.Format(consts.DateFormat))
+	return err
+}
+
+// SelectStaleDates returns the dates whose summary is missing or outdated, most recent first: never
+// summarized, summarized before the day was over (so the summary is not finalized), or that received
+// reports after their last summarize run started (late reports, e.g. imported). Reports stored up to
+// consts.LateReportsMargin before the run started count as late, as they may not have been committed yet
+func SelectStaleDates(ctx context.Context, db *sql.DB) ([]time.Time, error) {
+	margin := fmt.Sprintf("-%d seconds", int(consts.LateReportsMargin.Seconds()))
+	rows, err := db.QueryContext(ctx, `
+SELECT date(date) FROM ingested_days
+WHERE summarized IS NULL OR summarized < datetime(date, '+1 day') OR ingested >= datetime(summarized, ?)
+ORDER BY date DESC`, margin)
+	if err != nil {
+		return nil, fmt.Errorf("querying stale dates: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var dates []time.Time
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, fmt.Errorf("scanning date: %w", err)
+		}
+		t, err := time.Parse(consts.DateFormat, d)
+		if err != nil {
+			return nil, fmt.Errorf("parsing date %s: %w", d, err)
+		}
+		dates = append(dates, t)
+	}
+	return dates, rows.Err()
+}
+
+// MarkSampled records that only a sample of the instances (see summary.InSample) have their reports stored
+// for the day of the given time, from now on. The lowest rate of the day wins
+func MarkSampled(ctx context.Context, db *sql.DB, t time.Time, rate float64) error {
+	_, err := db.ExecContext(ctx, `
+INSERT INTO ingested_days (date, ingested, sample_rate) VALUES (date(?), ?, ?)
+ON CONFLICT(date) DO UPDATE SET sample_rate = MIN(COALESCE(sample_rate, 1), excluded.sample_rate)`,
+		t.UTC().Format(consts.DateFormat), t.UTC().Format(consts.DateTimeFormat), rate)
+	return err
+}
+
+// SampleRate returns the sample rate of the ingestion for a date (see MarkSampled), or 0 if it was not sampled
+func SampleRate(ctx context.Context, db *sql.DB, date time.Time) (float64, error) {
+	var rate sql.NullFloat64
+	err := db.QueryRowContext(ctx, `SELECT sample_rate FROM ingested_days WHERE date = date(?)`,
+		date.Format(consts.DateFormat)).Scan(&rate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return rate.Float64, err
+}
+
+// CountReports returns the number of reports stored for the date
+func CountReports(ctx context.Context, db *sql.DB, date time.Time) (int64, error) {
+	var count int64
+	d := date.Format(consts.DateFormat)
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM insights WHERE time >= date(?) AND time < date(?, '+1 day')`, d, d).Scan(&count)
+	return count, err
+}
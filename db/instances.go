@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// instances records the first and last time each instance reported. Created by the baseline migration
+// (migrations/0001_baseline.sql)
+
+// upsertInstanceQuery records the first and last time an instance reported
+const upsertInstanceQuery = `
+INSERT INTO instances (id, first_seen, last_seen) VALUES (?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+	first_seen = MIN(first_seen, excluded.first_seen),
+	last_seen = MAX(last_seen, excluded.last_seen)`
+
+// backfillInstances populates the instances table from existing reports, when it is empty.
+// Instances first seen before the retention period will have their first_seen set to their oldest retained report.
+func backfillInstances(db *sql.DB) error {
+	var count int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM instances`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec(`
+INSERT INTO instances (id, first_seen, last_seen)
+SELECT id, MIN(time), MAX(time) FROM insights GROUP BY id`)
+	return err
+}
+
+// RebuildInstances recreates the instances, instance_versions and active_days tables from all reports in
+// the database
+func RebuildInstances(db *sql.DB) error {
+	if _, err := db.Exec(`DELETE FROM instances`); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`DELETE FROM instance_versions`); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`DELETE FROM active_days`); err != nil {
+		return err
+	}
+	if err := backfillInstances(db); err != nil {
+		return err
+	}
+	if err := backfillInstanceVersions(db); err != nil {
+		return err
+	}
+	return backfillActiveDays(db)
+}
+
+// CountNewInstances returns the number of instances that reported for the first time on the given date
+func CountNewInstances(ctx context.Context, db *sql.DB, date time.Time) (int64, error) {
+	var count int64
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM instances WHERE date(first_seen) = date(?)`,
+		date.Format(consts.DateFormat)).Scan(&count)
+	return count, err
+}
+
+// CountChurnedInstances returns the number of instances whose last report was ChurnDays before the given date.
+// These are instances that stopped reporting, and are accounted as churned on the given date.
+func CountChurnedInstances(ctx context.Context, db *sql.DB, date time.Time) (int64, error) {
+	var count int64
+	lastSeen := date.AddDate(0, 0, -consts.ChurnDays).Format(consts.DateFormat)
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM instances WHERE date(last_seen) = date(?)`, lastSeen).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,38 @@
+package db
+
+import "database/sql"
+
+// latest_reports references the latest report of each instance per day, by (id, time). It is kept up to
+// date on insert, so SelectData doesn't need to find the MAX(time) of each instance in the insights table.
+// Rows are referenced by (id, time) instead of rowid, as VACUUM can renumber rowids.
+// Created by the baseline migration (migrations/0001_baseline.sql)
+
+// upsertLatestReportQuery records a report as the latest of its instance for the day, unless a newer one exists.
+// Reports with the same time replace the previous one, as in the insights table the last inserted wins
+const upsertLatestReportQuery = `
+INSERT INTO latest_reports (date, id, time) VALUES (date(?), ?, ?)
+ON CONFLICT(date, id) DO UPDATE SET time = excluded.time
+WHERE excluded.time >= latest_reports.time`
+
+// backfillLatestReports populates the latest_reports table from existing reports, when it is empty
+func backfillLatestReports(db *sql.DB) error {
+	var count int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM latest_reports`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec(`
+INSERT INTO latest_reports (date, id, time)
+SELECT date(time), id, MAX(time) FROM insights GROUP BY date(time), id`)
+	return err
+}
+
+// RebuildLatestReports recreates the latest_reports table from all reports in the database
+func RebuildLatestReports(db *sql.DB) error {
+	if _, err := db.Exec(`DELETE FROM latest_reports`); err != nil {
+		return err
+	}
+	return backfillLatestReports(db)
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// migrations are the schema changes, applied in order of their version: the number prefixing their file
+// name (e.g. 0002_insights_rejected.sql). Applied migrations must never change, add a new one instead
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// schema_version records the migrations applied to the database
+const createSchemaVersionTableQuery = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER NOT NULL PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	applied DATETIME default CURRENT_TIMESTAMP
+);
+`
+
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+// loadMigrations returns the embedded migrations, sorted by version
+func loadMigrations() ([]migration, error) {
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var list []migration
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, prefix)
+		}
+		query, err := migrations.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, migration{version: version, name: name, query: string(query)})
+	}
+	slices.SortFunc(list, func(a, b migration) int { return a.version - b.version })
+	for i := 1; i < len(list); i++ {
+		if list[i].version == list[i-1].version {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", list[i-1].name, list[i].name)
+		}
+	}
+	return list, nil
+}
+
+// SchemaVersion returns the version of the last migration applied to the database (0 if none)
+func SchemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	return version, err
+}
+
+// checkSchemaCurrent fails unless the database was migrated to the latest schema, for the read-only opens
+// that can't migrate it (see OpenReadOnly)
+func checkSchemaCurrent(db *sql.DB) error {
+	list, err := loadMigrations()
+	if err != nil {
+		return fmt.Errorf("loading migrations: %w", err)
+	}
+	var current int
+	var migrated bool // No schema_version table before migrations were introduced
+	if err := db.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&migrated); err != nil {
+		return err
+	}
+	if migrated {
+		if current, err = SchemaVersion(db); err != nil {
+			return fmt.Errorf("reading schema version: %w", err)
+		}
+	}
+	if latest := list[len(list)-1].version; current != latest {
+		return fmt.Errorf("database schema version %d doesn't match the latest migration (%d), open it once with the server or a tool writing to it to migrate it", current, latest)
+	}
+	return nil
+}
+
+// Migrate applies the migrations missing from the database, each in its own transaction. Databases created
+// before migrations were introduced are upgraded to the baseline schema first. Called by OpenDB, so the
+// server and all tools opening a database migrate it. Fails if the database was migrated by a newer version
+func Migrate(db *sql.DB) error {
+	list, err := loadMigrations()
+	if err != nil {
+		return fmt.Errorf("loading migrations: %w", err)
+	}
+	if _, err := db.Exec(createSchemaVersionTableQuery); err != nil {
+		return err
+	}
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if latest := list[len(list)-1].version; current > latest {
+		return fmt.Errorf("database schema version %d is newer than the latest known migration (%d)", current, latest)
+	}
+	if current == 0 {
+		if err := upgradeLegacySchema(db); err != nil {
+			return fmt.Errorf("upgrading legacy schema: %w", err)
+		}
+	}
+
+	for _, m := range list {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("applying migration %s: %w", m.name, err)
+		}
+		log.Printf("Applied migration %s", m.name)
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(m.query); err != nil {
+		return err
+	}
+	// Fails if another process applied it concurrently, rolling back this one
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// upgradeLegacySchema adds the columns missing from an insights table created before migrations were
+// introduced (by older versions, or by cmd/consolidate for bulk imports), as the CREATE TABLE IF NOT
+// EXISTS statements of the baseline migration leave existing tables untouched
+func upgradeLegacySchema(db *sql.DB) error {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'insights')`).Scan(&exists)
+	if err != nil || !exists {
+		return err
+	}
+	// Databases created before the country column was introduced
+	if err := addColumnIfMissing(db, "insights", "country", "VARCHAR"); err != nil {
+		return err
+	}
+	for _, c := range reportColumns {
+		if err := addColumnIfMissing(db, "insights", c.name, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
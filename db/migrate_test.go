@@ -0,0 +1,75 @@
+package db_test
+
+import (
+	"database/sql"
+	"path/filepath"
+
+	"github.com/navidrome/insights/db"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrate", func() {
+	var dbConn *sql.DB
+
+	BeforeEach(func() {
+		dbConn = openMemoryDB()
+	})
+
+	DescribeTable("upgrades the database to the latest schema",
+		func(legacy string) {
+			if legacy != "" {
+				_, err := dbConn.Exec(legacy)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = dbConn.Exec(`INSERT INTO insights (id, time, data) VALUES ('a', '2025-06-01 10:00:00', '{}')`)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			Expect(db.Migrate(dbConn)).To(Succeed())
+			Expect(queryStrings(dbConn, `SELECT name FROM schema_version ORDER BY version`)).To(Equal([]string{
+				"0001_baseline", "0002_insights_rejected", "0003_sample_rate", "0004_received", "0005_instance_versions", "0006_active_days",
+			}))
+			Expect(queryStrings(dbConn, `SELECT name FROM pragma_table_info('insights')`)).To(ContainElements(
+				"country", "version", "os_type", "arch", "containerized", "tracks", "received",
+			))
+			if legacy != "" {
+				Expect(queryStrings(dbConn, `SELECT id FROM insights`)).To(Equal([]string{"a"}))
+			}
+
+			// Migrating again is a no-op
+			Expect(db.Migrate(dbConn)).To(Succeed())
+			Expect(db.SchemaVersion(dbConn)).To(Equal(6))
+		},
+		Entry("new database", ""),
+		Entry("legacy database without the country column",
+			`CREATE TABLE insights (id VARCHAR NOT NULL, time DATETIME default CURRENT_TIMESTAMP, data JSONB)`),
+		Entry("legacy database with the country column",
+			`CREATE TABLE insights (id VARCHAR NOT NULL, time DATETIME default CURRENT_TIMESTAMP, data JSONB, country VARCHAR)`),
+	)
+
+	It("refuses a database migrated by a newer version", func() {
+		Expect(db.Migrate(dbConn)).To(Succeed())
+		_, err := dbConn.Exec(`INSERT INTO schema_version (version, name) VALUES (7, '0007_future')`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(db.Migrate(dbConn)).To(MatchError("database schema version 7 is newer than the latest known migration (6)"))
+	})
+
+	It("refuses read-only opens of databases not migrated to the latest schema", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "insights.db")
+		legacy, err := sql.Open("sqlite3", "file:"+path)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = legacy.Exec(`CREATE TABLE insights (id VARCHAR NOT NULL, time DATETIME, data JSONB)`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(legacy.Close()).To(Succeed())
+
+		_, err = db.OpenReadOnly(path)
+		Expect(err).To(MatchError(ContainSubstring("doesn't match the latest migration (6)")))
+
+		dbConn, err := db.OpenDB(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dbConn.Close()).To(Succeed())
+		dbConn, err = db.OpenReadOnly(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dbConn.Close()).To(Succeed())
+	})
+})
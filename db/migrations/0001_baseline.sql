@@ -0,0 +1,48 @@
+-- Schema of the databases created before migrations were introduced. Databases created by older
+-- versions are upgraded to it first (see upgradeLegacySchema), so all statements must be idempotent
+
+CREATE TABLE IF NOT EXISTS insights (
+	id VARCHAR NOT NULL,
+	time DATETIME default CURRENT_TIMESTAMP,
+	data JSONB,
+	country VARCHAR,
+	version VARCHAR,
+	os_type VARCHAR,
+	arch VARCHAR,
+	containerized BOOLEAN,
+	tracks INTEGER
+);
+CREATE INDEX IF NOT EXISTS insights_time ON insights(time);
+CREATE INDEX IF NOT EXISTS insights_id_time ON insights(id, time);
+CREATE INDEX IF NOT EXISTS insights_version ON insights(version);
+CREATE INDEX IF NOT EXISTS insights_os_arch ON insights(os_type, arch);
+
+CREATE TABLE IF NOT EXISTS blocked_instances (
+	id VARCHAR NOT NULL PRIMARY KEY,
+	reason VARCHAR,
+	time DATETIME default CURRENT_TIMESTAMP
+);
+
+-- First and last time each instance reported, used for new/churned counts
+CREATE TABLE IF NOT EXISTS instances (
+	id VARCHAR NOT NULL PRIMARY KEY,
+	first_seen DATETIME NOT NULL,
+	last_seen DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS instances_first_seen ON instances(first_seen);
+CREATE INDEX IF NOT EXISTS instances_last_seen ON instances(last_seen);
+
+-- Latest report of each instance per day, by (id, time), read by SelectData
+CREATE TABLE IF NOT EXISTS latest_reports (
+	date DATE NOT NULL,
+	id VARCHAR NOT NULL,
+	time DATETIME NOT NULL,
+	PRIMARY KEY (date, id)
+) WITHOUT ROWID;
+
+-- When the last report of each day was stored, and when the day was last summarized
+CREATE TABLE IF NOT EXISTS ingested_days (
+	date DATE NOT NULL PRIMARY KEY,
+	ingested DATETIME NOT NULL,
+	summarized DATETIME
+) WITHOUT ROWID;
@@ -0,0 +1,11 @@
+-- Reports moved out of the insights table by QuarantineReports (cmd/check), with the reason they were
+-- rejected, so they can be inspected (and fixed and reimported) later. The first cmd/check versions
+-- created it on demand, hence IF NOT EXISTS
+CREATE TABLE IF NOT EXISTS insights_rejected (
+	id VARCHAR,
+	time DATETIME,
+	data JSONB,
+	country VARCHAR,
+	reason VARCHAR NOT NULL,
+	rejected DATETIME default CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+-- Fraction of the instances whose reports were stored for the day, when the ingestion was sampled because
+-- of a traffic spike (NULL when all reports were stored)
+ALTER TABLE ingested_days ADD COLUMN sample_rate REAL;
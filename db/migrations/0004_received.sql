@@ -0,0 +1,3 @@
+-- When the server stored each report. Usually its time, but imported reports keep their original time,
+-- and reports stored before this column was introduced have none
+ALTER TABLE insights ADD COLUMN received DATETIME;
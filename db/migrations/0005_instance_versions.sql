@@ -0,0 +1,10 @@
+-- First time each instance reported each stable release (x.y.z), kept when the reports are purged so the
+-- time instances take to upgrade can be measured (see UpgradeTimes). Filled from the existing reports by
+-- OpenDB (backfillInstanceVersions)
+CREATE TABLE IF NOT EXISTS instance_versions (
+	id VARCHAR NOT NULL,
+	release VARCHAR NOT NULL,
+	first_seen DATETIME NOT NULL,
+	PRIMARY KEY (id, release)
+);
+CREATE INDEX IF NOT EXISTS instance_versions_release ON instance_versions(release);
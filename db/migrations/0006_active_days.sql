@@ -0,0 +1,8 @@
+-- Days each instance reported, kept longer than the reports so the distinct instances of the last
+-- consts.MonthlyActiveDays can be counted for any date still summarized from the database (see
+-- CountActiveInstances). Filled from the existing reports by OpenDB (backfillActiveDays)
+CREATE TABLE IF NOT EXISTS active_days (
+	date DATE NOT NULL,
+	id VARCHAR NOT NULL,
+	PRIMARY KEY (date, id)
+);
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// stableReleaseRegex matches the stable release versions reported by instances, like "0.54.2 (0b184893)"
+var stableReleaseRegex = regexp.MustCompile(`^(\d+\.\d+\.\d+) \(`)
+
+// upsertInstanceVersionQuery records the first time an instance reported a release
+const upsertInstanceVersionQuery = `
+INSERT INTO instance_versions (id, release, first_seen) VALUES (?, ?, ?)
+ON CONFLICT(id, release) DO UPDATE SET first_seen = MIN(first_seen, excluded.first_seen)`
+
+// recordInstanceVersion records the release of a report at ts, if it is a stable release
+func recordInstanceVersion(ctx context.Context, tx *sql.Tx, id, version, ts string) error {
+	m := stableReleaseRegex.FindStringSubmatch(version)
+	if m == nil {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, upsertInstanceVersionQuery, id, m[1], ts)
+	return err
+}
+
+// backfillInstanceVersions populates the instance_versions table from existing reports, when it is empty.
+// Like for the instances table, releases first reported before the retention period have their
+// first_seen set to their oldest retained report
+func backfillInstanceVersions(db *sql.DB) error {
+	var count int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM instance_versions`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec(`
+INSERT INTO instance_versions (id, release, first_seen)
+SELECT id, release, MIN(time) FROM (
+	SELECT id, substr(version, 1, instr(version, ' (') - 1) AS release, time FROM insights
+	WHERE version GLOB '[0-9]*.[0-9]*.[0-9]* (*'
+)
+WHERE release NOT GLOB '*[^0-9.]*'
+GROUP BY id, release`)
+	return err
+}
+
+// ReleaseUpgrades is how long the instances took to upgrade to a release
+type ReleaseUpgrades struct {
+	Release    string    `json:"release"`
+	Released   time.Time `json:"released"`   // Release date, or the first time an instance reported it when unknown
+	Instances  int64     `json:"instances"`  // Instances that reported the release
+	Upgraded   int64     `json:"upgraded"`   // Instances that reported a lower release before, excluding new installations and downgrades
+	MedianDays float64   `json:"medianDays"` // Median days between the release date and the upgraded instances adopting it
+	P90Days    float64   `json:"p90Days"`    // 90th percentile of the same days, the slow upgraders
+}
+
+// UpgradeTimes returns how long instances took to upgrade to each release, sorted by release date. The
+// release dates are taken from releaseDates when present. Instances that reported a lower release (by
+// semver) before are the ones that upgraded; the ones whose first release was this one are new
+// installations, and the ones that reported a higher release before downgraded
+func UpgradeTimes(ctx context.Context, db *sql.DB, releaseDates map[string]time.Time) ([]ReleaseUpgrades, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, release, first_seen FROM instance_versions ORDER BY id, first_seen`)
+	if err != nil {
+		return nil, fmt.Errorf("querying instance versions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	type adoption struct {
+		firstSeen time.Time
+		upgraded  bool
+	}
+	type prior struct {
+		version   *semver.Version
+		firstSeen time.Time
+	}
+	byRelease := map[string][]adoption{}
+	var id string
+	var priors []prior // Releases reported by the instance id, in the order it first reported them
+	for rows.Next() {
+		var rowID, release string
+		var a adoption
+		if err := rows.Scan(&rowID, &release, &a.firstSeen); err != nil {
+			return nil, fmt.Errorf("scanning instance versions: %w", err)
+		}
+		if rowID != id {
+			id, priors = rowID, priors[:0]
+		}
+		version, err := semver.StrictNewVersion(release)
+		if err != nil {
+			return nil, fmt.Errorf("parsing release %q of %s: %w", release, id, err)
+		}
+		a.upgraded = slices.ContainsFunc(priors, func(p prior) bool {
+			return p.firstSeen.Before(a.firstSeen) && p.version.LessThan(version)
+		})
+		byRelease[release] = append(byRelease[release], a)
+		priors = append(priors, prior{version: version, firstSeen: a.firstSeen})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	upgrades := make([]ReleaseUpgrades, 0, len(byRelease))
+	for release, adoptions := range byRelease {
+		u := ReleaseUpgrades{Release: release, Instances: int64(len(adoptions))}
+		// Sorted by first_seen, the first adoption is the first time the release was reported
+		slices.SortFunc(adoptions, func(a, b adoption) int { return a.firstSeen.Compare(b.firstSeen) })
+		released, ok := releaseDates[release]
+		if !ok {
+			released = adoptions[0].firstSeen.UTC().Truncate(24 * time.Hour)
+		}
+		u.Released = released
+		var days []float64
+		for _, a := range adoptions {
+			if a.upgraded {
+				days = append(days, max(0, a.firstSeen.Sub(released).Hours()/24))
+			}
+		}
+		u.Upgraded = int64(len(days))
+		if len(days) > 0 {
+			slices.Sort(days)
+			u.MedianDays = percentile(days, 0.5)
+			u.P90Days = percentile(days, 0.9)
+		}
+		upgrades = append(upgrades, u)
+	}
+	slices.SortFunc(upgrades, func(a, b ReleaseUpgrades) int {
+		return cmp.Or(a.Released.Compare(b.Released), cmp.Compare(a.Release, b.Release))
+	})
+	return upgrades, nil
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating between the closest ranks,
+// rounded to a tenth of a day
+func percentile(sorted []float64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(rank)
+	value := sorted[lower]
+	if lower+1 < len(sorted) {
+		value += (sorted[lower+1] - sorted[lower]) * (rank - float64(lower))
+	}
+	return math.Round(value*10) / 10
+}
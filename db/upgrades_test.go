@@ -0,0 +1,44 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/navidrome/insights/db"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UpgradeTimes", func() {
+	var dbConn *sql.DB
+
+	BeforeEach(func() {
+		dbConn = openMemoryDB()
+		Expect(db.Migrate(dbConn)).To(Succeed())
+	})
+
+	DescribeTable("counts the instances that reported a lower release before as upgraded",
+		func(releases []string, expected map[string]int64) {
+			// Releases reported by instance a, one day apart, and b installing each of them
+			for i, release := range releases {
+				_, err := dbConn.Exec(`INSERT INTO instance_versions (id, release, first_seen) VALUES
+('a', ?1, datetime('2025-06-01', ?2 || ' days')), ('b' || ?2, ?1, datetime('2025-06-01', ?2 || ' days'))`, release, i)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			upgrades, err := db.UpgradeTimes(context.Background(), dbConn, nil)
+			Expect(err).NotTo(HaveOccurred())
+			upgraded := map[string]int64{}
+			for _, u := range upgrades {
+				Expect(u.Instances).To(Equal(int64(2)))
+				upgraded[u.Release] = u.Upgraded
+			}
+			Expect(upgraded).To(Equal(expected))
+		},
+		Entry("new installation", []string{"0.55.0"}, map[string]int64{"0.55.0": 0}),
+		Entry("upgrades", []string{"0.54.2", "0.55.0", "0.55.1"}, map[string]int64{"0.54.2": 0, "0.55.0": 1, "0.55.1": 1}),
+		Entry("upgrade to a two digit minor", []string{"0.9.0", "0.10.0"}, map[string]int64{"0.9.0": 0, "0.10.0": 1}),
+		Entry("downgrade", []string{"0.55.1", "0.55.0"}, map[string]int64{"0.55.1": 0, "0.55.0": 0}),
+		Entry("upgrade after a downgrade", []string{"0.55.1", "0.55.0", "0.56.0"}, map[string]int64{"0.55.1": 0, "0.55.0": 0, "0.56.0": 1}),
+	)
+})
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"log"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// WindowReport holds the reportColumns of the latest report of an instance in a time window (see
+// SelectWindow). The full report is only set when requested, as it needs to be decoded
+type WindowReport struct {
+	Version       string         `json:"version"`
+	OSType        string         `json:"osType"`
+	Arch          string         `json:"arch"`
+	Containerized bool           `json:"containerized"`
+	Tracks        int64          `json:"tracks"`
+	Data          *insights.Data `json:"data,omitempty"`
+}
+
+// SelectWindow returns the latest report of each instance reporting in the (from, to] window. The full
+// reports are only read and decoded when withData is set. The iteration stops early if the context is
+// done, so callers must check ctx.Err() before using the results
+func SelectWindow(ctx context.Context, db *sql.DB, from, to time.Time, withData bool) (iter.Seq[WindowReport], error) {
+	dataColumn := "NULL"
+	if withData {
+		dataColumn = "i1.data"
+	}
+	query := `
+SELECT COALESCE(i1.version, ''), COALESCE(i1.os_type, ''), COALESCE(i1.arch, ''),
+       COALESCE(i1.containerized, 0), COALESCE(i1.tracks, 0), ` + dataColumn + `
+FROM insights i1
+INNER JOIN (
+    SELECT id, MAX(time) as max_time
+    FROM insights
+    WHERE time > ? AND time <= ?
+    GROUP BY id
+) i2 ON i1.id = i2.id AND i1.time = i2.max_time
+WHERE i1.time > ? AND i1.time <= ?
+ORDER BY i1.id, i1.time DESC;`
+
+	f := from.UTC().Format(consts.DateTimeFormat)
+	t := to.UTC().Format(consts.DateTimeFormat)
+	rows, err := db.QueryContext(ctx, query, f, t, f, t) //#nosec G202 -- dataColumn is one of two constants
+	if err != nil {
+		return nil, fmt.Errorf("querying data: %w", err)
+	}
+
+	return func(yield func(WindowReport) bool) {
+		defer func() { _ = rows.Close() }()
+		for rows.Next() {
+			var r WindowReport
+			var j []byte
+			if err := rows.Scan(&r.Version, &r.OSType, &r.Arch, &r.Containerized, &r.Tracks, &j); err != nil {
+				log.Printf("Error scanning row: %s", err)
+				return
+			}
+			if withData {
+				j, err := DecodeData(j)
+				if err != nil {
+					log.Printf("Error decoding data: %s", err)
+					return
+				}
+				r.Data = &insights.Data{}
+				if err := json.Unmarshal(j, r.Data); err != nil {
+					log.Printf("Error unmarshalling data: %s", err)
+					return
+				}
+			}
+			if !yield(r) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("Error reading rows: %s", err)
+		}
+	}, nil
+}
+
+// WindowCount is the number of instances whose latest report in a time window has the same reportColumns
+// (see CountWindow), with the aggregates of their library sizes
+type WindowCount struct {
+	Version       string
+	OSType        string
+	Arch          string
+	Containerized bool
+	Instances     int64
+	ZeroTracks    uint64 // Instances reporting no tracks
+	MillionPlus   uint64 // Instances reporting 1,000,000 tracks or more
+	WithTracks    int64  // Instances reporting tracks, the ones TracksSum and TracksMax are computed over
+	TracksSum     int64
+	TracksMax     int64
+}
+
+// CountWindow counts the latest report of each instance reporting in the (from, to] window, like
+// SelectWindow, grouped by their reportColumns. The aggregation is done by SQLite, so it is much faster
+// than iterating the reports on large databases
+func CountWindow(ctx context.Context, db *sql.DB, from, to time.Time) ([]WindowCount, error) {
+	query := `
+SELECT version, os_type, arch, containerized, COUNT(*),
+       SUM(tracks = 0), SUM(tracks >= 1000000), SUM(tracks > 0),
+       COALESCE(SUM(CASE WHEN tracks > 0 THEN tracks END), 0), MAX(tracks)
+FROM (
+    SELECT COALESCE(i1.version, '') AS version, COALESCE(i1.os_type, '') AS os_type,
+           COALESCE(i1.arch, '') AS arch, COALESCE(i1.containerized, 0) AS containerized,
+           COALESCE(i1.tracks, 0) AS tracks
+    FROM insights i1
+    INNER JOIN (
+        SELECT id, MAX(time) as max_time
+        FROM insights
+        WHERE time > ? AND time <= ?
+        GROUP BY id
+    ) i2 ON i1.id = i2.id AND i1.time = i2.max_time
+    WHERE i1.time > ? AND i1.time <= ?
+)
+GROUP BY version, os_type, arch, containerized;`
+
+	f := from.UTC().Format(consts.DateTimeFormat)
+	t := to.UTC().Format(consts.DateTimeFormat)
+	rows, err := db.QueryContext(ctx, query, f, t, f, t)
+	if err != nil {
+		return nil, fmt.Errorf("counting data: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var counts []WindowCount
+	for rows.Next() {
+		var c WindowCount
+		if err := rows.Scan(&c.Version, &c.OSType, &c.Arch, &c.Containerized, &c.Instances,
+			&c.ZeroTracks, &c.MillionPlus, &c.WithTracks, &c.TracksSum, &c.TracksMax); err != nil {
+			return nil, fmt.Errorf("scanning counts: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
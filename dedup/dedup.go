@@ -0,0 +1,156 @@
+// Package dedup tracks the (id, time) pairs of the reports already imported, so merging backups
+// (cmd/consolidate) or exports (cmd/import) never stores the same report twice
+package dedup
+
+import (
+	"crypto/md5" //#nosec G501 -- used only for deduplication, not security
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Key is a hash of a report's (id, time) pair
+type Key [16]byte
+
+// NewKey creates the key of a report, from its id and time as stored in the database
+func NewKey(id, t string) Key {
+	return md5.Sum([]byte(id + "\x00" + t)) //#nosec G401 -- used only for deduplication, not security
+}
+
+// LoadKeys adds the (id, time) pairs already present in the database to the key set
+func LoadKeys(db *sql.DB, keys KeySet) error {
+	rows, err := db.Query("SELECT id, time FROM insights")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id, t string
+		if err := rows.Scan(&id, &t); err != nil {
+			return err
+		}
+		if _, err := keys.Add(NewKey(id, t)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// KeySet tracks the keys of the reports already imported, to skip duplicates
+type KeySet interface {
+	// Add records the key and reports whether it was not already present
+	Add(key Key) (bool, error)
+	Len() int64
+	Close() error
+}
+
+// MemoryKeySet keeps all keys in memory. Fastest, but grows with the number of rows
+type MemoryKeySet map[Key]struct{}
+
+func (s MemoryKeySet) Add(key Key) (bool, error) {
+	if _, seen := s[key]; seen {
+		return false, nil
+	}
+	s[key] = struct{}{}
+	return true, nil
+}
+
+func (s MemoryKeySet) Len() int64 { return int64(len(s)) }
+
+func (s MemoryKeySet) Close() error { return nil }
+
+// diskKeySetCommitSize is the number of inserts per transaction in the on-disk key set
+const diskKeySetCommitSize = 100000
+
+// DiskKeySet keeps keys in a temporary SQLite table with a UNIQUE (primary key) constraint,
+// bounding memory usage at the cost of speed
+type DiskKeySet struct {
+	dir     string
+	db      *sql.DB
+	tx      *sql.Tx
+	stmt    *sql.Stmt
+	count   int64
+	pending int
+}
+
+// NewDiskKeySet creates an empty DiskKeySet in a temporary directory, removed by Close
+func NewDiskKeySet() (*DiskKeySet, error) {
+	dir, err := os.MkdirTemp("", "insights-dedup-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp directory: %w", err)
+	}
+	dbConn, err := sql.Open("sqlite3", filepath.Join(dir, "dedup.db")+"?_journal_mode=OFF&_synchronous=OFF")
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	dbConn.SetMaxOpenConns(1)
+	s := &DiskKeySet{dir: dir, db: dbConn}
+	if _, err := dbConn.Exec("CREATE TABLE seen (key BLOB PRIMARY KEY) WITHOUT ROWID"); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	if err := s.begin(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *DiskKeySet) begin() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO seen (key) VALUES (?)")
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	s.tx, s.stmt, s.pending = tx, stmt, 0
+	return nil
+}
+
+func (s *DiskKeySet) commit() error {
+	_ = s.stmt.Close()
+	err := s.tx.Commit()
+	s.tx, s.stmt = nil, nil
+	return err
+}
+
+func (s *DiskKeySet) Add(key Key) (bool, error) {
+	res, err := s.stmt.Exec(key[:])
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 0 {
+		return false, nil
+	}
+	s.count++
+	s.pending++
+	if s.pending >= diskKeySetCommitSize {
+		if err := s.commit(); err != nil {
+			return false, err
+		}
+		if err := s.begin(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (s *DiskKeySet) Len() int64 { return s.count }
+
+func (s *DiskKeySet) Close() error {
+	if s.tx != nil {
+		_ = s.commit()
+	}
+	err := s.db.Close()
+	_ = os.RemoveAll(s.dir)
+	return err
+}
@@ -0,0 +1,146 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/navidrome/insights/consts"
+)
+
+// Reporter forwards errors and panics to Sentry (SENTRY_DSN) and/or a generic webhook (ERROR_WEBHOOK_URL),
+// in addition to the logs. The same error is forwarded at most once per consts.ErrorReportInterval, so a
+// failing database doesn't flood the destinations with one event per request.
+// A nil Reporter is valid and does nothing.
+type Reporter struct {
+	hub        *sentry.Hub
+	webhookURL string
+	client     *http.Client
+
+	mu       sync.Mutex
+	reported map[string]time.Time // Last time each error message was forwarded
+}
+
+// FromEnv creates a Reporter for the configured destinations, or returns nil if none are configured
+func FromEnv() (*Reporter, error) {
+	dsn := os.Getenv("SENTRY_DSN")
+	webhookURL := os.Getenv("ERROR_WEBHOOK_URL")
+	if dsn == "" && webhookURL == "" {
+		return nil, nil
+	}
+	r := &Reporter{
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: consts.ErrorReportTimeout},
+		reported:   map[string]time.Time{},
+	}
+	if dsn != "" {
+		client, err := sentry.NewClient(sentry.ClientOptions{
+			Dsn:         dsn,
+			Environment: os.Getenv("SENTRY_ENVIRONMENT"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("configuring Sentry: %w", err)
+		}
+		r.hub = sentry.NewHub(client, sentry.NewScope())
+	}
+	return r, nil
+}
+
+// Error forwards an error, with tags describing where it happened (e.g. "task": "summarize")
+func (r *Reporter) Error(err error, tags map[string]string) {
+	if r == nil || err == nil || !r.shouldReport(err.Error()) {
+		return
+	}
+	if r.hub != nil {
+		r.hub.WithScope(func(scope *sentry.Scope) {
+			scope.SetTags(tags)
+			r.hub.CaptureException(err)
+		})
+	}
+	r.sendWebhook(err.Error(), tags)
+}
+
+// Panic forwards a recovered panic, with the stack trace of the goroutine that panicked
+func (r *Reporter) Panic(recovered any, stack []byte, tags map[string]string) {
+	msg := fmt.Sprintf("panic: %v", recovered)
+	if r == nil || !r.shouldReport(msg) {
+		return
+	}
+	if r.hub != nil {
+		r.hub.WithScope(func(scope *sentry.Scope) {
+			scope.SetTags(tags)
+			r.hub.Recover(recovered)
+		})
+	}
+	r.sendWebhook(msg+"\n\n"+string(stack), tags)
+}
+
+// Flush waits for the events sent to Sentry to be delivered, up to the given timeout
+func (r *Reporter) Flush(timeout time.Duration) {
+	if r == nil || r.hub == nil {
+		return
+	}
+	r.hub.Flush(timeout)
+}
+
+func (r *Reporter) shouldReport(msg string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if last, ok := r.reported[msg]; ok && now.Sub(last) < consts.ErrorReportInterval {
+		return false
+	}
+	// Forget old messages, so the map doesn't grow forever
+	for m, last := range r.reported {
+		if now.Sub(last) >= consts.ErrorReportInterval {
+			delete(r.reported, m)
+		}
+	}
+	r.reported[msg] = now
+	return true
+}
+
+// webhookPayload is the JSON posted to ERROR_WEBHOOK_URL
+type webhookPayload struct {
+	Message string            `json:"message"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	Time    time.Time         `json:"time"`
+}
+
+// sendWebhook posts the message in the background, so callers (request handlers) are never delayed
+func (r *Reporter) sendWebhook(msg string, tags map[string]string) {
+	if r.webhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(webhookPayload{Message: msg, Tags: tags, Time: time.Now().UTC()})
+	if err != nil {
+		log.Printf("Error encoding error report: %v", err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), consts.ErrorReportTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.webhookURL, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Error creating error report request: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := r.client.Do(req) //#nosec G704 -- URL is from controlled env var
+		if err != nil {
+			log.Printf("Error sending error report: %v", err)
+			return
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Error sending error report: webhook returned %s", resp.Status)
+		}
+	}()
+}
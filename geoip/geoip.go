@@ -0,0 +1,55 @@
+package geoip
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// Resolver maps IP addresses to ISO 3166-1 alpha-2 country codes using a MaxMind DB.
+// A nil Resolver is valid and always returns an empty country.
+type Resolver struct {
+	reader *maxminddb.Reader
+}
+
+func Open(path string) (*Resolver, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{reader: reader}, nil
+}
+
+func (r *Resolver) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
+
+// Country returns the 2-letter country code for the given remote address (with or without port),
+// or an empty string if it can't be determined. The address itself is never stored.
+func (r *Resolver) Country(remoteAddr string) string {
+	if r == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return ""
+	}
+
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := r.reader.Lookup(ip.Unmap()).Decode(&record); err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// Notifier posts pipeline events (daily summary done, chart export failed, instance drops) to chat webhooks,
+// read from NOTIFY_WEBHOOK_URLS (comma separated).
+// A nil Notifier is valid and does nothing.
+type Notifier struct {
+	urls    []string
+	dropPct float64
+	client  *http.Client
+}
+
+// FromEnv creates a Notifier for the configured webhooks, or returns nil if none are configured
+func FromEnv() (*Notifier, error) {
+	var urls []string
+	for u := range strings.SplitSeq(os.Getenv("NOTIFY_WEBHOOK_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	n := &Notifier{
+		urls:    urls,
+		dropPct: consts.DefaultNotifyDropPct,
+		client:  &http.Client{Timeout: consts.NotifyTimeout},
+	}
+	if v := os.Getenv("NOTIFY_DROP_PCT"); v != "" {
+		pct, err := strconv.ParseFloat(v, 64)
+		if err != nil || pct <= 0 || pct > 100 {
+			return nil, fmt.Errorf("invalid NOTIFY_DROP_PCT %q", v)
+		}
+		n.dropPct = pct
+	}
+	return n, nil
+}
+
+// DropPct returns the day-over-day instance count drop (in percent) that should be notified
+func (n *Notifier) DropPct() float64 {
+	if n == nil {
+		return 0
+	}
+	return n.dropPct
+}
+
+// payload is compatible with both Discord ("content") and Slack ("text") webhooks, which ignore
+// the other field
+type payload struct {
+	Content string `json:"content"`
+	Text    string `json:"text"`
+}
+
+// Send posts the message to all webhooks in the background. Delivery errors are only logged
+func (n *Notifier) Send(msg string) {
+	if n == nil {
+		return
+	}
+	if len(msg) > consts.MaxNotifyMessageLength {
+		msg = msg[:consts.MaxNotifyMessageLength-3] + "..."
+	}
+	body, err := json.Marshal(payload{Content: msg, Text: msg})
+	if err != nil {
+		log.Printf("Error encoding notification: %v", err)
+		return
+	}
+	for _, url := range n.urls {
+		go n.post(url, body)
+	}
+}
+
+func (n *Notifier) post(url string, body []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), consts.NotifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error creating notification request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req) //#nosec G704 -- URL is from controlled env var
+	if err != nil {
+		log.Printf("Error sending notification: %v", err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Error sending notification: webhook returned %s", resp.Status)
+	}
+}
@@ -0,0 +1,235 @@
+package replica
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/insights/db"
+)
+
+// Config holds the S3 destination for the replica, read from REPLICA_* env vars
+type Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Insecure  bool
+	Interval  time.Duration
+}
+
+// ConfigFromEnv reads the replica configuration. Replication is enabled only when REPLICA_S3_BUCKET is set
+func ConfigFromEnv() (Config, bool, error) {
+	cfg := Config{
+		Endpoint:  cmp.Or(os.Getenv("REPLICA_S3_ENDPOINT"), "s3.amazonaws.com"),
+		Region:    os.Getenv("REPLICA_S3_REGION"),
+		Bucket:    os.Getenv("REPLICA_S3_BUCKET"),
+		Prefix:    cmp.Or(os.Getenv("REPLICA_S3_PREFIX"), "insights"),
+		AccessKey: cmp.Or(os.Getenv("REPLICA_S3_ACCESS_KEY"), os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretKey: cmp.Or(os.Getenv("REPLICA_S3_SECRET_KEY"), os.Getenv("AWS_SECRET_ACCESS_KEY")),
+		Interval:  consts.DefaultReplicaInterval,
+	}
+	if cfg.Bucket == "" {
+		return cfg, false, nil
+	}
+	if v := os.Getenv("REPLICA_S3_INSECURE"); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, false, fmt.Errorf("invalid REPLICA_S3_INSECURE %q", v)
+		}
+		cfg.Insecure = insecure
+	}
+	if v := os.Getenv("REPLICA_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < time.Second {
+			return cfg, false, fmt.Errorf("invalid REPLICA_INTERVAL %q", v)
+		}
+		cfg.Interval = interval
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return cfg, false, errors.New("replication requires REPLICA_S3_ACCESS_KEY and REPLICA_S3_SECRET_KEY")
+	}
+	return cfg, true, nil
+}
+
+// Replicator continuously ships new reports to S3 as compressed segments, so a disk failure loses at
+// most one interval of reports instead of everything since the last daily backup. Segments are named
+// "<prefix>/segments/<afterRowID>-<lastRowID>.jsonl.gz" and hold one JSON report per line; daily backups
+// are uploaded to "<prefix>/backups/". Restoring means taking the latest backup and replaying the
+// segments inserted after it.
+// A nil Replicator is valid and does nothing.
+type Replicator struct {
+	dbConn    *sql.DB
+	client    *minio.Client
+	cfg       Config
+	statePath string
+	lastRowID int64
+}
+
+// state is persisted in DATA_FOLDER, so shipping resumes where it stopped after a restart
+type state struct {
+	LastRowID int64 `json:"lastRowID"`
+}
+
+// segmentRecord is the format of each line in a segment
+type segmentRecord struct {
+	ID      string          `json:"id"`
+	Time    time.Time       `json:"time"`
+	Data    json.RawMessage `json:"data"`
+	Country string          `json:"country,omitempty"`
+}
+
+func New(dbConn *sql.DB, cfg Config, dataFolder string) (*Replicator, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating S3 client: %w", err)
+	}
+	r := &Replicator{
+		dbConn:    dbConn,
+		client:    client,
+		cfg:       cfg,
+		statePath: filepath.Join(dataFolder, consts.ReplicaStateFile),
+	}
+	if err := r.loadState(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Replicator) loadState() error {
+	data, err := os.ReadFile(r.statePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading replica state: %w", err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("parsing replica state: %w", err)
+	}
+	r.lastRowID = s.LastRowID
+	return nil
+}
+
+func (r *Replicator) saveState() error {
+	data, err := json.Marshal(state{LastRowID: r.lastRowID})
+	if err != nil {
+		return err
+	}
+	tmp := r.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, consts.FilePermissions); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.statePath)
+}
+
+// Run ships new reports every interval, until the context is cancelled
+func (r *Replicator) Run(ctx context.Context) {
+	log.Printf("Replicating reports to s3://%s/%s every %s", r.cfg.Bucket, r.cfg.Prefix, r.cfg.Interval) //#nosec G706 -- config is from controlled env vars
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Ship(ctx); err != nil {
+			log.Printf("Error replicating reports: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ship uploads all reports inserted since the last shipped one, in segments of at most
+// consts.ReplicaSegmentRows reports. Returns the number of reports shipped
+func (r *Replicator) Ship(ctx context.Context) (int, error) {
+	// The database was replaced (e.g. restored from a backup), so rowids no longer match the state
+	maxRowID, err := db.MaxRowID(r.dbConn)
+	if err != nil {
+		return 0, err
+	}
+	if maxRowID < r.lastRowID {
+		log.Printf("Replica state is ahead of the database (rowid %d > %d), shipping all reports again", r.lastRowID, maxRowID)
+		r.lastRowID = 0
+	}
+
+	var shipped int
+	for {
+		reports, lastRowID, err := db.SelectRawReportsAfter(r.dbConn, r.lastRowID, consts.ReplicaSegmentRows)
+		if err != nil {
+			return shipped, err
+		}
+		if len(reports) == 0 {
+			return shipped, nil
+		}
+		if err := r.uploadSegment(ctx, reports, r.lastRowID, lastRowID); err != nil {
+			return shipped, err
+		}
+		r.lastRowID = lastRowID
+		if err := r.saveState(); err != nil {
+			return shipped, fmt.Errorf("saving replica state: %w", err)
+		}
+		shipped += len(reports)
+	}
+}
+
+func (r *Replicator) uploadSegment(ctx context.Context, reports []db.RawReport, afterRowID, lastRowID int64) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, report := range reports {
+		rec := segmentRecord{ID: report.ID, Time: report.Time.UTC(), Data: json.RawMessage(report.Data), Country: report.Country}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("encoding report %s: %w", report.ID, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	key := path.Join(r.cfg.Prefix, "segments", fmt.Sprintf("%012d-%012d.jsonl.gz", afterRowID, lastRowID))
+	_, err := r.client.PutObject(ctx, r.cfg.Bucket, key, &buf, int64(buf.Len()), minio.PutObjectOptions{
+		ContentType: "application/gzip",
+	})
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", key, err)
+	}
+	return nil
+}
+
+// UploadBackup uploads a backup file (see backup.Create), giving restores a starting point
+func (r *Replicator) UploadBackup(ctx context.Context, backupPath string) error {
+	if r == nil {
+		return nil
+	}
+	key := path.Join(r.cfg.Prefix, "backups", filepath.Base(backupPath))
+	_, err := r.client.FPutObject(ctx, r.cfg.Bucket, key, backupPath, minio.PutObjectOptions{
+		ContentType: "application/zip",
+	})
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", key, err)
+	}
+	return nil
+}
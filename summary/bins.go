@@ -0,0 +1,97 @@
+package summary
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+)
+
+// Bins holds the lower bounds of the ranges the library sizes are counted in (see mapToBins). Each range
+// goes up to the next bound, the last one is open
+type Bins struct {
+	Tracks  []int64 `json:"tracks,omitempty"`
+	Albums  []int64 `json:"albums,omitempty"`
+	Artists []int64 `json:"artists,omitempty"`
+}
+
+// DefaultBins returns the bins used when not configured
+func DefaultBins() Bins {
+	return Bins{
+		Tracks:  []int64{0, 1, 100, 500, 1000, 5000, 10000, 20000, 50000, 100000, 500000, 1000000},
+		Albums:  []int64{0, 1, 10, 50, 100, 500, 1000, 2000, 5000, 10000, 50000, 100000},
+		Artists: []int64{0, 1, 10, 50, 100, 500, 1000, 2000, 5000, 10000, 50000, 100000},
+	}
+}
+
+var (
+	binsMu      sync.RWMutex
+	libraryBins = DefaultBins()
+)
+
+// CurrentBins returns the bins in use
+func CurrentBins() Bins {
+	binsMu.RLock()
+	defer binsMu.RUnlock()
+	return libraryBins
+}
+
+func setBins(b Bins) {
+	binsMu.Lock()
+	defer binsMu.Unlock()
+	libraryBins = b
+}
+
+func binsFilePath() string {
+	return filepath.Join(config.DataFolder(), consts.BinsFile)
+}
+
+// LoadBins loads the bins from the bins file in DATA_FOLDER, a JSON object with the same keys as Bins.
+// Missing keys keep the default bins. Albums and artists share a chart, so they must have the same bins.
+// Changing the bins only affects the summaries computed from then on, the charts use the latest one. If
+// the file does not exist, the default bins are used. On error, the current bins are kept. Returns whether
+// the file was loaded
+func LoadBins() (bool, error) {
+	data, err := os.ReadFile(binsFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		setBins(DefaultBins())
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	b := DefaultBins()
+	if err := json.Unmarshal(data, &b); err != nil {
+		return false, fmt.Errorf("parsing %s: %w", consts.BinsFile, err)
+	}
+	for name, values := range map[string][]int64{"tracks": b.Tracks, "albums": b.Albums, "artists": b.Artists} {
+		if err := validBins(values); err != nil {
+			return false, fmt.Errorf("parsing %s: %s: %w", consts.BinsFile, name, err)
+		}
+	}
+	if !slices.Equal(b.Albums, b.Artists) {
+		return false, fmt.Errorf("parsing %s: albums and artists must have the same bins", consts.BinsFile)
+	}
+	setBins(b)
+	return true, nil
+}
+
+// validBins checks that the bins start at 0, so all values are counted, and are increasing
+func validBins(values []int64) error {
+	if len(values) == 0 || values[0] != 0 {
+		return errors.New("the first bin must be 0")
+	}
+	for i := 1; i < len(values); i++ {
+		if values[i] <= values[i-1] {
+			return fmt.Errorf("bin %d is not greater than the previous one", values[i])
+		}
+	}
+	return nil
+}
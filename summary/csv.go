@@ -0,0 +1,68 @@
+package summary
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/navidrome/insights/consts"
+)
+
+// WriteCSV writes the summaries as CSV, one row per day. Each OS found in any of the
+// summaries gets its own column, prefixed with "os:".
+func WriteCSV(w io.Writer, summaries []SummaryRecord) error {
+	osSet := make(map[string]struct{})
+	for _, s := range summaries {
+		for os := range s.Data.OS {
+			osSet[os] = struct{}{}
+		}
+	}
+	osNames := slices.Sorted(maps.Keys(osSet))
+
+	header := []string{"date", "instances", "activeUsers", "newInstances", "churnedInstances"}
+	for _, name := range []string{"tracks", "albums", "artists"} {
+		header = append(header, name+"Mean", name+"Median", name+"Max")
+	}
+	for _, os := range osNames {
+		header = append(header, "os:"+os)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		record := []string{
+			s.Time.Format(consts.DateFormat),
+			strconv.FormatInt(s.Data.NumInstances, 10),
+			strconv.FormatInt(s.Data.NumActiveUsers, 10),
+			strconv.FormatInt(s.Data.NewInstances, 10),
+			strconv.FormatInt(s.Data.ChurnedInstances, 10),
+		}
+		for _, stats := range []*Stats{s.Data.TrackStats, s.Data.AlbumStats, s.Data.ArtistStats} {
+			record = append(record, statsColumns(stats)...)
+		}
+		for _, os := range osNames {
+			record = append(record, strconv.FormatUint(s.Data.OS[os], 10))
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func statsColumns(stats *Stats) []string {
+	if stats == nil {
+		return []string{"", "", ""}
+	}
+	return []string{
+		fmt.Sprintf("%.2f", stats.Mean),
+		fmt.Sprintf("%.2f", stats.Median),
+		strconv.FormatInt(stats.Max, 10),
+	}
+}
@@ -0,0 +1,82 @@
+package summary
+
+import (
+	"cmp"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Difference is a value that differs between two summaries. Field is the JSON path of the value, with map
+// keys in brackets (e.g. "versions[0.54.0]", "trackStats.mean"). A nil value is missing from its summary
+type Difference struct {
+	Field string
+	A, B  any
+}
+
+// floatTolerance is the relative difference under which stats floats are considered equal, as they can
+// differ in the last digits depending on the summation order
+const floatTolerance = 1e-9
+
+// Diff compares two summaries field by field, returning their differences sorted by field
+func Diff(a, b Summary) []Difference {
+	var diffs []Difference
+	diffValues("", reflect.ValueOf(a), reflect.ValueOf(b), &diffs)
+	slices.SortFunc(diffs, func(x, y Difference) int { return cmp.Compare(x.Field, y.Field) })
+	return diffs
+}
+
+func diffValues(path string, a, b reflect.Value, diffs *[]Difference) {
+	switch a.Kind() {
+	case reflect.Struct:
+		t := a.Type()
+		for i := range t.NumField() {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValues(name, a.Field(i), b.Field(i), diffs)
+		}
+	case reflect.Pointer:
+		switch {
+		case a.IsNil() && b.IsNil():
+		case a.IsNil():
+			*diffs = append(*diffs, Difference{Field: path, B: b.Elem().Interface()})
+		case b.IsNil():
+			*diffs = append(*diffs, Difference{Field: path, A: a.Elem().Interface()})
+		default:
+			diffValues(path, a.Elem(), b.Elem(), diffs)
+		}
+	case reflect.Map:
+		// Missing and empty maps are the same, as empty maps are omitted from the JSON files
+		keys := map[string]struct{}{}
+		for _, m := range []reflect.Value{a, b} {
+			for _, k := range m.MapKeys() {
+				keys[k.String()] = struct{}{}
+			}
+		}
+		for k := range keys {
+			key := reflect.ValueOf(k)
+			va, vb := a.MapIndex(key), b.MapIndex(key)
+			field := path + "[" + k + "]"
+			switch {
+			case !va.IsValid():
+				*diffs = append(*diffs, Difference{Field: field, B: vb.Interface()})
+			case !vb.IsValid():
+				*diffs = append(*diffs, Difference{Field: field, A: va.Interface()})
+			default:
+				diffValues(field, va, vb, diffs)
+			}
+		}
+	case reflect.Float64:
+		x, y := a.Float(), b.Float()
+		if math.Abs(x-y) > floatTolerance*max(1, math.Abs(x), math.Abs(y)) {
+			*diffs = append(*diffs, Difference{Field: path, A: x, B: y})
+		}
+	default:
+		if !a.Equal(b) {
+			*diffs = append(*diffs, Difference{Field: path, A: a.Interface(), B: b.Interface()})
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package summary
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// Dimensions holds the values a single report contributes to a summary, after applying the version, OS,
+// player type and filesystem mappings. External stores (see the clickhouse package) keep them alongside
+// the raw report, so they can aggregate summaries with the same mappings as SummarizeData
+type Dimensions struct {
+	Version          string            `json:"version"`
+	OS               string            `json:"os"`
+	Distro           string            `json:"distro"`     // Only for non-containerized Linux
+	OSName           string            `json:"os_name"`    // Only for Windows and macOS, with OSVersion
+	OSVersion        string            `json:"os_version"` // Normalized, e.g. "Windows 11"
+	MusicFS          string            `json:"music_fs"`
+	DataFS           string            `json:"data_fs"`
+	PlayerTypes      map[string]uint64 `json:"player_types"`    // Active players of each type
+	Players          int64             `json:"players"`         // Total active players
+	PlayerVersions   []string          `json:"player_versions"` // "type/version" of the players with a version
+	FileSuffixes     []string          `json:"file_suffixes"`
+	Plugins          []string          `json:"plugins"`
+	PluginVersions   []string          `json:"plugin_versions"`
+	ConfigFlags      []string          `json:"config_flags"`
+	Features         []string          `json:"features"`
+	ScannerExtractor string            `json:"scanner_extractor"`
+}
+
+// ReportDimensions maps a report to its summary dimensions
+func ReportDimensions(data insights.Data) Dimensions {
+	d := Dimensions{
+		Version:          mapVersion(data),
+		OS:               mapOS(data),
+		MusicFS:          mapFS(data.FS.Music),
+		DataFS:           mapFS(data.FS.Data),
+		PlayerTypes:      map[string]uint64{},
+		ScannerExtractor: data.Config.ScannerExtractor,
+	}
+	if data.OS.Type == "linux" && !data.OS.Containerized {
+		d.Distro = data.OS.Distro
+	}
+	if osName, version := mapOSVersion(data); version != "" {
+		d.OSName, d.OSVersion = osName, version
+	}
+	d.Players = mapPlayerTypes(data, d.PlayerTypes)
+	playerVersions := map[string]map[string]uint64{}
+	mapPlayerVersions(data, playerVersions)
+	d.PlayerVersions = []string{}
+	for client, versions := range playerVersions {
+		for version := range versions {
+			d.PlayerVersions = append(d.PlayerVersions, client+"/"+version)
+		}
+	}
+	slices.Sort(d.PlayerVersions)
+
+	suffixes, plugins, versions, flags, features := map[string]uint64{}, map[string]uint64{}, map[string]uint64{}, map[string]uint64{}, map[string]uint64{}
+	mapFileSuffixes(data, suffixes)
+	mapPlugins(data, plugins, versions)
+	mapConfigFlags(data, flags)
+	mapFeatures(data, features)
+	d.FileSuffixes = sortedKeys(suffixes)
+	d.Plugins = sortedKeys(plugins)
+	d.PluginVersions = sortedKeys(versions)
+	d.ConfigFlags = sortedKeys(flags)
+	d.Features = sortedKeys(features)
+	return d
+}
+
+// sortedKeys returns the keys of m, sorted. Never nil, so empty lists are encoded as [] instead of null
+func sortedKeys(m map[string]uint64) []string {
+	keys := slices.AppendSeq(make([]string, 0, len(m)), maps.Keys(m))
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,84 @@
+package summary
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+var (
+	fsTypesMu sync.RWMutex
+	fsTypes   = fsMappings
+)
+
+func currentFSTypes() map[string]string {
+	fsTypesMu.RLock()
+	defer fsTypesMu.RUnlock()
+	return fsTypes
+}
+
+func setFSTypes(types map[string]string) {
+	fsTypesMu.Lock()
+	defer fsTypesMu.Unlock()
+	fsTypes = types
+}
+
+func fsTypesFilePath() string {
+	return filepath.Join(config.DataFolder(), consts.FSTypesFile)
+}
+
+// LoadFSTypes loads the filesystem type mappings from the filesystem types file in DATA_FOLDER, so new
+// filesystems can be labeled without a release. The file holds a JSON object mapping the reported type
+// (e.g. "unknown(0x2011bab0)") to its name, merged over the built-in fsMappings. If the file does not
+// exist, only the built-in mappings are used. On error, the current mappings are kept.
+// Returns the number of mappings loaded from the file
+func LoadFSTypes() (int, error) {
+	data, err := os.ReadFile(fsTypesFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		setFSTypes(fsMappings)
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var custom map[string]string
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", consts.FSTypesFile, err)
+	}
+
+	types := maps.Clone(fsMappings)
+	for k, v := range custom {
+		if k == "" || v == "" {
+			return 0, fmt.Errorf("parsing %s: empty type or name for %q", consts.FSTypesFile, k)
+		}
+		types[k] = v
+	}
+	setFSTypes(types)
+	return len(custom), nil
+}
+
+// CountUnmappedFS increments the count of each unrecognized filesystem type ("unknown(0x...)")
+// reported by the instance without a mapping, to help spot filesystems that should be labeled
+func CountUnmappedFS(data insights.Data, counts map[string]uint64) {
+	types := currentFSTypes()
+	seen := map[string]bool{}
+	for _, info := range []*insights.FSInfo{data.FS.Music, data.FS.Data} {
+		if info == nil || !strings.HasPrefix(info.Type, "unknown(") || seen[info.Type] {
+			continue
+		}
+		seen[info.Type] = true
+		if _, ok := types[info.Type]; !ok {
+			counts[info.Type]++
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package summary
+
+import "strconv"
+
+// histogramBucket returns the bucket of a value in the fine-grained histograms: the value rounded down to
+// one significant digit (0-9, 10, 20... 90, 100, 200...), so each decade has 9 buckets. Any bins whose
+// bounds have one significant digit (like the default Bins) can be computed exactly from the histogram,
+// even after the reports were purged
+func histogramBucket(v int64) int64 {
+	p := int64(1)
+	for v/p >= 10 {
+		p *= 10
+	}
+	return v / p * p
+}
+
+// mapToHistogram counts the value in its histogram bucket. Negative values are not counted
+func mapToHistogram(v int64, counters map[string]uint64) {
+	if key, ok := HistogramKey(v); ok {
+		counters[key]++
+	}
+}
+
+// HistogramKey returns the key of the histogram bucket of a value. Negative values have none
+func HistogramKey(v int64) (string, bool) {
+	if v < 0 {
+		return "", false
+	}
+	return strconv.FormatInt(histogramBucket(v), 10), true
+}
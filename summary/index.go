@@ -0,0 +1,83 @@
+package summary
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+)
+
+const indexFileName = "index.json"
+
+// summaryIndex maps each summary date (YYYY-MM-DD) to its file and instance count, so GetSummaries
+// can load summaries without walking the whole summaries tree
+type summaryIndex map[string]summaryIndexEntry
+
+type summaryIndexEntry struct {
+	Path      string `json:"path"` // Relative to the summaries folder
+	Instances int64  `json:"instances"`
+}
+
+// indexMu serializes summary writes and index updates/rebuilds, keeping the index consistent with the files
+var indexMu sync.Mutex
+
+func summariesDir() string {
+	return filepath.Join(config.DataFolder(), consts.SummariesDir)
+}
+
+func indexFilePath() string {
+	return filepath.Join(summariesDir(), indexFileName)
+}
+
+// loadIndex reads the index file. Returns nil if it does not exist
+func loadIndex() (summaryIndex, error) {
+	data, err := os.ReadFile(indexFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index summaryIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// saveIndex writes the index atomically, so readers never see a partially written file
+func saveIndex(index summaryIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	path := indexFilePath()
+	if err := os.MkdirAll(filepath.Dir(path), consts.DirPermissions); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, consts.FilePermissions); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// updateIndex records a saved summary in the index. Must be called with indexMu held. A missing
+// index is not created here, as it is rebuilt from scratch by the next GetSummaries call
+func updateIndex(date, filePath string, instances int64) error {
+	index, err := loadIndex()
+	if err != nil || index == nil {
+		return err
+	}
+	rel, err := filepath.Rel(summariesDir(), filePath)
+	if err != nil {
+		return err
+	}
+	index[date] = summaryIndexEntry{Path: rel, Instances: instances}
+	return saveIndex(index)
+}
@@ -0,0 +1,104 @@
+package summary
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OutlierCaps holds the max value of each statistic (keyed by the name of its summary field without the
+// "Stats" suffix, e.g. "track" for trackStats) included in it. Larger values, like the joke payloads
+// reporting billions of tracks, are excluded from the statistics so a handful of them can't dominate the
+// mean and standard deviation, and counted in Stats.Excluded. The bins and totals still count them
+type OutlierCaps map[string]int64
+
+// DefaultOutlierCaps returns the caps used when not configured, well above any real instance
+func DefaultOutlierCaps() OutlierCaps {
+	return OutlierCaps{
+		"track":      10_000_000,
+		"album":      1_000_000,
+		"artist":     1_000_000,
+		"playlist":   100_000,
+		"share":      100_000,
+		"radio":      100_000,
+		"library":    1_000,
+		"activeUser": 10_000,
+		"cpu":        1_024,
+		"mem":        1 << 20, // 1TB
+	}
+}
+
+// OutlierCapsFromEnv reads the caps from STATS_OUTLIER_CAPS, a comma-separated list of stat=max pairs
+// (e.g. "track=5000000,album=500000") overriding the defaults, or "off" to include all values
+func OutlierCapsFromEnv() (OutlierCaps, error) {
+	caps := DefaultOutlierCaps()
+	v := strings.TrimSpace(os.Getenv("STATS_OUTLIER_CAPS"))
+	if v == "" {
+		return caps, nil
+	}
+	if v == "off" {
+		return OutlierCaps{}, nil
+	}
+	for pair := range strings.SplitSeq(v, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if _, ok := caps[name]; !ok {
+			return nil, fmt.Errorf("invalid STATS_OUTLIER_CAPS: unknown stat %q", name)
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid STATS_OUTLIER_CAPS: invalid max %q for %s", value, name)
+		}
+		caps[name] = n
+	}
+	return caps, nil
+}
+
+var (
+	outlierCapsMu sync.RWMutex
+	outlierCaps   = DefaultOutlierCaps()
+)
+
+// ConfigureOutliers sets the caps used by the summaries computed from now on
+func ConfigureOutliers(caps OutlierCaps) {
+	outlierCapsMu.Lock()
+	defer outlierCapsMu.Unlock()
+	outlierCaps = caps
+}
+
+// ConfigureOutliersFromEnv configures the caps from the env var (see OutlierCapsFromEnv)
+func ConfigureOutliersFromEnv() error {
+	caps, err := OutlierCapsFromEnv()
+	if err != nil {
+		return err
+	}
+	ConfigureOutliers(caps)
+	return nil
+}
+
+// CurrentOutlierCaps returns the caps in use
+func CurrentOutlierCaps() OutlierCaps {
+	outlierCapsMu.RLock()
+	defer outlierCapsMu.RUnlock()
+	return outlierCaps
+}
+
+// calcStatsExcluding computes the statistics of the values up to limit (all values if limit is 0), recording
+// the number of values excluded
+func calcStatsExcluding(values []int64, limit int64) *Stats {
+	if limit <= 0 {
+		return calcStats(values)
+	}
+	kept := make([]int64, 0, len(values))
+	for _, v := range values {
+		if v <= limit {
+			kept = append(kept, v)
+		}
+	}
+	stats := calcStats(kept)
+	if excluded := int64(len(values) - len(kept)); excluded > 0 && stats != nil {
+		stats.Excluded = excluded
+	}
+	return stats
+}
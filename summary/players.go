@@ -0,0 +1,175 @@
+package summary
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/navidrome/insights/config"
+	"github.com/navidrome/insights/consts"
+	"github.com/navidrome/navidrome/core/metrics/insights"
+)
+
+// playerRule maps the ActivePlayers names matching the pattern to a player type. An empty type
+// discards the player
+type playerRule struct {
+	re  *regexp.Regexp
+	typ string
+}
+
+// playerTypeEntry is the format of each rule in the player types file
+type playerTypeEntry struct {
+	Pattern string `json:"pattern"`
+	Type    string `json:"type"`
+}
+
+var (
+	playerRulesMu sync.RWMutex
+	playerRules   = defaultPlayerRules()
+)
+
+// defaultPlayerRules returns the built-in mappings from playersTypes
+func defaultPlayerRules() []playerRule {
+	rules := make([]playerRule, 0, len(playersTypes))
+	for re, typ := range playersTypes {
+		rules = append(rules, playerRule{re: re, typ: typ})
+	}
+	return rules
+}
+
+func currentPlayerRules() []playerRule {
+	playerRulesMu.RLock()
+	defer playerRulesMu.RUnlock()
+	return playerRules
+}
+
+// matchPlayerType returns the type of the first rule matching the player name
+func matchPlayerType(rules []playerRule, name string) (string, bool) {
+	for _, r := range rules {
+		if r.re.MatchString(name) {
+			return r.typ, true
+		}
+	}
+	return "", false
+}
+
+// CountUnmappedPlayers increments the count of each ActivePlayers name that doesn't match any
+// mapping rule, to help spot new clients that should be added to the mappings
+func CountUnmappedPlayers(data insights.Data, counts map[string]uint64) {
+	rules := currentPlayerRules()
+	for p := range data.Library.ActivePlayers {
+		if _, ok := matchPlayerType(rules, p); !ok {
+			counts[p]++
+		}
+	}
+}
+
+// playerVersionRegex matches the version in a player name, like "Symfonium 12.3.1" or "NewClient/1.2":
+// digits after a separator, optionally prefixed with v
+var playerVersionRegex = regexp.MustCompile(`(?:^|[\s_/@-])v?(\d+)(?:\.(\d+))?(?:\.\d+)*`)
+
+// playerVersion splits a player name into the client name before its version, and the version grouped
+// by major ("12.x"), or by minor for 0.x versions ("0.54.x"). The version is empty if the name has none
+func playerVersion(name string) (string, string) {
+	m := playerVersionRegex.FindStringSubmatchIndex(name)
+	if m == nil {
+		return name, ""
+	}
+	client := strings.TrimSpace(name[:m[0]])
+	major := name[m[2]:m[3]]
+	if major == "0" && m[4] >= 0 {
+		return client, "0." + name[m[4]:m[5]] + ".x"
+	}
+	return client, major + ".x"
+}
+
+// mapPlayerVersions counts the instances using each version of each player type, for the players whose
+// name includes a version. Players without a type are counted under their name without the version
+func mapPlayerVersions(data insights.Data, versions map[string]map[string]uint64) {
+	rules := currentPlayerRules()
+	seen := map[[2]string]bool{}
+	for p := range data.Library.ActivePlayers {
+		client, version := playerVersion(p)
+		if version == "" {
+			continue
+		}
+		if t, ok := matchPlayerType(rules, p); ok {
+			client = t
+		}
+		if client == "" || seen[[2]string{client, version}] {
+			continue
+		}
+		seen[[2]string{client, version}] = true
+		if versions[client] == nil {
+			versions[client] = map[string]uint64{}
+		}
+		versions[client][version]++
+	}
+}
+
+// SplitPlayerVersion splits a Dimensions.PlayerVersions entry into its player type and version. The type
+// may contain slashes, the version never does
+func SplitPlayerVersion(entry string) (string, string) {
+	i := strings.LastIndex(entry, "/")
+	if i < 0 {
+		return entry, ""
+	}
+	return entry[:i], entry[i+1:]
+}
+
+func playerTypesFilePath() string {
+	return filepath.Join(config.DataFolder(), consts.PlayerTypesFile)
+}
+
+// LoadPlayerTypes loads the player type mappings from the player types file in DATA_FOLDER, so new
+// clients can be mapped without a release. The file holds a JSON list of {"pattern", "type"} rules,
+// checked in order before the built-in ones. A rule with the same pattern as a built-in one replaces it.
+// If the file does not exist, only the built-in rules are used. On error, the current rules are kept.
+// Returns the number of rules loaded from the file
+func LoadPlayerTypes() (int, error) {
+	data, err := os.ReadFile(playerTypesFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		setPlayerRules(defaultPlayerRules())
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var entries []playerTypeEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", consts.PlayerTypesFile, err)
+	}
+
+	rules := make([]playerRule, 0, len(entries)+len(playersTypes))
+	custom := make(map[string]bool, len(entries))
+	for i, e := range entries {
+		if e.Pattern == "" {
+			return 0, fmt.Errorf("parsing %s: rule %d has no pattern", consts.PlayerTypesFile, i+1)
+		}
+		re, err := regexp.Compile(e.Pattern)
+		if err != nil {
+			return 0, fmt.Errorf("parsing %s: rule %d: %w", consts.PlayerTypesFile, i+1, err)
+		}
+		rules = append(rules, playerRule{re: re, typ: e.Type})
+		custom[e.Pattern] = true
+	}
+	for _, r := range defaultPlayerRules() {
+		if !custom[r.re.String()] {
+			rules = append(rules, r)
+		}
+	}
+	setPlayerRules(rules)
+	return len(entries), nil
+}
+
+func setPlayerRules(rules []playerRule) {
+	playerRulesMu.Lock()
+	defer playerRulesMu.Unlock()
+	playerRules = rules
+}
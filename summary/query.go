@@ -0,0 +1,82 @@
+package summary
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MetricValue resolves a dotted metric path into the summary, with the JSON names of its fields (e.g.
+// "numInstances", "trackStats.mean", "versions.0.55.0", "osVersions.macOS.macOS 14"). The key of a
+// counts map is the rest of the path, so it can contain dots. Keys missing from a map count as zero,
+// while a missing stats value is returned as nil. Returns an error if the path does not resolve to a
+// number in the Summary structure, regardless of the data in this summary
+func MetricValue(s Summary, metric string) (*float64, error) {
+	if metric == "" {
+		return nil, fmt.Errorf("empty metric")
+	}
+	return resolveMetric(reflect.ValueOf(s), reflect.TypeOf(s), metric, metric)
+}
+
+// resolveMetric resolves the path into v, of type t. v is the zero Value when the data is missing, so the
+// rest of the path is still validated against the type
+func resolveMetric(v reflect.Value, t reflect.Type, path, metric string) (*float64, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		if v.IsValid() && v.IsNil() {
+			v = reflect.Value{}
+		} else if v.IsValid() {
+			v = v.Elem()
+		}
+		return resolveMetric(v, t.Elem(), path, metric)
+	case reflect.Struct:
+		if path == "" {
+			return nil, fmt.Errorf("metric %q is not a number", metric)
+		}
+		name, rest, _ := strings.Cut(path, ".")
+		for i := range t.NumField() {
+			if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); tag == name {
+				var fv reflect.Value
+				if v.IsValid() {
+					fv = v.Field(i)
+				}
+				return resolveMetric(fv, t.Field(i).Type, rest, metric)
+			}
+		}
+		return nil, fmt.Errorf("unknown field %q in metric %q", name, metric)
+	case reflect.Map:
+		if path == "" {
+			return nil, fmt.Errorf("metric %q is not a number", metric)
+		}
+		key, rest := path, ""
+		if t.Elem().Kind() == reflect.Map {
+			key, rest, _ = strings.Cut(path, ".")
+		}
+		if v.IsValid() {
+			v = v.MapIndex(reflect.ValueOf(key))
+			if !v.IsValid() {
+				v = reflect.Zero(t.Elem())
+			}
+		}
+		return resolveMetric(v, t.Elem(), rest, metric)
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		if path != "" {
+			return nil, fmt.Errorf("unknown field %q in metric %q", path, metric)
+		}
+		if !v.IsValid() {
+			return nil, nil
+		}
+		var f float64
+		switch t.Kind() {
+		case reflect.Int64:
+			f = float64(v.Int())
+		case reflect.Uint64:
+			f = float64(v.Uint())
+		default:
+			f = v.Float()
+		}
+		return &f, nil
+	default:
+		return nil, fmt.Errorf("metric %q is not a number", metric)
+	}
+}
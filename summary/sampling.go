@@ -0,0 +1,64 @@
+package summary
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"reflect"
+)
+
+// InSample reports whether an instance belongs to the sample of the given rate (0-1), the fraction of
+// instances kept when the ingestion is sampled. The decision only depends on a hash of the instance ID, so
+// an instance is either always or never sampled, and the samples of lower rates are included in higher ones
+func InSample(id string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	h := sha256.Sum256([]byte(id))
+	return float64(binary.BigEndian.Uint64(h[:8])) < rate*math.MaxUint64
+}
+
+// Scaled returns the summary of a sampled day (see SamplingRate) with its counts scaled by 1/SamplingRate,
+// estimating the counts of all the instances, so the charts and metrics don't show a drop on sampled days.
+// The instance counts of the breakdowns, NumInstances, NumActiveUsers and the library totals are scaled;
+// NewInstances, ChurnedInstances, WeeklyInstances and MonthlyInstances (not sampled) and the statistics are
+// kept. Summaries of days that were not sampled are returned unchanged
+func (s Summary) Scaled() Summary {
+	if s.SamplingRate <= 0 || s.SamplingRate >= 1 || s.Estimated {
+		return s
+	}
+	scale := func(v uint64) uint64 { return uint64(math.Round(float64(v) / s.SamplingRate)) }
+	scaled := s
+	scaled.Estimated = true
+	for _, n := range []*int64{&scaled.NumInstances, &scaled.NumActiveUsers, &scaled.TotalTracks, &scaled.TotalAlbums, &scaled.TotalArtists} {
+		*n = int64(scale(uint64(*n))) //#nosec G115 -- counts are positive
+	}
+	v := reflect.ValueOf(&scaled).Elem()
+	for i := range v.NumField() {
+		switch m := v.Field(i).Interface().(type) {
+		case map[string]uint64:
+			v.Field(i).Set(reflect.ValueOf(scaleCounts(m, scale)))
+		case map[string]map[string]uint64:
+			nested := make(map[string]map[string]uint64, len(m))
+			for k, counts := range m {
+				nested[k] = scaleCounts(counts, scale)
+			}
+			if m == nil {
+				nested = nil
+			}
+			v.Field(i).Set(reflect.ValueOf(nested))
+		}
+	}
+	return scaled
+}
+
+func scaleCounts(counts map[string]uint64, scale func(uint64) uint64) map[string]uint64 {
+	if counts == nil {
+		return nil
+	}
+	scaled := make(map[string]uint64, len(counts))
+	for k, v := range counts {
+		scaled[k] = scale(v)
+	}
+	return scaled
+}
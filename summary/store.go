@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/navidrome/insights/consts"
@@ -19,10 +20,8 @@ type SummaryRecord struct {
 }
 
 func SummaryFilePath(t time.Time) string {
-	dataFolder := os.Getenv("DATA_FOLDER")
 	return filepath.Join(
-		dataFolder,
-		consts.SummariesDir,
+		summariesDir(),
 		t.Format("2006"),
 		t.Format("01"),
 		"summary-"+t.Format(consts.DateFormat)+".json",
@@ -30,6 +29,9 @@ func SummaryFilePath(t time.Time) string {
 }
 
 func SaveSummary(summary Summary, t time.Time) error {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
 	filePath := SummaryFilePath(t)
 
 	// Create directory structure if needed
@@ -44,17 +46,140 @@ func SaveSummary(summary Summary, t time.Time) error {
 		return err
 	}
 
-	return os.WriteFile(filePath, data, consts.FilePermissions)
+	if err := os.WriteFile(filePath, data, consts.FilePermissions); err != nil {
+		return err
+	}
+
+	if err := updateIndex(t.Format(consts.DateFormat), filePath, summary.NumInstances); err != nil {
+		// The index is only an optimization: remove it, so the next GetSummaries rebuilds it
+		log.Printf("Warning: error updating summaries index, removing it: %v", err)
+		_ = os.Remove(indexFilePath())
+	}
+	notifySave()
+	return nil
+}
+
+// LoadSummary reads the stored summary of the given date
+func LoadSummary(t time.Time) (Summary, error) {
+	var summary Summary
+	data, err := os.ReadFile(SummaryFilePath(t))
+	if err != nil {
+		return summary, err
+	}
+	err = json.Unmarshal(data, &summary)
+	return summary, err
+}
+
+var (
+	saveHooksMu sync.Mutex
+	saveHooks   []func()
+)
+
+// OnSave registers a function to be called after each summary is saved, e.g. to invalidate caches
+func OnSave(fn func()) {
+	saveHooksMu.Lock()
+	defer saveHooksMu.Unlock()
+	saveHooks = append(saveHooks, fn)
+}
+
+func notifySave() {
+	saveHooksMu.Lock()
+	defer saveHooksMu.Unlock()
+	for _, fn := range saveHooks {
+		fn()
+	}
 }
 
 // summaryFileRegex matches files like "summary-2025-11-29.json"
 var summaryFileRegex = regexp.MustCompile(`^summary-(\d{4}-\d{2}-\d{2})\.json$`)
 
+// GetSummaries returns all non-empty summaries, sorted by date. Summaries are loaded through the
+// index file when available, falling back to a full walk of the summaries folder (rebuilding the index)
+// when it is missing or stale
 func GetSummaries() ([]SummaryRecord, error) {
-	dataFolder := os.Getenv("DATA_FOLDER")
-	baseDir := filepath.Join(dataFolder, consts.SummariesDir)
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	index, err := loadIndex()
+	if err != nil {
+		log.Printf("Warning: error reading summaries index, rebuilding it: %v", err)
+	}
+	if index != nil {
+		summaries, err := readIndexedSummaries(index)
+		if err == nil {
+			return summaries, nil
+		}
+		log.Printf("Warning: summaries index is stale, rebuilding it: %v", err)
+	}
+
+	summaries, index, err := walkSummaries()
+	if err != nil {
+		return nil, err
+	}
+	if len(index) > 0 {
+		if err := saveIndex(index); err != nil {
+			log.Printf("Warning: error saving summaries index: %v", err)
+		}
+	}
+	return summaries, nil
+}
+
+// FilterSummaries returns the summaries with dates between from and to, inclusive.
+// Zero values for from or to leave that side of the range unbounded.
+func FilterSummaries(summaries []SummaryRecord, from, to time.Time) []SummaryRecord {
+	var filtered []SummaryRecord
+	for _, s := range summaries {
+		if !from.IsZero() && s.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && s.Time.After(to) {
+			continue
+		}
+		filtered = append(filtered, s)
+	}
+	return filtered
+}
+
+// readIndexedSummaries loads the non-empty summaries listed in the index. Returns an error if any
+// indexed file is missing, meaning the index is stale
+func readIndexedSummaries(index summaryIndex) ([]SummaryRecord, error) {
+	baseDir := summariesDir()
+	var summaries []SummaryRecord
+	for dateStr, entry := range index {
+		// Skip empty summaries without reading them
+		if entry.Instances == 0 {
+			continue
+		}
+		t, err := time.Parse(consts.DateFormat, dateStr)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(baseDir, entry.Path)
+		data, err := os.ReadFile(path) //#nosec G304 -- path is from the index in a controlled directory
+		if err != nil {
+			return nil, err
+		}
+		var summary Summary
+		if err := json.Unmarshal(data, &summary); err != nil {
+			log.Printf("Warning: skipping malformed file %s: %v", path, err)
+			continue
+		}
+		if summary.NumInstances == 0 {
+			continue
+		}
+		summaries = append(summaries, SummaryRecord{Time: t, Data: summary})
+	}
+	sortSummaries(summaries)
+	return summaries, nil
+}
+
+// walkSummaries loads all non-empty summaries by walking the summaries folder, also returning the
+// index for all summary files found
+func walkSummaries() ([]SummaryRecord, summaryIndex, error) {
+	baseDir := summariesDir()
 
 	var summaries []SummaryRecord
+	index := summaryIndex{}
 
 	err := filepath.WalkDir(baseDir, func(path string, d fs.DirEntry, err error) error { //#nosec G703 -- baseDir is from controlled env var and constant
 		if err != nil {
@@ -96,6 +221,10 @@ func GetSummaries() ([]SummaryRecord, error) {
 			return nil
 		}
 
+		if rel, err := filepath.Rel(baseDir, path); err == nil {
+			index[dateStr] = summaryIndexEntry{Path: rel, Instances: summary.NumInstances}
+		}
+
 		// Skip empty summaries
 		if summary.NumInstances == 0 {
 			return nil
@@ -106,13 +235,16 @@ func GetSummaries() ([]SummaryRecord, error) {
 	})
 
 	if err != nil && !os.IsNotExist(err) {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Sort by date ascending
+	sortSummaries(summaries)
+	return summaries, index, nil
+}
+
+// sortSummaries sorts by date ascending
+func sortSummaries(summaries []SummaryRecord) {
 	slices.SortFunc(summaries, func(a, b SummaryRecord) int {
 		return a.Time.Compare(b.Time)
 	})
-
-	return summaries, nil
 }
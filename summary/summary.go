@@ -1,6 +1,7 @@
 package summary
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -8,9 +9,11 @@ import (
 	"reflect"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/navidrome/insights/consts"
 	"github.com/navidrome/insights/db"
 	"github.com/navidrome/navidrome/core/metrics/insights"
 	"golang.org/x/text/cases"
@@ -24,42 +27,105 @@ type Stats struct {
 	Mean   float64 `json:"mean"`
 	Median float64 `json:"median"`
 	StdDev float64 `json:"stdDev"`
+	// Instances whose value was excluded as an outlier (see OutlierCaps)
+	Excluded int64 `json:"excluded,omitempty"`
 }
 
 type Summary struct {
-	NumInstances     int64             `json:"numInstances,omitempty"`
-	NumActiveUsers   int64             `json:"numActiveUsers,omitempty"`
-	Versions         map[string]uint64 `json:"versions,omitempty"`
-	OS               map[string]uint64 `json:"os,omitempty"`
-	Distros          map[string]uint64 `json:"distros,omitempty"`
-	PlayerTypes      map[string]uint64 `json:"playerTypes,omitempty"`
-	Players          map[string]uint64 `json:"players,omitempty"`
-	Users            map[string]uint64 `json:"users,omitempty"`
-	Tracks           map[string]uint64 `json:"tracks,omitempty"`
-	Albums           map[string]uint64 `json:"albums,omitempty"`
-	Artists          map[string]uint64 `json:"artists,omitempty"`
-	MusicFS          map[string]uint64 `json:"musicFS,omitempty"`
-	DataFS           map[string]uint64 `json:"dataFS,omitempty"`
-	FileSuffixes     map[string]uint64 `json:"fileSuffixes,omitempty"`
-	Plugins          map[string]uint64 `json:"plugins,omitempty"`
-	PluginVersions   map[string]uint64 `json:"pluginVersions,omitempty"`
-	ConfigFlags      map[string]uint64 `json:"configFlags,omitempty"`
-	ScannerExtractor map[string]uint64 `json:"scannerExtractor,omitempty"`
-	TrackStats       *Stats            `json:"trackStats,omitempty"`
-	AlbumStats       *Stats            `json:"albumStats,omitempty"`
-	ArtistStats      *Stats            `json:"artistStats,omitempty"`
-	PlaylistStats    *Stats            `json:"playlistStats,omitempty"`
-	ShareStats       *Stats            `json:"shareStats,omitempty"`
-	RadioStats       *Stats            `json:"radioStats,omitempty"`
-	LibraryStats     *Stats            `json:"libraryStats,omitempty"`
-	ActiveUserStats  *Stats            `json:"activeUserStats,omitempty"`
+	Finalized        bool                         `json:"finalized,omitempty"`    // Summarized after the end of the UTC day, with all its reports
+	SamplingRate     float64                      `json:"samplingRate,omitempty"` // Fraction of the instances summarized, when the ingestion was sampled (see InSample)
+	Estimated        bool                         `json:"estimated,omitempty"`    // Counts scaled by 1/SamplingRate, estimating all the instances (see Summary.Scaled). Never set in the stored files
+	NumInstances     int64                        `json:"numInstances,omitempty"`
+	WeeklyInstances  int64                        `json:"weeklyInstances,omitempty"`  // Distinct instances that reported in the consts.WeeklyActiveDays ending on the date
+	MonthlyInstances int64                        `json:"monthlyInstances,omitempty"` // Same in the consts.MonthlyActiveDays
+	NumActiveUsers   int64                        `json:"numActiveUsers,omitempty"`
+	TotalTracks      int64                        `json:"totalTracks,omitempty"` // Sum over all instances
+	TotalAlbums      int64                        `json:"totalAlbums,omitempty"`
+	TotalArtists     int64                        `json:"totalArtists,omitempty"`
+	NewInstances     int64                        `json:"newInstances,omitempty"`
+	ChurnedInstances int64                        `json:"churnedInstances,omitempty"`
+	Versions         map[string]uint64            `json:"versions,omitempty"`
+	OS               map[string]uint64            `json:"os,omitempty"`
+	Distros          map[string]uint64            `json:"distros,omitempty"`
+	PlayerTypes      map[string]uint64            `json:"playerTypes,omitempty"`
+	Players          map[string]uint64            `json:"players,omitempty"`
+	PlayerVersions   map[string]map[string]uint64 `json:"playerVersions,omitempty"` // Player type -> client version ("12.x"), instances using it
+	Users            map[string]uint64            `json:"users,omitempty"`
+	Tracks           map[string]uint64            `json:"tracks,omitempty"`
+	Albums           map[string]uint64            `json:"albums,omitempty"`
+	Artists          map[string]uint64            `json:"artists,omitempty"`
+	TrackHistogram   map[string]uint64            `json:"trackHistogram,omitempty"` // Tracks per instance in log-scale buckets (see histogramBucket)
+	MusicFS          map[string]uint64            `json:"musicFS,omitempty"`
+	DataFS           map[string]uint64            `json:"dataFS,omitempty"`
+	FileSuffixes     map[string]uint64            `json:"fileSuffixes,omitempty"`
+	Plugins          map[string]uint64            `json:"plugins,omitempty"`
+	PluginVersions   map[string]uint64            `json:"pluginVersions,omitempty"`
+	ConfigFlags      map[string]uint64            `json:"configFlags,omitempty"`
+	ScannerExtractor map[string]uint64            `json:"scannerExtractor,omitempty"`
+	Features         map[string]uint64            `json:"features,omitempty"`
+	CPUs             map[string]uint64            `json:"cpus,omitempty"`
+	Memory           map[string]uint64            `json:"memory,omitempty"`     // Memory obtained from the OS by Navidrome, binned in MB
+	OSVersions       map[string]map[string]uint64 `json:"osVersions,omitempty"` // OS name -> normalized version
+	Countries        map[string]uint64            `json:"countries,omitempty"`
+	TrackStats       *Stats                       `json:"trackStats,omitempty"`
+	AlbumStats       *Stats                       `json:"albumStats,omitempty"`
+	ArtistStats      *Stats                       `json:"artistStats,omitempty"`
+	PlaylistStats    *Stats                       `json:"playlistStats,omitempty"`
+	ShareStats       *Stats                       `json:"shareStats,omitempty"`
+	RadioStats       *Stats                       `json:"radioStats,omitempty"`
+	LibraryStats     *Stats                       `json:"libraryStats,omitempty"`
+	ActiveUserStats  *Stats                       `json:"activeUserStats,omitempty"`
+	CPUStats         *Stats                       `json:"cpuStats,omitempty"`
+	MemStats         *Stats                       `json:"memStats,omitempty"` // In MB
 }
 
-func SummarizeData(dbConn *sql.DB, date time.Time) error {
-	rows, err := db.SelectData(dbConn, date)
+// SummarizeData summarizes the latest report of each instance for the given date and saves the summary,
+// returning the number of reports summarized. The date is then marked as summarized in the database, so it
+// is only summarized again if it receives late reports (see db.SelectStaleDates)
+func SummarizeData(ctx context.Context, dbConn *sql.DB, date time.Time) (int64, error) {
+	return SummarizeDataFrom(ctx, dbConn, dbConn, date)
+}
+
+// SummarizeDataFrom is SummarizeData reading the reports from readConn (e.g. a read-only pool, see
+// db.OpenReadDB), only using dbConn to mark the date as summarized
+func SummarizeDataFrom(ctx context.Context, readConn, dbConn *sql.DB, date time.Time) (int64, error) {
+	started := time.Now()
+	summary, err := ComputeSummary(ctx, readConn, date)
+	if err != nil {
+		return 0, err
+	}
+	if summary.NumInstances == 0 {
+		log.Printf("No data to summarize for %s", date.Format("2006-01-02"))
+		return 0, nil
+	}
+
+	// Save summary to file
+	err = SaveSummary(summary, date)
+	if err != nil {
+		log.Printf("Error saving summary: %s", err)
+		return summary.NumInstances, err
+	}
+	if err := db.MarkSummarized(ctx, dbConn, date, started); err != nil {
+		log.Printf("Error marking %s as summarized: %s", date.Format("2006-01-02"), err)
+	}
+	return summary.NumInstances, nil
+}
+
+// ComputeSummary summarizes the latest report of each instance for the given date, without saving it.
+// The summary is empty (zero NumInstances) if there are no reports for the date
+func ComputeSummary(ctx context.Context, dbConn *sql.DB, date time.Time) (Summary, error) {
+	finalized := DayOver(date, time.Now())
+	// When the ingestion was sampled, the instances stored before it started are left out, so the summary
+	// only counts the sample. Read before opening the reports cursor, which is only closed by iterating it
+	sampleRate, err := db.SampleRate(ctx, dbConn, date)
+	if err != nil {
+		log.Printf("Error reading sample rate: %s", err)
+		return Summary{}, err
+	}
+	rows, err := db.SelectData(ctx, dbConn, date)
 	if err != nil {
 		log.Printf("Error selecting data: %s", err)
-		return err
+		return Summary{}, err
 	}
 	summary := Summary{
 		Versions:         make(map[string]uint64),
@@ -71,6 +137,7 @@ func SummarizeData(dbConn *sql.DB, date time.Time) error {
 		Tracks:           make(map[string]uint64),
 		Albums:           make(map[string]uint64),
 		Artists:          make(map[string]uint64),
+		TrackHistogram:   make(map[string]uint64),
 		MusicFS:          make(map[string]uint64),
 		DataFS:           make(map[string]uint64),
 		FileSuffixes:     make(map[string]uint64),
@@ -78,38 +145,77 @@ func SummarizeData(dbConn *sql.DB, date time.Time) error {
 		PluginVersions:   make(map[string]uint64),
 		ConfigFlags:      make(map[string]uint64),
 		ScannerExtractor: make(map[string]uint64),
+		Features:         make(map[string]uint64),
+		CPUs:             make(map[string]uint64),
+		OSVersions:       make(map[string]map[string]uint64),
+		PlayerVersions:   make(map[string]map[string]uint64),
+		Memory:           make(map[string]uint64),
+		Countries:        make(map[string]uint64),
 	}
 
+	bins := CurrentBins()
+
 	// Collect values for statistics calculation
 	var trackValues, albumValues, artistValues []int64
 	var playlistValues, shareValues, radioValues, libraryValues []int64
 	var activeUserValues []int64
+	var cpuValues, memValues []int64
 
-	for data := range rows {
+	for report := range rows {
+		data := report.Data
+		if sampleRate > 0 && !InSample(data.InsightsID, sampleRate) {
+			continue
+		}
 		// Summarize data here
 		summary.NumInstances++
 		summary.NumActiveUsers += data.Library.ActiveUsers
+		summary.TotalTracks += data.Library.Tracks
+		summary.TotalAlbums += data.Library.Albums
+		summary.TotalArtists += data.Library.Artists
 		summary.Versions[mapVersion(data)]++
 		summary.OS[mapOS(data)]++
 		if data.OS.Type == "linux" && !data.OS.Containerized {
 			summary.Distros[data.OS.Distro]++
 		}
+		if osName, version := mapOSVersion(data); version != "" {
+			if summary.OSVersions[osName] == nil {
+				summary.OSVersions[osName] = make(map[string]uint64)
+			}
+			summary.OSVersions[osName][version]++
+		}
 		summary.Users[fmt.Sprintf("%d", data.Library.ActiveUsers)]++
 		summary.MusicFS[mapFS(data.FS.Music)]++
 		summary.DataFS[mapFS(data.FS.Data)]++
 		totalPlayers := mapPlayerTypes(data, summary.PlayerTypes)
 		summary.Players[fmt.Sprintf("%d", totalPlayers)]++
+		mapPlayerVersions(data, summary.PlayerVersions)
 		mapFileSuffixes(data, summary.FileSuffixes)
 		mapPlugins(data, summary.Plugins, summary.PluginVersions)
 		mapConfigFlags(data, summary.ConfigFlags)
+		mapFeatures(data, summary.Features)
 		if data.Config.ScannerExtractor != "" {
 			summary.ScannerExtractor[data.Config.ScannerExtractor]++
 		}
+		if report.Country != "" {
+			summary.Countries[report.Country]++
+		}
 
 		// Bin tracks, albums, and artists
-		mapToBins(data.Library.Tracks, TrackBins, summary.Tracks)
-		mapToBins(data.Library.Albums, AlbumBins, summary.Albums)
-		mapToBins(data.Library.Artists, ArtistBins, summary.Artists)
+		mapToBins(data.Library.Tracks, bins.Tracks, summary.Tracks)
+		mapToBins(data.Library.Albums, bins.Albums, summary.Albums)
+		mapToBins(data.Library.Artists, bins.Artists, summary.Artists)
+		mapToHistogram(data.Library.Tracks, summary.TrackHistogram)
+
+		// Host resources, skipped when not reported
+		if data.OS.NumCPU > 0 {
+			mapToBins(int64(data.OS.NumCPU), CPUBins, summary.CPUs)
+			cpuValues = append(cpuValues, int64(data.OS.NumCPU))
+		}
+		if data.Mem.Sys > 0 {
+			memMB := int64(data.Mem.Sys / (1024 * 1024))
+			mapToBins(memMB, MemoryBins, summary.Memory)
+			memValues = append(memValues, memMB)
+		}
 
 		// Collect values for statistics (only non-zero for tracks, albums, artists)
 		if data.Library.Tracks > 0 {
@@ -128,28 +234,50 @@ func SummarizeData(dbConn *sql.DB, date time.Time) error {
 		libraryValues = append(libraryValues, data.Library.Libraries)
 		activeUserValues = append(activeUserValues, data.Library.ActiveUsers)
 	}
+	// Don't save a partial summary if the selection was interrupted
+	if err := ctx.Err(); err != nil {
+		return Summary{}, fmt.Errorf("summarizing %s: %w", date.Format("2006-01-02"), err)
+	}
 
 	if summary.NumInstances == 0 {
-		log.Printf("No data to summarize for %s", date.Format("2006-01-02"))
-		return nil
+		return Summary{}, nil
 	}
 
-	// Calculate statistics for all fields
-	summary.TrackStats = calcStats(trackValues)
-	summary.AlbumStats = calcStats(albumValues)
-	summary.ArtistStats = calcStats(artistValues)
-	summary.PlaylistStats = calcStats(playlistValues)
-	summary.ShareStats = calcStats(shareValues)
-	summary.RadioStats = calcStats(radioValues)
-	summary.LibraryStats = calcStats(libraryValues)
-	summary.ActiveUserStats = calcStats(activeUserValues)
-
-	// Save summary to file
-	err = SaveSummary(summary, date)
-	if err != nil {
-		log.Printf("Error saving summary: %s", err)
+	// Count instances that started or stopped reporting
+	if summary.NewInstances, err = db.CountNewInstances(ctx, dbConn, date); err != nil {
+		log.Printf("Error counting new instances: %s", err)
 	}
-	return err
+	if summary.ChurnedInstances, err = db.CountChurnedInstances(ctx, dbConn, date); err != nil {
+		log.Printf("Error counting churned instances: %s", err)
+	}
+	if summary.WeeklyInstances, err = db.CountActiveInstances(ctx, dbConn, date, consts.WeeklyActiveDays); err != nil {
+		log.Printf("Error counting weekly active instances: %s", err)
+	}
+	if summary.MonthlyInstances, err = db.CountActiveInstances(ctx, dbConn, date, consts.MonthlyActiveDays); err != nil {
+		log.Printf("Error counting monthly active instances: %s", err)
+	}
+
+	// Calculate statistics for all fields, without the outliers
+	caps := CurrentOutlierCaps()
+	summary.TrackStats = calcStatsExcluding(trackValues, caps["track"])
+	summary.AlbumStats = calcStatsExcluding(albumValues, caps["album"])
+	summary.ArtistStats = calcStatsExcluding(artistValues, caps["artist"])
+	summary.PlaylistStats = calcStatsExcluding(playlistValues, caps["playlist"])
+	summary.ShareStats = calcStatsExcluding(shareValues, caps["share"])
+	summary.RadioStats = calcStatsExcluding(radioValues, caps["radio"])
+	summary.LibraryStats = calcStatsExcluding(libraryValues, caps["library"])
+	summary.ActiveUserStats = calcStatsExcluding(activeUserValues, caps["activeUser"])
+	summary.CPUStats = calcStatsExcluding(cpuValues, caps["cpu"])
+	summary.MemStats = calcStatsExcluding(memValues, caps["mem"])
+	summary.Finalized = finalized
+	summary.SamplingRate = sampleRate
+	return summary, nil
+}
+
+// DayOver reports whether the UTC day of date was over at time t, so no more reports can be stored for
+// it. A summary computed after that is final, earlier ones are provisional
+func DayOver(date, t time.Time) bool {
+	return !t.UTC().Before(date.AddDate(0, 0, 1))
 }
 
 // calcStats computes min, max, mean, median, and standard deviation for a slice of values
@@ -206,18 +334,26 @@ func mapVersion(data insights.Data) string {
 	return versionRegex.ReplaceAllString(data.Version, "($1)")
 }
 
-var TrackBins = []int64{0, 1, 100, 500, 1000, 5000, 10000, 20000, 50000, 100000, 500000, 1000000}
-var AlbumBins = []int64{0, 1, 10, 50, 100, 500, 1000, 2000, 5000, 10000, 50000, 100000}
-var ArtistBins = []int64{0, 1, 10, 50, 100, 500, 1000, 2000, 5000, 10000, 50000, 100000}
+// The library size bins are configurable, see Bins
+var CPUBins = []int64{1, 2, 4, 8, 16, 32, 64}
+var MemoryBins = []int64{0, 64, 128, 256, 512, 1024, 2048} // In MB
 
 func mapToBins(count int64, bins []int64, counters map[string]uint64) {
+	if key, ok := BinKey(count, bins); ok {
+		counters[key]++
+	}
+}
+
+// BinKey returns the key of the bin of a count: the largest bin not greater than it. Counts below the
+// first bin have none
+func BinKey(count int64, bins []int64) (string, bool) {
 	for i := range bins {
 		bin := bins[len(bins)-1-i]
 		if count >= bin {
-			counters[fmt.Sprintf("%d", bin)]++
-			return
+			return strconv.FormatInt(bin, 10), true
 		}
 	}
+	return "", false
 }
 
 var caser = cases.Title(language.Und)
@@ -240,6 +376,8 @@ func mapOS(data insights.Data) string {
 	return osName + " - " + data.OS.Arch
 }
 
+// playersTypes holds the built-in player type mappings. Additional mappings can be loaded from
+// the player types file (see LoadPlayerTypes)
 var playersTypes = map[*regexp.Regexp]string{
 	regexp.MustCompile("NavidromeUI.*"):       "NavidromeUI",
 	regexp.MustCompile("supersonic"):          "Supersonic",
@@ -262,13 +400,11 @@ var playersTypes = map[*regexp.Regexp]string{
 }
 
 func mapPlayerTypes(data insights.Data, players map[string]uint64) int64 {
+	rules := currentPlayerRules()
 	seen := map[string]uint64{}
 	for p, count := range data.Library.ActivePlayers {
-		for r, t := range playersTypes {
-			if r.MatchString(p) {
-				p = t
-				break
-			}
+		if t, ok := matchPlayerType(rules, p); ok {
+			p = t
 		}
 		if p != "" {
 			v := seen[p]
@@ -316,6 +452,79 @@ func mapConfigFlags(data insights.Data, configFlags map[string]uint64) {
 	}
 }
 
+// Ex: 10.0.26100.1742 (Windows 11 builds start at 22000)
+var windowsVersionRegex = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// mapOSVersion returns the OS name and its normalized version (e.g. "Windows 11", "macOS 14") for
+// Windows and macOS instances. Returns an empty version for other OSes or when it's not reported
+func mapOSVersion(data insights.Data) (osName, version string) {
+	v := strings.TrimSpace(data.OS.Version)
+	if v == "" {
+		return "", ""
+	}
+	switch data.OS.Type {
+	case "windows":
+		m := windowsVersionRegex.FindStringSubmatch(v)
+		if m == nil {
+			return "Windows", "Windows (other)"
+		}
+		switch m[1] + "." + m[2] {
+		case "10.0":
+			if build, _ := strconv.Atoi(m[3]); build >= 22000 {
+				return "Windows", "Windows 11"
+			}
+			return "Windows", "Windows 10"
+		case "6.3":
+			return "Windows", "Windows 8.1"
+		case "6.2":
+			return "Windows", "Windows 8"
+		case "6.1":
+			return "Windows", "Windows 7"
+		}
+		return "Windows", "Windows " + m[1] + "." + m[2]
+	case "darwin":
+		// Before macOS 11 the minor version identified the release (10.15 Catalina)
+		parts := strings.Split(v, ".")
+		if parts[0] == "10" && len(parts) > 1 {
+			return "macOS", "macOS 10." + parts[1]
+		}
+		return "macOS", "macOS " + parts[0]
+	}
+	return "", ""
+}
+
+// features maps the user-facing features tracked in summaries to whether the instance has them enabled.
+// Unlike ConfigFlags, which counts every boolean config option, these are labeled for display.
+// Spotify is not part of the reported config, so it can't be tracked
+var features = []struct {
+	name    string
+	enabled func(insights.Data) bool
+}{
+	{"Last.fm", func(d insights.Data) bool { return d.Config.EnableLastFM }},
+	{"ListenBrainz", func(d insights.Data) bool { return d.Config.EnableListenBrainz }},
+	{"Deezer", func(d insights.Data) bool { return d.Config.EnableDeezer }},
+	{"Jukebox", func(d insights.Data) bool { return d.Config.EnableJukebox }},
+	{"Sharing", func(d insights.Data) bool { return d.Config.EnableSharing }},
+	{"Downloads", func(d insights.Data) bool { return d.Config.EnableDownloads }},
+	{"Prometheus", func(d insights.Data) bool { return d.Config.EnablePrometheus }},
+	{"Now Playing", func(d insights.Data) bool { return d.Config.EnableNowPlaying }},
+	{"Star Rating", func(d insights.Data) bool { return d.Config.EnableStarRating }},
+	{"Artwork Upload", func(d insights.Data) bool { return d.Config.EnableArtworkUpload }},
+	{"Smart Playlists", func(d insights.Data) bool { return d.Config.HasSmartPlaylists }},
+	{"Reverse Proxy", func(d insights.Data) bool { return d.Config.ReverseProxyConfigured }},
+	{"TLS", func(d insights.Data) bool { return d.Config.TLSConfigured }},
+}
+
+func mapFeatures(data insights.Data, counts map[string]uint64) {
+	for _, f := range features {
+		if f.enabled(data) {
+			counts[f.name]++
+		}
+	}
+}
+
+// fsMappings holds the built-in names for filesystem types the clients don't recognize. Additional
+// mappings can be loaded from the filesystem types file (see LoadFSTypes)
 var fsMappings = map[string]string{
 	"unknown(0x2011bab0)": "exfat",
 	"unknown(0x7366746e)": "ntfs",
@@ -339,7 +548,7 @@ func mapFS(fs *insights.FSInfo) string {
 	if fs == nil {
 		return "unknown"
 	}
-	if t, ok := fsMappings[fs.Type]; ok {
+	if t, ok := currentFSTypes()[fs.Type]; ok {
 		return t
 	}
 	return strings.ToLower(fs.Type)
@@ -1,10 +1,18 @@
 package summary
 
 import (
+	"bytes"
+	"fmt"
 	"maps"
+	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/navidrome/insights/consts"
 	"github.com/navidrome/navidrome/core/metrics/insights"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -56,6 +64,18 @@ var _ = Describe("Summary", func() {
 			mapToBins(5, []int64{}, counters)
 			Expect(counters).To(BeEmpty())
 		})
+
+		It("should bin CPU counts and memory sizes", func() {
+			for _, cpus := range []int64{1, 3, 4, 12, 128} {
+				mapToBins(cpus, CPUBins, counters)
+			}
+			Expect(counters).To(Equal(map[string]uint64{"1": 1, "2": 1, "4": 1, "8": 1, "64": 1}))
+
+			memory := make(map[string]uint64)
+			mapToBins(40, MemoryBins, memory)
+			mapToBins(300, MemoryBins, memory)
+			Expect(memory).To(Equal(map[string]uint64{"0": 1, "256": 1}))
+		})
 	})
 
 	DescribeTable("mapVersion",
@@ -69,6 +89,16 @@ var _ = Describe("Summary", func() {
 		Entry("should map any version with a hash", "0.54.3-SNAPSHOT (734eb30a)", insights.Data{Version: "0.54.3-SNAPSHOT (734eb30a)"}),
 	)
 
+	DescribeTable("DayOver",
+		func(expected bool, t time.Time) {
+			Expect(DayOver(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), t)).To(Equal(expected))
+		},
+		Entry("during the day", false, time.Date(2025, 1, 1, 23, 59, 0, 0, time.UTC)),
+		Entry("at midnight UTC", true, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)),
+		Entry("in another time zone", false, time.Date(2025, 1, 2, 0, 30, 0, 0, time.FixedZone("CET", 3600))),
+		Entry("days later", true, time.Date(2025, 1, 5, 12, 0, 0, 0, time.UTC)),
+	)
+
 	DescribeTable("mapOS",
 		func(expected, osType, arch string, containerized bool) {
 			var data insights.Data
@@ -83,6 +113,24 @@ var _ = Describe("Summary", func() {
 		Entry("should map bsd to BSD", "FreeBSD - x86_64", "freebsd", "x86_64", false),
 		Entry("should map unknown OS types", "Unknown - x86_64", "unknown", "x86_64", false),
 	)
+	DescribeTable("mapOSVersion",
+		func(osType, version, expectedOS, expectedVersion string) {
+			var data insights.Data
+			data.OS.Type = osType
+			data.OS.Version = version
+			osName, v := mapOSVersion(data)
+			Expect(osName).To(Equal(expectedOS))
+			Expect(v).To(Equal(expectedVersion))
+		},
+		Entry("Windows 11", "windows", "10.0.26100.1742", "Windows", "Windows 11"),
+		Entry("Windows 10", "windows", "10.0.19045.4894", "Windows", "Windows 10"),
+		Entry("Windows 8.1", "windows", "6.3.9600", "Windows", "Windows 8.1"),
+		Entry("unparseable Windows version", "windows", "Microsoft Windows XP", "Windows", "Windows (other)"),
+		Entry("macOS 14", "darwin", "14.5", "macOS", "macOS 14"),
+		Entry("macOS 10.15", "darwin", "10.15.7", "macOS", "macOS 10.15"),
+		Entry("missing version", "darwin", "", "", ""),
+		Entry("other OSes", "linux", "22.04", "", ""),
+	)
 	Describe("calcStats", func() {
 		It("should return nil for empty slice", func() {
 			Expect(calcStats([]int64{})).To(BeNil())
@@ -131,6 +179,94 @@ var _ = Describe("Summary", func() {
 		})
 	})
 
+	Describe("histogramBucket", func() {
+		It("rounds down to one significant digit", func() {
+			for v, bucket := range map[int64]int64{0: 0, 7: 7, 10: 10, 19: 10, 99: 90, 100: 100, 12345: 10000, 987654: 900000} {
+				Expect(histogramBucket(v)).To(Equal(bucket), "value %d", v)
+			}
+		})
+
+		It("can be re-binned exactly into the default bins", func() {
+			hist, tracks := map[string]uint64{}, map[string]uint64{}
+			for _, v := range []int64{0, 1, 99, 100, 499, 500, 4321, 19999, 20000, 123456, 2000000} {
+				mapToHistogram(v, hist)
+				mapToBins(v, DefaultBins().Tracks, tracks)
+			}
+			rebinned := map[string]uint64{}
+			for key, n := range hist {
+				v, err := strconv.ParseInt(key, 10, 64)
+				Expect(err).NotTo(HaveOccurred())
+				for range n {
+					mapToBins(v, DefaultBins().Tracks, rebinned)
+				}
+			}
+			Expect(rebinned).To(Equal(tracks))
+		})
+	})
+
+	Describe("FilterSummaries", func() {
+		summaries := []SummaryRecord{
+			{Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+			{Time: time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)},
+		}
+
+		It("returns all summaries when range is unbounded", func() {
+			Expect(FilterSummaries(summaries, time.Time{}, time.Time{})).To(HaveLen(3))
+		})
+
+		It("includes both ends of the range", func() {
+			result := FilterSummaries(summaries, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC))
+			Expect(result).To(HaveLen(2))
+			Expect(result[0].Time.Day()).To(Equal(2))
+		})
+
+		It("supports open-ended ranges", func() {
+			Expect(FilterSummaries(summaries, time.Time{}, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))).To(HaveLen(1))
+			Expect(FilterSummaries(summaries, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), time.Time{})).To(HaveLen(1))
+		})
+	})
+
+	Describe("calcStatsExcluding", func() {
+		It("should exclude the values above the cap and count them", func() {
+			stats := calcStatsExcluding([]int64{10, 20, 30, 9223372036854775807}, 1000)
+			Expect(stats.Max).To(Equal(int64(30)))
+			Expect(stats.Mean).To(Equal(float64(20)))
+			Expect(stats.Excluded).To(Equal(int64(1)))
+		})
+
+		It("should include all values without a cap", func() {
+			stats := calcStatsExcluding([]int64{10, 5000}, 0)
+			Expect(stats.Max).To(Equal(int64(5000)))
+			Expect(stats.Excluded).To(BeZero())
+		})
+	})
+
+	Describe("OutlierCapsFromEnv", func() {
+		It("should override the default caps", func() {
+			GinkgoT().Setenv("STATS_OUTLIER_CAPS", "track=5000, album=100")
+			caps, err := OutlierCapsFromEnv()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(caps["track"]).To(Equal(int64(5000)))
+			Expect(caps["album"]).To(Equal(int64(100)))
+			Expect(caps["artist"]).To(Equal(DefaultOutlierCaps()["artist"]))
+		})
+
+		It("should disable the caps with off", func() {
+			GinkgoT().Setenv("STATS_OUTLIER_CAPS", "off")
+			Expect(OutlierCapsFromEnv()).To(BeEmpty())
+		})
+
+		It("should reject unknown stats and invalid values", func() {
+			GinkgoT().Setenv("STATS_OUTLIER_CAPS", "songs=10")
+			_, err := OutlierCapsFromEnv()
+			Expect(err).To(HaveOccurred())
+			GinkgoT().Setenv("STATS_OUTLIER_CAPS", "track=-1")
+			_, err = OutlierCapsFromEnv()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	Describe("mapFileSuffixes", func() {
 		It("should count one instance per suffix", func() {
 			suffixes := make(map[string]uint64)
@@ -229,6 +365,209 @@ var _ = Describe("Summary", func() {
 			map[string]uint64{"ranchmusicarchiver": 3, "ArchiveTune": 1}),
 	)
 
+	DescribeTable("playerVersion",
+		func(name, client, version string) {
+			c, v := playerVersion(name)
+			Expect(c).To(Equal(client))
+			Expect(v).To(Equal(version))
+		},
+		Entry("slash separator", "psysonic/1.46.0", "psysonic", "1.x"),
+		Entry("v prefix and major 0", "AudioMuse-AI/v0.8.9", "AudioMuse-AI", "0.8.x"),
+		Entry("underscore separator", "NavidromeUI_1.0", "NavidromeUI", "1.x"),
+		Entry("space separator", "Tempo 3.12", "Tempo", "3.x"),
+		Entry("no version", "Feishin", "Feishin", ""),
+		Entry("digits in the name", "playSub_iPhone11", "playSub_iPhone11", ""),
+	)
+
+	Describe("mapPlayerVersions", func() {
+		It("counts each instance once per type and version", func() {
+			var data insights.Data
+			data.Library.ActivePlayers = map[string]int64{
+				"psysonic/1.46.0": 3, "psysonic/1.45.0": 2, "AudioMuse-AI/v0.8.9": 1,
+				"NewClient/2.1": 1, "Feishin": 4, "DSubCC/5.0": 1,
+			}
+			versions := map[string]map[string]uint64{}
+			mapPlayerVersions(data, versions)
+			Expect(versions).To(Equal(map[string]map[string]uint64{
+				"psysonic":     {"1.x": 1},
+				"AudioMuse-AI": {"0.8.x": 1},
+				"NewClient":    {"2.x": 1},
+			}))
+		})
+	})
+
+	Describe("CountUnmappedPlayers", func() {
+		It("counts only the players not matching any mapping rule", func() {
+			counts := make(map[string]uint64)
+			var data1, data2 insights.Data
+			data1.Library.ActivePlayers = map[string]int64{"NavidromeUI_1.0": 2, "Feishin": 1, "DSubCC": 3}
+			data2.Library.ActivePlayers = map[string]int64{"Feishin": 4, "NewClient": 1}
+			CountUnmappedPlayers(data1, counts)
+			CountUnmappedPlayers(data2, counts)
+			Expect(counts).To(Equal(map[string]uint64{"Feishin": 2, "NewClient": 1}))
+		})
+	})
+
+	Describe("LoadPlayerTypes", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			tempDir = GinkgoT().TempDir()
+			GinkgoT().Setenv("DATA_FOLDER", tempDir)
+			DeferCleanup(func() { setPlayerRules(defaultPlayerRules()) })
+		})
+
+		writeRules := func(content string) {
+			Expect(os.WriteFile(filepath.Join(tempDir, consts.PlayerTypesFile), []byte(content), 0600)).To(Succeed())
+		}
+
+		mapPlayers := func(activePlayers map[string]int64) map[string]uint64 {
+			var data insights.Data
+			data.Library.ActivePlayers = activePlayers
+			players := make(map[string]uint64)
+			mapPlayerTypes(data, players)
+			return players
+		}
+
+		It("keeps the built-in rules when the file does not exist", func() {
+			n, err := LoadPlayerTypes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(BeZero())
+			Expect(mapPlayers(map[string]int64{"NavidromeUI_1.0": 2})).To(Equal(map[string]uint64{"NavidromeUI": 2}))
+		})
+
+		It("adds custom rules and overrides built-in ones with the same pattern", func() {
+			writeRules(`[{"pattern": "^NewClient/.*", "type": "NewClient"}, {"pattern": "DSubCC", "type": "DSub"}]`)
+			n, err := LoadPlayerTypes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(2))
+			Expect(mapPlayers(map[string]int64{"NewClient/1.2": 3, "DSubCC": 1, "NavidromeUI_1.0": 2})).To(Equal(
+				map[string]uint64{"NewClient": 3, "DSub": 1, "NavidromeUI": 2}))
+		})
+
+		It("checks custom rules before the built-in ones", func() {
+			writeRules(`[{"pattern": "^NavidromeUI_2", "type": ""}]`)
+			_, err := LoadPlayerTypes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mapPlayers(map[string]int64{"NavidromeUI_2.0": 5, "NavidromeUI_1.0": 2})).To(Equal(map[string]uint64{"NavidromeUI": 2}))
+		})
+
+		It("keeps the current rules when the file is invalid", func() {
+			writeRules(`[{"pattern": "^NewClient/.*", "type": "NewClient"}]`)
+			_, err := LoadPlayerTypes()
+			Expect(err).NotTo(HaveOccurred())
+
+			writeRules(`[{"pattern": "([", "type": "Broken"}]`)
+			_, err = LoadPlayerTypes()
+			Expect(err).To(HaveOccurred())
+			writeRules(`{not json`)
+			_, err = LoadPlayerTypes()
+			Expect(err).To(HaveOccurred())
+
+			Expect(mapPlayers(map[string]int64{"NewClient/1.2": 3})).To(Equal(map[string]uint64{"NewClient": 3}))
+		})
+	})
+
+	Describe("LoadBins", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			tempDir = GinkgoT().TempDir()
+			GinkgoT().Setenv("DATA_FOLDER", tempDir)
+			DeferCleanup(func() { setBins(DefaultBins()) })
+		})
+
+		writeBins := func(content string) {
+			Expect(os.WriteFile(filepath.Join(tempDir, consts.BinsFile), []byte(content), 0600)).To(Succeed())
+		}
+
+		It("uses the default bins when the file does not exist", func() {
+			loaded, err := LoadBins()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(BeFalse())
+			Expect(CurrentBins()).To(Equal(DefaultBins()))
+		})
+
+		It("overrides the configured bins, keeping the default ones of the others", func() {
+			writeBins(`{"tracks": [0, 1000, 10000]}`)
+			loaded, err := LoadBins()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(BeTrue())
+			Expect(CurrentBins().Tracks).To(Equal([]int64{0, 1000, 10000}))
+			Expect(CurrentBins().Albums).To(Equal(DefaultBins().Albums))
+		})
+
+		It("keeps the current bins when the file is invalid", func() {
+			writeBins(`{"tracks": [0, 1000, 10000]}`)
+			_, err := LoadBins()
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, content := range []string{`{"tracks": [1, 1000]}`, `{"tracks": [0, 1000, 500]}`, `{"albums": [0, 10]}`, `{not json`} {
+				writeBins(content)
+				_, err = LoadBins()
+				Expect(err).To(HaveOccurred(), content)
+			}
+			Expect(CurrentBins().Tracks).To(Equal([]int64{0, 1000, 10000}))
+		})
+	})
+
+	Describe("LoadFSTypes", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			tempDir = GinkgoT().TempDir()
+			GinkgoT().Setenv("DATA_FOLDER", tempDir)
+			DeferCleanup(func() { setFSTypes(fsMappings) })
+		})
+
+		writeTypes := func(content string) {
+			Expect(os.WriteFile(filepath.Join(tempDir, consts.FSTypesFile), []byte(content), 0600)).To(Succeed())
+		}
+
+		It("keeps the built-in mappings when the file does not exist", func() {
+			n, err := LoadFSTypes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(BeZero())
+			Expect(mapFS(&insights.FSInfo{Type: "unknown(0x2011bab0)"})).To(Equal("exfat"))
+		})
+
+		It("merges custom mappings over the built-in ones", func() {
+			writeTypes(`{"unknown(0x1234)": "newfs", "unknown(0x187)": "automount"}`)
+			n, err := LoadFSTypes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(2))
+			Expect(mapFS(&insights.FSInfo{Type: "unknown(0x1234)"})).To(Equal("newfs"))
+			Expect(mapFS(&insights.FSInfo{Type: "unknown(0x187)"})).To(Equal("automount"))
+			Expect(mapFS(&insights.FSInfo{Type: "unknown(0x2011bab0)"})).To(Equal("exfat"))
+			Expect(fsMappings).To(HaveKeyWithValue("unknown(0x187)", "autofs"))
+		})
+
+		It("keeps the current mappings when the file is invalid", func() {
+			writeTypes(`{"unknown(0x1234)": "newfs"}`)
+			_, err := LoadFSTypes()
+			Expect(err).NotTo(HaveOccurred())
+
+			writeTypes(`{"unknown(0x5678)": ""}`)
+			_, err = LoadFSTypes()
+			Expect(err).To(HaveOccurred())
+			Expect(mapFS(&insights.FSInfo{Type: "unknown(0x1234)"})).To(Equal("newfs"))
+		})
+	})
+
+	Describe("CountUnmappedFS", func() {
+		It("counts each unmapped unknown type once per instance", func() {
+			counts := make(map[string]uint64)
+			var data1, data2 insights.Data
+			data1.FS.Music = &insights.FSInfo{Type: "unknown(0x1234)"}
+			data1.FS.Data = &insights.FSInfo{Type: "unknown(0x1234)"}
+			data2.FS.Music = &insights.FSInfo{Type: "unknown(0x2011bab0)"}
+			data2.FS.Data = &insights.FSInfo{Type: "ext4"}
+			CountUnmappedFS(data1, counts)
+			CountUnmappedFS(data2, counts)
+			Expect(counts).To(Equal(map[string]uint64{"unknown(0x1234)": 1}))
+		})
+	})
+
 	Describe("mapConfigFlags", func() {
 		It("should count true boolean fields using JSON tag names", func() {
 			configFlags := make(map[string]uint64)
@@ -274,4 +613,241 @@ var _ = Describe("Summary", func() {
 			Expect(configFlags).To(BeEmpty())
 		})
 	})
+
+	Describe("mapFeatures", func() {
+		It("should count enabled features by display name", func() {
+			features := make(map[string]uint64)
+			var data1, data2 insights.Data
+			data1.Config.EnableLastFM = true
+			data1.Config.EnableJukebox = true
+			data2.Config.EnableLastFM = true
+			data2.Config.ScanOnStartup = true
+			mapFeatures(data1, features)
+			mapFeatures(data2, features)
+			Expect(features).To(Equal(map[string]uint64{"Last.fm": 2, "Jukebox": 1}))
+		})
+	})
+
+	Describe("ReportDimensions", func() {
+		It("maps a report with the same rules as the summary", func() {
+			var data insights.Data
+			data.Version = "0.54.0 (abcdef0123456789)"
+			data.OS.Type = "linux"
+			data.OS.Arch = "amd64"
+			data.OS.Distro = "debian"
+			data.FS.Music = &insights.FSInfo{Type: "unknown(0x7366746e)"}
+			data.Library.ActivePlayers = map[string]int64{"NavidromeUI_1.0": 2, "NavidromeUI_2.0": 1, "feishin": 3}
+			data.Library.FileSuffixes = map[string]int64{"mp3": 10, "flac": 5}
+			data.Config.EnableLastFM = true
+
+			d := ReportDimensions(data)
+			Expect(d.Version).To(Equal("0.54.0 (abcdef01)"))
+			Expect(d.OS).To(Equal("Linux - amd64"))
+			Expect(d.Distro).To(Equal("debian"))
+			Expect(d.OSVersion).To(BeEmpty())
+			Expect(d.MusicFS).To(Equal("ntfs"))
+			Expect(d.DataFS).To(Equal("unknown"))
+			Expect(d.PlayerTypes).To(Equal(map[string]uint64{"NavidromeUI": 2}))
+			Expect(d.Players).To(Equal(int64(2)))
+			Expect(d.FileSuffixes).To(Equal([]string{"flac", "mp3"}))
+			Expect(d.Features).To(Equal([]string{"Last.fm"}))
+			Expect(d.ConfigFlags).To(ContainElement("enableLastFM"))
+			Expect(d.Plugins).To(BeEmpty())
+			Expect(d.Plugins).ToNot(BeNil())
+		})
+
+		It("only sets the distro of non-containerized Linux", func() {
+			var data insights.Data
+			data.OS.Type = "linux"
+			data.OS.Containerized = true
+			data.OS.Distro = "alpine"
+			Expect(ReportDimensions(data).Distro).To(BeEmpty())
+		})
+	})
+
+	Describe("Diff", func() {
+		It("returns nothing for equal summaries", func() {
+			s := Summary{NumInstances: 2, Versions: map[string]uint64{"0.54.0": 2}, TrackStats: &Stats{Min: 1, Max: 3, Mean: 2}}
+			Expect(Diff(s, s)).To(BeEmpty())
+		})
+
+		It("treats missing and empty maps as equal", func() {
+			Expect(Diff(Summary{}, Summary{Versions: map[string]uint64{}})).To(BeEmpty())
+		})
+
+		It("lists the differing fields, map keys and stats", func() {
+			a := Summary{
+				NumInstances: 2,
+				Versions:     map[string]uint64{"0.54.0": 1, "0.53.0": 1},
+				OSVersions:   map[string]map[string]uint64{"Windows": {"Windows 11": 1}},
+				TrackStats:   &Stats{Min: 1, Max: 3, Mean: 2},
+			}
+			b := Summary{
+				NumInstances: 3,
+				Versions:     map[string]uint64{"0.54.0": 2, "0.55.0": 1},
+				OSVersions:   map[string]map[string]uint64{"Windows": {"Windows 11": 2}},
+				TrackStats:   &Stats{Min: 1, Max: 3, Mean: 2.5},
+				AlbumStats:   &Stats{Min: 1},
+			}
+			Expect(Diff(a, b)).To(Equal([]Difference{
+				{Field: "albumStats", B: Stats{Min: 1}},
+				{Field: "numInstances", A: int64(2), B: int64(3)},
+				{Field: "osVersions[Windows][Windows 11]", A: uint64(1), B: uint64(2)},
+				{Field: "trackStats.mean", A: 2.0, B: 2.5},
+				{Field: "versions[0.53.0]", A: uint64(1)},
+				{Field: "versions[0.54.0]", A: uint64(1), B: uint64(2)},
+				{Field: "versions[0.55.0]", B: uint64(1)},
+			}))
+		})
+
+		It("ignores float rounding differences", func() {
+			a := Summary{TrackStats: &Stats{StdDev: 0.1 + 0.2}}
+			b := Summary{TrackStats: &Stats{StdDev: 0.3}}
+			Expect(Diff(a, b)).To(BeEmpty())
+		})
+	})
+
+	Describe("WriteCSV", func() {
+		It("writes one row per day with a column per OS", func() {
+			summaries := []SummaryRecord{
+				{
+					Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+					Data: Summary{
+						NumInstances:   10,
+						NumActiveUsers: 20,
+						OS:             map[string]uint64{"Linux - amd64": 7, "macOS - arm64": 3},
+						TrackStats:     &Stats{Mean: 1500.5, Median: 1000, Max: 5000},
+					},
+				},
+				{
+					Time: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+					Data: Summary{
+						NumInstances: 12,
+						OS:           map[string]uint64{"Linux - amd64": 10, "Windows - amd64": 2},
+					},
+				},
+			}
+			var buf bytes.Buffer
+			Expect(WriteCSV(&buf, summaries)).To(Succeed())
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			Expect(lines).To(HaveLen(3))
+			Expect(lines[0]).To(HaveSuffix("os:Linux - amd64,os:Windows - amd64,os:macOS - arm64"))
+			Expect(lines[1]).To(HavePrefix("2025-01-01,10,20,0,0,1500.50,1000.00,5000,,,,,,,"))
+			Expect(lines[1]).To(HaveSuffix(",7,0,3"))
+			Expect(lines[2]).To(HaveSuffix(",10,2,0"))
+		})
+
+		It("writes only the header when there are no summaries", func() {
+			var buf bytes.Buffer
+			Expect(WriteCSV(&buf, nil)).To(Succeed())
+			Expect(strings.Count(buf.String(), "\n")).To(Equal(1))
+		})
+	})
+
+	Describe("MetricValue", func() {
+		s := Summary{
+			NumInstances: 10,
+			Versions:     map[string]uint64{"0.55.0 (abc)": 4, "0.55.0": 6},
+			OSVersions:   map[string]map[string]uint64{"macOS": {"macOS 14": 3}},
+			TrackStats:   &Stats{Mean: 1500.5},
+		}
+		value := func(metric string) any {
+			v, err := MetricValue(s, metric)
+			Expect(err).NotTo(HaveOccurred())
+			if v == nil {
+				return nil
+			}
+			return *v
+		}
+
+		It("resolves fields, stats and map keys containing dots", func() {
+			Expect(value("numInstances")).To(Equal(10.0))
+			Expect(value("trackStats.mean")).To(Equal(1500.5))
+			Expect(value("versions.0.55.0")).To(Equal(6.0))
+			Expect(value("osVersions.macOS.macOS 14")).To(Equal(3.0))
+		})
+
+		It("returns zero for missing map keys and nil for missing stats", func() {
+			Expect(value("versions.0.40.0")).To(Equal(0.0))
+			Expect(value("countries.BR")).To(Equal(0.0))
+			Expect(value("osVersions.Windows.Windows 11")).To(Equal(0.0))
+			Expect(value("albumStats.median")).To(BeNil())
+		})
+
+		DescribeTable("rejects paths not resolving to a number",
+			func(metric string) {
+				_, err := MetricValue(s, metric)
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("empty", ""),
+			Entry("unknown field", "unknown"),
+			Entry("unknown stat", "albumStats.average"),
+			Entry("map", "versions"),
+			Entry("struct", "trackStats"),
+			Entry("bool", "finalized"),
+			Entry("path past a number", "numInstances.total"),
+		)
+	})
+
+	Describe("InSample", func() {
+		ids := make([]string, 10000)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("instance-%d", i)
+		}
+		count := func(rate float64) int {
+			n := 0
+			for _, id := range ids {
+				if InSample(id, rate) {
+					n++
+				}
+			}
+			return n
+		}
+
+		It("keeps about the given fraction of the instances", func() {
+			Expect(count(0.1)).To(BeNumerically("~", 1000, 100))
+			Expect(count(0.5)).To(BeNumerically("~", 5000, 250))
+		})
+		It("keeps all instances at rate 1 and none at rate 0", func() {
+			Expect(count(1)).To(Equal(len(ids)))
+			Expect(count(0)).To(BeZero())
+		})
+		It("includes the sample of a lower rate in higher ones", func() {
+			for _, id := range ids {
+				if InSample(id, 0.1) {
+					Expect(InSample(id, 0.3)).To(BeTrue())
+				}
+			}
+		})
+	})
+
+	Describe("Summary.Scaled", func() {
+		It("scales the counts of sampled days, keeping the unsampled ones", func() {
+			s := Summary{
+				SamplingRate: 0.1, NumInstances: 100, TotalTracks: 5000, NewInstances: 7, WeeklyInstances: 900,
+				Versions:   map[string]uint64{"0.54.0": 60, "0.53.0": 40},
+				OSVersions: map[string]map[string]uint64{"Windows": {"11": 3}},
+				TrackStats: &Stats{Mean: 50},
+			}
+			scaled := s.Scaled()
+			Expect(scaled.Estimated).To(BeTrue())
+			Expect(scaled.NumInstances).To(Equal(int64(1000)))
+			Expect(scaled.TotalTracks).To(Equal(int64(50000)))
+			Expect(scaled.Versions).To(Equal(map[string]uint64{"0.54.0": 600, "0.53.0": 400}))
+			Expect(scaled.OSVersions).To(Equal(map[string]map[string]uint64{"Windows": {"11": 30}}))
+			Expect(scaled.NewInstances).To(Equal(int64(7)))
+			Expect(scaled.WeeklyInstances).To(Equal(int64(900)))
+			Expect(scaled.TrackStats.Mean).To(Equal(50.0))
+			Expect(scaled.Players).To(BeNil())
+
+			// The original is not modified, and scaling twice is a no-op
+			Expect(s.Versions["0.54.0"]).To(Equal(uint64(60)))
+			Expect(scaled.Scaled()).To(Equal(scaled))
+		})
+		It("returns days that were not sampled unchanged", func() {
+			s := Summary{NumInstances: 100, Versions: map[string]uint64{"0.54.0": 100}}
+			Expect(s.Scaled()).To(Equal(s))
+		})
+	})
 })
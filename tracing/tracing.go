@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/navidrome/insights/consts"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Provider exports OpenTelemetry spans over OTLP/HTTP to the collector configured with the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT (or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) env var. The other standard
+// OTEL_* variables (headers, sampler, service name, ...) are honored too.
+// Until a Provider is created spans are no-ops, so instrumented code costs almost nothing when tracing
+// is not configured. A nil Provider is valid and does nothing.
+type Provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// FromEnv creates a Provider and registers it globally, or returns nil if no OTLP endpoint is configured
+func FromEnv(ctx context.Context) (*Provider, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("configuring OTLP exporter: %w", err)
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the default service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", consts.TracingServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("configuring tracing resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return &Provider{tp: tp}, nil
+}
+
+// Shutdown exports the pending spans, waiting up to the given timeout
+func (p *Provider) Shutdown(timeout time.Duration) {
+	if p == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := p.tp.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down tracing: %v", err)
+	}
+}
+
+// Tracer returns the tracer used for the spans created by the insights code
+func Tracer() trace.Tracer {
+	return otel.Tracer(consts.TracingServiceName)
+}
@@ -0,0 +1,7 @@
+// Package web embeds the public dashboard, so production builds can serve it (see SERVE_DASHBOARD)
+package web
+
+import "embed"
+
+//go:embed index.html
+var FS embed.FS